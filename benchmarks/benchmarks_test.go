@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ardanlabs/python-go/benchmarks/internal/bench"
+)
+
+func TestGoDetect(t *testing.T) {
+	require := require.New(t)
+
+	data := bench.GenData(rand.New(rand.NewSource(7)), 10_000)
+	indices := goDetect(data)
+	require.NotEmpty(indices)
+	for _, i := range indices {
+		require.Greater(data[i], 80.0)
+	}
+}
+
+func TestCtypes(t *testing.T) {
+	if exec.Command("python3", "-c", "import numpy").Run() != nil {
+		t.Skip("python3 with numpy not found")
+	}
+	require := require.New(t)
+
+	lib := filepath.Join(t.TempDir(), "_outliers.so")
+	out, err := exec.Command("go", "build", "-buildmode=c-shared", "-o", lib, "./ctypes").CombinedOutput()
+	require.NoError(err, string(out))
+
+	data := bench.GenData(rand.New(rand.NewSource(7)), 10_000)
+	indices, durations, err := ctypesRunner(lib)(context.Background(), data, 2)
+	require.NoError(err)
+	require.Len(durations, 2)
+	require.Equal(goDetect(data), indices)
+}
@@ -0,0 +1,217 @@
+// Command benchcompare runs the same outliers detection workload through
+// embedded Python (py-in-mem), the gRPC server and a subprocess bridge
+// (pyproc) and writes a JSON report with durations, allocations and payload
+// sizes per mechanism and data size.
+//
+//	$ python ../grpc/py/server.py &
+//	$ go run -tags embedpy ./cmd/benchcompare -o report.json
+//
+// Allocations are counted in the Go process only. Payload sizes are the
+// bytes serialized per call in each direction: the protobuf messages for
+// gRPC, the msgpack frames for the subprocess bridge and the copied indices
+// for embedded Python, which shares the data memory.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ardanlabs/python-go/benchmarks/internal/bench"
+	"github.com/ardanlabs/python-go/grpc/pb"
+	"github.com/ardanlabs/python-go/pybridge"
+	"github.com/ardanlabs/python-go/pyproc"
+)
+
+const detectFunc = "outliers.detect"
+
+// mechanism is a way to call outliers.detect
+type mechanism struct {
+	name    string
+	backend pybridge.Backend
+	err     error // Error creating backend
+	// payload returns the request and response sizes for data and indices
+	payload func(data []float64, indices []int) (int, int, error)
+}
+
+func main() {
+	sizesFlag := flag.String("sizes", "1000,10000,100000", "comma separated data sizes")
+	count := flag.Int("count", 100, "number of calls per mechanism and size")
+	only := flag.String("only", "", "comma separated mechanisms to run (default all)")
+	root := flag.String("root", "..", "repository root directory")
+	grpcAddr := flag.String("grpc", "localhost:9999", "gRPC server address")
+	out := flag.String("o", "-", "JSON report file (- for stdout)")
+	flag.Parse()
+
+	sizes, err := bench.ParseSizes(*sizesFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *count <= 0 {
+		log.Fatalf("bad count: %d", *count)
+	}
+
+	mechs := newMechanisms(*root, *grpcAddr)
+	defer func() {
+		for _, m := range mechs {
+			if m.backend != nil {
+				m.backend.Close()
+			}
+		}
+	}()
+	if *only != "" {
+		mechs = filterMechanisms(mechs, strings.Split(*only, ","))
+	}
+
+	report := bench.NewReport(*count)
+	rnd := rand.New(rand.NewSource(353))
+	for _, size := range sizes {
+		data := bench.GenData(rnd, size)
+		for _, m := range mechs {
+			res := run(m, data, *count)
+			log.Printf("%-10s %9d %s", m.name, size, res.Summary())
+			report.Results = append(report.Results, res)
+		}
+	}
+
+	if err := report.WriteJSON(*out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newMechanisms(root, grpcAddr string) []mechanism {
+	pyDir := filepath.Join(root, "py-in-mem") // outliers.py
+	configs := []struct {
+		name    string
+		cfg     pybridge.Config
+		payload func([]float64, []int) (int, int, error)
+	}{
+		{"embedded", pybridge.Config{Backend: "embedded"}, embeddedPayload},
+		{"grpc", pybridge.Config{Backend: "grpc", Addr: grpcAddr}, grpcPayload},
+		{"subprocess", pybridge.Config{
+			Backend: "subprocess",
+			Pool:    pyproc.Config{Size: 1, Dir: pyDir},
+		}, subprocessPayload},
+	}
+
+	mechs := make([]mechanism, len(configs))
+	for i, c := range configs {
+		b, err := pybridge.New(c.cfg)
+		mechs[i] = mechanism{name: c.name, backend: b, err: err, payload: c.payload}
+	}
+	return mechs
+}
+
+func filterMechanisms(mechs []mechanism, names []string) []mechanism {
+	var out []mechanism
+	for _, m := range mechs {
+		for _, name := range names {
+			if m.name == name {
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+// run calls m count times on data
+func run(m mechanism, data []float64, count int) bench.Result {
+	res := bench.Result{Mechanism: m.name, Size: len(data)}
+	if m.err != nil {
+		res.Error = m.err.Error()
+		return res
+	}
+
+	ctx := context.Background()
+	// Warm up (load the function, connect) and check the mechanism works
+	indices, err := pybridge.Detect(ctx, m.backend, detectFunc, data)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Outliers = len(indices)
+
+	res.RequestBytes, res.ResponseBytes, err = m.payload(data, indices)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	durations := make([]time.Duration, count)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := range durations {
+		start := time.Now()
+		if _, err := pybridge.Detect(ctx, m.backend, detectFunc, data); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		durations[i] = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+
+	res.SetStats(durations)
+	res.AllocsPerOp = int64(after.Mallocs-before.Mallocs) / int64(count)
+	res.BytesPerOp = int64(after.TotalAlloc-before.TotalAlloc) / int64(count)
+	return res
+}
+
+// embeddedPayload: data memory is shared with Python, indices are copied as
+// int64.
+func embeddedPayload(_ []float64, indices []int) (int, int, error) {
+	return 0, len(indices) * 8, nil
+}
+
+// grpcPayload returns the protobuf message sizes, same as pybridge.GRPC
+// sends.
+func grpcPayload(data []float64, indices []int) (int, int, error) {
+	now := timestamppb.Now()
+	req := pb.OutliersRequest{Metrics: make([]*pb.Metric, len(data))}
+	for i, v := range data {
+		req.Metrics[i] = &pb.Metric{Time: now, Value: v}
+	}
+
+	resp := pb.OutliersResponse{Indices: make([]int32, len(indices))}
+	for i, v := range indices {
+		resp.Indices[i] = int32(v)
+	}
+
+	return proto.Size(&req), proto.Size(&resp), nil
+}
+
+// subprocessPayload returns the msgpack frame sizes (with the 4 bytes size
+// header), the messages mirror pyproc's request and response.
+func subprocessPayload(data []float64, indices []int) (int, int, error) {
+	module, fn, _ := strings.Cut(detectFunc, ".")
+	req := struct {
+		ID     uint64        `msgpack:"id"`
+		Op     string        `msgpack:"op"`
+		Module string        `msgpack:"module"`
+		Func   string        `msgpack:"func"`
+		Args   []interface{} `msgpack:"args"`
+	}{1, "call", module, fn, []interface{}{pyproc.Float64s(data)}}
+	resp := struct {
+		ID     uint64      `msgpack:"id"`
+		Result interface{} `msgpack:"result"`
+		Error  string      `msgpack:"error"`
+	}{1, indices, ""}
+
+	reqData, err := msgpack.Marshal(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	respData, err := msgpack.Marshal(resp)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(reqData) + 4, len(respData) + 4, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayload(t *testing.T) {
+	require := require.New(t)
+
+	data := make([]float64, 1000)
+	indices := []int{7, 113, 835}
+
+	req, resp, err := embeddedPayload(data, indices)
+	require.NoError(err)
+	require.Equal(0, req, "embedded request")
+	require.Equal(24, resp, "embedded response")
+
+	// 8 bytes per value plus framing
+	req, resp, err = subprocessPayload(data, indices)
+	require.NoError(err)
+	require.Greater(req, 8000, "subprocess request")
+	require.Less(req, 8100, "subprocess request")
+	require.Greater(resp, 0, "subprocess response")
+
+	// Each metric has a timestamp
+	req, resp, err = grpcPayload(data, indices)
+	require.NoError(err)
+	require.Greater(req, 9000, "grpc request")
+	require.Greater(resp, 0, "grpc response")
+}
+
+func TestRunError(t *testing.T) {
+	m := mechanism{name: "grpc", err: fmt.Errorf("not running")}
+	res := run(m, []float64{1, 2, 3}, 1)
+	require.Equal(t, "not running", res.Error)
+	require.Contains(t, res.Summary(), "error")
+}
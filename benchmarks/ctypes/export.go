@@ -0,0 +1,48 @@
+// Go outliers detection as a shared library for Python ctypes, used by
+// py/ctypes_bench.py.
+//
+//	$ go build -buildmode=c-shared -o _outliers.so ./ctypes
+package main
+
+import "C"
+
+import (
+	"math"
+	"unsafe"
+)
+
+// detect writes indices of outliers in values to out (which must have room
+// for n values) and returns the number of outliers.
+//
+//export detect
+func detect(values *C.double, n C.long, out *C.long) C.long {
+	if n == 0 {
+		return 0
+	}
+
+	data := unsafe.Slice((*float64)(unsafe.Pointer(values)), int(n))
+	indices := unsafe.Slice((*C.long)(unsafe.Pointer(out)), int(n))
+
+	var sum float64
+	for _, v := range data {
+		sum += v
+	}
+	mean := sum / float64(len(data))
+
+	var sq float64
+	for _, v := range data {
+		sq += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(sq / float64(len(data)))
+
+	count := 0
+	for i, v := range data {
+		if math.Abs(v-mean) > 2*std {
+			indices[count] = C.long(i)
+			count++
+		}
+	}
+	return C.long(count)
+}
+
+func main() {}
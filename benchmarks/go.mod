@@ -0,0 +1,31 @@
+module github.com/ardanlabs/python-go/benchmarks
+
+go 1.21.3
+
+replace (
+	github.com/ardanlabs/python-go => ../
+	github.com/ardanlabs/python-go/pybridge => ../pybridge
+	py-in-mem => ../py-in-mem
+)
+
+require (
+	github.com/ardanlabs/python-go v0.0.0
+	github.com/ardanlabs/python-go/pybridge v0.0.0
+	github.com/stretchr/testify v1.8.4
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	google.golang.org/protobuf v1.30.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	google.golang.org/grpc v1.56.3 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	py-in-mem v0.0.0 // indirect
+)
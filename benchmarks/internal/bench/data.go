@@ -0,0 +1,34 @@
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// GenData returns size values with a few outliers.
+func GenData(rnd *rand.Rand, size int) []float64 {
+	data := make([]float64, size)
+	for i := range data {
+		// normally we're below 40% CPU utilization
+		data[i] = rnd.Float64() * 40
+	}
+	for i := 0; i < size/1000+1; i++ {
+		data[rnd.Intn(size)] = 90 + rnd.Float64()*10
+	}
+	return data
+}
+
+// ParseSizes parses a comma separated list of data sizes.
+func ParseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("bad size: %q", f)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
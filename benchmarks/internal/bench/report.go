@@ -0,0 +1,173 @@
+// Package bench has the report and data generation shared by the benchmark
+// commands.
+package bench
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotBuilt is the error of mechanisms that are not built.
+var ErrNotBuilt = errors.New("not built")
+
+// Report is the benchmark report.
+type Report struct {
+	Time    time.Time `json:"time"`
+	GOOS    string    `json:"goos"`
+	GOARCH  string    `json:"goarch"`
+	CPUs    int       `json:"cpus"`
+	Go      string    `json:"go"`
+	Python  string    `json:"python"`
+	Count   int       `json:"count"`
+	Results []Result  `json:"results"`
+}
+
+// Result is the result of a single mechanism on a single data size.
+// Durations are in nanoseconds. Allocations and payload sizes are set by
+// benchcompare only.
+type Result struct {
+	Mechanism string        `json:"mechanism"`
+	Size      int           `json:"size"`
+	Runs      int           `json:"runs"`
+	Mean      time.Duration `json:"mean_ns"`
+	Min       time.Duration `json:"min_ns"`
+	Median    time.Duration `json:"median_ns"`
+	Max       time.Duration `json:"max_ns"`
+
+	AllocsPerOp   int64 `json:"allocs_per_op,omitempty"`
+	BytesPerOp    int64 `json:"alloc_bytes_per_op,omitempty"`
+	RequestBytes  int   `json:"request_bytes,omitempty"`
+	ResponseBytes int   `json:"response_bytes,omitempty"`
+	Outliers      int   `json:"outliers,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// NewReport returns an empty report for count runs per result.
+func NewReport(count int) *Report {
+	r := Report{
+		Time:   time.Now().UTC(),
+		GOOS:   runtime.GOOS,
+		GOARCH: runtime.GOARCH,
+		CPUs:   runtime.NumCPU(),
+		Go:     runtime.Version(),
+		Count:  count,
+	}
+	if out, err := exec.Command("python3", "--version").Output(); err == nil {
+		r.Python = strings.TrimSpace(string(out))
+	}
+	return &r
+}
+
+// SetStats sets the run statistics from durations.
+func (r *Result) SetStats(durations []time.Duration) {
+	if len(durations) == 0 {
+		return
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	r.Runs = len(sorted)
+	r.Mean = total / time.Duration(len(sorted))
+	r.Min = sorted[0]
+	r.Median = sorted[len(sorted)/2]
+	r.Max = sorted[len(sorted)-1]
+}
+
+// Summary returns a one line summary of r for logs.
+func (r *Result) Summary() string {
+	if r.Error != "" {
+		return "error: " + r.Error
+	}
+	s := fmt.Sprintf("mean=%v min=%v max=%v", r.Mean, r.Min, r.Max)
+	if r.AllocsPerOp > 0 || r.RequestBytes > 0 || r.ResponseBytes > 0 {
+		s += fmt.Sprintf(" allocs/op=%d req=%dB resp=%dB", r.AllocsPerOp, r.RequestBytes, r.ResponseBytes)
+	}
+	return s
+}
+
+// WriteJSON writes the report to path, "-" for stdout.
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// WriteMarkdown writes a table of mean durations, mechanisms as rows and data
+// sizes as columns.
+func (r *Report) WriteMarkdown(path string) error {
+	var (
+		mechs []string
+		sizes []int
+		cells = make(map[string]map[int]string)
+	)
+	for _, res := range r.Results {
+		if _, ok := cells[res.Mechanism]; !ok {
+			mechs = append(mechs, res.Mechanism)
+			cells[res.Mechanism] = make(map[int]string)
+		}
+		if !containsInt(sizes, res.Size) {
+			sizes = append(sizes, res.Size)
+		}
+		cell := res.Mean.Round(time.Microsecond).String()
+		if res.Error != "" {
+			cell = "error"
+			if res.Error == ErrNotBuilt.Error() {
+				cell = "not built"
+			}
+		}
+		cells[res.Mechanism][res.Size] = cell
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Outliers Detection Benchmark\n\n")
+	fmt.Fprintf(&b, "%s/%s, %d CPUs, %s, %s, mean of %d runs.\n\n", r.GOOS, r.GOARCH, r.CPUs, r.Go, r.Python, r.Count)
+
+	fmt.Fprint(&b, "| mechanism |")
+	for _, size := range sizes {
+		fmt.Fprintf(&b, " %d |", size)
+	}
+	fmt.Fprint(&b, "\n|---|")
+	for range sizes {
+		fmt.Fprint(&b, "---:|")
+	}
+	fmt.Fprintln(&b)
+	for _, mech := range mechs {
+		fmt.Fprintf(&b, "| %s |", mech)
+		for _, size := range sizes {
+			fmt.Fprintf(&b, " %s |", cells[mech][size])
+		}
+		fmt.Fprintln(&b)
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func containsInt(values []int, n int) bool {
+	for _, v := range values {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
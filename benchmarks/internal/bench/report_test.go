@@ -0,0 +1,47 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	require := require.New(t)
+
+	r := NewReport(3)
+	res := Result{Mechanism: "go", Size: 1000}
+	res.SetStats([]time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond})
+	require.Equal(2*time.Millisecond, res.Mean)
+	require.Equal(time.Millisecond, res.Min)
+	require.Equal(2*time.Millisecond, res.Median)
+	require.Equal(3*time.Millisecond, res.Max)
+	require.NotContains(res.Summary(), "allocs/op")
+	r.Results = append(r.Results, res)
+	r.Results = append(r.Results, Result{Mechanism: "cgo", Size: 1000, Error: ErrNotBuilt.Error()})
+
+	dir := t.TempDir()
+	require.NoError(r.WriteJSON(filepath.Join(dir, "report.json")))
+
+	md := filepath.Join(dir, "report.md")
+	require.NoError(r.WriteMarkdown(md))
+	data, err := os.ReadFile(md)
+	require.NoError(err)
+	require.Contains(string(data), "| mechanism | 1000 |\n|---|---:|\n| go | 2ms |\n| cgo | not built |\n")
+}
+
+func TestParseSizes(t *testing.T) {
+	require := require.New(t)
+
+	sizes, err := ParseSizes("10, 200,3000")
+	require.NoError(err)
+	require.Equal([]int{10, 200, 3000}, sizes)
+
+	for _, s := range []string{"", "10,", "-1", "x"} {
+		_, err := ParseSizes(s)
+		require.Error(err, s)
+	}
+}
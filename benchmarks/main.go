@@ -0,0 +1,113 @@
+// Run the same outliers detection workload through every Go/Python interop
+// mechanism in the repository and write a comparative report.
+//
+//	$ make run
+//
+// Mechanisms that are not built or not running (e.g. the gRPC server) are
+// reported as errors, the other mechanisms still run. See README.md.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/python-go/benchmarks/internal/bench"
+)
+
+// runner runs outliers detection on data count times and returns the
+// indices and the duration of each run.
+type runner struct {
+	name string
+	run  func(ctx context.Context, data []float64, count int) ([]int, []time.Duration, error)
+}
+
+// timed returns a runner function timing detect calls from Go.
+func timed(detect func(context.Context, []float64) ([]int, error)) func(context.Context, []float64, int) ([]int, []time.Duration, error) {
+	return func(ctx context.Context, data []float64, count int) ([]int, []time.Duration, error) {
+		var indices []int
+		durations := make([]time.Duration, count)
+		for i := range durations {
+			start := time.Now()
+			out, err := detect(ctx, data)
+			if err != nil {
+				return nil, nil, err
+			}
+			durations[i] = time.Since(start)
+			indices = out
+		}
+		return indices, durations, nil
+	}
+}
+
+func main() {
+	var cfg config
+	sizesFlag := flag.String("sizes", "1000,10000,100000,1000000", "comma separated data sizes")
+	count := flag.Int("count", 20, "number of runs per mechanism and size")
+	only := flag.String("only", "", "comma separated mechanisms to run (default all)")
+	jsonOut := flag.String("json", "report.json", "JSON report file")
+	mdOut := flag.String("md", "report.md", "markdown report file")
+	flag.StringVar(&cfg.root, "root", "..", "repository root directory")
+	flag.StringVar(&cfg.grpcAddr, "grpc", "localhost:9999", "gRPC server address")
+	flag.StringVar(&cfg.httpAddr, "http", "localhost:8081", "HTTP server address")
+	flag.StringVar(&cfg.lib, "lib", "_outliers.so", "ctypes shared library")
+	flag.Parse()
+
+	sizes, err := bench.ParseSizes(*sizesFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runners, closeAll := newRunners(cfg)
+	defer closeAll()
+	if *only != "" {
+		runners = filterRunners(runners, strings.Split(*only, ","))
+	}
+
+	ctx := context.Background()
+	rnd := rand.New(rand.NewSource(353))
+	report := bench.NewReport(*count)
+	for _, size := range sizes {
+		data := bench.GenData(rnd, size)
+		want := goDetect(data)
+		for _, r := range runners {
+			res := bench.Result{Mechanism: r.name, Size: size}
+			indices, durations, err := r.run(ctx, data, *count)
+			switch {
+			case err != nil:
+				res.Error = err.Error()
+			case !equalInts(indices, want):
+				res.Error = fmt.Sprintf("wrong result: %d outliers, expected %d", len(indices), len(want))
+			default:
+				res.SetStats(durations)
+			}
+			log.Printf("%-10s %9d %s", r.name, size, res.Summary())
+			report.Results = append(report.Results, res)
+		}
+	}
+
+	if err := report.WriteJSON(*jsonOut); err != nil {
+		log.Fatal(err)
+	}
+	if err := report.WriteMarkdown(*mdOut); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "reports written to %s and %s\n", *jsonOut, *mdOut)
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+"""Time Go outliers detection called from Python via ctypes.
+
+Reads float64 values from a file and prints JSON with the outliers indices
+and the duration of each call in nanoseconds.
+"""
+import ctypes
+import json
+from time import perf_counter_ns
+
+import numpy as np
+
+
+def load(lib):
+    """Load detect from lib and set its signature"""
+    so = ctypes.cdll.LoadLibrary(lib)
+    detect = so.detect
+    detect.argtypes = [
+        ctypes.POINTER(ctypes.c_double),
+        ctypes.c_long,
+        ctypes.POINTER(ctypes.c_long),
+    ]
+    detect.restype = ctypes.c_long
+    return detect
+
+
+def run(detect, data):
+    """Return outlier indices in data"""
+    data = np.ascontiguousarray(data, dtype='float64')
+    out = np.empty(len(data), dtype=ctypes.c_long)
+    n = detect(
+        data.ctypes.data_as(ctypes.POINTER(ctypes.c_double)),
+        len(data),
+        out.ctypes.data_as(ctypes.POINTER(ctypes.c_long)),
+    )
+    return out[:n]
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('--lib', default='_outliers.so', help='shared library')
+    parser.add_argument('--count', type=int, default=20, help='runs')
+    parser.add_argument('data', help='float64 data file')
+    args = parser.parse_args()
+
+    detect = load(args.lib)
+    data = np.fromfile(args.data, dtype='<f8')
+    durations = []
+    for _ in range(args.count):
+        start = perf_counter_ns()
+        indices = run(detect, data)
+        durations.append(perf_counter_ns() - start)
+
+    reply = {'indices': indices.tolist(), 'durations_ns': durations}
+    print(json.dumps(reply))
@@ -0,0 +1,60 @@
+"""HTTP+JSON outliers server
+
+POST /detect with {"values": [float, ...]}, returns {"indices": [int, ...]}
+"""
+import json
+import logging
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+import numpy as np
+
+
+def find_outliers(data: np.ndarray):
+    """Return indices where values more than 2 standard deviations from mean"""
+    out = np.where(np.abs(data - data.mean()) > 2 * data.std())
+    # np.where returns a tuple for each dimension, we want the 1st element
+    return out[0]
+
+
+class Handler(BaseHTTPRequestHandler):
+    protocol_version = 'HTTP/1.1'  # keep-alive
+
+    def do_POST(self):
+        if self.path != '/detect':
+            self.send_error(404)
+            return
+
+        size = int(self.headers.get('Content-Length', 0))
+        try:
+            req = json.loads(self.rfile.read(size))
+            data = np.asarray(req['values'], dtype='float64')
+        except (ValueError, KeyError) as err:
+            self.send_error(400, str(err))
+            return
+
+        indices = find_outliers(data)
+        body = json.dumps({'indices': indices.tolist()}).encode('utf-8')
+        self.send_response(200)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def log_message(self, format, *args):
+        logging.debug(format, *args)
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('--port', type=int, default=8081, help='port')
+    args = parser.parse_args()
+
+    logging.basicConfig(
+        level=logging.INFO,
+        format='%(asctime)s - %(levelname)s - %(message)s',
+    )
+    server = ThreadingHTTPServer(('', args.port), Handler)
+    logging.info('server ready on port %r', args.port)
+    server.serve_forever()
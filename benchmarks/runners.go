@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/python-go/benchmarks/internal/bench"
+	"github.com/ardanlabs/python-go/pybridge"
+	"github.com/ardanlabs/python-go/pyproc"
+)
+
+// config is runners configuration.
+type config struct {
+	root     string // repository root
+	grpcAddr string
+	httpAddr string
+	lib      string // ctypes shared library
+}
+
+const detectFunc = "outliers.detect"
+
+// newRunners returns all the runners and a function to release them.
+func newRunners(cfg config) ([]runner, func()) {
+	pyDir := filepath.Join(cfg.root, "py-in-mem") // outliers.py
+	var backends []pybridge.Backend
+
+	bridge := func(name string, bcfg pybridge.Config) runner {
+		b, err := pybridge.New(bcfg)
+		if err != nil {
+			return errRunner(name, err)
+		}
+		backends = append(backends, b)
+		detect := func(ctx context.Context, data []float64) ([]int, error) {
+			return pybridge.Detect(ctx, b, detectFunc, data)
+		}
+		return runner{name, timed(detect)}
+	}
+
+	runners := []runner{
+		{"go", timed(func(_ context.Context, data []float64) ([]int, error) {
+			return goDetect(data), nil
+		})},
+		bridge("cgo", pybridge.Config{Backend: "embedded"}),
+		bridge("subprocess", pybridge.Config{
+			Backend: "subprocess",
+			Pool:    pyproc.Config{Size: 1, Dir: pyDir},
+		}),
+		bridge("grpc", pybridge.Config{Backend: "grpc", Addr: cfg.grpcAddr}),
+		{"http", timed(httpDetect("http://" + cfg.httpAddr + "/detect"))},
+		{"ctypes", ctypesRunner(cfg.lib)},
+	}
+
+	closeAll := func() {
+		for _, b := range backends {
+			b.Close()
+		}
+	}
+	return runners, closeAll
+}
+
+func filterRunners(runners []runner, names []string) []runner {
+	var out []runner
+	for _, r := range runners {
+		for _, name := range names {
+			if r.name == name {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+// errRunner returns a runner that always fails with err.
+func errRunner(name string, err error) runner {
+	if errors.Is(err, pybridge.ErrNotBuilt) {
+		err = bench.ErrNotBuilt
+	}
+	run := func(context.Context, []float64, int) ([]int, []time.Duration, error) {
+		return nil, nil, err
+	}
+	return runner{name, run}
+}
+
+// goDetect is the reference implementation, same as outliers.py.
+func goDetect(data []float64) []int {
+	var sum float64
+	for _, v := range data {
+		sum += v
+	}
+	mean := sum / float64(len(data))
+
+	var sq float64
+	for _, v := range data {
+		sq += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(sq / float64(len(data)))
+
+	indices := []int{}
+	for i, v := range data {
+		if math.Abs(v-mean) > 2*std {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// httpDetect returns a function calling py/http_server.py.
+func httpDetect(url string) func(context.Context, []float64) ([]int, error) {
+	client := &http.Client{}
+	return func(ctx context.Context, data []float64) ([]int, error) {
+		body, err := json.Marshal(map[string][]float64{"values": data})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: bad status - %s", url, resp.Status)
+		}
+
+		var reply struct {
+			Indices []int `json:"indices"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+			return nil, err
+		}
+		return reply.Indices, nil
+	}
+}
+
+// ctypesRunner runs py/ctypes_bench.py, where Python calls Go outliers
+// detection in lib via ctypes. Timing is done in Python.
+func ctypesRunner(lib string) func(context.Context, []float64, int) ([]int, []time.Duration, error) {
+	return func(ctx context.Context, data []float64, count int) ([]int, []time.Duration, error) {
+		if _, err := os.Stat(lib); err != nil {
+			return nil, nil, bench.ErrNotBuilt
+		}
+
+		file, err := os.CreateTemp("", "outliers-*.f8")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.Remove(file.Name())
+
+		err = binary.Write(file, binary.LittleEndian, data)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cmd := exec.CommandContext(
+			ctx, "python3", filepath.Join("py", "ctypes_bench.py"),
+			"--lib", lib, "--count", strconv.Itoa(count), file.Name(),
+		)
+		out, err := cmd.Output()
+		if err != nil {
+			var eerr *exec.ExitError
+			if errors.As(err, &eerr) {
+				return nil, nil, fmt.Errorf("ctypes_bench.py: %s", lastLine(eerr.Stderr))
+			}
+			return nil, nil, err
+		}
+
+		var reply struct {
+			Indices   []int   `json:"indices"`
+			Durations []int64 `json:"durations_ns"`
+		}
+		if err := json.Unmarshal(out, &reply); err != nil {
+			return nil, nil, fmt.Errorf("ctypes_bench.py: bad output: %w", err)
+		}
+
+		durations := make([]time.Duration, len(reply.Durations))
+		for i, d := range reply.Durations {
+			durations[i] = time.Duration(d)
+		}
+		return reply.Indices, durations, nil
+	}
+}
+
+// lastLine returns the last line of out, the exception in a Python traceback.
+func lastLine(out []byte) string {
+	out = bytes.TrimSpace(out)
+	if i := bytes.LastIndexByte(out, '\n'); i >= 0 {
+		out = out[i+1:]
+	}
+	return string(out)
+}
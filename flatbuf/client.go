@@ -0,0 +1,127 @@
+package flatbuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/ardanlabs/python-go/flatbuf/fb"
+)
+
+// Metrics are metric values, Times and Values must be the same length.
+type Metrics struct {
+	Name   string
+	Times  []time.Time
+	Values []float64
+}
+
+// Client is an outliers detection client, it's safe to use from multiple
+// goroutines but requests on the same client are sent one at a time.
+type Client struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	builder *flatbuffers.Builder
+}
+
+// Dial returns a client connected to addr (e.g. "localhost:9997").
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Client{
+		conn:    conn,
+		builder: flatbuffers.NewBuilder(1024),
+	}
+	return &c, nil
+}
+
+// Detect returns indices of outliers in m.Values.
+func (c *Client) Detect(ctx context.Context, m Metrics) ([]int, error) {
+	if len(m.Times) != len(m.Values) {
+		return nil, fmt.Errorf("times and values length mismatch (%d != %d)", len(m.Times), len(m.Values))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// Unblock reads & writes when ctx is canceled before the deadline.
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	buildRequest(c.builder, m)
+	if err := writeFrame(c.conn, c.builder); err != nil {
+		return nil, c.ioError(ctx, err)
+	}
+
+	frame, err := readFrame(c.conn)
+	if err != nil {
+		return nil, c.ioError(ctx, err)
+	}
+
+	resp := fb.GetSizePrefixedRootAsOutliersResponse(frame, 0)
+	if msg := resp.Error(); len(msg) > 0 {
+		return nil, errors.New(string(msg))
+	}
+
+	indices := make([]int, resp.IndicesLength())
+	for i := range indices {
+		indices[i] = int(resp.Indices(i))
+	}
+	return indices, nil
+}
+
+// ioError closes the connection after a failed request, it's in an unknown
+// state and can't be used for other requests.
+func (c *Client) ioError(ctx context.Context, err error) error {
+	c.conn.Close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// buildRequest builds a size prefixed OutliersRequest from m in b.
+func buildRequest(b *flatbuffers.Builder, m Metrics) {
+	b.Reset()
+
+	// Vectors and strings must be created before the table that holds them.
+	name := b.CreateString(m.Name)
+
+	fb.OutliersRequestStartTimesVector(b, len(m.Times))
+	for i := len(m.Times) - 1; i >= 0; i-- {
+		b.PrependInt64(m.Times[i].UnixNano())
+	}
+	times := b.EndVector(len(m.Times))
+
+	fb.OutliersRequestStartValuesVector(b, len(m.Values))
+	for i := len(m.Values) - 1; i >= 0; i-- {
+		b.PrependFloat64(m.Values[i])
+	}
+	values := b.EndVector(len(m.Values))
+
+	fb.OutliersRequestStart(b)
+	fb.OutliersRequestAddName(b, name)
+	fb.OutliersRequestAddTimes(b, times)
+	fb.OutliersRequestAddValues(b, values)
+	fb.FinishSizePrefixedOutliersRequestBuffer(b, fb.OutliersRequestEnd(b))
+}
@@ -0,0 +1,55 @@
+// Command client sends dummy metrics to the FlatBuffers outliers server.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/ardanlabs/python-go/flatbuf"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9997", "server address")
+	size := flag.Int("size", 1000, "number of metrics")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := flatbuf.Dial(ctx, *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	indices, err := client.Detect(ctx, dummyData(*size))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("outliers at: %v", indices)
+}
+
+func dummyData(size int) flatbuf.Metrics {
+	m := flatbuf.Metrics{
+		Name:   "CPU",
+		Times:  make([]time.Time, size),
+		Values: make([]float64, size),
+	}
+	t := time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+	for i := 0; i < size; i++ {
+		m.Times[i] = t
+		// normally we're below 40% CPU utilization
+		m.Values[i] = rand.Float64() * 40
+		t = t.Add(time.Second)
+	}
+	// Create some outliers
+	for _, i := range []int{7, 113, 835} {
+		if i < size {
+			m.Values[i] = 90 + rand.Float64()*10
+		}
+	}
+	return m
+}
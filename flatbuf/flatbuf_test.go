@@ -0,0 +1,203 @@
+package flatbuf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ardanlabs/python-go/flatbuf/fb"
+)
+
+func testMetrics(size int) Metrics {
+	m := Metrics{
+		Name:   "CPU",
+		Times:  make([]time.Time, size),
+		Values: make([]float64, size),
+	}
+	t := time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+	for i := range m.Values {
+		m.Times[i] = t.Add(time.Duration(i) * time.Second)
+		m.Values[i] = float64(i % 10)
+	}
+	m.Values[7] = 97.3
+	m.Values[113] = 92.1
+	return m
+}
+
+func startServer(t testing.TB, s *Server) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	t.Cleanup(func() { s.Close() })
+	return lis.Addr().String()
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	m := testMetrics(200)
+	b := flatbuffers.NewBuilder(0)
+	buildRequest(b, m)
+
+	req := fb.GetSizePrefixedRootAsOutliersRequest(b.FinishedBytes(), 0)
+	require.Equal(m.Name, string(req.Name()))
+	require.Equal(len(m.Values), req.ValuesLength())
+	require.Equal(len(m.Times), req.TimesLength())
+	for i := range m.Values {
+		require.Equal(m.Values[i], req.Values(i))
+		require.Equal(m.Times[i].UnixNano(), req.Times(i))
+	}
+}
+
+func TestDetect(t *testing.T) {
+	require := require.New(t)
+
+	addr := startServer(t, &Server{})
+	ctx := context.Background()
+	c, err := Dial(ctx, addr)
+	require.NoError(err)
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		indices, err := c.Detect(ctx, testMetrics(1000))
+		require.NoError(err)
+		require.Equal([]int{7, 113}, indices)
+	}
+
+	indices, err := c.Detect(ctx, Metrics{})
+	require.NoError(err)
+	require.Empty(indices)
+}
+
+func TestDetectError(t *testing.T) {
+	require := require.New(t)
+
+	s := Server{
+		Detect: func(req *fb.OutliersRequest) ([]int32, error) {
+			return nil, fmt.Errorf("no %s", req.Name())
+		},
+	}
+	addr := startServer(t, &s)
+	ctx := context.Background()
+	c, err := Dial(ctx, addr)
+	require.NoError(err)
+	defer c.Close()
+
+	_, err = c.Detect(ctx, testMetrics(200))
+	require.EqualError(err, "no CPU")
+
+	_, err = c.Detect(ctx, Metrics{Values: []float64{1}})
+	require.Error(err)
+}
+
+func TestDetectCanceled(t *testing.T) {
+	require := require.New(t)
+
+	block := make(chan struct{})
+	defer close(block)
+	s := Server{
+		Detect: func(req *fb.OutliersRequest) ([]int32, error) {
+			<-block
+			return nil, nil
+		},
+	}
+	addr := startServer(t, &s)
+	c, err := Dial(context.Background(), addr)
+	require.NoError(err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.Detect(ctx, testMetrics(200))
+	require.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func TestBadFrame(t *testing.T) {
+	require := require.New(t)
+
+	addr := startServer(t, &Server{})
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(err)
+	defer conn.Close()
+
+	// Root offset points outside of the buffer.
+	_, err = conn.Write([]byte{4, 0, 0, 0, 0xff, 0xff, 0, 0})
+	require.NoError(err)
+
+	frame, err := readFrame(conn)
+	require.NoError(err)
+	resp := fb.GetSizePrefixedRootAsOutliersResponse(frame, 0)
+	require.Contains(string(resp.Error()), "bad request")
+}
+
+func hasPyModules(mods ...string) bool {
+	code := "import " + strings.Join(mods, ", ")
+	return exec.Command("python3", "-c", code).Run() == nil
+}
+
+func TestPythonServer(t *testing.T) {
+	if !hasPyModules("flatbuffers", "numpy") {
+		t.Skip("python3 with flatbuffers & numpy not found")
+	}
+	require := require.New(t)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	cmd := exec.Command("python3", "server.py", "--port", fmt.Sprint(port))
+	cmd.Dir = "py"
+	stderr, err := cmd.StderrPipe()
+	require.NoError(err)
+	require.NoError(cmd.Start())
+	defer cmd.Process.Kill()
+
+	// Wait for "server ready" log line.
+	s := bufio.NewScanner(stderr)
+	for s.Scan() {
+		if strings.Contains(s.Text(), "server ready") {
+			break
+		}
+	}
+	go func() {
+		for s.Scan() {
+			fmt.Fprintln(os.Stderr, s.Text())
+		}
+	}()
+
+	ctx := context.Background()
+	c, err := Dial(ctx, fmt.Sprintf("localhost:%d", port))
+	require.NoError(err)
+	defer c.Close()
+
+	indices, err := c.Detect(ctx, testMetrics(1000))
+	require.NoError(err)
+	require.Equal([]int{7, 113}, indices)
+}
+
+func BenchmarkDetect(b *testing.B) {
+	require := require.New(b)
+
+	addr := startServer(b, &Server{})
+	ctx := context.Background()
+	c, err := Dial(ctx, addr)
+	require.NoError(err)
+	defer c.Close()
+
+	m := testMetrics(100_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := c.Detect(ctx, m)
+		require.NoError(err)
+	}
+}
@@ -0,0 +1,45 @@
+// Package flatbuf is a FlatBuffers variant of the gRPC outliers example.
+//
+// Messages (see outliers.fbs) are sent over a plain TCP connection as size
+// prefixed FlatBuffers: a little-endian uint32 length followed by the buffer.
+// The receiving side reads the values straight out of the frame, the Python
+// server (py/server.py) views them as a numpy array without a decoding pass.
+package flatbuf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+const maxFrameSize = 1 << 30
+
+// writeFrame writes a finished size prefixed buffer.
+func writeFrame(w io.Writer, b *flatbuffers.Builder) error {
+	_, err := w.Write(b.FinishedBytes())
+	return err
+}
+
+// readFrame reads a size prefixed buffer. The returned frame includes the
+// size prefix so the buffer keeps the alignment it was built with.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [flatbuffers.SizeUint32]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame too large (%d > %d)", size, maxFrameSize)
+	}
+
+	frame := make([]byte, len(header)+int(size))
+	copy(frame, header[:])
+	if _, err := io.ReadFull(r, frame[len(header):]); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,3 @@
+package flatbuf
+
+//go:generate flatc --go -o . outliers.fbs
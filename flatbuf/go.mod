@@ -0,0 +1,14 @@
+module github.com/ardanlabs/python-go/flatbuf
+
+go 1.21
+
+require (
+	github.com/google/flatbuffers v23.5.26+incompatible
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
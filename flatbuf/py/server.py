@@ -0,0 +1,97 @@
+"""FlatBuffers outliers server, see ../frame.go for the protocol"""
+import logging
+import socketserver
+import struct
+
+import flatbuffers
+import numpy as np
+
+from fb import OutliersRequest, OutliersResponse
+
+size_fmt = '<I'
+size_len = struct.calcsize(size_fmt)
+
+
+def find_outliers(data: np.ndarray):
+    """Return indices where values more than 2 standard deviations from mean"""
+    out = np.where(np.abs(data - data.mean()) > 2 * data.std())
+    # np.where returns a tuple for each dimension, we want the 1st element
+    return out[0]
+
+
+def read_frame(rfile):
+    """Read a size prefixed buffer, return None on EOF"""
+    header = rfile.read(size_len)
+    if len(header) < size_len:
+        return None
+    size, = struct.unpack(size_fmt, header)
+    # Keep the size prefix so the buffer alignment is the one Go built
+    frame = bytearray(size_len + size)
+    frame[:size_len] = header
+    view = memoryview(frame)[size_len:]
+    while view:
+        n = rfile.readinto(view)
+        if not n:
+            return None
+        view = view[n:]
+    return frame
+
+
+def build_response(indices, error=''):
+    """Return a size prefixed OutliersResponse buffer"""
+    builder = flatbuffers.Builder(1024)
+    msg = builder.CreateString(error) if error else None
+    vec = builder.CreateNumpyVector(indices.astype('<i4'))
+    OutliersResponse.Start(builder)
+    OutliersResponse.AddIndices(builder, vec)
+    if msg is not None:
+        OutliersResponse.AddError(builder, msg)
+    builder.FinishSizePrefixed(OutliersResponse.End(builder))
+    return builder.Output()
+
+
+def handle(frame):
+    """Handle a single request frame, return response frame"""
+    try:
+        req = OutliersRequest.OutliersRequest.GetRootAs(frame, size_len)
+        if req.ValuesIsNone():
+            data = np.empty(0, dtype='<f8')
+        else:
+            # A view into frame, no copy and no decoding
+            data = req.ValuesAsNumpy()
+        indices = find_outliers(data)
+        logging.info('found %d outliers in %d values', len(indices), len(data))
+        return build_response(indices)
+    except Exception as err:
+        logging.exception('bad request')
+        return build_response(np.empty(0, dtype='<i4'), str(err))
+
+
+class Handler(socketserver.StreamRequestHandler):
+    def handle(self):
+        while True:
+            frame = read_frame(self.rfile)
+            if frame is None:
+                return
+            self.wfile.write(handle(frame))
+
+
+class Server(socketserver.ThreadingTCPServer):
+    allow_reuse_address = True
+    daemon_threads = True
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('--port', type=int, default=9997, help='port')
+    args = parser.parse_args()
+
+    logging.basicConfig(
+        level=logging.INFO,
+        format='%(asctime)s - %(levelname)s - %(message)s',
+    )
+    with Server(('', args.port), Handler) as server:
+        logging.info('server ready on port %r', args.port)
+        server.serve_forever()
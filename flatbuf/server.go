@@ -0,0 +1,166 @@
+package flatbuf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+
+	"github.com/ardanlabs/python-go/flatbuf/fb"
+)
+
+// DetectFunc returns the outliers indices in req. req is only valid until
+// DetectFunc returns, it points into the connection read buffer.
+type DetectFunc func(req *fb.OutliersRequest) ([]int32, error)
+
+// Server serves outliers requests in Go, it's the Go counterpart of
+// py/server.py and is useful for testing clients.
+type Server struct {
+	Detect DetectFunc // Defaults to Outliers
+
+	mu    sync.Mutex
+	lis   net.Listener
+	conns map[net.Conn]bool
+}
+
+// Serve accepts connections on lis and serves requests until Close is called.
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	s.lis = lis
+	s.conns = make(map[net.Conn]bool)
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the listener and closes all open connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lis == nil {
+		return nil
+	}
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return s.lis.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	detect := s.Detect
+	if detect == nil {
+		detect = Outliers
+	}
+
+	b := flatbuffers.NewBuilder(1024)
+	for {
+		frame, err := readFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("flatbuf: read: %s", err)
+			}
+			return
+		}
+
+		req := fb.GetSizePrefixedRootAsOutliersRequest(frame, 0)
+		indices, err := safeDetect(detect, req)
+		buildResponse(b, indices, err)
+		if err := writeFrame(conn, b); err != nil {
+			log.Printf("flatbuf: write: %s", err)
+			return
+		}
+	}
+}
+
+// safeDetect calls detect, malformed buffers panic on access.
+func safeDetect(detect DetectFunc, req *fb.OutliersRequest) (indices []int32, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("bad request: %v", e)
+		}
+	}()
+
+	return detect(req)
+}
+
+// buildResponse builds a size prefixed OutliersResponse in b.
+func buildResponse(b *flatbuffers.Builder, indices []int32, err error) {
+	b.Reset()
+
+	var msg flatbuffers.UOffsetT
+	if err != nil {
+		msg = b.CreateString(err.Error())
+		indices = nil
+	}
+
+	fb.OutliersResponseStartIndicesVector(b, len(indices))
+	for i := len(indices) - 1; i >= 0; i-- {
+		b.PrependInt32(indices[i])
+	}
+	vec := b.EndVector(len(indices))
+
+	fb.OutliersResponseStart(b)
+	fb.OutliersResponseAddIndices(b, vec)
+	if err != nil {
+		fb.OutliersResponseAddError(b, msg)
+	}
+	fb.FinishSizePrefixedOutliersResponseBuffer(b, fb.OutliersResponseEnd(b))
+}
+
+// Outliers returns indices where values are more than 2 standard deviations
+// from the mean, same as find_outliers in py/server.py.
+func Outliers(req *fb.OutliersRequest) ([]int32, error) {
+	n := req.ValuesLength()
+	if n == 0 {
+		return nil, nil
+	}
+
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += req.Values(i)
+	}
+	mean := sum / float64(n)
+
+	var sq float64
+	for i := 0; i < n; i++ {
+		d := req.Values(i) - mean
+		sq += d * d
+	}
+	std := math.Sqrt(sq / float64(n))
+
+	var indices []int32
+	for i := 0; i < n; i++ {
+		if math.Abs(req.Values(i)-mean) > 2*std {
+			indices = append(indices, int32(i))
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,15 @@
+"""Load trades and outliers from the Go Arrow Flight server"""
+from pyarrow import flight
+
+
+def load(name, addr='grpc://localhost:8815'):
+    """Load dataset (trades or outliers) as a pandas DataFrame"""
+    client = flight.connect(addr)
+    return client.do_get(flight.Ticket(name.encode())).read_pandas()
+
+
+if __name__ == '__main__':
+    for name in ('trades', 'outliers'):
+        df = load(name)
+        print(f'{name}: {len(df)} rows')
+        print(df.head())
@@ -0,0 +1,321 @@
+// Arrow Flight server for trades and outliers
+//
+// Python clients can load the data directly into pandas:
+//
+//	client = flight.connect('grpc://localhost:8815')
+//	df = client.do_get(flight.Ticket(b'trades')).read_pandas()
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/flight"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Number of rows in a record batch
+	batchSize = 64 * 1024
+
+	tradesSQL = `
+SELECT time, symbol, price, buy
+FROM trades
+ORDER BY time
+`
+
+	pricesSQL = `
+SELECT time, symbol, price
+FROM trades
+ORDER BY symbol, time
+`
+)
+
+var (
+	tradesSchema = arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}},
+		{Name: "symbol", Type: arrow.BinaryTypes.String},
+		{Name: "price", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "buy", Type: arrow.FixedWidthTypes.Boolean},
+	}, nil)
+
+	outliersSchema = arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}},
+		{Name: "symbol", Type: arrow.BinaryTypes.String},
+		{Name: "price", Type: arrow.PrimitiveTypes.Float64},
+	}, nil)
+
+	// Ticket name -> schema
+	schemas = map[string]*arrow.Schema{
+		"trades":   tradesSchema,
+		"outliers": outliersSchema,
+	}
+)
+
+// Server serves trades and price outliers as Arrow record batches.
+type Server struct {
+	flight.BaseFlightServer
+
+	db  *sql.DB
+	mem memory.Allocator
+}
+
+// NewServer returns a new Server serving trades from dbFile.
+func NewServer(dbFile string) (*Server, error) {
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Server{
+		db:  db,
+		mem: memory.DefaultAllocator,
+	}
+	return &s, nil
+}
+
+// Close closes the underlying database.
+func (s *Server) Close() error {
+	return s.db.Close()
+}
+
+// ListFlights lists the available datasets.
+func (s *Server) ListFlights(_ *flight.Criteria, stream flight.FlightService_ListFlightsServer) error {
+	for name := range schemas {
+		info, err := s.flightInfo(name)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFlightInfo returns information on a dataset, the descriptor path is the
+// dataset name.
+func (s *Server) GetFlightInfo(_ context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if len(desc.Path) != 1 {
+		return nil, status.Errorf(codes.InvalidArgument, "bad path: %v", desc.Path)
+	}
+
+	return s.flightInfo(desc.Path[0])
+}
+
+func (s *Server) flightInfo(name string) (*flight.FlightInfo, error) {
+	schema, ok := schemas[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown dataset: %q", name)
+	}
+
+	info := flight.FlightInfo{
+		Schema: flight.SerializeSchema(schema, s.mem),
+		FlightDescriptor: &flight.FlightDescriptor{
+			Type: flight.DescriptorPATH,
+			Path: []string{name},
+		},
+		Endpoint: []*flight.FlightEndpoint{
+			{Ticket: &flight.Ticket{Ticket: []byte(name)}},
+		},
+		TotalRecords: -1,
+		TotalBytes:   -1,
+	}
+	return &info, nil
+}
+
+// DoGet streams a dataset, the ticket is the dataset name.
+func (s *Server) DoGet(tkt *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	switch name := string(tkt.Ticket); name {
+	case "trades":
+		return s.sendTrades(stream)
+	case "outliers":
+		return s.sendOutliers(stream)
+	default:
+		return status.Errorf(codes.NotFound, "unknown dataset: %q", name)
+	}
+}
+
+func (s *Server) sendTrades(stream flight.FlightService_DoGetServer) error {
+	rows, err := s.db.QueryContext(stream.Context(), tradesSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(tradesSchema))
+	defer w.Close()
+
+	b := array.NewRecordBuilder(s.mem, tradesSchema)
+	defer b.Release()
+
+	var (
+		t      time.Time
+		symbol string
+		price  float64
+		buy    bool
+	)
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(&t, &symbol, &price, &buy); err != nil {
+			return err
+		}
+
+		b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(t.UnixMicro()))
+		b.Field(1).(*array.StringBuilder).Append(symbol)
+		b.Field(2).(*array.Float64Builder).Append(price)
+		b.Field(3).(*array.BooleanBuilder).Append(buy)
+
+		n++
+		if n == batchSize {
+			if err := writeRecord(w, b); err != nil {
+				return err
+			}
+			n = 0
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return writeRecord(w, b)
+	}
+	return nil
+}
+
+// price is a symbol price at a point in time.
+type price struct {
+	time  time.Time
+	value float64
+}
+
+func (s *Server) sendOutliers(stream flight.FlightService_DoGetServer) error {
+	rows, err := s.db.QueryContext(stream.Context(), pricesSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(outliersSchema))
+	defer w.Close()
+
+	b := array.NewRecordBuilder(s.mem, outliersSchema)
+	defer b.Release()
+
+	// Rows are sorted by symbol, we detect once a symbol is done
+	var (
+		current string
+		prices  []price
+	)
+	flush := func() {
+		for _, i := range outliers(prices) {
+			b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(prices[i].time.UnixMicro()))
+			b.Field(1).(*array.StringBuilder).Append(current)
+			b.Field(2).(*array.Float64Builder).Append(prices[i].value)
+		}
+		prices = prices[:0]
+	}
+
+	for rows.Next() {
+		var (
+			symbol string
+			p      price
+		)
+		if err := rows.Scan(&p.time, &symbol, &p.value); err != nil {
+			return err
+		}
+
+		if symbol != current {
+			flush()
+			current = symbol
+		}
+		prices = append(prices, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	flush()
+
+	return writeRecord(w, b)
+}
+
+// writeRecord writes the current content of b to w.
+func writeRecord(w *flight.Writer, b *array.RecordBuilder) error {
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	return w.Write(rec)
+}
+
+// outliers returns indices of prices that are more than 2 standard deviations
+// from the mean, same as outliers.py in py-in-mem (see TestOutliersPython).
+func outliers(prices []price) []int {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	n := float64(len(prices))
+	mean := 0.0
+	for _, p := range prices {
+		mean += p.value
+	}
+	mean /= n
+
+	std := 0.0
+	for _, p := range prices {
+		std += (p.value - mean) * (p.value - mean)
+	}
+	std = math.Sqrt(std / n)
+
+	var out []int
+	for i, p := range prices {
+		if math.Abs(p.value-mean) > 2*std {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8815", "address to listen on")
+	dbFile := flag.String("db", "trades.db", "trades database file")
+	flag.Parse()
+
+	if _, err := os.Stat(*dbFile); err != nil {
+		log.Fatal(err)
+	}
+
+	svc, err := NewServer(*dbFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer svc.Close()
+
+	srv := flight.NewServerWithMiddleware(nil)
+	if err := srv.Init(*addr); err != nil {
+		log.Fatal(err)
+	}
+	srv.RegisterFlightService(svc)
+	srv.SetShutdownOnSignals(os.Interrupt)
+
+	log.Printf("server listening on %s", srv.Addr())
+	if err := srv.Serve(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math/rand"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/flight"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const schemaSQL = `
+CREATE TABLE trades (
+    time TIMESTAMP,
+    symbol VARCHAR(32),
+    price FLOAT,
+    buy BOOLEAN
+);
+`
+
+func createDB(t *testing.T, size int) string {
+	require := require.New(t)
+
+	dbFile := filepath.Join(t.TempDir(), "trades.db")
+	db, err := sql.Open("sqlite3", dbFile)
+	require.NoError(err, "open")
+	defer db.Close()
+
+	_, err = db.Exec(schemaSQL)
+	require.NoError(err, "schema")
+
+	start := time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+	for i := 0; i < size; i++ {
+		price := 100.0 + float64(i%10)
+		if i == 17 {
+			price = 1000
+		}
+		_, err := db.Exec(
+			"INSERT INTO trades VALUES (?, ?, ?, ?)",
+			start.Add(time.Duration(i)*time.Second), "MSFT", price, i%2 == 0,
+		)
+		require.NoError(err, "insert")
+	}
+
+	return dbFile
+}
+
+func startServer(t *testing.T, dbFile string) flight.Client {
+	require := require.New(t)
+
+	svc, err := NewServer(dbFile)
+	require.NoError(err, "new server")
+	t.Cleanup(func() { svc.Close() })
+
+	srv := flight.NewServerWithMiddleware(nil)
+	require.NoError(srv.Init("localhost:0"), "init")
+	srv.RegisterFlightService(svc)
+	go srv.Serve()
+	t.Cleanup(srv.Shutdown)
+
+	client, err := flight.NewClientWithMiddleware(
+		srv.Addr().String(), nil, nil,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(err, "client")
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func doGet(t *testing.T, client flight.Client, name string) int {
+	require := require.New(t)
+
+	stream, err := client.DoGet(context.Background(), &flight.Ticket{Ticket: []byte(name)})
+	require.NoError(err, "do get")
+
+	r, err := flight.NewRecordReader(stream)
+	require.NoError(err, "reader")
+	defer r.Release()
+
+	rows := 0
+	for r.Next() {
+		rows += int(r.Record().NumRows())
+	}
+	require.NoError(r.Err(), "read")
+	return rows
+}
+
+func TestDoGet(t *testing.T) {
+	const size = 100
+	client := startServer(t, createDB(t, size))
+
+	require.Equal(t, size, doGet(t, client, "trades"), "trades")
+	require.Equal(t, 1, doGet(t, client, "outliers"), "outliers")
+}
+
+func TestDoGetUnknown(t *testing.T) {
+	client := startServer(t, createDB(t, 1))
+
+	stream, err := client.DoGet(context.Background(), &flight.Ticket{Ticket: []byte("nope")})
+	require.NoError(t, err, "do get")
+	_, err = stream.Recv()
+	require.Error(t, err, "recv")
+}
+
+func TestOutliers(t *testing.T) {
+	prices := make([]price, 1000)
+	for i := range prices {
+		prices[i].value = 10
+	}
+	prices[7].value = 100
+	prices[113].value = 97
+
+	require.Equal(t, []int{7, 113}, outliers(prices))
+	require.Nil(t, outliers(nil))
+}
+
+// outliersPy prints the result of detect from py-in-mem outliers.py for each
+// series (JSON on stdin). Without numpy it uses plain.py, the same detector in
+// pure Python.
+const outliersPy = `
+import json
+import sys
+
+sys.path.insert(0, sys.argv[1])
+try:
+    import numpy as np
+    from outliers import detect
+
+    def run(values):
+        return detect(np.array(values, dtype=np.float64)).tolist()
+except ImportError:
+    from plain import detect as run
+
+print(json.dumps([run(values) for values in json.load(sys.stdin)]))
+`
+
+// TestOutliersPython checks outliers returns the same indices as the Python
+// detector, they must be kept in sync.
+func TestOutliersPython(t *testing.T) {
+	require := require.New(t)
+
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+
+	rnd := rand.New(rand.NewSource(7))
+	series := [][]float64{
+		{10},
+		{10, 10, 10, 10},
+		{100, 101, 102, 103, 104, 105, 106, 107, 108, 109, 1000},
+	}
+	for _, size := range []int{10, 100, 1000} {
+		values := make([]float64, size)
+		for i := range values {
+			values[i] = 100 + rnd.NormFloat64()*5
+		}
+		for i := 0; i < size/50+1; i++ {
+			values[rnd.Intn(size)] = 150 + rnd.Float64()*50
+		}
+		series = append(series, values)
+	}
+
+	data, err := json.Marshal(series)
+	require.NoError(err, "marshal")
+	cmd := exec.Command(python, "-c", outliersPy, "../py-in-mem")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	require.NoError(err, "python")
+
+	var expected [][]int
+	require.NoError(json.Unmarshal(out, &expected), "unmarshal")
+	require.Len(expected, len(series))
+
+	for i, values := range series {
+		prices := make([]price, len(values))
+		for j, v := range values {
+			prices[j].value = v
+		}
+		indices := outliers(prices)
+		if indices == nil {
+			indices = []int{}
+		}
+		require.Equal(expected[i], indices, "series %d", i)
+	}
+}
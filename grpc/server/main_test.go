@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/ardanlabs/python-go/grpc/pb"
+
+	outliers "py-in-mem"
+)
+
+func startServer(t *testing.T) pb.OutliersClient {
+	require := require.New(t)
+
+	// plain.detect doesn't need numpy
+	require.NoError(outliers.AddPath("../../py-in-mem"))
+	pool, err := outliers.NewPool(2, "plain", "detect")
+	require.NoError(err, "pool")
+	t.Cleanup(pool.Close)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err, "listen")
+
+	srv := grpc.NewServer()
+	pb.RegisterOutliersServer(srv, &Server{pool: pool})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err, "dial")
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewOutliersClient(conn)
+}
+
+func TestDetect(t *testing.T) {
+	require := require.New(t)
+	client := startServer(t)
+
+	req := pb.OutliersRequest{
+		Metrics: make([]*pb.Metric, 1000),
+	}
+	for i := range req.Metrics {
+		req.Metrics[i] = &pb.Metric{Name: "CPU", Value: float64(i % 10)}
+	}
+	indices := []int32{7, 113, 835}
+	for _, i := range indices {
+		req.Metrics[i].Value = 97
+	}
+
+	resp, err := client.Detect(context.Background(), &req)
+	require.NoError(err)
+	require.Equal(indices, resp.Indices)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Detect(ctx, &req)
+	require.Equal(codes.Canceled, status.Code(err))
+}
+
+func TestGRPCError(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{context.Canceled, codes.Canceled},
+		{fmt.Errorf("%w: 1s", outliers.ErrTimeout), codes.DeadlineExceeded},
+		{outliers.ErrPoolClosed, codes.Unavailable},
+		{&outliers.PyError{Type: "ValueError"}, codes.Internal},
+		{fmt.Errorf("oops"), codes.Unknown},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			require.Equal(t, tc.code, status.Code(grpcError(tc.err)))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Command ingest receives UDP metrics and logs outliers found by Python.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ardanlabs/python-go/ingest"
+	"github.com/ardanlabs/python-go/pybridge"
+	"github.com/ardanlabs/python-go/pyproc"
+)
+
+func main() {
+	var (
+		cfg  ingest.Config
+		bcfg pybridge.Config
+	)
+	addr := flag.String("addr", ":8125", "UDP address to listen on")
+	flag.DurationVar(&cfg.Window, "window", 10*time.Second, "window time span")
+	flag.IntVar(&cfg.MaxSize, "max-size", 10_000, "maximal values in a window")
+	flag.StringVar(&bcfg.Backend, "backend", "grpc", "detection backend (grpc, embedded or subprocess)")
+	flag.StringVar(&bcfg.Addr, "grpc", "localhost:9999", "gRPC server address")
+	pyDir := flag.String("pydir", "../py-in-mem", "subprocess: directory of outliers.py")
+	flag.Parse()
+
+	bcfg.Pool = pyproc.Config{Dir: *pyDir}
+	backend, err := pybridge.New(bcfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer backend.Close()
+
+	handler := func(w ingest.Window, indices []int, err error) {
+		if err != nil {
+			log.Printf("%s: detect error: %s", w.Name, err)
+			return
+		}
+		for _, i := range indices {
+			log.Printf("%s: outlier %f at %s", w.Name, w.Values[i], w.Times[i].Format(time.RFC3339Nano))
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	srv := ingest.NewServer(backend, handler, cfg)
+	log.Printf("listening on %s (%s backend)", *addr, bcfg.Backend)
+	err = srv.ListenAndServe(ctx, *addr)
+	log.Printf("stats: %+v", srv.Stats())
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
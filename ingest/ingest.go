@@ -0,0 +1,294 @@
+// Package ingest receives metrics over UDP, groups them into windows and
+// sends each window to Python for outliers detection.
+//
+// Datagrams are statsd like, one metric per line:
+//
+//	cpu.load:37.5|g
+//	requests:12|c
+//
+// The type (after the "|") is ignored, all values are treated as float64.
+// Each metric name has its own window, a window is sent to detection when it
+// spans Config.Window or reaches Config.MaxSize values.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ardanlabs/python-go/pybridge"
+)
+
+// Metric is a single metric value.
+type Metric struct {
+	Name  string
+	Value float64
+	Time  time.Time // Time received
+}
+
+// Window is a sequence of values of a metric.
+type Window struct {
+	Name   string
+	Times  []time.Time
+	Values []float64
+}
+
+// Handler is called with the outliers indices (in w.Values) of every window
+// sent to detection, or with the detection error.
+type Handler func(w Window, indices []int, err error)
+
+// Config is server configuration, zero values are replaced by defaults.
+type Config struct {
+	Window  time.Duration // Window time span, defaults to 10s
+	MaxSize int           // Maximal values in a window, defaults to 10,000
+	MinSize int           // Smaller windows are discarded, defaults to 10
+	Workers int           // Concurrent detection calls, defaults to 4
+	Queue   int           // Windows waiting for detection, defaults to 64
+	Func    string        // Detection function, defaults to "outliers.detect"
+	Timeout time.Duration // Detection call timeout, defaults to 5s
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = 10_000
+	}
+	if c.MinSize <= 0 {
+		c.MinSize = 10
+	}
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.Queue <= 0 {
+		c.Queue = 64
+	}
+	if c.Func == "" {
+		c.Func = "outliers.detect"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	return c
+}
+
+// Stats are server counters.
+type Stats struct {
+	Packets  uint64 // Datagrams received
+	Metrics  uint64 // Metrics parsed
+	BadLines uint64 // Lines that failed to parse
+	Windows  uint64 // Windows sent to detection
+	Dropped  uint64 // Windows dropped since the detection queue was full
+	Errors   uint64 // Detection errors
+}
+
+// Server is a UDP metrics server.
+type Server struct {
+	cfg     Config
+	backend pybridge.Backend
+	handler Handler
+
+	packets  atomic.Uint64
+	metrics  atomic.Uint64
+	badLines atomic.Uint64
+	windows  atomic.Uint64
+	dropped  atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// NewServer returns a server sending windows to backend and results to h.
+func NewServer(backend pybridge.Backend, h Handler, cfg Config) *Server {
+	s := Server{
+		cfg:     cfg.withDefaults(),
+		backend: backend,
+		handler: h,
+	}
+	return &s
+}
+
+// Stats returns the current server counters.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Packets:  s.packets.Load(),
+		Metrics:  s.metrics.Load(),
+		BadLines: s.badLines.Load(),
+		Windows:  s.windows.Load(),
+		Dropped:  s.dropped.Load(),
+		Errors:   s.errors.Load(),
+	}
+}
+
+// ListenAndServe listens on UDP addr (e.g. ":8125") and calls Serve.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, pc)
+}
+
+// Serve reads metrics from pc until ctx is canceled. On cancel it closes pc,
+// sends open windows to detection and waits for detection to finish.
+func (s *Server) Serve(ctx context.Context, pc net.PacketConn) error {
+	metrics := make(chan Metric, 1024)
+	windows := make(chan Window, s.cfg.Queue)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.detect(windows)
+		}()
+	}
+
+	aggDone := make(chan struct{})
+	go func() {
+		defer close(aggDone)
+		s.aggregate(metrics, windows)
+		close(windows)
+	}()
+
+	stop := context.AfterFunc(ctx, func() { pc.Close() })
+	defer stop()
+
+	err := s.read(pc, metrics)
+	close(metrics)
+	<-aggDone
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// read reads datagrams from pc and sends parsed metrics to out.
+func (s *Server) read(pc net.PacketConn, out chan<- Metric) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.packets.Add(1)
+
+		now := time.Now()
+		for _, line := range bytes.Split(buf[:n], []byte{'\n'}) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+
+			m, err := ParseMetric(line, now)
+			if err != nil {
+				s.badLines.Add(1)
+				continue
+			}
+			s.metrics.Add(1)
+			out <- m
+		}
+	}
+}
+
+// aggregate groups metrics to windows and sends full or expired windows to
+// out. Open windows are sent when in is closed.
+func (s *Server) aggregate(in <-chan Metric, out chan<- Window) {
+	open := make(map[string]*Window)
+	send := func(w *Window) {
+		delete(open, w.Name)
+		if len(w.Values) < s.cfg.MinSize {
+			return
+		}
+
+		select {
+		case out <- *w:
+			s.windows.Add(1)
+		default:
+			s.dropped.Add(1)
+		}
+	}
+
+	tick := time.NewTicker(s.cfg.Window / 4)
+	defer tick.Stop()
+
+	for {
+		select {
+		case m, ok := <-in:
+			if !ok {
+				for _, w := range open {
+					send(w)
+				}
+				return
+			}
+
+			w, ok := open[m.Name]
+			if !ok {
+				w = &Window{Name: m.Name}
+				open[m.Name] = w
+			}
+			w.Times = append(w.Times, m.Time)
+			w.Values = append(w.Values, m.Value)
+			if len(w.Values) >= s.cfg.MaxSize {
+				send(w)
+			}
+		case now := <-tick.C:
+			for _, w := range open {
+				if now.Sub(w.Times[0]) >= s.cfg.Window {
+					send(w)
+				}
+			}
+		}
+	}
+}
+
+// detect runs detection on windows from in.
+func (s *Server) detect(in <-chan Window) {
+	for w := range in {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		indices, err := pybridge.Detect(ctx, s.backend, s.cfg.Func, w.Values)
+		cancel()
+		if err != nil {
+			s.errors.Add(1)
+		}
+
+		if s.handler != nil {
+			s.handler(w, indices, err)
+		}
+	}
+}
+
+// ParseMetric parses a single "name:value|type" line.
+func ParseMetric(line []byte, now time.Time) (Metric, error) {
+	line = bytes.TrimSpace(line)
+	i := bytes.IndexByte(line, ':')
+	if i <= 0 {
+		return Metric{}, fmt.Errorf("%q: missing name", line)
+	}
+	name, rest := line[:i], line[i+1:]
+
+	if j := bytes.IndexByte(rest, '|'); j >= 0 {
+		rest = rest[:j]
+	}
+
+	val, err := strconv.ParseFloat(string(rest), 64)
+	if err != nil {
+		return Metric{}, fmt.Errorf("%q: bad value", line)
+	}
+
+	m := Metric{
+		Name:  string(name),
+		Value: val,
+		Time:  now,
+	}
+	return m, nil
+}
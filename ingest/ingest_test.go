@@ -0,0 +1,151 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend returns indices of values above 90.
+type fakeBackend struct{}
+
+func (fakeBackend) Call(ctx context.Context, fn string, args ...any) (any, error) {
+	var indices []int
+	for i, v := range args[0].([]float64) {
+		if v > 90 {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
+
+func (fakeBackend) Close() error { return nil }
+
+type result struct {
+	name    string
+	size    int
+	indices []int
+	err     error
+}
+
+func TestServer(t *testing.T) {
+	require := require.New(t)
+
+	var (
+		mu      sync.Mutex
+		results []result
+	)
+	handler := func(w Window, indices []int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result{w.Name, len(w.Values), indices, err})
+	}
+
+	cfg := Config{
+		Window:  time.Hour,
+		MaxSize: 100,
+		MinSize: 5,
+		Workers: 1,
+	}
+	srv := NewServer(fakeBackend{}, handler, cfg)
+
+	pc, err := net.ListenPacket("udp", "localhost:0")
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ctx, pc) }()
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	require.NoError(err)
+	defer conn.Close()
+
+	for i := 0; i < 10; i++ {
+		var lines []string
+		for j := 0; j < 15; j++ {
+			v := 10.0
+			if i*15+j == 42 {
+				v = 97.5
+			}
+			lines = append(lines, fmt.Sprintf("cpu:%f|g", v))
+		}
+		if i == 0 {
+			lines = append(lines, "mem:1|g", "bad line", "")
+		}
+		_, err := conn.Write([]byte(strings.Join(lines, "\n")))
+		require.NoError(err)
+	}
+
+	// UDP on localhost shouldn't drop, but give the reader time.
+	require.Eventually(func() bool {
+		return srv.Stats().Metrics == 151
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	require.ErrorIs(<-errc, context.Canceled)
+
+	// 150 cpu values: one full window of 100, the rest sent on shutdown.
+	// mem window is smaller than MinSize.
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(results, 2)
+	require.Equal(result{"cpu", 100, []int{42}, nil}, results[0])
+	require.Equal("cpu", results[1].name)
+	require.NoError(results[1].err)
+	require.Equal(50, results[1].size)
+
+	stats := srv.Stats()
+	require.Equal(uint64(10), stats.Packets)
+	require.Equal(uint64(1), stats.BadLines)
+	require.Equal(uint64(2), stats.Windows)
+}
+
+func TestWindowExpire(t *testing.T) {
+	require := require.New(t)
+
+	done := make(chan Window, 1)
+	handler := func(w Window, indices []int, err error) { done <- w }
+	srv := NewServer(fakeBackend{}, handler, Config{Window: 40 * time.Millisecond, MinSize: 1})
+
+	pc, err := net.ListenPacket("udp", "localhost:0")
+	require.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx, pc)
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	require.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("disk:3|g\ndisk:4|g\n"))
+	require.NoError(err)
+
+	select {
+	case w := <-done:
+		require.Equal([]float64{3, 4}, w.Values)
+	case <-time.After(time.Second):
+		require.FailNow("window not expired")
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	require := require.New(t)
+	now := time.Now()
+
+	m, err := ParseMetric([]byte("cpu.load:37.5|g"), now)
+	require.NoError(err)
+	require.Equal(Metric{"cpu.load", 37.5, now}, m)
+
+	m, err = ParseMetric([]byte("requests:12"), now)
+	require.NoError(err)
+	require.Equal(12.0, m.Value)
+
+	for _, line := range []string{":1|g", "cpu", "cpu:x|g"} {
+		_, err := ParseMetric([]byte(line), now)
+		require.Error(err, line)
+	}
+}
@@ -0,0 +1,31 @@
+"""Send random CPU metrics, with a few outliers, to the ingest server"""
+import socket
+from random import random
+from time import sleep
+
+
+def send(sock, addr, name, values):
+    """Send values in a single datagram"""
+    data = ''.join(f'{name}:{v:.3f}|g\n' for v in values)
+    sock.sendto(data.encode('utf-8'), addr)
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('--host', default='localhost', help='server host')
+    parser.add_argument('--port', type=int, default=8125, help='server port')
+    parser.add_argument('--rate', type=int, default=1000, help='metrics/sec')
+    args = parser.parse_args()
+
+    addr = (args.host, args.port)
+    sock = socket.socket(socket.AF_INET, socket.SOCK_DGRAM)
+    batch = 100
+    while True:
+        # normally we're below 40% CPU utilization
+        values = [random() * 40 for _ in range(batch)]
+        if random() < 0.1:
+            values[0] = 90 + random() * 10
+        send(sock, addr, 'cpu', values)
+        sleep(batch / args.rate)
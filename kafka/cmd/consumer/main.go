@@ -0,0 +1,53 @@
+// Command consumer writes trades from a Kafka topic to an SQLite database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/ardanlabs/python-go/kafka"
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+func main() {
+	var cfg kafka.Config
+	brokers := flag.String("brokers", "localhost:9092", "comma separated brokers")
+	dbFile := flag.String("db", "trades.db", "database file")
+	flag.StringVar(&cfg.Topic, "topic", "trades", "topic")
+	flag.StringVar(&cfg.Group, "group", "trades-db", "consumer group")
+	flag.StringVar(&cfg.DeadLetter, "dead", "trades.dead", "dead letter topic")
+	flag.StringVar(&cfg.Format, "format", "json", "message format (json or avro)")
+	flag.Parse()
+
+	cfg.Brokers = strings.Split(*brokers, ",")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	db, err := trades.NewDB(*dbFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := kafka.NewConsumer(cfg, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("consuming %q from %s", cfg.Topic, *brokers)
+	err = c.Run(ctx)
+	c.Close()
+	if cerr := db.Close(); cerr != nil {
+		log.Printf("close: %s", cerr)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package kafka writes trades from a Kafka topic to a trades database.
+//
+// Offsets are committed only after the trades of a poll are committed to the
+// database, delivery is at least once. Messages that can't be decoded are
+// sent to a dead letter topic with the error in the "error" header.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+// Config is consumer configuration.
+type Config struct {
+	Brokers    []string
+	Topic      string // Defaults to "trades"
+	Group      string // Defaults to "trades-db"
+	DeadLetter string // Defaults to Topic + ".dead"
+	Format     string // Key in Decoders, defaults to "json"
+	BatchSize  int    // Maximal records per poll, defaults to 1000
+}
+
+// Consumer reads trades from a topic and writes them to a database.
+type Consumer struct {
+	client *kgo.Client
+	db     *trades.DB
+	decode Decoder
+	cfg    Config
+
+	// Called after records are committed, used in testing.
+	committed func([]*kgo.Record)
+}
+
+// NewConsumer returns a new consumer. opts are passed to the Kafka client
+// after the Config options.
+func NewConsumer(cfg Config, db *trades.DB, opts ...kgo.Opt) (*Consumer, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("no brokers")
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = "trades"
+	}
+	if cfg.Group == "" {
+		cfg.Group = "trades-db"
+	}
+	if cfg.DeadLetter == "" {
+		cfg.DeadLetter = cfg.Topic + ".dead"
+	}
+	if cfg.Format == "" {
+		cfg.Format = "json"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+
+	decode, ok := Decoders[cfg.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format: %q", cfg.Format)
+	}
+
+	opts = append([]kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ConsumerGroup(cfg.Group),
+		kgo.ConsumeTopics(cfg.Topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.DisableAutoCommit(),
+		// Don't lose partitions between writing to the database and
+		// committing offsets.
+		kgo.BlockRebalanceOnPoll(),
+	}, opts...)
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Consumer{
+		client: client,
+		db:     db,
+		decode: decode,
+		cfg:    cfg,
+	}
+	return &c, nil
+}
+
+// Run consumes trades until ctx is canceled or there's an error writing to
+// the database or committing offsets. Uncommitted messages are consumed again
+// when the consumer restarts.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		fetches := c.client.PollRecords(ctx, c.cfg.BatchSize)
+		if fetches.IsClientClosed() {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			log.Printf("kafka: fetch %s/%d: %s", topic, partition, err)
+		})
+
+		err := c.process(ctx, fetches.Records())
+		c.client.AllowRebalance()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// process writes records to the database and commits their offsets.
+func (c *Consumer) process(ctx context.Context, records []*kgo.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var dead []*kgo.Record
+	for _, r := range records {
+		trade, err := c.decode(r.Value)
+		if err != nil {
+			dead = append(dead, c.deadLetter(r, err))
+			continue
+		}
+
+		if err := c.db.Add(trade); err != nil {
+			return err
+		}
+	}
+
+	if err := c.db.Flush(); err != nil {
+		return fmt.Errorf("unable to flush trades: %w", err)
+	}
+
+	if len(dead) > 0 {
+		if err := c.client.ProduceSync(ctx, dead...).FirstErr(); err != nil {
+			return fmt.Errorf("dead letter: %w", err)
+		}
+	}
+
+	if err := c.client.CommitRecords(ctx, records...); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	if c.committed != nil {
+		c.committed(records)
+	}
+	return nil
+}
+
+// deadLetter returns a dead letter record for r with the error and the
+// original position in the headers.
+func (c *Consumer) deadLetter(r *kgo.Record, reason error) *kgo.Record {
+	log.Printf("kafka: dead letter %s/%d@%d: %s", r.Topic, r.Partition, r.Offset, reason)
+	headers := append([]kgo.RecordHeader{
+		{Key: "error", Value: []byte(reason.Error())},
+		{Key: "topic", Value: []byte(r.Topic)},
+		{Key: "partition", Value: []byte(strconv.Itoa(int(r.Partition)))},
+		{Key: "offset", Value: []byte(strconv.FormatInt(r.Offset, 10))},
+	}, r.Headers...)
+
+	dr := kgo.Record{
+		Topic:   c.cfg.DeadLetter,
+		Key:     r.Key,
+		Value:   r.Value,
+		Headers: headers,
+	}
+	return &dr
+}
+
+// Close leaves the consumer group and closes the client. It doesn't close the
+// database.
+func (c *Consumer) Close() {
+	c.client.Close()
+}
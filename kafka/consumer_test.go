@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+var (
+	start = time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+)
+
+func newDB(t *testing.T) *trades.DB {
+	db, err := trades.NewDB(filepath.Join(t.TempDir(), "trades.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func jsonMsg(i int) []byte {
+	ts := start.Add(time.Duration(i) * time.Second).Format(time.RFC3339)
+	s := fmt.Sprintf(`{"time": %q, "symbol": "AAPL", "price": %d, "buy": %v}`, ts, 100+i, i%2 == 1)
+	return []byte(s)
+}
+
+func produce(t *testing.T, brokers []string, values ...[]byte) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.DefaultProduceTopic("trades"))
+	require.NoError(t, err)
+	defer client.Close()
+
+	records := make([]*kgo.Record, len(values))
+	for i, v := range values {
+		records[i] = &kgo.Record{Value: v}
+	}
+	require.NoError(t, client.ProduceSync(context.Background(), records...).FirstErr())
+}
+
+// consume runs a consumer until n records were committed.
+func consume(t *testing.T, brokers []string, db *trades.DB, n int) {
+	c, err := NewConsumer(Config{Brokers: brokers}, db, kgo.FetchMaxWait(100*time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count := 0
+	c.committed = func(records []*kgo.Record) {
+		count += len(records)
+		if count >= n {
+			cancel()
+		}
+	}
+
+	require.ErrorIs(t, c.Run(ctx), context.Canceled)
+	require.Equal(t, n, count)
+}
+
+func TestConsumer(t *testing.T) {
+	require := require.New(t)
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "trades", "trades.dead"))
+	require.NoError(err)
+	defer cluster.Close()
+	brokers := cluster.ListenAddrs()
+
+	const size = 10
+	var values [][]byte
+	for i := 0; i < size; i++ {
+		values = append(values, jsonMsg(i))
+	}
+	values = append(values, []byte(`{"symbol": "AAPL"`))
+	produce(t, brokers, values...)
+
+	db := newDB(t)
+	consume(t, brokers, db, len(values))
+
+	out, err := db.Query(start, start.Add(time.Hour))
+	require.NoError(err)
+	require.Len(out, size)
+	require.Equal(103.0, out[3].Price)
+	require.True(out[3].IsBuy)
+
+	// Offsets are committed, a new consumer gets only new messages.
+	produce(t, brokers, jsonMsg(size))
+	db = newDB(t)
+	consume(t, brokers, db, 1)
+	out, err = db.Query(start, start.Add(time.Hour))
+	require.NoError(err)
+	require.Len(out, 1)
+	require.Equal(float64(100+size), out[0].Price)
+
+	// Malformed message in dead letter topic.
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.ConsumeTopics("trades.dead"))
+	require.NoError(err)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fetches := client.PollRecords(ctx, 1)
+	require.NoError(fetches.Err())
+	records := fetches.Records()
+	require.Len(records, 1)
+	require.Equal(values[size], records[0].Value)
+	require.Equal("error", records[0].Headers[0].Key)
+	require.Equal(fmt.Sprint(size), string(records[0].Headers[3].Value))
+}
+
+func TestDecodeJSON(t *testing.T) {
+	require := require.New(t)
+
+	trade, err := DecodeJSON(jsonMsg(1))
+	require.NoError(err)
+	require.Equal(trades.Trade{
+		Time:   start.Add(time.Second),
+		Symbol: "AAPL",
+		Price:  101,
+		IsBuy:  true,
+	}, trade)
+
+	_, err = DecodeJSON([]byte(`{"time": "2020-05-22T14:13:11Z", "symbol": "AAPL"}`))
+	require.EqualError(err, "missing price")
+
+	_, err = DecodeJSON([]byte(`{"symbol": "AAPL", "price": 1}`))
+	require.EqualError(err, "missing time")
+}
+
+func TestDecodeAvro(t *testing.T) {
+	require := require.New(t)
+
+	at := avroTrade{
+		Time:   start,
+		Symbol: "MSFT",
+		Price:  183.5,
+	}
+	data, err := avro.Marshal(avroSchema, at)
+	require.NoError(err)
+
+	// Magic byte + schema ID
+	msg := append([]byte{0, 0, 0, 0, 7}, data...)
+	trade, err := DecodeAvro(msg)
+	require.NoError(err)
+	require.Equal(trades.Trade{
+		Time:   start,
+		Symbol: "MSFT",
+		Price:  183.5,
+	}, trade)
+
+	_, err = DecodeAvro(data)
+	require.Error(err)
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hamba/avro/v2"
+
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+// Decoder decodes a message value to a trade.
+type Decoder func(data []byte) (trades.Trade, error)
+
+// Decoders are the supported message formats.
+var Decoders = map[string]Decoder{
+	"json": DecodeJSON,
+	"avro": DecodeAvro,
+}
+
+type jsonTrade struct {
+	Time   time.Time `json:"time"`
+	Symbol string    `json:"symbol"`
+	Price  *float64  `json:"price"`
+	Buy    bool      `json:"buy"`
+}
+
+// DecodeJSON decodes a JSON trade:
+//
+//	{"time": "2020-05-22T14:13:11Z", "symbol": "AAPL", "price": 318.2, "buy": true}
+func DecodeJSON(data []byte) (trades.Trade, error) {
+	var jt jsonTrade
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return trades.Trade{}, err
+	}
+
+	if jt.Price == nil {
+		return trades.Trade{}, errors.New("missing price")
+	}
+
+	t := trades.Trade{
+		Time:   jt.Time,
+		Symbol: jt.Symbol,
+		Price:  *jt.Price,
+		IsBuy:  jt.Buy,
+	}
+	return t, validate(t)
+}
+
+var (
+	//go:embed trade.avsc
+	avroSchemaJSON string
+	avroSchema     = avro.MustParse(avroSchemaJSON)
+)
+
+type avroTrade struct {
+	Time   time.Time `avro:"time"`
+	Symbol string    `avro:"symbol"`
+	Price  float64   `avro:"price"`
+	Buy    bool      `avro:"buy"`
+}
+
+// DecodeAvro decodes an Avro trade (see trade.avsc) in the Confluent wire
+// format: a zero magic byte, a 4 byte schema ID and the Avro data. The schema
+// ID is ignored, data is always decoded with trade.avsc.
+func DecodeAvro(data []byte) (trades.Trade, error) {
+	const headerSize = 5
+	if len(data) < headerSize || data[0] != 0 {
+		return trades.Trade{}, errors.New("bad avro header")
+	}
+
+	var at avroTrade
+	if err := avro.Unmarshal(avroSchema, data[headerSize:], &at); err != nil {
+		return trades.Trade{}, err
+	}
+
+	t := trades.Trade{
+		Time:   at.Time,
+		Symbol: at.Symbol,
+		Price:  at.Price,
+		IsBuy:  at.Buy,
+	}
+	return t, validate(t)
+}
+
+func validate(t trades.Trade) error {
+	if t.Time.IsZero() {
+		return errors.New("missing time")
+	}
+
+	if t.Symbol == "" {
+		return errors.New("missing symbol")
+	}
+
+	if t.Price < 0 {
+		return fmt.Errorf("negative price: %f", t.Price)
+	}
+
+	return nil
+}
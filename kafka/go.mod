@@ -0,0 +1,28 @@
+module github.com/ardanlabs/python-go/kafka
+
+go 1.21
+
+replace github.com/ardanlabs/python-go => ../
+
+require (
+	github.com/ardanlabs/python-go v0.0.0-00010101000000-000000000000
+	github.com/hamba/avro/v2 v2.20.1
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/stretchr/testify v1.8.4
+	github.com/twmb/franz-go v1.15.3
+	github.com/twmb/franz-go/pkg/kfake v0.0.0-20240412162337-6a58760afaa7
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.7 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/pierrec/lz4/v4 v4.1.18 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/twmb/franz-go/pkg/kmsg v1.7.0 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
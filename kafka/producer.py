@@ -0,0 +1,39 @@
+"""Produce random JSON trades to the "trades" Kafka topic"""
+import json
+from datetime import datetime, timezone
+from random import choice, random
+
+from confluent_kafka import Producer
+
+symbols = ['AAPL', 'GOOG', 'MSFT', 'NVDA']
+
+
+def trade_message(symbol, price, is_buy):
+    """Return JSON encoded trade"""
+    trade = {
+        'time': datetime.now(timezone.utc).isoformat(),
+        'symbol': symbol,
+        'price': price,
+        'buy': is_buy,
+    }
+    return json.dumps(trade).encode('utf-8')
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument(
+        '--brokers', default='localhost:9092', help='bootstrap servers')
+    parser.add_argument('--topic', default='trades', help='topic')
+    parser.add_argument('--count', type=int, default=1000, help='trades')
+    args = parser.parse_args()
+
+    producer = Producer({'bootstrap.servers': args.brokers})
+    for _ in range(args.count):
+        symbol = choice(symbols)
+        msg = trade_message(symbol, round(random() * 500, 2), random() > 0.5)
+        producer.produce(args.topic, msg, key=symbol)
+        producer.poll(0)
+    producer.flush()
+    print(f'produced {args.count} trades')
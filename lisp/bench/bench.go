@@ -0,0 +1,104 @@
+// Benchmark Collatz stopping time computation in Go, embedded Python and a
+// Python subprocess.
+//
+//	$ go run .
+//	$ go run -tags embedpy . # Include embedded Python (requires python3-embed)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// result is the number below the limit with the longest stopping time.
+type result struct {
+	n     int
+	steps int
+}
+
+var errNotBuilt = errors.New("not built")
+
+// runner computes the longest stopping time below limit.
+type runner struct {
+	name string
+	run  func(limit int) (result, error)
+}
+
+// stoppingTime returns number of Collatz steps it takes n to reach 1.
+func stoppingTime(n int) int {
+	steps := 0
+	for n != 1 {
+		if n%2 == 0 {
+			n /= 2
+		} else {
+			n = n*3 + 1
+		}
+		steps++
+	}
+	return steps
+}
+
+// longest returns the number below limit with the longest stopping time.
+func longest(limit int) (result, error) {
+	best := result{1, 0}
+	for n := 1; n < limit; n++ {
+		if steps := stoppingTime(n); steps > best.steps {
+			best = result{n, steps}
+		}
+	}
+	return best, nil
+}
+
+// timeRun runs r count times and returns the last result and mean duration.
+func timeRun(r runner, limit, count int) (result, time.Duration, error) {
+	var res result
+	var total time.Duration
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		out, err := r.run(limit)
+		if err != nil {
+			return result{}, 0, err
+		}
+		total += time.Since(start)
+		res = out
+	}
+	return res, total / time.Duration(count), nil
+}
+
+func main() {
+	limit := flag.Int("limit", 1_000_000, "compute stopping times below limit")
+	count := flag.Int("count", 3, "number of runs per runner")
+	pyDir := flag.String("pydir", ".", "directory of stopping.py")
+	flag.Parse()
+
+	runners := []runner{
+		{"go", longest},
+		{"embedded", embeddedRunner(*pyDir)},
+		{"subprocess", subprocessRunner(*pyDir)},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "runner\tn\tsteps\ttime\trelative")
+	var base time.Duration
+	for _, r := range runners {
+		res, d, err := timeRun(r, *limit, *count)
+		if err == errNotBuilt {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t(not built)\n", r.name)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("error: %s - %s", r.name, err)
+		}
+		if base == 0 {
+			base = d
+		}
+		rel := float64(d) / float64(base)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%v\t%.2fx\n", r.name, res.n, res.steps, d, rel)
+	}
+	w.Flush()
+}
@@ -0,0 +1,100 @@
+//go:build embedpy
+
+package main
+
+/*
+#cgo pkg-config: python3-embed
+
+#include <Python.h>
+
+// Initialize Python and add dir to sys.path, returns -1 on error.
+//
+// The initializing thread holds the GIL, we release it so calls from any
+// thread can acquire it with PyGILState_Ensure.
+static int init_python(const char *dir) {
+  Py_Initialize();
+
+  int rc = -1;
+  PyObject *path = PySys_GetObject("path"); // borrowed
+  PyObject *py_dir = PyUnicode_FromString(dir);
+  if (path == NULL || py_dir == NULL) {
+    PyErr_Print();
+  } else {
+    rc = PyList_Insert(path, 0, py_dir);
+  }
+  Py_XDECREF(py_dir);
+
+  PyEval_SaveThread();
+  return rc;
+}
+
+// Call stopping.longest(limit), returns -1 on error. Must be called with the
+// GIL held.
+static long call_longest(long limit, long *steps) {
+  PyObject *mod = PyImport_ImportModule("stopping");
+  if (mod == NULL) {
+    PyErr_Print();
+    return -1;
+  }
+
+  PyObject *out = PyObject_CallMethod(mod, "longest", "l", limit);
+  Py_DECREF(mod);
+  if (out == NULL) {
+    PyErr_Print();
+    return -1;
+  }
+
+  long n;
+  if (!PyArg_ParseTuple(out, "ll", &n, steps)) {
+    PyErr_Print();
+    n = -1;
+  }
+  Py_DECREF(out);
+  return n;
+}
+
+// Same as call_longest, acquiring the GIL from any thread
+static long longest(long limit, long *steps) {
+  PyGILState_STATE gil = PyGILState_Ensure();
+  long n = call_longest(limit, steps);
+  PyGILState_Release(gil);
+  return n;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+var (
+	initOnce sync.Once
+	initErr  error
+)
+
+// embeddedRunner calls stopping.longest in an embedded Python interpreter.
+func embeddedRunner(pyDir string) func(int) (result, error) {
+	return func(limit int) (result, error) {
+		initOnce.Do(func() {
+			dir, _ := filepath.Abs(pyDir)
+			cDir := C.CString(dir)
+			defer C.free(unsafe.Pointer(cDir))
+			if C.init_python(cDir) != 0 {
+				initErr = fmt.Errorf("can't initialize Python")
+			}
+		})
+		if initErr != nil {
+			return result{}, initErr
+		}
+
+		var steps C.long
+		n := C.longest(C.long(limit), &steps)
+		if n == -1 {
+			return result{}, fmt.Errorf("stopping.longest failed")
+		}
+		return result{int(n), int(steps)}, nil
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !embedpy
+
+package main
+
+// embeddedRunner is a placeholder when built without embedded Python support.
+func embeddedRunner(pyDir string) func(int) (result, error) {
+	return func(int) (result, error) {
+		return result{}, errNotBuilt
+	}
+}
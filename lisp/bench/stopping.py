@@ -0,0 +1,29 @@
+"""Collatz stopping time, used by the benchmark driver (bench.go)"""
+import sys
+
+
+def stopping_time(n):
+    """Return number of Collatz steps it takes n to reach 1"""
+    steps = 0
+    while n != 1:
+        if n % 2 == 0:
+            n //= 2
+        else:
+            n = n * 3 + 1
+        steps += 1
+    return steps
+
+
+def longest(limit):
+    """Return (n, steps) for n < limit with the longest stopping time"""
+    best_n, best_steps = 1, 0
+    for n in range(1, limit):
+        steps = stopping_time(n)
+        if steps > best_steps:
+            best_n, best_steps = n, steps
+    return best_n, best_steps
+
+
+if __name__ == '__main__':
+    n, steps = longest(int(sys.argv[1]))
+    print(n, steps)
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// subprocessRunner runs stopping.py as a Python subprocess. Timing includes
+// the interpreter start up.
+func subprocessRunner(pyDir string) func(int) (result, error) {
+	script := filepath.Join(pyDir, "stopping.py")
+	return func(limit int) (result, error) {
+		out, err := exec.Command("python3", script, strconv.Itoa(limit)).Output()
+		if err != nil {
+			return result{}, err
+		}
+
+		var res result
+		if _, err := fmt.Sscan(string(out), &res.n, &res.steps); err != nil {
+			return result{}, fmt.Errorf("bad output %q: %w", out, err)
+		}
+		return res, nil
+	}
+}
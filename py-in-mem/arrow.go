@@ -0,0 +1,105 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/cdata"
+)
+
+// CallArrow calls the function with rec as a pyarrow.RecordBatch, the function
+// should return a pyarrow.RecordBatch which is returned as an arrow.Record.
+//
+// Data is passed in both directions with the Arrow C Data Interface, without
+// copying or serialization. You need pyarrow installed. The caller should
+// call Release on the returned record.
+func (f *PyFunc) CallArrow(rec arrow.Record) (arrow.Record, error) {
+	if rec == nil {
+		return nil, fmt.Errorf("nil record")
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	// Python writes to the C structs, allocate them in C memory
+	inArr, inSchema := newCArrow()
+	defer freeCArrow(inArr, inSchema)
+	outArr, outSchema := newCArrow()
+	defer freeCArrow(outArr, outSchema)
+
+	cdata.ExportArrowRecordBatch(rec, inArr, inSchema)
+
+	err := withGIL(func() error {
+		batch, err := importBatch(inArr, inSchema)
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(batch)
+
+		out, err := callObject(f.fn, batch)
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(out)
+
+		if typeName(out) != "RecordBatch" {
+			return fmt.Errorf("function returned %s, expected pyarrow.RecordBatch", typeName(out))
+		}
+
+		res, err := callMethodObject(out, "_export_to_c", uintptr(unsafe.Pointer(outArr)), uintptr(unsafe.Pointer(outSchema)))
+		if err != nil {
+			return err
+		}
+		C.Py_DecRef(res)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cdata.ImportCRecordBatch(outArr, outSchema)
+}
+
+// importBatch creates a pyarrow.RecordBatch from C structs. pyarrow takes
+// ownership of the data, on error the structs are released. Must be called
+// with the GIL held.
+func importBatch(arr *cdata.CArrowArray, schema *cdata.CArrowSchema) (*C.PyObject, error) {
+	cls, err := importAttr("pyarrow", "RecordBatch")
+	if err != nil {
+		cdata.ReleaseCArrowArray(arr)
+		cdata.ReleaseCArrowSchema(schema)
+		return nil, err
+	}
+	defer C.Py_DecRef(cls)
+
+	batch, err := callMethodObject(cls, "_import_from_c", uintptr(unsafe.Pointer(arr)), uintptr(unsafe.Pointer(schema)))
+	if err != nil {
+		cdata.ReleaseCArrowArray(arr)
+		cdata.ReleaseCArrowSchema(schema)
+		return nil, err
+	}
+	return batch, nil
+}
+
+// newCArrow allocates zeroed C Data Interface structs in C memory.
+func newCArrow() (*cdata.CArrowArray, *cdata.CArrowSchema) {
+	arr := C.calloc(1, C.size_t(unsafe.Sizeof(cdata.CArrowArray{})))
+	schema := C.calloc(1, C.size_t(unsafe.Sizeof(cdata.CArrowSchema{})))
+	return (*cdata.CArrowArray)(arr), (*cdata.CArrowSchema)(schema)
+}
+
+func freeCArrow(arr *cdata.CArrowArray, schema *cdata.CArrowSchema) {
+	C.free(unsafe.Pointer(arr))
+	C.free(unsafe.Pointer(schema))
+}
@@ -0,0 +1,22 @@
+package outliers
+
+// AsyncResult is the result of PyFunc.CallAsync
+type AsyncResult struct {
+	Value interface{}
+	Err   error
+}
+
+// CallAsync is like Call but doesn't wait for the result, which is sent on
+// the returned channel. The channel is buffered, you don't have to read from
+// it.
+//
+// Async Python functions run concurrently on the event loop, other functions
+// run in their own goroutine and are serialized by the GIL.
+func (f *PyFunc) CallAsync(args ...interface{}) <-chan AsyncResult {
+	ch := make(chan AsyncResult, 1)
+	go func() {
+		out, err := f.Call(args...)
+		ch <- AsyncResult{out, err}
+	}()
+	return ch
+}
@@ -0,0 +1,138 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+var callbacks struct {
+	sync.RWMutex
+	funcs map[string]reflect.Value
+}
+
+// RegisterFunc makes fn callable from Python code as go.<name> in the "go"
+// module (main interpreter only), for example to report progress:
+//
+//	outliers.RegisterFunc("progress", func(pct float64) { ... })
+//
+// and in Python
+//
+//	import go
+//	go.progress(0.5)
+//
+// Arguments are converted from Python like PyFunc.Call results and then to
+// fn's parameter types. fn can return a value, an error or both, a non-nil
+// error (or a panic) raises RuntimeError in Python.
+func RegisterFunc(name string, fn interface{}) error {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func {
+		return fmt.Errorf("%T is not a function", fn)
+	}
+
+	typ := rv.Type()
+	if typ.IsVariadic() {
+		return fmt.Errorf("variadic functions not supported")
+	}
+	switch typ.NumOut() {
+	case 0, 1:
+	case 2:
+		if typ.Out(1) != errorType {
+			return fmt.Errorf("second return value must be an error")
+		}
+	default:
+		return fmt.Errorf("too many return values")
+	}
+
+	if err := initialize(); err != nil {
+		return err
+	}
+
+	callbacks.Lock()
+	if callbacks.funcs == nil {
+		callbacks.funcs = make(map[string]reflect.Value)
+	}
+	callbacks.funcs[name] = rv
+	callbacks.Unlock()
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	return cError(C.register_func(cName))
+}
+
+//export goCallback
+func goCallback(self, args *C.PyObject) *C.PyObject {
+	out, err := callGo(self, args)
+	if err != nil {
+		msg := C.CString(err.Error())
+		defer C.free(unsafe.Pointer(msg))
+
+		C.py_raise(msg)
+		return nil
+	}
+	return out
+}
+
+// callGo calls the Go function registered as self with Python args, called
+// from Python with the GIL held.
+func callGo(self, args *C.PyObject) (out *C.PyObject, err error) {
+	v, err := fromPython(self)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := v.(string)
+
+	callbacks.RLock()
+	fn, ok := callbacks.funcs[name]
+	callbacks.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("go.%s: not registered", name)
+	}
+
+	v, err = fromPython(args)
+	if err != nil {
+		return nil, fmt.Errorf("go.%s: %w", name, err)
+	}
+	pyArgs, _ := v.([]interface{})
+
+	typ := fn.Type()
+	if len(pyArgs) != typ.NumIn() {
+		return nil, fmt.Errorf("go.%s: expected %d arguments, got %d", name, typ.NumIn(), len(pyArgs))
+	}
+
+	in := make([]reflect.Value, len(pyArgs))
+	for i, arg := range pyArgs {
+		in[i] = reflect.New(typ.In(i)).Elem()
+		if err := setValue(in[i], arg); err != nil {
+			return nil, fmt.Errorf("go.%s: argument %d: %w", name, i, err)
+		}
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			out, err = nil, fmt.Errorf("go.%s: panic: %v", name, e)
+		}
+	}()
+
+	results := fn.Call(in)
+	if n := len(results); n > 0 && typ.Out(n-1) == errorType {
+		if e := results[n-1].Interface(); e != nil {
+			return nil, fmt.Errorf("go.%s: %w", name, e.(error))
+		}
+		results = results[:n-1]
+	}
+
+	if len(results) == 0 {
+		return C.py_none(), nil
+	}
+	return toPython(results[0].Interface())
+}
@@ -0,0 +1,18 @@
+//go:build py_abi3 && !py_dlopen
+
+package outliers
+
+// Build the glue with the limited API of Python 3.8 (see "Stable ABI" in the
+// Python docs). numpy is used via its Python API since its C API isn't part of
+// the limited API.
+//
+// Executables can't link with libpython3.so on Linux (it only forwards to
+// libpython3.x), so we still link with the installed libpython3.x. Combine
+// with loading libpython at runtime to run the same binary with other Python
+// versions.
+
+/*
+#cgo pkg-config: python3-embed
+#cgo CFLAGS: -DPy_LIMITED_API=0x03080000
+*/
+import "C"
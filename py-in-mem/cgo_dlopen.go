@@ -0,0 +1,105 @@
+//go:build py_dlopen
+
+package outliers
+
+// Build the glue with the limited API (see cgo_abi3.go) without linking with
+// libpython, it's loaded with dlopen (LoadLibrary on Windows) on
+// initialization (see WithLibPython). On Windows Py_NO_ENABLE_SHARED stops
+// Python.h from declaring the functions we define in dlopen.c as DLL imports.
+// The Python include directory is passed in CGO_CFLAGS, e.g.
+//
+//	$ export CGO_CFLAGS="$(pkg-config --cflags python3-embed)"
+//	$ go build -tags py_dlopen
+
+/*
+#cgo CFLAGS: -DPy_LIMITED_API=0x03080000 -DPY_DLOPEN
+#cgo linux LDFLAGS: -ldl
+#cgo windows CFLAGS: -DPy_NO_ENABLE_SHARED
+#include <stdlib.h>
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// libPythonScript prints the path of libpython
+const libPythonScript = `
+import os, sys, sysconfig
+if os.name == 'nt':
+    print(os.path.join(sys.base_prefix, 'python%d%d.dll' % sys.version_info[:2]))
+else:
+    print(os.path.join(sysconfig.get_config_var('LIBDIR'), sysconfig.get_config_var('LDLIBRARY')))
+`
+
+// loadLibPython loads libpython from path. If path is empty it tries shared
+// libraries of Python 3.8 and later (newest first) in home and in the dynamic
+// linker search path, then asks python3 (python on Windows) in $PATH where
+// its libpython is.
+func loadLibPython(path, home string) error {
+	if path != "" {
+		if err := dlopenPython(path); err != nil {
+			return fmt.Errorf("can't load libpython: %w", err)
+		}
+		return nil
+	}
+
+	names := libPythonNames(runtime.GOOS)
+	var candidates []string
+	if home != "" {
+		// DLLs are in the installation directory on Windows
+		libDir := filepath.Join(home, "lib")
+		if runtime.GOOS == "windows" {
+			libDir = home
+		}
+		for _, name := range names {
+			candidates = append(candidates, filepath.Join(libDir, name))
+		}
+	}
+	candidates = append(candidates, names...)
+
+	python := "python3"
+	if runtime.GOOS == "windows" {
+		python = "python"
+	}
+	if out, err := exec.Command(python, "-c", libPythonScript).Output(); err == nil {
+		candidates = append(candidates, strings.TrimSpace(string(out)))
+	}
+
+	var errs []string
+	for _, path := range candidates {
+		err := dlopenPython(path)
+		if err == nil {
+			return nil
+		}
+		// Don't list all the missing files
+		msg := strings.ToLower(err.Error())
+		if !strings.Contains(msg, "no such file") && !strings.Contains(msg, "could not be found") {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	msg := "can't find libpython (Python 3.8 or later), install Python or set its path with WithLibPython"
+	if len(errs) > 0 {
+		msg += ": " + strings.Join(errs, "; ")
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+func dlopenPython(path string) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	cErr := C.load_python(cPath)
+	if cErr != nil {
+		defer C.free(unsafe.Pointer(cErr))
+		return fmt.Errorf("%s", C.GoString(cErr))
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build py_nonumpy
+
+package outliers
+
+// Build the glue without numpy headers, numpy is used via its Python API as
+// with the limited API (see cgo_abi3.go). Combine with any of the other build
+// tags.
+//
+// numpy is optional at runtime in this mode and with the "py_abi3" and
+// "py_dlopen" tags: when it isn't installed Python functions get values as a
+// memoryview (e.g. memoryview.cast('d') of data for Detect) and can return
+// any sequence of int as indices.
+
+/*
+#cgo CFLAGS: -DPY_NO_NUMPY
+*/
+import "C"
@@ -0,0 +1,12 @@
+//go:build !pyconfig && !py_abi3 && !py_dlopen
+
+package outliers
+
+// Python flags from pkg-config, numpy include directory is passed in
+// CGO_CFLAGS (see Makefile). Use "go generate" and "-tags pyconfig" to
+// detect both from the installed Python instead.
+
+/*
+#cgo pkg-config: python3-embed
+*/
+import "C"
@@ -0,0 +1,68 @@
+package outliers
+
+/*
+#include <stdlib.h>
+
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Config is the interpreter configuration, it's mapped onto Python's PyConfig
+// (see https://docs.python.org/3/c-api/init_config.html). The zero value is
+// Python's default configuration.
+//
+// PyConfig isn't part of the limited API, with the "py_abi3" or "py_dlopen"
+// build tags Home, NoUserSite, OptimizationLevel and the hash seed are set
+// with the matching PYTHON* environment variables and ProgramName, Isolated
+// and IgnoreEnvironment are not supported.
+type Config struct {
+	ProgramName       string // Program name (sys.argv[0] default, sys.executable lookup)
+	Home              string // Python home directory (PYTHONHOME)
+	Isolated          bool   // Isolated mode, implies IgnoreEnvironment and NoUserSite (-I)
+	IgnoreEnvironment bool   // Ignore PYTHON* environment variables (-E)
+	NoUserSite        bool   // Don't add the user site-packages to sys.path (-s)
+	OptimizationLevel int    // 1 removes asserts, 2 also docstrings (-O, -OO)
+	UseHashSeed       bool   // Use HashSeed instead of a random seed
+	HashSeed          uint32 // str, bytes and datetime hash seed (PYTHONHASHSEED)
+}
+
+// WithConfig initializes Python with cfg. WithCondaEnv overrides cfg.Home.
+func WithConfig(cfg Config) InitOption {
+	return func(c *initConfig) {
+		c.config = cfg
+	}
+}
+
+// initInterpreter initializes the main interpreter with cfg
+func initInterpreter(cfg Config, signals bool) error {
+	c := C.py_config_t{
+		isolated:                cBool(cfg.Isolated),
+		use_environment:         cBool(!cfg.IgnoreEnvironment),
+		user_site_directory:     cBool(!cfg.NoUserSite),
+		optimization_level:      C.int(cfg.OptimizationLevel),
+		use_hash_seed:           cBool(cfg.UseHashSeed),
+		hash_seed:               C.ulong(cfg.HashSeed),
+		install_signal_handlers: cBool(signals),
+	}
+	if cfg.ProgramName != "" {
+		c.program_name = C.CString(cfg.ProgramName)
+		defer C.free(unsafe.Pointer(c.program_name))
+	}
+	if cfg.Home != "" {
+		c.home = C.CString(cfg.Home)
+		defer C.free(unsafe.Pointer(c.home))
+	}
+
+	return cError(C.init_python(&c))
+}
+
+func cBool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,456 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// toPython converts v to a new reference to a Python object, must be called
+// with the GIL held.
+func toPython(v interface{}) (*C.PyObject, error) {
+	var obj *C.PyObject
+	switch v := v.(type) {
+	case nil:
+		return C.py_none(), nil
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		obj = C.PyBool_FromLong(C.long(b))
+	case int:
+		obj = C.PyLong_FromLongLong(C.longlong(v))
+	case int8:
+		obj = C.PyLong_FromLongLong(C.longlong(v))
+	case int16:
+		obj = C.PyLong_FromLongLong(C.longlong(v))
+	case int32:
+		obj = C.PyLong_FromLongLong(C.longlong(v))
+	case int64:
+		obj = C.PyLong_FromLongLong(C.longlong(v))
+	case uint:
+		obj = C.PyLong_FromUnsignedLongLong(C.ulonglong(v))
+	case uint8:
+		obj = C.PyLong_FromUnsignedLongLong(C.ulonglong(v))
+	case uint16:
+		obj = C.PyLong_FromUnsignedLongLong(C.ulonglong(v))
+	case uint32:
+		obj = C.PyLong_FromUnsignedLongLong(C.ulonglong(v))
+	case uint64:
+		obj = C.PyLong_FromUnsignedLongLong(C.ulonglong(v))
+	case uintptr:
+		obj = C.PyLong_FromUnsignedLongLong(C.ulonglong(v))
+	case float32:
+		obj = C.PyFloat_FromDouble(C.double(v))
+	case float64:
+		obj = C.PyFloat_FromDouble(C.double(v))
+	case complex64:
+		obj = C.PyComplex_FromDoubles(C.double(real(v)), C.double(imag(v)))
+	case complex128:
+		obj = C.PyComplex_FromDoubles(C.double(real(v)), C.double(imag(v)))
+	case string:
+		cs, size := cString(v)
+		obj = C.PyUnicode_FromStringAndSize(cs, size)
+	case []byte:
+		if v == nil {
+			return C.py_none(), nil
+		}
+		cs := (*C.char)(unsafe.Pointer(unsafe.SliceData(v)))
+		obj = C.PyBytes_FromStringAndSize(cs, C.Py_ssize_t(len(v)))
+	case *DataFrame:
+		return v.toPython()
+	case Pickled:
+		return unpickle(v)
+	case *Object:
+		return v.newRef()
+	case *MemoryView:
+		return v.newRef()
+	default:
+		if obj, ok, err := codecToPython(v); ok {
+			return obj, err
+		}
+		return reflectToPython(reflect.ValueOf(v))
+	}
+
+	if obj == nil {
+		return nil, cError(C.py_error())
+	}
+	return obj, nil
+}
+
+// prepare runs the Go side of converting v to Python: registered codecs
+// encode values to Pickled and structs become maps. Calling it before
+// acquiring the GIL lets this Go code run while other goroutines call Python.
+func prepare(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128, string, []byte, []float64, []int, []string, []complex128,
+		*DataFrame, Pickled, *Object, *MemoryView:
+		return v, nil
+	}
+
+	if c, ok := goTypeCodec(reflect.TypeOf(v)); ok {
+		return c.Encode(v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Struct:
+		m := structToMap(rv)
+		for k, val := range m {
+			pv, err := prepare(val)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			m[k] = pv
+		}
+		return m, nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return prepare(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if (rv.Kind() == reflect.Slice && rv.IsNil()) || !needsPrepare(rv.Type().Elem()) {
+			return v, nil
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			pv, err := prepare(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("%d: %w", i, err)
+			}
+			out[i] = pv
+		}
+		return out, nil
+	case reflect.Map:
+		typ := rv.Type()
+		if rv.IsNil() || (!needsPrepare(typ.Key()) && !needsPrepare(typ.Elem())) {
+			return v, nil
+		}
+		out := make(map[interface{}]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := prepare(iter.Key().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+			val, err := prepare(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", iter.Key(), err)
+			}
+			out[key] = val
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// needsPrepare returns true if values of typ might be changed by prepare.
+func needsPrepare(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Interface, reflect.Struct, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	_, ok := goTypeCodec(typ)
+	return ok
+}
+
+// prepareArgs runs prepare on args and kwargs values.
+func prepareArgs(args []interface{}, kwargs map[string]interface{}) ([]interface{}, map[string]interface{}, error) {
+	pArgs := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := prepare(arg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		pArgs[i] = v
+	}
+
+	var pKw map[string]interface{}
+	if len(kwargs) > 0 {
+		pKw = make(map[string]interface{}, len(kwargs))
+		for k, arg := range kwargs {
+			v, err := prepare(arg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("argument %s: %w", k, err)
+			}
+			pKw[k] = v
+		}
+	}
+	return pArgs, pKw, nil
+}
+
+// reflectToPython converts slices, arrays, maps, structs and pointers.
+func reflectToPython(rv reflect.Value) (*C.PyObject, error) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return C.py_none(), nil
+		}
+
+		list := C.PyList_New(C.Py_ssize_t(rv.Len()))
+		if list == nil {
+			return nil, cError(C.py_error())
+		}
+		for i := 0; i < rv.Len(); i++ {
+			item, err := toPython(rv.Index(i).Interface())
+			if err != nil {
+				C.Py_DecRef(list)
+				return nil, fmt.Errorf("%d: %w", i, err)
+			}
+			C.PyList_SetItem(list, C.Py_ssize_t(i), item) // steals item
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return C.py_none(), nil
+		}
+
+		dict := C.PyDict_New()
+		if dict == nil {
+			return nil, cError(C.py_error())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := dictSet(dict, iter.Key().Interface(), iter.Value().Interface()); err != nil {
+				C.Py_DecRef(dict)
+				return nil, err
+			}
+		}
+		return dict, nil
+	case reflect.Struct:
+		return reflectToPython(reflect.ValueOf(structToMap(rv)))
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return C.py_none(), nil
+		}
+		return toPython(rv.Elem().Interface())
+	}
+
+	if !rv.IsValid() {
+		return C.py_none(), nil
+	}
+	return nil, fmt.Errorf("can't convert %s to Python", rv.Type())
+}
+
+// dictSet sets dict[key] = val.
+func dictSet(dict *C.PyObject, key, val interface{}) error {
+	pyKey, err := toPython(key)
+	if err != nil {
+		return fmt.Errorf("key %v: %w", key, err)
+	}
+	defer C.Py_DecRef(pyKey)
+
+	pyVal, err := toPython(val)
+	if err != nil {
+		return fmt.Errorf("%v: %w", key, err)
+	}
+	defer C.Py_DecRef(pyVal)
+
+	if C.PyDict_SetItem(dict, pyKey, pyVal) != 0 {
+		return cError(C.py_error())
+	}
+	return nil
+}
+
+// toPyTuple converts args to a Python tuple.
+func toPyTuple(args []interface{}) (*C.PyObject, error) {
+	tuple := C.PyTuple_New(C.Py_ssize_t(len(args)))
+	if tuple == nil {
+		return nil, cError(C.py_error())
+	}
+
+	for i, arg := range args {
+		obj, err := toPython(arg)
+		if err != nil {
+			C.Py_DecRef(tuple)
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		C.PyTuple_SetItem(tuple, C.Py_ssize_t(i), obj) // steals obj
+	}
+	return tuple, nil
+}
+
+// fromPython converts a Python object to a Go value, must be called with the
+// GIL held. obj is borrowed.
+func fromPython(obj *C.PyObject) (interface{}, error) {
+	switch C.py_kind(obj) {
+	case C.PY_NONE:
+		return nil, nil
+	case C.PY_BOOL:
+		return C.PyObject_IsTrue(obj) == 1, nil
+	case C.PY_INT:
+		v := C.PyLong_AsLongLong(obj)
+		if v == -1 && C.PyErr_Occurred() != nil {
+			return nil, cError(C.py_error())
+		}
+		return int(v), nil
+	case C.PY_FLOAT:
+		return float64(C.PyFloat_AsDouble(obj)), nil
+	case C.PY_COMPLEX:
+		return complex(float64(C.PyComplex_RealAsDouble(obj)), float64(C.PyComplex_ImagAsDouble(obj))), nil
+	case C.PY_STR:
+		// PyUnicode_AsUTF8AndSize isn't in the limited API before 3.10
+		data := C.PyUnicode_AsUTF8String(obj)
+		if data == nil {
+			return nil, cError(C.py_error())
+		}
+		defer C.Py_DecRef(data)
+
+		var (
+			cs   *C.char
+			size C.Py_ssize_t
+		)
+		C.PyBytes_AsStringAndSize(data, &cs, &size)
+		return C.GoStringN(cs, C.int(size)), nil
+	case C.PY_BYTES:
+		return bytesFromPython(obj), nil
+	case C.PY_BUFFER:
+		// Copy to bytes, also makes a non contiguous memoryview contiguous
+		data := C.PyBytes_FromObject(obj)
+		if data == nil {
+			return nil, cError(C.py_error())
+		}
+		defer C.Py_DecRef(data)
+
+		return bytesFromPython(data), nil
+	case C.PY_LIST, C.PY_TUPLE:
+		return seqFromPython(obj)
+	case C.PY_DICT:
+		return dictFromPython(obj)
+	}
+
+	if isDataFrame(obj) {
+		return dataFrameFromPython(obj)
+	}
+	if data := C.complex128_bytes(obj); data != nil {
+		defer C.Py_DecRef(data)
+		return complex128sFromPython(data), nil
+	}
+	if C.PyErr_Occurred() != nil {
+		return nil, cError(C.py_error())
+	}
+	return tolist(obj)
+}
+
+// complex128sFromPython returns a copy of the complex128 values in obj, a
+// bytes object (see complex128_bytes).
+func complex128sFromPython(obj *C.PyObject) []complex128 {
+	var (
+		cs   *C.char
+		size C.Py_ssize_t
+	)
+	C.PyBytes_AsStringAndSize(obj, &cs, &size)
+	out := make([]complex128, int(size)/16)
+	copy(out, unsafe.Slice((*complex128)(unsafe.Pointer(cs)), len(out)))
+	return out
+}
+
+// bytesFromPython returns a copy of the data in obj, a bytes object.
+func bytesFromPython(obj *C.PyObject) []byte {
+	var (
+		cs   *C.char
+		size C.Py_ssize_t
+	)
+	C.PyBytes_AsStringAndSize(obj, &cs, &size)
+	return C.GoBytes(unsafe.Pointer(cs), C.int(size))
+}
+
+// seqFromPython converts a list or a tuple to []interface{}.
+func seqFromPython(obj *C.PyObject) (interface{}, error) {
+	size := C.PySequence_Size(obj)
+	if size < 0 {
+		return nil, cError(C.py_error())
+	}
+
+	out := make([]interface{}, size)
+	for i := range out {
+		item := C.PySequence_GetItem(obj, C.Py_ssize_t(i)) // new reference
+		if item == nil {
+			return nil, cError(C.py_error())
+		}
+		v, err := fromPython(item)
+		C.Py_DecRef(item)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", i, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// dictFromPython converts a dict to map[string]interface{} if all keys are
+// strings, otherwise to map[interface{}]interface{}.
+func dictFromPython(obj *C.PyObject) (interface{}, error) {
+	var (
+		pos        C.Py_ssize_t
+		key, value *C.PyObject // borrowed
+	)
+
+	m := make(map[interface{}]interface{})
+	strKeys := true
+	for C.PyDict_Next(obj, &pos, &key, &value) != 0 {
+		k, err := fromPython(key)
+		if err != nil {
+			return nil, fmt.Errorf("key: %w", err)
+		}
+		if !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("unhashable key type: %T", k)
+		}
+		v, err := fromPython(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", k, err)
+		}
+		if _, ok := k.(string); !ok {
+			strKeys = false
+		}
+		m[k] = v
+	}
+
+	if !strKeys {
+		return m, nil
+	}
+
+	sm := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		sm[k.(string)] = v
+	}
+	return sm, nil
+}
+
+// tolist converts objects with a tolist method (numpy arrays & scalars), other
+// objects are pickled.
+func tolist(obj *C.PyObject) (interface{}, error) {
+	name := C.CString("tolist")
+	defer C.free(unsafe.Pointer(name))
+
+	if C.PyObject_HasAttrString(obj, name) == 0 {
+		return pickledFromPython(obj)
+	}
+
+	list := C.py_call_method(obj, name)
+	if list == nil {
+		return nil, cError(C.py_error())
+	}
+	defer C.Py_DecRef(list)
+
+	if C.py_kind(list) == C.PY_OTHER {
+		return nil, fmt.Errorf("can't convert %s to Go", typeName(obj))
+	}
+	return fromPython(list)
+}
+
+// typeName returns the Python type name of obj.
+func typeName(obj *C.PyObject) string {
+	cs := C.py_type_name(obj)
+	defer C.free(unsafe.Pointer(cs))
+
+	return C.GoString(cs)
+}
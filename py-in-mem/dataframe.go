@@ -0,0 +1,236 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// DataFrame is a pandas DataFrame. PyFunc.Call converts *DataFrame arguments
+// to pandas.DataFrame and pandas.DataFrame results to *DataFrame.
+type DataFrame struct {
+	Columns []string                 // Column names, in order
+	Data    map[string][]interface{} // Column name -> values
+}
+
+// NewDataFrame returns a DataFrame from rows, which must be a slice of
+// structs. Columns are the exported field names or their "py" tag (see
+// Decode).
+func NewDataFrame(rows interface{}) (*DataFrame, error) {
+	rv := reflect.ValueOf(rows)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a slice of structs", rows)
+	}
+
+	fields := structFields(rv.Type().Elem())
+	df := DataFrame{
+		Columns: make([]string, len(fields)),
+		Data:    make(map[string][]interface{}, len(fields)),
+	}
+	for i, f := range fields {
+		df.Columns[i] = f.Name
+		col := make([]interface{}, rv.Len())
+		for r := range col {
+			col[r] = rv.Index(r).FieldByIndex(f.Index).Interface()
+		}
+		df.Data[f.Name] = col
+	}
+	return &df, nil
+}
+
+// Len returns the number of rows in df
+func (df *DataFrame) Len() int {
+	if len(df.Columns) == 0 {
+		return 0
+	}
+	return len(df.Data[df.Columns[0]])
+}
+
+// Decode stores df rows in out, which must be a pointer to a slice of
+// structs. Columns are matched to exported field names (or "py" tags),
+// columns without a matching field are ignored.
+func (df *DataFrame) Decode(out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice || rv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a slice of structs", out)
+	}
+
+	typ := rv.Elem().Type().Elem()
+	rows := reflect.MakeSlice(rv.Elem().Type(), df.Len(), df.Len())
+	for _, f := range structFields(typ) {
+		col, ok := df.Data[f.Name]
+		if !ok {
+			continue
+		}
+		for r, v := range col {
+			if err := setValue(rows.Index(r).FieldByIndex(f.Index), v); err != nil {
+				return fmt.Errorf("%s[%d]: %w", f.Name, r, err)
+			}
+		}
+	}
+	rv.Elem().Set(rows)
+	return nil
+}
+
+// toPython converts df to pandas.DataFrame, must be called with the GIL held.
+func (df *DataFrame) toPython() (*C.PyObject, error) {
+	cls, err := importAttr("pandas", "DataFrame")
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(cls)
+
+	args, err := toPyTuple([]interface{}{df.Data})
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(args)
+
+	kw, err := toPython(map[string]interface{}{"columns": df.Columns})
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(kw)
+
+	obj := C.PyObject_Call(cls, args, kw)
+	if obj == nil {
+		return nil, cError(C.py_error())
+	}
+	return obj, nil
+}
+
+// isDataFrame returns true if obj is a pandas.DataFrame.
+func isDataFrame(obj *C.PyObject) bool {
+	if typeName(obj) != "DataFrame" {
+		return false
+	}
+
+	name := C.CString("to_dict")
+	defer C.free(unsafe.Pointer(name))
+
+	return C.PyObject_HasAttrString(obj, name) == 1
+}
+
+// dataFrameFromPython converts a pandas.DataFrame to *DataFrame, must be called
+// with the GIL held.
+func dataFrameFromPython(obj *C.PyObject) (*DataFrame, error) {
+	cols, err := callMethod(obj, "to_dict", "list") // column -> values
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := callMethod(obj, "columns.tolist")
+	if err != nil {
+		return nil, err
+	}
+
+	df := DataFrame{Data: make(map[string][]interface{})}
+	for _, name := range names.([]interface{}) {
+		df.Columns = append(df.Columns, fmt.Sprint(name))
+	}
+
+	iter := reflect.ValueOf(cols).MapRange()
+	for iter.Next() {
+		values, ok := iter.Value().Interface().([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("column %v: bad values: %T", iter.Key(), iter.Value().Interface())
+		}
+		df.Data[fmt.Sprint(iter.Key().Interface())] = values
+	}
+	return &df, nil
+}
+
+// importAttr returns a new reference to module.name, must be called with the
+// GIL held.
+func importAttr(module, name string) (*C.PyObject, error) {
+	cMod := C.CString(module)
+	defer C.free(unsafe.Pointer(cMod))
+
+	mod := C.PyImport_ImportModule(cMod)
+	if mod == nil {
+		return nil, cError(C.py_error())
+	}
+	defer C.Py_DecRef(mod)
+
+	return getAttr(mod, name)
+}
+
+// getAttr returns a new reference to obj.name, must be called with the GIL
+// held.
+func getAttr(obj *C.PyObject, name string) (*C.PyObject, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	attr := C.PyObject_GetAttrString(obj, cName)
+	if attr == nil {
+		return nil, cError(C.py_error())
+	}
+	return attr, nil
+}
+
+// callMethod calls obj.path(args...) and converts the result to Go, path can
+// be dotted (e.g. "columns.tolist"). Must be called with the GIL held.
+func callMethod(obj *C.PyObject, path string, args ...interface{}) (interface{}, error) {
+	out, err := callMethodObject(obj, path, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(out)
+
+	return fromPython(out)
+}
+
+// callMethodObject calls obj.path(args...) and returns a new reference to the
+// result. Must be called with the GIL held.
+func callMethodObject(obj *C.PyObject, path string, args ...interface{}) (*C.PyObject, error) {
+	C.Py_IncRef(obj)
+	fn := obj
+	for _, name := range strings.Split(path, ".") {
+		attr, err := getAttr(fn, name)
+		C.Py_DecRef(fn)
+		if err != nil {
+			return nil, err
+		}
+		fn = attr
+	}
+	defer C.Py_DecRef(fn)
+
+	pyArgs, err := toPyTuple(args)
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(pyArgs)
+
+	out := C.PyObject_Call(fn, pyArgs, nil)
+	if out == nil {
+		return nil, cError(C.py_error())
+	}
+	return out, nil
+}
+
+// callObject calls fn(args...) with Python objects and returns a new
+// reference to the result. Must be called with the GIL held.
+func callObject(fn *C.PyObject, args ...*C.PyObject) (*C.PyObject, error) {
+	tuple := C.PyTuple_New(C.Py_ssize_t(len(args)))
+	if tuple == nil {
+		return nil, cError(C.py_error())
+	}
+	defer C.Py_DecRef(tuple)
+
+	for i, arg := range args {
+		C.Py_IncRef(arg)
+		C.PyTuple_SetItem(tuple, C.Py_ssize_t(i), arg) // steals arg
+	}
+
+	out := C.PyObject_Call(fn, tuple, nil)
+	if out == nil {
+		return nil, cError(C.py_error())
+	}
+	return out, nil
+}
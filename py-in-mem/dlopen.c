@@ -0,0 +1,243 @@
+//go:build py_dlopen
+
+#include "glue.h"
+
+#include <stdio.h>
+#include <string.h>
+
+#ifdef _WIN32
+#include <windows.h>
+
+static void *lib_open(const char *path) { return LoadLibraryA(path); }
+
+static void *lib_sym(void *lib, const char *name) {
+  return (void *)GetProcAddress((HMODULE)lib, name);
+}
+
+static void lib_close(void *lib) { FreeLibrary((HMODULE)lib); }
+
+// Error loading path, e.g. "python311.dll: The specified module could not be
+// found."
+static char *lib_error(const char *path) {
+  char msg[256] = "unknown error";
+  char buf[512];
+  FormatMessageA(FORMAT_MESSAGE_FROM_SYSTEM | FORMAT_MESSAGE_IGNORE_INSERTS,
+                 NULL, GetLastError(), 0, msg, sizeof(msg), NULL);
+  msg[strcspn(msg, "\r\n")] = '\0';
+  snprintf(buf, sizeof(buf), "%s: %s", path, msg);
+  return strdup(buf);
+}
+#else
+#include <dlfcn.h>
+
+// RTLD_GLOBAL so extension modules (e.g. numpy) find Python symbols
+static void *lib_open(const char *path) {
+  return dlopen(path, RTLD_NOW | RTLD_GLOBAL);
+}
+
+static void *lib_sym(void *lib, const char *name) { return dlsym(lib, name); }
+
+static void lib_close(void *lib) { dlclose(lib); }
+
+// Error loading path, dlerror already has the path
+static char *lib_error(const char *path) { return strdup(dlerror()); }
+#endif
+
+// Python functions used by the glue and the Go code, each is defined here
+// with the same signature and calls libpython through a pointer resolved by
+// load_python. Data and variadic functions are redirected in dlopen.h.
+//
+// PY_FUNC(return type, name, parameters, arguments)
+// PY_VOID(name, parameters, arguments)
+
+#if Py_LIMITED_API + 0 >= 0x03090000
+#define PY_FUNCS_39                                                           \
+  PY_FUNC(PyObject *, PyCMethod_New,                                          \
+          (PyMethodDef * ml, PyObject * self, PyObject * mod,                 \
+           PyTypeObject * cls),                                               \
+          (ml, self, mod, cls))                                               \
+  PY_FUNC(PyInterpreterState *, PyThreadState_GetInterpreter,                 \
+          (PyThreadState * ts), (ts))                                         \
+  PY_FUNC(PyThreadState *, Py_NewInterpreter, (void), ())
+#else
+#define PY_FUNCS_39                                                           \
+  PY_FUNC(PyObject *, PyCFunction_NewEx,                                      \
+          (PyMethodDef * ml, PyObject * self, PyObject * mod),                \
+          (ml, self, mod))
+#endif
+
+#define PY_FUNCS                                                              \
+  PY_FUNCS_39                                                                 \
+  PY_FUNC(PyObject *, PyBool_FromLong, (long v), (v))                         \
+  PY_FUNC(char *, PyBytes_AsString, (PyObject * o), (o))                      \
+  PY_FUNC(int, PyBytes_AsStringAndSize,                                       \
+          (PyObject * o, char **s, Py_ssize_t *len), (o, s, len))             \
+  PY_FUNC(PyObject *, PyBytes_FromObject, (PyObject * o), (o))                \
+  PY_FUNC(PyObject *, PyBytes_FromStringAndSize,                              \
+          (const char *s, Py_ssize_t len), (s, len))                          \
+  PY_FUNC(Py_ssize_t, PyBytes_Size, (PyObject * o), (o))                      \
+  PY_FUNC(PyObject *, PyComplex_FromDoubles, (double real, double imag),      \
+          (real, imag))                                                       \
+  PY_FUNC(double, PyComplex_ImagAsDouble, (PyObject * o), (o))                \
+  PY_FUNC(double, PyComplex_RealAsDouble, (PyObject * o), (o))                \
+  PY_FUNC(PyObject *, PyDict_New, (void), ())                                 \
+  PY_FUNC(int, PyDict_Next,                                                   \
+          (PyObject * d, Py_ssize_t *pos, PyObject **key, PyObject **value),  \
+          (d, pos, key, value))                                               \
+  PY_FUNC(int, PyDict_SetItem, (PyObject * d, PyObject * key, PyObject * v),  \
+          (d, key, v))                                                        \
+  PY_FUNC(int, PyDict_SetItemString,                                          \
+          (PyObject * d, const char *key, PyObject *v), (d, key, v))          \
+  PY_VOID(PyErr_Clear, (void), ())                                            \
+  PY_FUNC(int, PyErr_ExceptionMatches, (PyObject * exc), (exc))              \
+  PY_VOID(PyErr_Fetch, (PyObject * *type, PyObject **value, PyObject **tb),   \
+          (type, value, tb))                                                  \
+  PY_FUNC(PyObject *, PyErr_NoMemory, (void), ())                             \
+  PY_VOID(PyErr_NormalizeException,                                           \
+          (PyObject * *type, PyObject **value, PyObject **tb),                \
+          (type, value, tb))                                                  \
+  PY_FUNC(PyObject *, PyErr_Occurred, (void), ())                             \
+  PY_VOID(PyErr_SetString, (PyObject * exc, const char *msg), (exc, msg))     \
+  PY_FUNC(PyObject *, PyEval_EvalCode,                                        \
+          (PyObject * code, PyObject * globals, PyObject * locals),           \
+          (code, globals, locals))                                            \
+  PY_FUNC(PyObject *, PyEval_GetBuiltins, (void), ())                         \
+  PY_VOID(PyEval_RestoreThread, (PyThreadState * ts), (ts))                   \
+  PY_FUNC(PyThreadState *, PyEval_SaveThread, (void), ())                     \
+  PY_FUNC(double, PyFloat_AsDouble, (PyObject * o), (o))                      \
+  PY_FUNC(PyObject *, PyFloat_FromDouble, (double v), (v))                    \
+  PY_FUNC(PyGILState_STATE, PyGILState_Ensure, (void), ())                    \
+  PY_VOID(PyGILState_Release, (PyGILState_STATE state), (state))              \
+  PY_FUNC(PyObject *, PyImport_AddModule, (const char *name), (name))         \
+  PY_FUNC(PyObject *, PyImport_ImportModule, (const char *name), (name))      \
+  PY_FUNC(PyObject *, PyList_New, (Py_ssize_t size), (size))                  \
+  PY_FUNC(int, PyList_SetItem, (PyObject * l, Py_ssize_t i, PyObject * v),    \
+          (l, i, v))                                                          \
+  PY_FUNC(long, PyLong_AsLong, (PyObject * o), (o))                           \
+  PY_FUNC(long long, PyLong_AsLongLong, (PyObject * o), (o))                  \
+  PY_FUNC(PyObject *, PyLong_FromLongLong, (long long v), (v))                \
+  PY_FUNC(PyObject *, PyLong_FromUnsignedLongLong, (unsigned long long v),    \
+          (v))                                                                \
+  PY_FUNC(PyObject *, PyMemoryView_FromMemory,                                \
+          (char *mem, Py_ssize_t size, int flags), (mem, size, flags))        \
+  PY_FUNC(PyObject *, PyMemoryView_FromObject, (PyObject * o), (o))           \
+  PY_FUNC(PyObject *, PyModule_GetDict, (PyObject * m), (m))                  \
+  PY_FUNC(PyObject *, PyNumber_Multiply, (PyObject * a, PyObject * b),        \
+          (a, b))                                                             \
+  PY_FUNC(PyObject *, PyObject_Call,                                          \
+          (PyObject * fn, PyObject * args, PyObject * kw), (fn, args, kw))    \
+  PY_FUNC(PyObject *, PyObject_CallObject, (PyObject * fn, PyObject * args),  \
+          (fn, args))                                                         \
+  PY_FUNC(PyObject *, PyObject_GetAttrString,                                 \
+          (PyObject * o, const char *name), (o, name))                        \
+  PY_FUNC(int, PyObject_HasAttrString, (PyObject * o, const char *name),      \
+          (o, name))                                                          \
+  PY_FUNC(int, PyObject_IsTrue, (PyObject * o), (o))                          \
+  PY_FUNC(int, PyObject_SetAttrString,                                        \
+          (PyObject * o, const char *name, PyObject *v), (o, name, v))        \
+  PY_FUNC(PyObject *, PyObject_Str, (PyObject * o), (o))                      \
+  PY_FUNC(PyObject *, PyObject_Type, (PyObject * o), (o))                     \
+  PY_FUNC(PyObject *, PySequence_GetItem, (PyObject * o, Py_ssize_t i),       \
+          (o, i))                                                             \
+  PY_FUNC(Py_ssize_t, PySequence_Size, (PyObject * o), (o))                   \
+  PY_VOID(PyThreadState_Clear, (PyThreadState * ts), (ts))                    \
+  PY_VOID(PyThreadState_Delete, (PyThreadState * ts), (ts))                   \
+  PY_FUNC(PyThreadState *, PyThreadState_Get, (void), ())                     \
+  PY_FUNC(PyThreadState *, PyThreadState_New, (PyInterpreterState * interp),  \
+          (interp))                                                           \
+  PY_FUNC(int, PyThreadState_SetAsyncExc, (unsigned long id, PyObject *exc),  \
+          (id, exc))                                                          \
+  PY_FUNC(PyThreadState *, PyThreadState_Swap, (PyThreadState * ts), (ts))    \
+  PY_FUNC(unsigned long, PyThread_get_thread_ident, (void), ())               \
+  PY_FUNC(PyObject *, PyTuple_New, (Py_ssize_t size), (size))                 \
+  PY_FUNC(int, PyTuple_SetItem, (PyObject * t, Py_ssize_t i, PyObject * v),   \
+          (t, i, v))                                                          \
+  PY_FUNC(unsigned long, PyType_GetFlags, (PyTypeObject * t), (t))            \
+  PY_FUNC(int, PyType_IsSubtype, (PyTypeObject * a, PyTypeObject * b),        \
+          (a, b))                                                             \
+  PY_FUNC(PyObject *, PyUnicode_AsUTF8String, (PyObject * o), (o))            \
+  PY_FUNC(PyObject *, PyUnicode_FromString, (const char *s), (s))             \
+  PY_FUNC(PyObject *, PyUnicode_FromStringAndSize,                            \
+          (const char *s, Py_ssize_t size), (s, size))                        \
+  PY_FUNC(PyObject *, Py_CompileString,                                       \
+          (const char *code, const char *file, int start),                    \
+          (code, file, start))                                                \
+  PY_VOID(Py_DecRef, (PyObject * o), (o))                                     \
+  PY_VOID(Py_EndInterpreter, (PyThreadState * ts), (ts))                      \
+  PY_FUNC(int, Py_FinalizeEx, (void), ())                                     \
+  PY_VOID(Py_IncRef, (PyObject * o), (o))                                     \
+  PY_VOID(Py_InitializeEx, (int initsigs), (initsigs))                        \
+  PY_VOID(_Py_Dealloc, (PyObject * o), (o))
+
+// Function pointers and definitions
+#define PY_FUNC(ret, name, params, args)                                      \
+  static ret(*py_dl_##name) params;                                           \
+  ret name params { return py_dl_##name args; }
+#define PY_VOID(name, params, args)                                           \
+  static void(*py_dl_##name) params;                                          \
+  void name params { py_dl_##name args; }
+PY_FUNCS
+#undef PY_FUNC
+#undef PY_VOID
+
+// Pointers redirected in dlopen.h
+PyObject *py_dl__Py_NoneStruct;
+PyTypeObject *py_dl_PyBool_Type;
+PyTypeObject *py_dl_PyByteArray_Type;
+PyTypeObject *py_dl_PyComplex_Type;
+PyTypeObject *py_dl_PyFloat_Type;
+PyTypeObject *py_dl_PyMemoryView_Type;
+PyObject **py_dl_PyExc_ImportError;
+PyObject **py_dl_PyExc_RuntimeError;
+PyObject **py_dl_PyExc_TimeoutError;
+PyObject **py_dl_PyExc_KeyboardInterrupt;
+PyObject **py_dl_PyExc_TypeError;
+PyObject *(*py_dl_PyObject_CallMethod)(PyObject *, const char *, const char *,
+                                       ...);
+
+typedef struct {
+  const char *name;
+  void **ptr;
+} symbol_t;
+
+#define PY_FUNC(ret, name, params, args) {#name, (void **)&py_dl_##name},
+#define PY_VOID(name, params, args) {#name, (void **)&py_dl_##name},
+static symbol_t symbols[] = {
+    PY_FUNCS
+    {"_Py_NoneStruct", (void **)&py_dl__Py_NoneStruct},
+    {"PyBool_Type", (void **)&py_dl_PyBool_Type},
+    {"PyByteArray_Type", (void **)&py_dl_PyByteArray_Type},
+    {"PyComplex_Type", (void **)&py_dl_PyComplex_Type},
+    {"PyFloat_Type", (void **)&py_dl_PyFloat_Type},
+    {"PyMemoryView_Type", (void **)&py_dl_PyMemoryView_Type},
+    {"PyExc_ImportError", (void **)&py_dl_PyExc_ImportError},
+    {"PyExc_RuntimeError", (void **)&py_dl_PyExc_RuntimeError},
+    {"PyExc_TimeoutError", (void **)&py_dl_PyExc_TimeoutError},
+    {"PyExc_KeyboardInterrupt", (void **)&py_dl_PyExc_KeyboardInterrupt},
+    {"PyExc_TypeError", (void **)&py_dl_PyExc_TypeError},
+    {"PyObject_CallMethod", (void **)&py_dl_PyObject_CallMethod},
+};
+#undef PY_FUNC
+#undef PY_VOID
+
+char *load_python(const char *path) {
+  char buf[512];
+
+  void *lib = lib_open(path);
+  if (lib == NULL) {
+    return lib_error(path);
+  }
+
+  for (size_t i = 0; i < sizeof(symbols) / sizeof(symbols[0]); i++) {
+    void *sym = lib_sym(lib, symbols[i].name);
+    if (sym == NULL) {
+      snprintf(buf, sizeof(buf), "%s: missing symbol %s", path,
+               symbols[i].name);
+      lib_close(lib);
+      return strdup(buf);
+    }
+    *symbols[i].ptr = sym;
+  }
+
+  return NULL;
+}
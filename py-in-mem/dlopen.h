@@ -0,0 +1,40 @@
+#ifndef DLOPEN_H
+#define DLOPEN_H
+
+// Built with the py_dlopen tag, libpython is loaded by load_python at runtime.
+// Functions are defined in dlopen.c and call libpython through pointers. Data
+// and variadic functions can't be wrapped like that, we redirect them to the
+// pointers with macros, so this file must be included after Python.h.
+
+extern PyObject *py_dl__Py_NoneStruct;
+extern PyTypeObject *py_dl_PyBool_Type;
+extern PyTypeObject *py_dl_PyByteArray_Type;
+extern PyTypeObject *py_dl_PyComplex_Type;
+extern PyTypeObject *py_dl_PyFloat_Type;
+extern PyTypeObject *py_dl_PyMemoryView_Type;
+extern PyObject **py_dl_PyExc_ImportError;
+extern PyObject **py_dl_PyExc_RuntimeError;
+extern PyObject **py_dl_PyExc_TimeoutError;
+extern PyObject **py_dl_PyExc_KeyboardInterrupt;
+extern PyObject **py_dl_PyExc_TypeError;
+extern PyObject *(*py_dl_PyObject_CallMethod)(PyObject *, const char *,
+                                              const char *, ...);
+
+#define _Py_NoneStruct (*py_dl__Py_NoneStruct)
+#define PyBool_Type (*py_dl_PyBool_Type)
+#define PyByteArray_Type (*py_dl_PyByteArray_Type)
+#define PyComplex_Type (*py_dl_PyComplex_Type)
+#define PyFloat_Type (*py_dl_PyFloat_Type)
+#define PyMemoryView_Type (*py_dl_PyMemoryView_Type)
+#define PyExc_ImportError (*py_dl_PyExc_ImportError)
+#define PyExc_RuntimeError (*py_dl_PyExc_RuntimeError)
+#define PyExc_TimeoutError (*py_dl_PyExc_TimeoutError)
+#define PyExc_KeyboardInterrupt (*py_dl_PyExc_KeyboardInterrupt)
+#define PyExc_TypeError (*py_dl_PyExc_TypeError)
+#define PyObject_CallMethod (*py_dl_PyObject_CallMethod)
+
+// Load libpython from path and resolve its symbols, returns NULL on success
+// or an error message (caller should free)
+char *load_python(const char *path);
+
+#endif // DLOPEN_H
@@ -1,13 +1,115 @@
 /* Package outliers provides outlier detection by calling a Python function.
 
-You *must* have numpy installed and the Python function you're calling should
-be importable (in the PYTHONPATH).
+You *must* have numpy installed (unless built with "py_nonumpy", see below)
+and the Python function you're calling should be importable (in the
+PYTHONPATH or added with AddPath).
 
 You need to set CGO_CFLAGS before building the code
 
 	$ export CGO_CFLAGS="-I $(python -c 'import numpy; print(numpy.get_include())'"
 	$ go build
 
+Python compiler and linker flags come from pkg-config (python3-embed), set
+PKG_CONFIG_PATH to pick a specific Python installation. Alternatively run
+"go generate" which detects both Python and numpy flags from the python3
+executable, then build with the "pyconfig" tag
+
+	$ go generate
+	$ go build -tags pyconfig
+
+On macOS use "go generate" as well, it detects framework builds of Python
+(python.org, Homebrew and Xcode) and links with "-framework Python".
+
+On Windows, where pkg-config usually isn't available, use "go generate" and
+the "pyconfig" tag with a Python from python.org and a MinGW-w64 gcc in the
+PATH (cgo doesn't support MSVC). The Python installation directory, where
+python3X.dll is, must be in the PATH when running the program.
+
+Build with the "py_abi3" tag to restrict the C glue to the Python limited API
+(stable ABI). numpy arrays are then created via numpy's Python API and
+results are copied once more.
+
+Build with the "py_dlopen" tag to load libpython at runtime instead of linking
+with it, the same executable then runs with any Python 3.8 or later and
+reports a friendly error when Python isn't installed (see WithLibPython). It
+uses the limited API as well, pass the Python include directory in
+CGO_CFLAGS.
+
+	$ export CGO_CFLAGS="$(pkg-config --cflags python3-embed)"
+	$ go build -tags py_dlopen
+
+Build with the "py_nonumpy" tag when numpy headers aren't available, numpy is
+then used via its Python API as with "py_abi3". In these three builds numpy is
+optional at runtime: without it Python functions get a memoryview of the data
+and can return indices as any sequence of int (e.g. a list).
+
+	$ go build -tags py_nonumpy
+
+Python is initialized on first use with the environment inherited from the
+process. Call Init first to pick a virtual environment (WithVenv) or a conda
+environment (WithCondaEnv), or to set the interpreter configuration
+(WithConfig) such as the Python home, isolated mode, the optimization level or
+the hash seed.
+
+WithImportAllow and WithImportDeny restrict the modules Python code may
+import, e.g. when the Python function comes from semi-trusted configuration.
+
+Python installs a SIGINT handler when initialized, which takes Ctrl-C away
+from Go. Use Init with WithGoSignals to keep signals in Go and Interrupt to
+stop running Python calls.
+
+Outliers values are safe for concurrent use. Python is initialized once and the
+GIL is released right after, every call acquires the GIL in the C glue and
+releases it before returning to Go. Calls into Python are serialized by the
+GIL, Go code (such as preparing data) keeps running in parallel.
+
+Call counts, errors and latency histograms of NewOutliers and of calls to
+Python by Detect methods are published with expvar under "outliers". Calls
+to Python carry the pprof label "outliers" in CPU profiles.
+
+Build with the "py_refdebug" tag to track references to Python objects held
+by Go values, CheckRefs then reports the ones that weren't released.
+
+Use WithSubInterpreter to run an Outliers in its own Python sub-interpreter,
+isolating the modules it loads from other Outliers values.
+
+A crash in Python (a fatal error or a segfault in a C extension) kills the
+whole process. Isolated runs the Python function in a worker process started
+from the same executable: a crash fails the call with ErrWorkerCrashed and the
+next call starts a new worker.
+
+Finalize shuts Python down when the program is done with it, e.g. at the end
+of a graceful shutdown: it waits for running calls, logs open handles, reports
+leaked references and calls Py_FinalizeEx. Restarting Python in the same
+process is not supported (numpy can't be loaded twice), use Isolated for an
+interpreter you can restart.
+
+To call other Python functions use LoadFunc, PyFunc.Call converts Go values to
+Python objects and the result back to Go (see PyFunc.Call for the mapping).
+
+	add, err := outliers.LoadFunc("operator", "add")
+	...
+	out, err := add.Call(1, 2) // out is 3
+
+CallOf converts the result to a Go type in the same step (see Decode).
+
+	sum, err := outliers.CallOf[float64](add, 1, 2.5) // sum is 3.5
+
+NewMemoryView passes a []byte to Python as a writable memoryview without
+copying it, Release it when Python is done with the memory.
+
+LoadFuncFromSource loads a function from Python code in memory, e.g. embedded
+in the executable with go:embed, instead of a file on the PYTHONPATH.
+
+LoadModule imports a module once, Module.Func and Module.Outliers then bind
+its functions without importing it again. NewOutliers does the same through a
+process-wide function cache: Outliers using the same module and function share
+one reference, and unused functions are kept for later NewOutliers calls up to
+SetFuncCacheSize.
+
+RegisterFunc goes the other way, exposing a Go function to Python code in the
+"go" module (e.g. "import go; go.progress(0.5)").
+
 Example:
 
 import (
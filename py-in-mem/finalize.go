@@ -0,0 +1,85 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrFinalized is returned by functions initializing or calling Python after
+// Finalize
+var ErrFinalized = errors.New("python finalized")
+
+// finalizeMu serializes calls to Finalize
+var finalizeMu sync.Mutex
+
+// Finalize shuts down the embedded Python interpreter: it waits for calls to
+// Python in progress to return, releases the Python objects of handles
+// (Outliers, PyFunc, Object ...) that are still open, logging them if
+// DebugLeaks is on, reports leaked references (see CheckRefs) and calls
+// Py_FinalizeEx, flushing Python buffers and running atexit handlers.
+//
+// After Finalize, functions that initialize Python and calls to Python with a
+// handle created before return ErrFinalized, closing a handle is a no-op.
+//
+// Finalize is for shutdown only, restarting Python in the same process is not
+// supported: numpy and other C extensions can't be loaded again after
+// Py_FinalizeEx. A service that needs to restart the interpreter should use
+// Isolated, which runs Python in a worker process; closing the Isolated and
+// creating a new one starts a fresh interpreter.
+//
+// Finalize is idempotent, it should be called once no goroutine uses Python,
+// e.g. at the end of a graceful shutdown.
+func Finalize() error {
+	finalizeMu.Lock()
+	defer finalizeMu.Unlock()
+
+	initOnce.Do(func() {
+		initErr = ErrFinalized // Never initialized, nothing to finalize
+	})
+	if pyFinalized() {
+		return nil
+	}
+	if initErr == nil {
+		ClearFuncCache()
+	}
+
+	running.Lock()
+	running.finalized = true
+	running.Unlock()
+	if initErr != nil {
+		return nil
+	}
+
+	for !idle() {
+		time.Sleep(time.Millisecond)
+	}
+
+	releaseAllOpen()
+	ClearFuncCache() // Functions released by Outliers
+	refErr := checkRefs(nil, true)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	return errors.Join(refErr, cError(C.finalize_python()))
+}
+
+// pyFinalized returns true after Finalize
+func pyFinalized() bool {
+	running.Lock()
+	defer running.Unlock()
+	return running.finalized
+}
+
+// idle returns true if no call to Python is in progress
+func idle() bool {
+	running.Lock()
+	defer running.Unlock()
+	return len(running.calls) == 0
+}
@@ -0,0 +1,165 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+)
+
+// DefaultFuncCacheSize is the default number of unused functions kept in the
+// function cache, see SetFuncCacheSize.
+const DefaultFuncCacheSize = 64
+
+// NewOutliers in the main interpreter gets functions from a process-wide
+// cache keyed by module and function name. Outliers using the same function
+// share its reference, counted in the cache, and functions no longer used by
+// any Outliers are kept (up to the cache size) for the next NewOutliers,
+// the least recently used are released first.
+var funcCache = struct {
+	sync.Mutex
+	size  int
+	funcs map[funcKey]*cachedFunc
+	idle  *list.List // Unused functions, most recently used first
+}{
+	size:  DefaultFuncCacheSize,
+	funcs: make(map[funcKey]*cachedFunc),
+	idle:  list.New(),
+}
+
+var (
+	funcCacheHits      = new(expvar.Int)
+	funcCacheMisses    = new(expvar.Int)
+	funcCacheEvictions = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("func_cache.hits", funcCacheHits)
+	metrics.Set("func_cache.misses", funcCacheMisses)
+	metrics.Set("func_cache.evictions", funcCacheEvictions)
+}
+
+type funcKey struct {
+	module string
+	name   string
+}
+
+type cachedFunc struct {
+	key  funcKey
+	fn   *C.PyObject
+	refs int           // Number of Outliers using fn
+	elem *list.Element // Element in idle when refs is 0
+}
+
+// SetFuncCacheSize sets the maximal number of unused functions kept in the
+// function cache (DefaultFuncCacheSize by default), releasing the least
+// recently used ones above n. With n <= 0 functions are released as soon as
+// the last Outliers using them is closed.
+func SetFuncCacheSize(n int) {
+	funcCache.Lock()
+	funcCache.size = max(n, 0)
+	evicted := evictFuncs(funcCache.size)
+	funcCache.Unlock()
+
+	releaseFuncs(evicted)
+}
+
+// ClearFuncCache releases all unused functions in the function cache, e.g.
+// after changing Python code that was already loaded. CheckRefs calls it.
+func ClearFuncCache() {
+	funcCache.Lock()
+	evicted := evictFuncs(0)
+	funcCache.Unlock()
+
+	releaseFuncs(evicted)
+}
+
+// acquireFunc returns module.name from the cache, loading it in the main
+// interpreter on a miss. Call releaseFunc when done.
+func acquireFunc(module, name string) (*C.PyObject, error) {
+	key := funcKey{module, name}
+	funcCache.Lock()
+	fn := refFunc(key)
+	funcCache.Unlock()
+	if fn != nil {
+		funcCacheHits.Add(1)
+		return fn, nil
+	}
+	funcCacheMisses.Add(1)
+
+	// Don't hold the lock while importing, the module code might call back
+	// into Go
+	fn, err := loadPyFunc(nil, module, name)
+	if err != nil {
+		return nil, err
+	}
+
+	funcCache.Lock()
+	cached := refFunc(key) // Loaded concurrently?
+	if cached == nil {
+		funcCache.funcs[key] = &cachedFunc{key: key, fn: fn, refs: 1}
+	}
+	funcCache.Unlock()
+
+	if cached != nil {
+		releaseFuncs([]*C.PyObject{fn})
+		return cached, nil
+	}
+	return fn, nil
+}
+
+// refFunc returns the cached function for key with its reference count
+// incremented, or nil if it's not in the cache. Must be called with funcCache
+// locked.
+func refFunc(key funcKey) *C.PyObject {
+	f, ok := funcCache.funcs[key]
+	if !ok {
+		return nil
+	}
+	if f.elem != nil {
+		funcCache.idle.Remove(f.elem)
+		f.elem = nil
+	}
+	f.refs++
+	return f.fn
+}
+
+// releaseFunc releases a function returned by acquireFunc.
+func releaseFunc(module, name string) {
+	funcCache.Lock()
+	f := funcCache.funcs[funcKey{module, name}]
+	f.refs--
+	if f.refs == 0 {
+		f.elem = funcCache.idle.PushFront(f)
+	}
+	evicted := evictFuncs(funcCache.size)
+	funcCache.Unlock()
+
+	releaseFuncs(evicted)
+}
+
+// evictFuncs removes the least recently used unused functions, keeping keep
+// of them, and returns the removed ones. Must be called with funcCache locked.
+func evictFuncs(keep int) []*C.PyObject {
+	var evicted []*C.PyObject
+	for funcCache.idle.Len() > keep {
+		f := funcCache.idle.Remove(funcCache.idle.Back()).(*cachedFunc)
+		delete(funcCache.funcs, f.key)
+		evicted = append(evicted, f.fn)
+		funcCacheEvictions.Add(1)
+	}
+	return evicted
+}
+
+// releaseFuncs releases evicted functions, it acquires the GIL so it must be
+// called without funcCache locked.
+func releaseFuncs(fns []*C.PyObject) {
+	for _, fn := range fns {
+		C.py_decref(fn)
+		refReleased(fn)
+	}
+}
@@ -1,75 +1,1131 @@
 #include "glue.h"
-#define NPY_NO_DEPRECATED_API NPY_1_19_API_VERSION 
+#include "_cgo_export.h"
+
+// The numpy C API isn't part of the limited API, with the py_nonumpy build
+// tag (PY_NO_NUMPY) we don't need numpy headers either
+#if !defined(Py_LIMITED_API) && !defined(PY_NO_NUMPY)
+#define NUMPY_C_API
+#define NPY_NO_DEPRECATED_API NPY_1_19_API_VERSION
 #include <numpy/arrayobject.h>
+#endif
+
+// Thread state of the thread that initialized Python
+static PyThreadState *main_state = NULL;
+
+#ifndef NUMPY_C_API
+// Without the numpy C API we use the numpy module from Python instead. Each
+// (sub) interpreter has its own numpy module so we import it on every call,
+// which is a lookup in sys.modules.
+//
+// If numpy isn't installed values are passed as memoryview objects and
+// results are converted with the array module.
+
+// Buffer flags are part of the limited API only from 3.11
+#ifndef PyBUF_WRITE
+#define PyBUF_WRITE 0x200
+#endif
+
+// Set by import_numpy when numpy isn't installed
+static int no_numpy = 0;
+
+static int init_numpy() {
+  if (no_numpy) {
+    return 0;
+  }
+
+  PyObject *numpy = PyImport_ImportModule("numpy");
+  Py_XDECREF(numpy);
+  return numpy == NULL ? -1 : 0;
+}
+
+// Called when init_numpy fails in the main interpreter, switches to working
+// without numpy if it isn't installed. Returns 1 if so, 0 otherwise (the
+// Python error is left set).
+static int numpy_fallback() {
+  if (!PyErr_ExceptionMatches(PyExc_ImportError)) {
+    return 0;
+  }
+  PyErr_Clear();
+  no_numpy = 1;
+  return 1;
+}
+#else
+// import_array is a macro that returns on error, wrap it in a function
+static int init_numpy() {
+  import_array1(-1);
+  return 0;
+}
+
+// numpy headers imply numpy is required
+static int numpy_fallback() { return 0; }
+#endif
+
+static py_error_t *new_error(const char *type, const char *msg);
+
+#ifdef Py_LIMITED_API
+static void set_env(const char *name, const char *value) {
+#ifdef _WIN32
+  _putenv_s(name, value);
+#else
+  setenv(name, value, 1);
+#endif
+}
+
+// PyConfig isn't part of the limited API, we use environment variables
+static py_error_t *initialize(const py_config_t *cfg) {
+  if (cfg->program_name != NULL || cfg->isolated || !cfg->use_environment) {
+    return new_error("RuntimeError",
+                     "program name, isolated mode and ignoring the "
+                     "environment need the full API (not py_abi3 or py_dlopen)");
+  }
+
+  char buf[32];
+  if (cfg->home != NULL) {
+    set_env("PYTHONHOME", cfg->home);
+  }
+  if (!cfg->user_site_directory) {
+    set_env("PYTHONNOUSERSITE", "1");
+  }
+  if (cfg->optimization_level > 0) {
+    snprintf(buf, sizeof(buf), "%d", cfg->optimization_level);
+    set_env("PYTHONOPTIMIZE", buf);
+  }
+  if (cfg->use_hash_seed) {
+    snprintf(buf, sizeof(buf), "%lu", cfg->hash_seed);
+    set_env("PYTHONHASHSEED", buf);
+  }
+
+  Py_InitializeEx(cfg->install_signal_handlers);
+  return NULL;
+}
+#else
+// Set a PyConfig string field from UTF-8 s, NULL s keeps the default
+static PyStatus set_string(PyConfig *config, wchar_t **field, const char *s) {
+  if (s == NULL) {
+    return PyStatus_Ok();
+  }
+  return PyConfig_SetBytesString(config, field, s);
+}
+
+static py_error_t *initialize(const py_config_t *cfg) {
+  PyConfig config;
+  PyConfig_InitPythonConfig(&config);
+
+  config.isolated = cfg->isolated;
+  config.use_environment = cfg->use_environment;
+  config.user_site_directory = cfg->user_site_directory;
+  config.optimization_level = cfg->optimization_level;
+  config.use_hash_seed = cfg->use_hash_seed;
+  config.hash_seed = cfg->hash_seed;
+  config.install_signal_handlers = cfg->install_signal_handlers;
+
+  PyStatus status =
+      set_string(&config, &config.program_name, cfg->program_name);
+  if (!PyStatus_Exception(status)) {
+    status = set_string(&config, &config.home, cfg->home);
+  }
+  if (!PyStatus_Exception(status)) {
+    status = Py_InitializeFromConfig(&config);
+  }
+  PyConfig_Clear(&config);
+
+  if (PyStatus_Exception(status)) {
+    return new_error("RuntimeError", status.err_msg != NULL
+                                         ? status.err_msg
+                                         : "can't initialize Python");
+  }
+  return NULL;
+}
+#endif
+
+// Initialize Python with cfg, call import_numpy after any setup code.
+// Returns an error (caller should free) or NULL.
+//
+// The initializing thread holds the GIL after initialization, we release it
+// so every call (from any thread) can acquire it with PyGILState_Ensure.
+py_error_t *init_python(const py_config_t *cfg) {
+  py_error_t *err = initialize(cfg);
+  if (err == NULL) {
+    main_state = PyEval_SaveThread();
+  }
+  return err;
+}
+
+// Finalize Python (Py_FinalizeEx), ending sub-interpreters as well. Returns
+// an error (caller should free) or NULL.
+py_error_t *finalize_python() {
+  PyGILState_Ensure(); // Finalization deletes the thread state
+  int status = Py_FinalizeEx();
+  main_state = NULL;
+  if (status != 0) {
+    return new_error("RuntimeError", "can't flush buffered data");
+  }
+  return NULL;
+}
+
+// Import numpy in the main interpreter. Returns an error (caller should free)
+// or NULL. Without the numpy C API a missing numpy isn't an error, see
+// numpy_fallback.
+py_error_t *import_numpy() {
+  py_error_t *err = NULL;
+  PyGILState_STATE gstate = PyGILState_Ensure();
+
+  if (init_numpy() != 0 && !numpy_fallback()) {
+    err = py_error();
+  }
+
+  PyGILState_Release(gstate);
+  return err;
+}
+
+// GIL state in the main interpreter or in a sub-interpreter
+typedef struct {
+  PyThreadState *ts; // Thread state in sub-interpreter, NULL for main
+  PyGILState_STATE gstate;
+} gil_t;
+
+// Acquire the GIL and make interp (NULL for main) the current interpreter.
+// PyGILState works only with the main interpreter, for sub-interpreters we
+// create a thread state for the duration of the call.
+static gil_t gil_acquire(PyInterpreterState *interp) {
+  gil_t gil = {NULL, PyGILState_UNLOCKED};
+  if (interp == NULL) {
+    gil.gstate = PyGILState_Ensure();
+    return gil;
+  }
+
+  gil.ts = PyThreadState_New(interp);
+  PyEval_RestoreThread(gil.ts);
+  return gil;
+}
+
+// Release the GIL acquired by gil_acquire
+static void gil_release(gil_t gil) {
+  if (gil.ts == NULL) {
+    PyGILState_Release(gil.gstate);
+    return;
+  }
+
+  PyThreadState_Clear(gil.ts);
+  PyEval_SaveThread();
+  PyThreadState_Delete(gil.ts);
+}
 
-// Return void * since import_array is a macro returning NULL
-void *init_python() {
-  Py_Initialize();
-  import_array();
+// Return an error that's not from Python
+static py_error_t *new_error(const char *type, const char *msg) {
+  py_error_t *err = calloc(1, sizeof(py_error_t));
+  err->type = strdup(type);
+  err->msg = strdup(msg);
+  return err;
+}
+
+// Create a new sub-interpreter with its own modules, returns NULL and sets
+// err on error. ts is set to the initial thread state of the interpreter, pass
+// it to end_interpreter.
+PyInterpreterState *new_interpreter(PyThreadState **ts, py_error_t **err) {
+#if defined(Py_LIMITED_API) && Py_LIMITED_API + 0 < 0x03090000
+  *err = new_error("RuntimeError", "sub-interpreters need the 3.9 limited API");
+  return NULL;
+#else
+  PyGILState_STATE gstate = PyGILState_Ensure();
+  PyThreadState *main_ts = PyThreadState_Get();
+  PyInterpreterState *interp = NULL;
+
+  // Py_NewInterpreter makes the new thread state current. Calls create their
+  // own thread state (see gil_acquire), we keep the initial one for
+  // end_interpreter.
+  *ts = Py_NewInterpreter();
+  if (*ts == NULL) {
+    *err = new_error("RuntimeError", "can't create sub-interpreter");
+  } else if (init_numpy() != 0) {
+    *err = py_error();
+    Py_EndInterpreter(*ts);
+    *ts = NULL;
+  } else {
+    interp = PyThreadState_GetInterpreter(*ts);
+  }
+
+  PyThreadState_Swap(main_ts);
+  PyGILState_Release(gstate);
+  return interp;
+#endif
+}
+
+// Destroy a sub-interpreter, ts is the thread state from new_interpreter
+void end_interpreter(PyThreadState *ts) {
+  if (main_state == NULL) { // finalize_python ended it
+    return;
+  }
+  PyGILState_STATE gstate = PyGILState_Ensure();
+  PyThreadState *main_ts = PyThreadState_Get();
+
+  PyThreadState_Swap(ts);
+  Py_EndInterpreter(ts);
+
+  PyThreadState_Swap(main_ts);
+  PyGILState_Release(gstate);
 }
 
 // Load function, same as "import module_name.func_name as obj" in Python
-// Returns the function object or NULL if not found
-PyObject *load_func(const char *module_name, char *func_name) {
+// Returns the function object or NULL and sets err if not found
+PyObject *load_func(PyInterpreterState *interp, const char *module_name,
+                    char *func_name, py_error_t **err) {
+  gil_t gil = gil_acquire(interp);
+  PyObject *func = NULL;
+
   // Import the module
-  PyObject *py_mod_name = PyUnicode_FromString(module_name);
-  if (py_mod_name == NULL) {
+  PyObject *module = PyImport_ImportModule(module_name);
+  if (module == NULL) {
+    goto done;
+  }
+
+  // Get function, same as "getattr(module, func_name)" in Python
+  func = PyObject_GetAttrString(module, func_name);
+  Py_DECREF(module);
+
+done:
+  if (func == NULL) {
+    *err = py_error();
+  }
+  gil_release(gil);
+  return func;
+}
+
+#ifndef NUMPY_C_API
+// numpy dtype names, struct (memoryview) formats and sizes for dtype_t
+static const char *dtype_names[] = {
+    [DTYPE_FLOAT64] = "float64",
+    [DTYPE_FLOAT32] = "float32",
+    [DTYPE_INT32] = "int32",
+    [DTYPE_INT64] = "int64",
+    [DTYPE_COMPLEX128] = "complex128",
+};
+
+static const char *dtype_formats[] = {
+    [DTYPE_FLOAT64] = "d",
+    [DTYPE_FLOAT32] = "f",
+    [DTYPE_INT32] = "i",
+    [DTYPE_INT64] = "q",
+    [DTYPE_COMPLEX128] = NULL, // memoryview doesn't support complex
+};
+
+static long dtype_sizes[] = {
+    [DTYPE_FLOAT64] = 8,
+    [DTYPE_FLOAT32] = 4,
+    [DTYPE_INT32] = 4,
+    [DTYPE_INT64] = 8,
+    [DTYPE_COMPLEX128] = 16,
+};
+
+// Create a memoryview with shape dims using values memory, same as
+// memoryview(values).cast(format, dims) in Python
+static PyObject *new_memoryview(PyObject *mem, dtype_t dtype, int nd,
+                                long *dims) {
+  if (dtype_formats[dtype] == NULL) {
+    PyErr_SetString(PyExc_TypeError, "complex values need numpy");
     return NULL;
   }
+  if (nd == 1) {
+    return PyObject_CallMethod(mem, "cast", "s", dtype_formats[dtype]);
+  }
+  return PyObject_CallMethod(mem, "cast", "s(ll)", dtype_formats[dtype],
+                             dims[0], dims[1]);
+}
+
+// Create a numpy array with shape dims using values memory, same as
+// numpy.frombuffer(values, dtype).reshape(dims) in Python. Without numpy
+// returns a memoryview (see new_memoryview).
+static PyObject *new_array(void *values, dtype_t dtype, int nd, long *dims) {
+  long size = dtype_sizes[dtype];
+  for (int i = 0; i < nd; i++) {
+    size *= dims[i];
+  }
+
+  PyObject *mem = PyMemoryView_FromMemory(values, size, PyBUF_WRITE);
+  if (mem == NULL) {
+    return NULL;
+  }
+  if (no_numpy) {
+    PyObject *view = new_memoryview(mem, dtype, nd, dims);
+    Py_DECREF(mem);
+    return view;
+  }
+
+  PyObject *numpy = PyImport_ImportModule("numpy");
+  if (numpy == NULL) {
+    Py_DECREF(mem);
+    return NULL;
+  }
+  PyObject *arr = PyObject_CallMethod(numpy, "frombuffer", "Os", mem, dtype_names[dtype]);
+  Py_DECREF(mem);
+  Py_DECREF(numpy);
+  if (arr == NULL || nd == 1) {
+    return arr;
+  }
+
+  PyObject *shaped = PyObject_CallMethod(arr, "reshape", "ll", dims[0], dims[1]);
+  Py_DECREF(arr);
+  return shaped;
+}
 
-  PyObject *module = PyImport_Import(py_mod_name);
-  Py_DECREF(py_mod_name);
+// Convert out to a contiguous int64 array, with numpy.ascontiguousarray or
+// without numpy with array.array('q', out)
+static PyObject *int64_array(PyObject *out) {
+  PyObject *module = PyImport_ImportModule(no_numpy ? "array" : "numpy");
   if (module == NULL) {
     return NULL;
   }
 
-  // Get function, same as "getattr(module, func_name)" in Python
-  PyObject *func = PyObject_GetAttrString(module, func_name);
+  PyObject *arr;
+  if (no_numpy) {
+    arr = PyObject_CallMethod(module, "array", "sO", "q", out);
+  } else {
+    arr = PyObject_CallMethod(module, "ascontiguousarray", "Os", out, "int64");
+  }
   Py_DECREF(module);
-  return func;
+  return arr;
 }
 
-// Call a function with array of values
-result_t detect(PyObject *func, double *values, long size) {
-  result_t res = {NULL, 0};
+// Set res indices from the array returned by the Python function. We can't
+// access the array memory without the numpy C API, copy it to a bytes object
+// which stays alive until py_decref.
+static int set_result(result_t *res, PyObject *out) {
+  PyObject *arr = int64_array(out);
+  if (arr == NULL) {
+    return -1;
+  }
+  PyObject *data = PyObject_CallMethod(arr, "tobytes", NULL);
+  Py_DECREF(arr);
+  if (data == NULL) {
+    return -1;
+  }
+
+  res->obj = data;
+  res->size = PyBytes_Size(data) / sizeof(int64_t);
+  res->indices = (int64_t *)PyBytes_AsString(data);
+  Py_DECREF(out);
+  return 0;
+}
+#else
+// numpy type numbers for dtype_t
+static int typenums[] = {
+    [DTYPE_FLOAT64] = NPY_FLOAT64,
+    [DTYPE_FLOAT32] = NPY_FLOAT32,
+    [DTYPE_INT32] = NPY_INT32,
+    [DTYPE_INT64] = NPY_INT64,
+    [DTYPE_COMPLEX128] = NPY_COMPLEX128,
+};
 
-  // Create numpy array from values
-  npy_intp dim[] = {size};
-  PyObject *arr = PyArray_SimpleNewFromData(1, dim, NPY_DOUBLE, values);
+// Create a numpy array with shape dims using values memory (no copy)
+static PyObject *new_array(void *values, dtype_t dtype, int nd, long *dims) {
+  npy_intp shape[] = {dims[0], nd > 1 ? dims[1] : 0};
+  return PyArray_SimpleNewFromData(nd, shape, typenums[dtype], values);
+}
+
+// Set res indices from the array returned by the Python function, converted
+// to a contiguous int64 array if needed (C long is 32 bit on Windows). The
+// array stays alive until py_decref.
+static int set_result(result_t *res, PyObject *out) {
+  PyObject *arr = PyArray_ContiguousFromAny(out, NPY_INT64, 1, 1);
   if (arr == NULL) {
-    res.err = 1;
-    return res;
+    return -1;
+  }
+
+  res->obj = arr;
+  res->size = PyArray_SIZE((PyArrayObject *)arr);
+  res->indices = (int64_t *)PyArray_DATA((PyArrayObject *)arr);
+  Py_DECREF(out);
+  return 0;
+}
+#endif
+
+// Python helper running awaitables on an event loop in a background thread,
+// installed as the "_outliers_async" module in each interpreter. We wait for
+// the result in small steps so a pending exception (see py_async_exc) can
+// interrupt the wait.
+static const char *async_code =
+    "import asyncio, concurrent.futures, threading\n"
+    "_loop = None\n"
+    "_lock = threading.Lock()\n"
+    "async def _await(obj):\n"
+    "    return await obj\n"
+    "def run(obj):\n"
+    "    global _loop\n"
+    "    with _lock:\n"
+    "        if _loop is None:\n"
+    "            _loop = asyncio.new_event_loop()\n"
+    "            threading.Thread(target=_loop.run_forever, daemon=True,\n"
+    "                             name='outliers-asyncio').start()\n"
+    "    fut = asyncio.run_coroutine_threadsafe(_await(obj), _loop)\n"
+    "    try:\n"
+    "        while not fut.done():\n"
+    "            concurrent.futures.wait((fut,), 0.01)\n"
+    "        return fut.result()\n"
+    "    except BaseException:\n"
+    "        fut.cancel()\n"
+    "        raise\n";
+
+// Return the _outliers_async module (borrowed reference) or NULL on error
+static PyObject *async_module() {
+  PyObject *module = PyImport_AddModule("_outliers_async"); // borrowed
+  if (module == NULL) {
+    return NULL;
+  }
+  if (PyObject_HasAttrString(module, "run")) {
+    return module;
   }
 
-  // Construct function arguments
+  PyObject *globals = PyModule_GetDict(module); // borrowed
+  if (PyDict_SetItemString(globals, "__builtins__", PyEval_GetBuiltins()) !=
+      0) {
+    return NULL;
+  }
+
+  PyObject *code = Py_CompileString(async_code, "<outliers>", Py_file_input);
+  if (code == NULL) {
+    return NULL;
+  }
+  PyObject *out = PyEval_EvalCode(code, globals, globals);
+  Py_DECREF(code);
+  if (out == NULL) {
+    return NULL;
+  }
+  Py_DECREF(out);
+  return module;
+}
+
+// If obj is awaitable (e.g. the result of calling an async function) run it
+// on the interpreter's event loop and return the result, otherwise return
+// obj. Steals the reference to obj, returns a new reference or NULL on error.
+// Must be called with the GIL held.
+PyObject *py_await(PyObject *obj) {
+  if (obj == NULL || !PyObject_HasAttrString(obj, "__await__")) {
+    return obj;
+  }
+
+  PyObject *out = NULL;
+  PyObject *module = async_module();
+  if (module != NULL) {
+    out = PyObject_CallMethod(module, "run", "O", obj);
+  }
+  Py_DECREF(obj);
+  return out;
+}
+
+// Copy res indices to malloc'ed memory and free the Python result object
+// while we hold the GIL, Go then converts the indices without acquiring the
+// GIL again and frees them. Returns 0 on success, -1 on error.
+static int copy_result(result_t *res) {
+  int64_t *indices = NULL;
+  if (res->size > 0) {
+    indices = malloc(res->size * sizeof(int64_t));
+    if (indices == NULL) {
+      PyErr_NoMemory();
+      return -1;
+    }
+    memcpy(indices, res->indices, res->size * sizeof(int64_t));
+  }
+
+  Py_DECREF(res->obj);
+  res->obj = NULL;
+  res->indices = indices;
+  return 0;
+}
+
+// Return a copy of arr (from new_array) that owns its memory, so Python code
+// can keep it after the call returns. Steals the reference to arr, returns
+// NULL on error.
+static PyObject *own_array(PyObject *arr) {
+  if (arr == NULL) {
+    return NULL;
+  }
+  if (!PyMemoryView_Check(arr)) {
+    PyObject *owned = PyObject_CallMethod(arr, "copy", NULL);
+    Py_DECREF(arr);
+    return owned;
+  }
+
+  // memoryview has no copy method, cast a view of a bytearray copy instead
+  PyObject *owned = NULL;
+  PyObject *data = PyByteArray_FromObject(arr);
+  PyObject *format = PyObject_GetAttrString(arr, "format");
+  PyObject *shape = PyObject_GetAttrString(arr, "shape");
+  if (data != NULL && format != NULL && shape != NULL) {
+    PyObject *mem = PyMemoryView_FromObject(data);
+    if (mem != NULL) {
+      owned = PyObject_CallMethod(mem, "cast", "OO", format, shape);
+      Py_DECREF(mem);
+    }
+  }
+  Py_XDECREF(shape);
+  Py_XDECREF(format);
+  Py_XDECREF(data);
+  Py_DECREF(arr);
+  return owned;
+}
+
+// Call a function with arr and keyword arguments kwargs (may be NULL) and set
+// res, must be called with the GIL held. Steals the reference to arr. If copy
+// is set the indices are copied (see copy_result). Returns 0 on success, -1 on
+// error (with the Python error set).
+static int call_arr(PyObject *func, PyObject *arr, PyObject *kwargs, int copy,
+                    result_t *res) {
+  // Construct function arguments, PyTuple_SetItem steals the reference to arr
   PyObject *args = PyTuple_New(1);
   PyTuple_SetItem(args, 0, arr);
 
-  PyArrayObject *out = (PyArrayObject *)PyObject_CallObject(func, args);
+  PyObject *out = py_await(PyObject_Call(func, args, kwargs));
+  Py_DECREF(args);
   if (out == NULL) {
-    res.err = 1;
-    return res;
+    return -1;
+  }
+
+  if (set_result(res, out) != 0) {
+    Py_DECREF(out);
+    return -1;
+  }
+  if (copy && copy_result(res) != 0) {
+    Py_CLEAR(res->obj);
+    return -1;
   }
+  return 0;
+}
+
+// Call a function with an nd (1 or 2) array of values with shape dims, see
+// call_arr.
+static int call_nd(PyObject *func, void *values, dtype_t dtype, int nd,
+                   long *dims, PyObject *kwargs, int copy, result_t *res) {
+  // Create numpy array from values
+  PyObject *arr = new_array(values, dtype, nd, dims);
+  if (arr == NULL) {
+    return -1;
+  }
+  return call_arr(func, arr, kwargs, copy, res);
+}
+
+// Call a function with an nd (1 or 2) array of values with shape dims
+static result_t detect_nd(PyInterpreterState *interp, PyObject *func,
+                          void *values, dtype_t dtype, int nd, long *dims,
+                          int copy) {
+  result_t res = {NULL, NULL, 0, NULL};
+  gil_t gil = gil_acquire(interp);
 
-  res.obj = (PyObject *)out;
-  res.size = PyArray_SIZE(out);
-  res.indices = (long *)PyArray_GETPTR1(out, 0);
+  if (call_nd(func, values, dtype, nd, dims, NULL, copy, &res) != 0) {
+    res.err = py_error();
+  }
+
+  gil_release(gil);
   return res;
 }
 
-// Return last error as char *, NULL if there was no error
-const char *py_last_error() {
-  PyObject *err = PyErr_Occurred();
-  if (err == NULL) {
+// Call a function for each of n series with the GIL acquired once. values
+// holds the series one after the other, series i has sizes[i] values.
+// Results are stored in results (n items), empty series are skipped. Returns
+// an error (caller should free) or NULL, on error results up to the failing
+// series are set.
+py_error_t *detect_batch(PyInterpreterState *interp, PyObject *func,
+                         double *values, long *sizes, long n,
+                         result_t *results) {
+  py_error_t *err = NULL;
+  gil_t gil = gil_acquire(interp);
+
+  for (long i = 0; i < n; i++) {
+    if (sizes[i] == 0) {
+      continue;
+    }
+
+    long dims[] = {sizes[i]};
+    if (call_nd(func, values, DTYPE_FLOAT64, 1, dims, NULL, 1, &results[i]) !=
+        0) {
+      err = py_error();
+      break;
+    }
+    values += sizes[i];
+  }
+
+  gil_release(gil);
+  return err;
+}
+
+// Call a function with array of values, indices in the result are copied
+// (see copy_result)
+result_t detect(PyInterpreterState *interp, PyObject *func, double *values,
+                long size) {
+  long dims[] = {size};
+  return detect_nd(interp, func, values, DTYPE_FLOAT64, 1, dims, 1);
+}
+
+// Like detect but the result object stays alive and indices point to its
+// memory, caller should free it with py_decref_in
+result_t detect_view(PyInterpreterState *interp, PyObject *func,
+                     double *values, long size) {
+  long dims[] = {size};
+  return detect_nd(interp, func, values, DTYPE_FLOAT64, 1, dims, 0);
+}
+
+// Like detect but the function gets a copy of values owned by Python, which
+// it may keep after the call returns (see Stream in stream.go)
+result_t detect_copy(PyInterpreterState *interp, PyObject *func,
+                     double *values, long size) {
+  result_t res = {NULL, NULL, 0, NULL};
+  long dims[] = {size};
+  gil_t gil = gil_acquire(interp);
+
+  PyObject *arr = own_array(new_array(values, DTYPE_FLOAT64, 1, dims));
+  if (arr == NULL || call_arr(func, arr, NULL, 1, &res) != 0) {
+    res.err = py_error();
+  }
+
+  gil_release(gil);
+  return res;
+}
+
+// Return a dict of n float values, names[i]: values[i], or NULL on error
+static PyObject *float_dict(char **names, double *values, long n) {
+  PyObject *dict = PyDict_New();
+  if (dict == NULL) {
     return NULL;
   }
 
-  PyObject *str = PyObject_Str(err);
-  const char *utf8 = PyUnicode_AsUTF8(str);
+  for (long i = 0; i < n; i++) {
+    PyObject *value = PyFloat_FromDouble(values[i]);
+    if (value == NULL || PyDict_SetItemString(dict, names[i], value) != 0) {
+      Py_XDECREF(value);
+      Py_DECREF(dict);
+      return NULL;
+    }
+    Py_DECREF(value);
+  }
+  return dict;
+}
+
+// Call a function with array of values and nparams float keyword arguments
+// (names[i]=params[i]), indices in the result are copied (see copy_result)
+result_t detect_params(PyInterpreterState *interp, PyObject *func,
+                       double *values, long size, char **names,
+                       double *params, long nparams) {
+  result_t res = {NULL, NULL, 0, NULL};
+  gil_t gil = gil_acquire(interp);
+
+  long dims[] = {size};
+  PyObject *kwargs = float_dict(names, params, nparams);
+  if (kwargs == NULL ||
+      call_nd(func, values, DTYPE_FLOAT64, 1, dims, kwargs, 1, &res) != 0) {
+    res.err = py_error();
+  }
+  Py_XDECREF(kwargs);
+
+  gil_release(gil);
+  return res;
+}
+
+// Call a function with array of values of type dtype, the array uses the
+// values memory (no copy)
+result_t detect_dtype(PyInterpreterState *interp, PyObject *func, void *values,
+                      dtype_t dtype, long size) {
+  long dims[] = {size};
+  return detect_nd(interp, func, values, dtype, 1, dims, 1);
+}
+
+// Call a function with a rows x cols matrix of values (row major)
+result_t detect_matrix(PyInterpreterState *interp, PyObject *func,
+                       double *values, long rows, long cols) {
+  long dims[] = {rows, cols};
+  return detect_nd(interp, func, values, DTYPE_FLOAT64, 2, dims, 1);
+}
+
+// Call func without arguments, used for calls that return indices but don't
+// take data
+result_t detect_call(PyInterpreterState *interp, PyObject *func) {
+  result_t res = {NULL, NULL, 0, NULL};
+  gil_t gil = gil_acquire(interp);
+
+  PyObject *out = py_await(PyObject_CallObject(func, NULL));
+  if (out == NULL || set_result(&res, out) != 0) {
+    Py_XDECREF(out);
+    res.err = py_error();
+  } else if (copy_result(&res) != 0) {
+    Py_CLEAR(res.obj);
+    res.err = py_error();
+  }
+
+  gil_release(gil);
+  return res;
+}
+
+// Create a stream detector by calling factory() and return its push method
+// (new reference). flush is set to its flush method or to NULL if it doesn't
+// have one. On error returns NULL and sets err (caller should free).
+PyObject *new_stream(PyInterpreterState *interp, PyObject *factory,
+                     PyObject **flush, py_error_t **err) {
+  gil_t gil = gil_acquire(interp);
+  PyObject *push = NULL;
+  *flush = NULL;
+
+  PyObject *detector = PyObject_CallObject(factory, NULL);
+  if (detector == NULL) {
+    *err = py_error();
+    goto done;
+  }
+
+  push = PyObject_GetAttrString(detector, "push");
+  if (push == NULL) {
+    *err = py_error();
+  } else if (PyObject_HasAttrString(detector, "flush")) {
+    *flush = PyObject_GetAttrString(detector, "flush");
+    if (*flush == NULL) {
+      *err = py_error();
+      Py_CLEAR(push);
+    }
+  }
+  Py_DECREF(detector);
+
+done:
+  gil_release(gil);
+  return push;
+}
+
+// Return a copy of str(obj) encoded as UTF-8 or NULL on error, caller
+// should free the returned value. PyUnicode_AsUTF8 isn't in the limited API.
+static char *str_dup(PyObject *obj) {
+  char *out = NULL;
+  PyObject *str = PyObject_Str(obj);
+  if (str == NULL) {
+    return NULL;
+  }
+
+  PyObject *data = PyUnicode_AsUTF8String(str);
   Py_DECREF(str);
-  return utf8;
+  if (data != NULL) {
+    out = strdup(PyBytes_AsString(data));
+    Py_DECREF(data);
+  }
+  return out;
+}
+
+// Return str(getattr(obj, name)), caller should free the returned value.
+// Returns "?" on error.
+static char *attr_str(PyObject *obj, const char *name) {
+  char *out = NULL;
+  PyObject *attr = PyObject_GetAttrString(obj, name);
+  if (attr != NULL) {
+    out = str_dup(attr);
+    Py_DECREF(attr);
+  }
+
+  if (out == NULL) {
+    PyErr_Clear();
+    out = strdup("?");
+  }
+  return out;
+}
+
+// Fill frame from a traceback object
+static void tb_frame(PyObject *tb, py_frame_t *frame) {
+  frame->line = -1;
+  PyObject *line = PyObject_GetAttrString(tb, "tb_lineno");
+  if (line != NULL) {
+    frame->line = PyLong_AsLong(line);
+    Py_DECREF(line);
+  }
+
+  PyObject *code = NULL;
+  PyObject *f = PyObject_GetAttrString(tb, "tb_frame");
+  if (f != NULL) {
+    code = PyObject_GetAttrString(f, "f_code");
+    Py_DECREF(f);
+  }
+
+  if (code == NULL) {
+    PyErr_Clear();
+    frame->file = strdup("?");
+    frame->func = strdup("?");
+    return;
+  }
+
+  frame->file = attr_str(code, "co_filename");
+  frame->func = attr_str(code, "co_name");
+  Py_DECREF(code);
+}
+
+// Return the current Python error and clear it. Returns NULL if there's no
+// error, caller should free the returned value with py_error_free.
+// Must be called with the GIL held.
+py_error_t *py_error() {
+  PyObject *type, *value, *traceback;
+  PyErr_Fetch(&type, &value, &traceback);
+  if (type == NULL) {
+    return NULL;
+  }
+
+  PyErr_NormalizeException(&type, &value, &traceback);
+  py_error_t *err = calloc(1, sizeof(py_error_t));
+  err->type = attr_str(type, "__name__");
+  err->msg = value ? str_dup(value) : NULL;
+  if (err->msg == NULL) {
+    PyErr_Clear();
+    err->msg = strdup("");
+  }
+
+  // Walk the traceback, same as following tb.tb_next in Python
+  PyObject *tb = traceback;
+  Py_XINCREF(tb);
+  while (tb != NULL && tb != Py_None) {
+    err->frames = realloc(err->frames, (err->nframes + 1) * sizeof(py_frame_t));
+    tb_frame(tb, &err->frames[err->nframes]);
+    err->nframes++;
+
+    PyObject *next = PyObject_GetAttrString(tb, "tb_next");
+    Py_DECREF(tb);
+    tb = next;
+  }
+  Py_XDECREF(tb);
+  PyErr_Clear();
+
+  Py_XDECREF(type);
+  Py_XDECREF(value);
+  Py_XDECREF(traceback);
+  return err;
+}
+
+// Free an error returned by py_error
+void py_error_free(py_error_t *err) {
+  for (long i = 0; i < err->nframes; i++) {
+    free(err->frames[i].file);
+    free(err->frames[i].func);
+  }
+  free(err->frames);
+  free(err->type);
+  free(err->msg);
+  free(err);
 }
 
 // Decrement reference counter for object. We can't use Py_DECREF directly from
 // Go since it's a macro
-void py_decref(PyObject *obj) { Py_DECREF(obj); }
+void py_decref(PyObject *obj) { py_decref_in(NULL, obj); }
+
+// Decrement reference counter for obj that belongs to interp (NULL for main),
+// objects are already gone after finalize_python
+void py_decref_in(PyInterpreterState *interp, PyObject *obj) {
+  if (main_state == NULL) {
+    return;
+  }
+  gil_t gil = gil_acquire(interp);
+  Py_DECREF(obj);
+  gil_release(gil);
+}
+
+// Return the kind of obj, used by Go to convert Python objects. Type checks
+// are macros and can't be called from Go.
+py_kind_t py_kind(PyObject *obj) {
+  if (obj == Py_None) {
+    return PY_NONE;
+  }
+  if (PyBool_Check(obj)) { // bool is a subclass of int, check it first
+    return PY_BOOL;
+  }
+  if (PyLong_Check(obj)) {
+    return PY_INT;
+  }
+  if (PyFloat_Check(obj)) {
+    return PY_FLOAT;
+  }
+  if (PyComplex_Check(obj)) {
+    return PY_COMPLEX;
+  }
+  if (PyUnicode_Check(obj)) {
+    return PY_STR;
+  }
+  if (PyList_Check(obj)) {
+    return PY_LIST;
+  }
+  if (PyTuple_Check(obj)) {
+    return PY_TUPLE;
+  }
+  if (PyDict_Check(obj)) {
+    return PY_DICT;
+  }
+  if (PyBytes_Check(obj)) {
+    return PY_BYTES;
+  }
+  if (PyByteArray_Check(obj) || PyMemoryView_Check(obj)) {
+    return PY_BUFFER;
+  }
+  return PY_OTHER;
+}
+
+// Return a writable memoryview of size bytes at mem (no copy), same as
+// memoryview((ctypes.c_char * size).from_address(mem)).cast('B') in Python.
+// owner is set to the ctypes array exporting the memory: Python code uses the
+// memory as long as there are references to owner other than ours, including
+// ones from views derived from the memoryview (see py_refcnt).
+PyObject *py_memoryview(void *mem, Py_ssize_t size, PyObject **owner) {
+  *owner = NULL;
+  PyObject *ctypes = PyImport_ImportModule("ctypes");
+  if (ctypes == NULL) {
+    return NULL;
+  }
+  PyObject *c_char = PyObject_GetAttrString(ctypes, "c_char");
+  Py_DECREF(ctypes);
+  if (c_char == NULL) {
+    return NULL;
+  }
+  PyObject *n = PyLong_FromLongLong(size);
+  if (n == NULL) {
+    Py_DECREF(c_char);
+    return NULL;
+  }
+  PyObject *type = PyNumber_Multiply(c_char, n);
+  Py_DECREF(c_char);
+  Py_DECREF(n);
+  if (type == NULL) {
+    return NULL;
+  }
+
+  PyObject *arr = PyObject_CallMethod(type, "from_address", "K",
+                                      (unsigned long long)(uintptr_t)mem);
+  Py_DECREF(type);
+  if (arr == NULL) {
+    return NULL;
+  }
+  PyObject *view = PyMemoryView_FromObject(arr);
+  if (view == NULL) {
+    Py_DECREF(arr);
+    return NULL;
+  }
+  // ctypes exports c_char items, we want bytes
+  PyObject *bytes_view = PyObject_CallMethod(view, "cast", "s", "B");
+  Py_DECREF(view);
+  if (bytes_view == NULL) {
+    Py_DECREF(arr);
+    return NULL;
+  }
+
+  *owner = arr;
+  return bytes_view;
+}
+
+// Return the reference count of obj, Py_REFCNT is a macro
+long py_refcnt(PyObject *obj) { return (long)Py_REFCNT(obj); }
+
+// Return a new reference to None
+PyObject *py_none() {
+  Py_INCREF(Py_None);
+  return Py_None;
+}
+
+// Call obj.name(), PyObject_CallMethod is variadic and can't be called from Go
+PyObject *py_call_method(PyObject *obj, const char *name) {
+  return PyObject_CallMethod(obj, name, NULL);
+}
+
+// Return the type name of obj, caller should free the returned value
+char *py_type_name(PyObject *obj) {
+  PyObject *type = PyObject_Type(obj);
+  char *name = attr_str(type, "__name__");
+  Py_DECREF(type);
+  return name;
+}
+
+// Return the values of obj, a 1 dimensional numpy complex array, as a bytes
+// object of contiguous complex128 values, same as
+// obj.astype('complex128').tobytes() in Python. Returns NULL without an error
+// set if obj isn't a numpy complex array.
+PyObject *complex128_bytes(PyObject *obj) {
+  PyObject *dtype = PyObject_GetAttrString(obj, "dtype");
+  if (dtype == NULL) {
+    PyErr_Clear();
+    return NULL;
+  }
+  char *kind = attr_str(dtype, "kind");
+  Py_DECREF(dtype);
+  int is_complex = strcmp(kind, "c") == 0;
+  free(kind);
+
+  long ndim = -1;
+  PyObject *attr = is_complex ? PyObject_GetAttrString(obj, "ndim") : NULL;
+  if (attr != NULL) {
+    ndim = PyLong_AsLong(attr);
+    Py_DECREF(attr);
+  }
+  PyErr_Clear();
+  if (ndim != 1) { // Scalars and matrices are converted with tolist
+    return NULL;
+  }
+
+  PyObject *arr = PyObject_CallMethod(obj, "astype", "s", "complex128");
+  if (arr == NULL) {
+    return NULL;
+  }
+  PyObject *data = PyObject_CallMethod(arr, "tobytes", NULL);
+  Py_DECREF(arr);
+  return data;
+}
+
+// Raise exc in thread tid running in interp (NULL for main), ASYNC_CLEAR
+// clears a pending exception.
+void py_async_exc(PyInterpreterState *interp, unsigned long tid,
+                  async_exc_t exc) {
+  PyObject *type = NULL;
+  switch (exc) {
+  case ASYNC_CLEAR:
+    break;
+  case ASYNC_TIMEOUT:
+    type = PyExc_TimeoutError;
+    break;
+  case ASYNC_INTERRUPT:
+    type = PyExc_KeyboardInterrupt;
+    break;
+  }
+
+  gil_t gil = gil_acquire(interp);
+  PyThreadState_SetAsyncExc(tid, type);
+  gil_release(gil);
+}
+
+// Python side of Go functions registered with register_func, self is the
+// function name
+static PyObject *go_call(PyObject *self, PyObject *args) {
+  return goCallback(self, args);
+}
+
+static PyMethodDef go_call_def = {"go_call", go_call, METH_VARARGS,
+                                  "Call a registered Go function"};
+
+// Add a Go function as name to the "go" module, returns an error (caller
+// should free) or NULL
+py_error_t *register_func(const char *name) {
+  py_error_t *err = NULL;
+  PyGILState_STATE gstate = PyGILState_Ensure();
+
+  PyObject *module = PyImport_AddModule("go"); // borrowed
+  if (module == NULL) {
+    err = py_error();
+    goto done;
+  }
+
+  PyObject *self = PyUnicode_FromString(name);
+  if (self == NULL) {
+    err = py_error();
+    goto done;
+  }
+
+  PyObject *func = PyCFunction_NewEx(&go_call_def, self, NULL);
+  Py_DECREF(self);
+  if (func == NULL) {
+    err = py_error();
+    goto done;
+  }
+
+  if (PyObject_SetAttrString(module, name, func) != 0) {
+    err = py_error();
+  }
+  Py_DECREF(func);
+
+done:
+  PyGILState_Release(gstate);
+  return err;
+}
+
+// Raise RuntimeError with msg
+void py_raise(const char *msg) { PyErr_SetString(PyExc_RuntimeError, msg); }
@@ -2,19 +2,121 @@
 #define GLUE_H
 
 #include <Python.h>
+#include <stdint.h>
+
+#ifdef PY_DLOPEN
+#include "dlopen.h"
+#endif
+
+// Traceback frame
+typedef struct {
+  char *file; // File name
+  char *func; // Function name
+  long line;  // Line number
+} py_frame_t;
+
+// Python exception, free with py_error_free
+typedef struct {
+  char *type;         // Exception type name
+  char *msg;          // Exception message
+  py_frame_t *frames; // Traceback frames, most recent call last
+  long nframes;       // Number of frames
+} py_error_t;
 
 // Result of calling detect
 typedef struct {
-  PyObject *obj; // numpy array object, so we can free it
-  long *indices; // indices of outliers
-  long size;     // number of outliers
-  int err;       // Flag if there was an error
+  PyObject *obj;   // numpy array object, so we can free it
+  int64_t *indices; // indices of outliers
+  long size;       // number of outliers
+  py_error_t *err; // Error (caller should free), NULL if no error
 } result_t;
 
-void *init_python();
-PyObject *load_func(const char *module_name, char *func_name);
-result_t detect(PyObject *func, double *values, long size);
-const char *py_last_error();
+// Type of values passed to detect_dtype
+typedef enum {
+  DTYPE_FLOAT64,
+  DTYPE_FLOAT32,
+  DTYPE_INT32,
+  DTYPE_INT64,
+  DTYPE_COMPLEX128,
+} dtype_t;
+
+// Kind of Python object, see py_kind
+typedef enum {
+  PY_OTHER,
+  PY_NONE,
+  PY_BOOL,
+  PY_INT,
+  PY_FLOAT,
+  PY_COMPLEX,
+  PY_STR,
+  PY_LIST,
+  PY_TUPLE,
+  PY_DICT,
+  PY_BYTES,
+  PY_BUFFER, // bytearray or memoryview
+} py_kind_t;
+
+// Exception raised in a thread by py_async_exc
+typedef enum {
+  ASYNC_CLEAR,     // Clear a pending exception
+  ASYNC_TIMEOUT,   // TimeoutError
+  ASYNC_INTERRUPT, // KeyboardInterrupt
+} async_exc_t;
+
+// Interpreter configuration for init_python, see Config in config.go
+typedef struct {
+  const char *program_name; // NULL for default
+  const char *home;         // NULL for default
+  int isolated;
+  int use_environment;
+  int user_site_directory;
+  int optimization_level;
+  int use_hash_seed;
+  unsigned long hash_seed;
+  int install_signal_handlers;
+} py_config_t;
+
+py_error_t *init_python(const py_config_t *cfg);
+py_error_t *import_numpy();
+py_error_t *finalize_python();
+PyInterpreterState *new_interpreter(PyThreadState **ts, py_error_t **err);
+void end_interpreter(PyThreadState *ts);
+PyObject *load_func(PyInterpreterState *interp, const char *module_name,
+                    char *func_name, py_error_t **err);
+result_t detect(PyInterpreterState *interp, PyObject *func, double *values,
+                long size);
+result_t detect_view(PyInterpreterState *interp, PyObject *func,
+                     double *values, long size);
+result_t detect_copy(PyInterpreterState *interp, PyObject *func,
+                     double *values, long size);
+result_t detect_params(PyInterpreterState *interp, PyObject *func,
+                       double *values, long size, char **names,
+                       double *params, long nparams);
+result_t detect_dtype(PyInterpreterState *interp, PyObject *func, void *values,
+                      dtype_t dtype, long size);
+result_t detect_matrix(PyInterpreterState *interp, PyObject *func,
+                       double *values, long rows, long cols);
+py_error_t *detect_batch(PyInterpreterState *interp, PyObject *func,
+                         double *values, long *sizes, long n,
+                         result_t *results);
+result_t detect_call(PyInterpreterState *interp, PyObject *func);
+PyObject *new_stream(PyInterpreterState *interp, PyObject *factory,
+                     PyObject **flush, py_error_t **err);
+py_error_t *py_error();
+void py_error_free(py_error_t *err);
 void py_decref(PyObject *obj);
+void py_decref_in(PyInterpreterState *interp, PyObject *obj);
+py_kind_t py_kind(PyObject *obj);
+PyObject *py_memoryview(void *mem, Py_ssize_t size, PyObject **owner);
+long py_refcnt(PyObject *obj);
+PyObject *py_none();
+PyObject *py_call_method(PyObject *obj, const char *name);
+char *py_type_name(PyObject *obj);
+PyObject *complex128_bytes(PyObject *obj);
+PyObject *py_await(PyObject *obj);
+py_error_t *register_func(const char *name);
+void py_raise(const char *msg);
+void py_async_exc(PyInterpreterState *interp, unsigned long tid,
+                  async_exc_t exc);
 
 #endif // GLUE_H
@@ -0,0 +1,102 @@
+package outliers
+
+// importFilterCode wraps builtins.__import__ to reject imports outside of
+// allow (None for all modules) or in deny, allow and deny are set in globals.
+//
+// Imports by allowed modules (e.g. numpy importing its dependencies), by the
+// standard library, by the import machinery and by the package itself (no
+// __name__ in globals) aren't restricted by allow, deny applies to all
+// imports. Relative imports stay in the importing package and aren't checked.
+const importFilterCode = `
+import builtins
+import os
+import sys
+import sysconfig
+
+# sys.stdlib_module_names is new in 3.10
+_stdlib_names = getattr(sys, 'stdlib_module_names', None)
+_stdlib_dirs = tuple(os.path.realpath(sysconfig.get_path(name)) + os.sep
+                     for name in ('stdlib', 'platstdlib'))
+
+
+def _matches(name, modules):
+    return any(name == m or name.startswith(m + '.') for m in modules)
+
+
+def _is_stdlib(name):
+    top = name.partition('.')[0]
+    if _stdlib_names is not None:
+        return top in _stdlib_names
+    path = getattr(sys.modules.get(name), '__file__', None)
+    if path is None:
+        return top in sys.builtin_module_names
+    path = os.path.realpath(path)
+    return path.startswith(_stdlib_dirs) and 'site-packages' not in path
+
+
+def _trusted(importer):
+    return (not importer or importer == '_outliers_async' or
+            importer.startswith(('importlib', '_frozen_importlib')) or
+            _is_stdlib(importer))
+
+
+def _filter_import(name, globals=None, locals=None, fromlist=(), level=0,
+                   _import=builtins.__import__, allow=allow, deny=deny):
+    if level == 0:
+        if globals is None:  # Called as __import__(name)
+            globals = sys._getframe(1).f_globals
+        importer = globals.get('__name__', '')
+        if _matches(name, deny) or (
+                allow is not None and not _matches(name, allow) and
+                not _matches(importer, allow) and not _trusted(importer)):
+            raise ImportError('import of %r is not allowed' % name, name=name)
+    return _import(name, globals, locals, fromlist, level)
+
+
+builtins.__import__ = _filter_import
+`
+
+// WithImportAllow restricts the modules Python code may import to modules
+// and their submodules (e.g. "numpy" allows "numpy.linalg"), useful when the
+// Python function comes from semi-trusted configuration. Modules imported by
+// an allowed module aren't restricted, so allowing "numpy" doesn't require
+// listing its dependencies, and the standard library can import the modules
+// it needs. Other imports raise ImportError. Modules loaded from Go (e.g. by
+// NewOutliers) don't need to be listed, the modules they import do. Calling
+// WithImportAllow several times adds to the list.
+//
+// The filter is installed in the main interpreter once Python is initialized,
+// it limits what code may import by mistake or by policy but isn't a security
+// boundary against malicious code (which can reach modules through allowed
+// ones).
+func WithImportAllow(modules ...string) InitOption {
+	return func(c *initConfig) {
+		c.importAllow = append(c.importAllow, modules...)
+		if c.importAllow == nil { // WithImportAllow() allows nothing
+			c.importAllow = []string{}
+		}
+	}
+}
+
+// WithImportDeny forbids importing modules and their submodules (e.g.
+// "subprocess", "socket"), even by allowed modules, see WithImportAllow.
+// Calling WithImportDeny several times adds to the list.
+func WithImportDeny(modules ...string) InitOption {
+	return func(c *initConfig) {
+		c.importDeny = append(c.importDeny, modules...)
+	}
+}
+
+// installImportFilter installs the import filter of cfg in the main
+// interpreter, if any
+func installImportFilter(cfg initConfig) error {
+	if cfg.importAllow == nil && cfg.importDeny == nil {
+		return nil
+	}
+
+	globals := map[string]interface{}{
+		"allow": cfg.importAllow, // nil converts to None
+		"deny":  append([]string{}, cfg.importDeny...),
+	}
+	return execPython(importFilterCode, globals)
+}
@@ -0,0 +1,180 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// InitOption is an option for Init
+type InitOption func(*initConfig)
+
+type initConfig struct {
+	config    Config // Interpreter configuration
+	venv      string // Virtual environment directory
+	condaEnv  string // Conda environment name
+	libPython string // libpython path, with the py_dlopen build tag
+	goSignals bool   // Don't install Python signal handlers
+
+	importAllow []string // Modules Python code may import, nil for all
+	importDeny  []string // Modules Python code may not import
+}
+
+// WithVenv uses the virtual environment at dir: its site-packages is added
+// to sys.path (ahead of the system ones, which are removed unless the
+// environment was created with --system-site-packages) and sys.prefix is
+// set to dir. The virtual environment must be created with the same Python
+// version the program is linked with.
+func WithVenv(dir string) InitOption {
+	return func(c *initConfig) {
+		c.venv = dir
+	}
+}
+
+// WithCondaEnv uses the conda environment name by setting the Python home to
+// its directory. The conda installation is found from $CONDA_EXE or by running
+// "conda info --base". The environment's Python must be the same version the
+// program is linked with.
+func WithCondaEnv(name string) InitOption {
+	return func(c *initConfig) {
+		c.condaEnv = name
+	}
+}
+
+// WithLibPython loads libpython from path (e.g. /usr/lib/libpython3.12.so.1.0)
+// when built with the "py_dlopen" tag. Without it the newest Python 3.8 or
+// later found is used. The option is ignored in other builds, where libpython
+// is linked with the executable.
+func WithLibPython(path string) InitOption {
+	return func(c *initConfig) {
+		c.libPython = path
+	}
+}
+
+// Init initializes Python with opts. Calling Init is optional, Python is
+// otherwise initialized on first use with the environment inherited from the
+// process. Init must be called before any other function in the package and
+// only once.
+func Init(opts ...InitOption) error {
+	var cfg initConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	called := false
+	initOnce.Do(func() {
+		called = true
+		initErr = initPython(cfg)
+	})
+	if !called {
+		if pyFinalized() {
+			return ErrFinalized
+		}
+		return fmt.Errorf("Python already initialized")
+	}
+	return initErr
+}
+
+// venvCode sets up a virtual environment, venv is set in globals
+const venvCode = `
+import os, site, sys
+
+cfg = {}
+with open(os.path.join(venv, 'pyvenv.cfg')) as fp:
+    for line in fp:
+        key, _, value = line.partition('=')
+        cfg[key.strip()] = value.strip()
+
+if os.name == 'nt':
+    site_dir = os.path.join(venv, 'Lib', 'site-packages')
+else:
+    site_dir = os.path.join(
+        venv, 'lib', 'python%d.%d' % sys.version_info[:2], 'site-packages')
+if not os.path.isdir(site_dir):
+    raise FileNotFoundError('%s: no site-packages for Python %d.%d' % (
+        venv, *sys.version_info[:2]))
+
+if cfg.get('include-system-site-packages', 'false').lower() != 'true':
+    system = set(site.getsitepackages())
+    system.add(site.getusersitepackages())
+    sys.path[:] = [p for p in sys.path if p not in system]
+
+before = list(sys.path)
+site.addsitedir(site_dir)
+added = [p for p in sys.path if p not in before]
+sys.path[:] = added + before
+sys.prefix = sys.exec_prefix = venv
+`
+
+// initPython initializes Python & numpy with cfg
+func initPython(cfg initConfig) error {
+	if cfg.condaEnv != "" {
+		root, err := condaRoot()
+		if err != nil {
+			return err
+		}
+
+		home := root
+		if cfg.condaEnv != "base" {
+			home = filepath.Join(root, "envs", cfg.condaEnv)
+		}
+		if _, err := os.Stat(home); err != nil {
+			return fmt.Errorf("conda environment %q: %w", cfg.condaEnv, err)
+		}
+		cfg.config.Home = home
+	}
+
+	if err := loadLibPython(cfg.libPython, cfg.config.Home); err != nil {
+		return err
+	}
+
+	if err := initInterpreter(cfg.config, !cfg.goSignals); err != nil {
+		return err
+	}
+
+	if cfg.venv != "" {
+		if err := setupVenv(cfg.venv); err != nil {
+			return fmt.Errorf("venv %q: %w", cfg.venv, err)
+		}
+	}
+
+	if err := cError(C.import_numpy()); err != nil {
+		return err
+	}
+
+	// Last, numpy and the venv imports aren't filtered
+	if err := installImportFilter(cfg); err != nil {
+		return fmt.Errorf("import filter: %w", err)
+	}
+	return nil
+}
+
+// setupVenv adds the virtual environment at dir to the main interpreter
+func setupVenv(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	return execPython(venvCode, map[string]interface{}{"venv": dir})
+}
+
+// condaRoot returns the conda installation directory
+func condaRoot() (string, error) {
+	// $CONDA_EXE is <root>/bin/conda (<root>\Scripts\conda.exe on Windows)
+	if exe := os.Getenv("CONDA_EXE"); exe != "" {
+		return filepath.Dir(filepath.Dir(exe)), nil
+	}
+
+	out, err := exec.Command("conda", "info", "--base").Output()
+	if err != nil {
+		return "", fmt.Errorf("can't find conda installation: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
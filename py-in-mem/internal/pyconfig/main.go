@@ -0,0 +1,171 @@
+// pyconfig generates cgo flags for the installed Python and numpy.
+//
+// Run it with "go generate" in py-in-mem and then build with "-tags pyconfig".
+// On macOS framework builds of Python (python.org, Homebrew and Xcode
+// installs) are linked with -framework.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// Printed by Python as JSON
+const script = `
+import json
+import os
+import sys
+import sysconfig
+
+try:
+    import numpy
+    np_include = numpy.get_include()
+except ImportError:
+    np_include = ''
+
+if os.name == 'nt':
+    # python3X.dll is in the installation directory, MinGW links with the DLL
+    libdir = sys.base_prefix
+    version = '%d%d' % sys.version_info[:2]
+else:
+    libdir = sysconfig.get_config_var('LIBDIR') or ''
+    version = sysconfig.get_config_var('LDVERSION') or sysconfig.get_config_var('VERSION')
+
+print(json.dumps({
+    'include': sysconfig.get_paths()['include'],
+    'libdir': libdir,
+    'version': version,
+    'short_version': '%d.%d' % sys.version_info[:2],
+    'numpy_include': np_include,
+    'windows': os.name == 'nt',
+    # macOS framework builds (python.org, Homebrew, Xcode)
+    'framework': sysconfig.get_config_var('PYTHONFRAMEWORK') or '',
+    'framework_prefix': sysconfig.get_config_var('PYTHONFRAMEWORKPREFIX') or '',
+}))
+`
+
+// Config is Python build configuration
+type Config struct {
+	Include         string `json:"include"`
+	LibDir          string `json:"libdir"`
+	Version         string `json:"version"`       // Library version (e.g. 3.11 or 3.13t)
+	ShortVersion    string `json:"short_version"` // e.g. 3.11
+	NumpyInclude    string `json:"numpy_include"`
+	Windows         bool   `json:"windows"`
+	Framework       string `json:"framework"`        // macOS framework name (e.g. Python)
+	FrameworkPrefix string `json:"framework_prefix"` // Directory with the framework
+	Python          string `json:"-"`
+}
+
+// LDFlags returns the linker flags
+func (c Config) LDFlags() string {
+	switch {
+	case c.Framework != "":
+		return fmt.Sprintf("-F%s -framework %s -Wl,-rpath,%s", c.FrameworkPrefix, c.Framework, c.FrameworkPrefix)
+	case c.LibDir == "":
+		return "-lpython" + c.Version
+	case c.Windows:
+		return fmt.Sprintf("-L%s -lpython%s", c.LibDir, c.Version)
+	}
+	return fmt.Sprintf("-L%s -Wl,-rpath,%s -lpython%s", c.LibDir, c.LibDir, c.Version)
+}
+
+var tmpl = template.Must(template.New("flags").Parse(`// Code generated by pyconfig from {{.Python}} (Python {{.Version}}); DO NOT EDIT.
+
+//go:build pyconfig && !py_abi3 && !py_dlopen
+
+package outliers
+
+/*
+#cgo CFLAGS: -I{{.Include}}{{if .NumpyInclude}} -I{{.NumpyInclude}}{{end}}{{if .Windows}} -DMS_WIN64{{end}}
+#cgo LDFLAGS: {{.LDFlags}}
+*/
+import "C"
+`))
+
+func pyConfig(python string) (Config, error) {
+	out, err := exec.Command(python, "-c", script).Output()
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", python, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(out, &cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: bad output: %w", python, err)
+	}
+	cfg.Python = python
+
+	// cgo directives need forward slashes
+	cfg.Include = filepath.ToSlash(cfg.Include)
+	cfg.LibDir = filepath.ToSlash(cfg.LibDir)
+	cfg.NumpyInclude = filepath.ToSlash(cfg.NumpyInclude)
+
+	if cfg.Framework != "" && !frameworkCurrent(cfg) {
+		// -framework links with Versions/Current, use the library in LIBDIR
+		// (a link to the framework) to get the right version
+		log.Printf("warning: %s.framework current version isn't %s, linking with %s", cfg.Framework, cfg.ShortVersion, cfg.LibDir)
+		cfg.Framework = ""
+	}
+	return cfg, nil
+}
+
+// frameworkCurrent returns true if the current version of the framework is
+// cfg's Python version
+func frameworkCurrent(cfg Config) bool {
+	current := filepath.Join(cfg.FrameworkPrefix, cfg.Framework+".framework", "Versions", "Current")
+	dest, err := os.Readlink(current)
+	if err != nil {
+		return false
+	}
+	return filepath.Base(dest) == cfg.ShortVersion
+}
+
+func main() {
+	defPython := "python3"
+	if runtime.GOOS == "windows" {
+		defPython = "python"
+	}
+	python := flag.String("python", defPython, "python executable")
+	outFile := flag.String("o", "cgo_pyconfig.go", "output file")
+	flag.Parse()
+
+	cfg, err := pyConfig(*python)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	// cgo rejects flags with spaces
+	for _, dir := range []string{cfg.Include, cfg.LibDir, cfg.NumpyInclude, cfg.FrameworkPrefix} {
+		if strings.ContainsAny(dir, " \t") {
+			log.Fatalf("error: path with spaces not supported: %q", dir)
+		}
+	}
+
+	if cfg.NumpyInclude == "" {
+		log.Printf("warning: numpy not found for %s", *python)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cfg); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	if err := os.WriteFile(*outFile, code, 0644); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
@@ -0,0 +1,322 @@
+package outliers
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// ErrWorkerCrashed is returned by Isolated.Detect when the worker process
+// died during the call, e.g. on a Python fatal error or a crash in a C
+// extension
+var ErrWorkerCrashed = errors.New("python worker crashed")
+
+// workerEnv is set in the environment of worker processes, see init
+const workerEnv = "OUTLIERS_ISOLATED_WORKER"
+
+var (
+	isolatedStarts  = new(expvar.Int)
+	isolatedCrashes = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("isolated.starts", isolatedStarts)
+	metrics.Set("isolated.crashes", isolatedCrashes)
+
+	if os.Getenv(workerEnv) == "" {
+		return
+	}
+	// Don't make processes started by Python code workers
+	os.Unsetenv(workerEnv)
+	os.Exit(runWorker())
+}
+
+// Isolated runs a Python function in a worker process instead of the current
+// one, so a crash in Python (a fatal error, a segfault in a C extension, a
+// call to os.abort) fails the call with ErrWorkerCrashed instead of killing
+// the Go process. The next call starts a new worker.
+//
+// The worker is the current executable started again, the outliers package
+// init function runs the worker loop before main is called. The worker
+// initializes Python with the default options, add module directories to
+// sys.path with the paths passed to NewIsolated.
+//
+// Calls are serialized and data is copied to the worker, making Isolated
+// slower than Outliers. It's safe for concurrent use.
+type Isolated struct {
+	mu     sync.Mutex
+	start  workerStart
+	w      *worker // nil if not running
+	closed bool
+}
+
+// worker is a running worker process
+type worker struct {
+	cmd    *exec.Cmd
+	reqs   *os.File   // Write end of the requests pipe
+	exited chan error // cmd.Wait result
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+}
+
+// workerStart is the first message sent to a worker
+type workerStart struct {
+	Module string
+	Func   string
+	Paths  []string
+}
+
+type workerReq struct {
+	Data   []float64
+	Params map[string]float64
+}
+
+type workerResp struct {
+	Indices []int
+	PyErr   *PyError
+	Err     string
+}
+
+func (r workerResp) err() error {
+	switch {
+	case r.PyErr != nil:
+		return r.PyErr
+	case r.Err != "":
+		return errors.New(r.Err)
+	}
+	return nil
+}
+
+// NewIsolated returns an Isolated using moduleName.funcName Python function
+// in a worker process, paths are added to the worker sys.path (see AddPath).
+func NewIsolated(moduleName, funcName string, paths ...string) (*Isolated, error) {
+	i := Isolated{
+		start: workerStart{
+			Module: moduleName,
+			Func:   funcName,
+			Paths:  paths,
+		},
+	}
+
+	// Fail early on a bad executable or Python function
+	w, err := i.startWorker()
+	if err != nil {
+		return nil, err
+	}
+	i.w = w
+	return &i, nil
+}
+
+// Detect returns slice of outliers indices, see Outliers.Detect. If ctx is
+// done before the worker replies the worker is killed, the next call starts
+// a new one.
+func (i *Isolated) Detect(ctx context.Context, data []float64) ([]int, error) {
+	return i.DetectWithParams(ctx, data, nil)
+}
+
+// DetectWithParams is like Detect with keyword arguments, see
+// Outliers.DetectWithParams.
+func (i *Isolated) DetectWithParams(ctx context.Context, data []float64, params map[string]float64) ([]int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return nil, fmt.Errorf("closed")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if i.w == nil {
+		w, err := i.startWorker()
+		if err != nil {
+			return nil, err
+		}
+		i.w = w
+	}
+
+	w := i.w
+	done := make(chan struct{})
+	var (
+		resp workerResp
+		err  error
+	)
+	go func() {
+		defer close(done)
+		if err = w.enc.Encode(workerReq{data, params}); err == nil {
+			err = w.dec.Decode(&resp)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		w.cmd.Process.Kill()
+		<-done
+		i.stopWorker()
+		return nil, ctx.Err()
+	}
+
+	if err != nil {
+		isolatedCrashes.Add(1)
+		if werr := i.stopWorker(); werr != nil {
+			err = werr
+		}
+		return nil, fmt.Errorf("%w: %s", ErrWorkerCrashed, err)
+	}
+	return resp.Indices, resp.err()
+}
+
+// Close stops the worker process, you can't use the Isolated after closing it
+func (i *Isolated) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	if i.w == nil {
+		return nil
+	}
+	return i.stopWorker()
+}
+
+// startWorker starts a worker process and waits until it loaded the function
+func (i *Isolated) startWorker() (*worker, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return nil, err
+	}
+
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), workerEnv+"=1")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{reqR, respW} // fd 3 & 4 in the worker
+	err = cmd.Start()
+	// The worker has its own copies
+	reqR.Close()
+	respW.Close()
+	if err != nil {
+		reqW.Close()
+		respR.Close()
+		return nil, err
+	}
+	isolatedStarts.Add(1)
+
+	w := &worker{
+		cmd:    cmd,
+		reqs:   reqW,
+		exited: make(chan error, 1),
+		enc:    gob.NewEncoder(reqW),
+		dec:    gob.NewDecoder(respR),
+	}
+	// Reading a response fails once the worker exits
+	go func() {
+		w.exited <- cmd.Wait()
+		respR.Close()
+	}()
+
+	var resp workerResp
+	if err := w.enc.Encode(i.start); err == nil {
+		err = w.dec.Decode(&resp)
+	}
+	if err != nil {
+		w.stop()
+		return nil, fmt.Errorf("%w: start: %s", ErrWorkerCrashed, err)
+	}
+	if err := resp.err(); err != nil {
+		w.stop()
+		return nil, err
+	}
+	return w, nil
+}
+
+// stopWorker stops the current worker and returns its exit error, if any
+func (i *Isolated) stopWorker() error {
+	err := i.w.stop()
+	i.w = nil
+	return err
+}
+
+// stop closes the requests pipe, the worker exits when it reads EOF, and
+// waits for it.
+func (w *worker) stop() error {
+	w.reqs.Close()
+	if err := <-w.exited; err != nil {
+		return fmt.Errorf("worker: %w", err)
+	}
+	return nil
+}
+
+// runWorker runs in the worker process, it reads requests from fd 3 and
+// writes responses to fd 4. Returns the process exit code.
+func runWorker() int {
+	dec := gob.NewDecoder(os.NewFile(3, "requests"))
+	enc := gob.NewEncoder(os.NewFile(4, "responses"))
+
+	var start workerStart
+	if err := dec.Decode(&start); err != nil {
+		log.Printf("outliers worker: %s", err)
+		return 1
+	}
+
+	o, err := workerOutliers(start)
+	if err := enc.Encode(newWorkerResp(nil, err)); err != nil || o == nil {
+		return 1
+	}
+	defer o.Close()
+
+	for {
+		var req workerReq
+		if err := dec.Decode(&req); err != nil {
+			return 0 // Parent closed the pipe
+		}
+
+		indices, err := o.DetectWithParams(req.Data, req.Params)
+		if err := enc.Encode(newWorkerResp(indices, err)); err != nil {
+			log.Printf("outliers worker: %s", err)
+			return 1
+		}
+	}
+}
+
+// workerOutliers returns the Outliers of the worker
+func workerOutliers(start workerStart) (*Outliers, error) {
+	for _, path := range start.Paths {
+		if err := AddPath(path); err != nil {
+			return nil, err
+		}
+	}
+	return NewOutliers(start.Module, start.Func)
+}
+
+func newWorkerResp(indices []int, err error) workerResp {
+	resp := workerResp{Indices: indices}
+	var pyErr *PyError
+	switch {
+	case errors.As(err, &pyErr):
+		resp.PyErr = pyErr
+	case err != nil:
+		resp.Err = err.Error()
+	}
+	return resp
+}
@@ -0,0 +1,151 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"unsafe"
+)
+
+// Handles (Outliers, PyFunc and Result values) have a finalizer that frees the
+// Python objects they hold if they are garbage collected without being
+// closed. Finalizers run at an unspecified time, you should still call Close
+// (or Release).
+
+var leaks struct {
+	sync.Mutex
+	debug   bool
+	handles map[uintptr]handle // Open handles
+}
+
+type handle struct {
+	kind    string
+	interp  *C.PyInterpreterState
+	release func() // Frees the handle Python objects, nil if Close does it
+	stack   []byte // Where the handle was created, only in debug mode
+	leaked  bool   // Logged by logLeak
+}
+
+// DebugLeaks turns leak debugging on or off. When on, handles record where
+// they were created, handles collected by the garbage collector without being
+// closed are logged, and handles still open when their sub-interpreter shuts
+// down (see WithSubInterpreter) or when Python is finalized (see Finalize)
+// are logged.
+func DebugLeaks(on bool) {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	leaks.debug = on
+}
+
+// track records the open handle at ptr. release frees the Python objects it
+// holds, it's called by Close (see untrack) or by Finalize if the handle is
+// still open. release must not reference the handle, which would never be
+// garbage collected.
+func track(ptr unsafe.Pointer, kind string, interp *C.PyInterpreterState, release func()) {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	if leaks.handles == nil {
+		leaks.handles = make(map[uintptr]handle)
+	}
+	h := handle{kind: kind, interp: interp, release: release}
+	if leaks.debug {
+		h.stack = debug.Stack()
+	}
+	// uintptr keys don't keep handles alive
+	leaks.handles[uintptr(ptr)] = h
+}
+
+// untrack removes the handle at ptr, called on Close. It returns the release
+// function passed to track, or nil if the handle Python objects are already
+// freed: its sub-interpreter was closed or Python was finalized.
+func untrack(ptr unsafe.Pointer) func() {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	h, ok := leaks.handles[uintptr(ptr)]
+	if !ok {
+		return nil
+	}
+	delete(leaks.handles, uintptr(ptr))
+	return h.release
+}
+
+// logLeak logs the handle at ptr in debug mode, called from finalizers before
+// closing the handle
+func logLeak(ptr unsafe.Pointer) {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	h, ok := leaks.handles[uintptr(ptr)]
+	if !ok || !leaks.debug || h.leaked {
+		return
+	}
+	h.leaked = true
+	leaks.handles[uintptr(ptr)] = h
+	log.Printf("outliers: %s garbage collected without Close, created at:\n%s", h.kind, h.stack)
+}
+
+// logOpen removes handles still open in interp, which is shutting down and
+// frees their Python objects, and logs them in debug mode
+func logOpen(interp *C.PyInterpreterState) {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	for ptr, h := range leaks.handles {
+		if h.interp != interp {
+			continue
+		}
+		delete(leaks.handles, ptr)
+		if leaks.debug {
+			log.Printf("outliers: %s open at interpreter shutdown, created at:\n%s", h.kind, h.stack)
+		}
+	}
+}
+
+// releaseAllOpen frees the Python objects of handles still open, in all
+// interpreters, and logs them in debug mode. Closing them afterwards is a
+// no-op. Outliers are released last: releasing one created
+// WithSubInterpreter ends its interpreter.
+func releaseAllOpen() {
+	leaks.Lock()
+	var handles, outliers []handle
+	for ptr, h := range leaks.handles {
+		delete(leaks.handles, ptr)
+		if leaks.debug {
+			log.Printf("outliers: %s open at Python finalization, created at:\n%s", h.kind, h.stack)
+		}
+		if h.kind == "Outliers" || h.kind == "Stream" {
+			outliers = append(outliers, h)
+		} else {
+			handles = append(handles, h)
+		}
+	}
+	leaks.Unlock()
+
+	for _, h := range append(handles, outliers...) {
+		if h.release != nil {
+			h.release()
+		}
+	}
+}
+
+// CheckRefs logs references to Python objects in the main interpreter held by
+// handles (Outliers, PyFunc, Result, Object and Stream values) that weren't
+// released yet, with where they were acquired, and returns an error if there
+// are any. Call it at shutdown after closing all handles. Closing an Outliers
+// created WithSubInterpreter does the same check for its interpreter. Unused
+// functions in the function cache are released first (see ClearFuncCache).
+//
+// References are tracked only when building with the "py_refdebug" tag,
+// otherwise CheckRefs returns nil.
+func CheckRefs() error {
+	ClearFuncCache()
+	return checkRefs(nil, false)
+}
@@ -0,0 +1,25 @@
+package outliers
+
+import "fmt"
+
+// libPythonNames returns the names of Python 3.8 and later shared libraries
+// on goos, newest first. Used when loading libpython at runtime (see
+// cgo_dlopen.go).
+func libPythonNames(goos string) []string {
+	var names []string
+	for minor := 13; minor >= 8; minor-- {
+		switch goos {
+		case "windows":
+			names = append(names, fmt.Sprintf("python3%d.dll", minor))
+		case "darwin":
+			names = append(names, fmt.Sprintf("libpython3.%d.dylib", minor))
+		default:
+			names = append(names, fmt.Sprintf("libpython3.%d.so.1.0", minor))
+		}
+	}
+	if goos == "windows" {
+		// Stable ABI DLL forwarding to the installed python3X.dll
+		names = append(names, "python3.dll")
+	}
+	return names
+}
@@ -0,0 +1,9 @@
+//go:build !py_dlopen
+
+package outliers
+
+// loadLibPython is a no-op, libpython is linked with the executable. See
+// cgo_dlopen.go for loading it at runtime.
+func loadLibPython(path, home string) error {
+	return nil
+}
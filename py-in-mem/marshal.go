@@ -0,0 +1,171 @@
+package outliers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Structs are converted to Python dicts with a key per exported field. The
+// "py" struct tag sets the key name, "-" skips the field and the "omitempty"
+// option skips the field if it has the zero value:
+//
+//	type Config struct {
+//		Window    int     `py:"window"`
+//		Threshold float64 `py:"threshold,omitempty"`
+//		Debug     bool    `py:"-"`
+//	}
+
+// Decode stores v, a value returned by PyFunc.Call, in out, which must be a
+// non-nil pointer. dicts are decoded to structs by key (see the "py" tag
+// above) or to maps, lists to slices or arrays, and numbers are converted to
+// the type of the destination.
+func Decode(v interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%T is not a non-nil pointer", out)
+	}
+	return setValue(rv.Elem(), v)
+}
+
+// structField is an exported struct field
+type structField struct {
+	Name      string // Name in Python
+	Index     []int
+	OmitEmpty bool
+}
+
+// structFields returns the exported fields of typ, honoring "py" tags.
+func structFields(typ reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("py")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			Name:      name,
+			Index:     f.Index,
+			OmitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
+
+// structToMap converts a struct to a map with a key per field, see
+// structFields.
+func structToMap(rv reflect.Value) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, f := range structFields(rv.Type()) {
+		fv := rv.FieldByIndex(f.Index)
+		if f.OmitEmpty && fv.IsZero() {
+			continue
+		}
+		m[f.Name] = fv.Interface()
+	}
+	return m
+}
+
+// setValue sets dest to v converted from Python, numbers are converted to the
+// type of dest.
+func setValue(dest reflect.Value, v interface{}) error {
+	if v == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Type().AssignableTo(dest.Type()):
+		dest.Set(rv)
+		return nil
+	case isNumber(rv.Kind()) && isNumber(dest.Kind()),
+		isComplex(rv.Kind()) && isComplex(dest.Kind()):
+		dest.Set(rv.Convert(dest.Type()))
+		return nil
+	case isNumber(rv.Kind()) && isComplex(dest.Kind()):
+		dest.SetComplex(complex(rv.Convert(reflect.TypeOf(0.0)).Float(), 0))
+		return nil
+	}
+
+	switch dest.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(dest.Type().Elem())
+		if err := setValue(ptr.Elem(), v); err != nil {
+			return err
+		}
+		dest.Set(ptr)
+		return nil
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, f := range structFields(dest.Type()) {
+			fv, ok := m[f.Name]
+			if !ok {
+				continue
+			}
+			if err := setValue(dest.FieldByIndex(f.Index), fv); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			break
+		}
+		if dest.Kind() == reflect.Array && len(items) != dest.Len() {
+			return fmt.Errorf("can't set %d items to %s", len(items), dest.Type())
+		}
+		if dest.Kind() == reflect.Slice {
+			dest.Set(reflect.MakeSlice(dest.Type(), len(items), len(items)))
+		}
+		for i, item := range items {
+			if err := setValue(dest.Index(i), item); err != nil {
+				return fmt.Errorf("%d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		if rv.Kind() != reflect.Map {
+			break
+		}
+		typ := dest.Type()
+		m := reflect.MakeMapWithSize(typ, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := reflect.New(typ.Key()).Elem()
+			if err := setValue(key, iter.Key().Interface()); err != nil {
+				return fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+			val := reflect.New(typ.Elem()).Elem()
+			if err := setValue(val, iter.Value().Interface()); err != nil {
+				return fmt.Errorf("%v: %w", iter.Key(), err)
+			}
+			m.SetMapIndex(key, val)
+		}
+		dest.Set(m)
+		return nil
+	}
+
+	return fmt.Errorf("can't set %T to %s", v, dest.Type())
+}
+
+func isNumber(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func isComplex(k reflect.Kind) bool {
+	return k == reflect.Complex64 || k == reflect.Complex128
+}
@@ -0,0 +1,163 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// MemoryView passes a []byte to Python as a writable memoryview sharing its
+// memory, without copying it, e.g. for Python functions parsing large binary
+// buffers. Pass the MemoryView as an argument to PyFunc.Call or Object.Call:
+//
+//	mv, err := outliers.NewMemoryView(buf)
+//	...
+//	defer mv.Release()
+//	records, err := parse.Call(mv)
+//
+// Python code can read and write buf through the memoryview, and keep it,
+// until Release. Go code shouldn't modify buf while Python uses it.
+type MemoryView struct {
+	mu     sync.RWMutex
+	data   []byte
+	pinner runtime.Pinner // Keeps data in place while Python has its address
+	obj    *C.PyObject    // The memoryview, nil once released
+	owner  *C.PyObject    // ctypes array exporting data, see py_memoryview
+}
+
+// ErrBufferInUse is returned by MemoryView.Release when Python code still
+// uses the memory
+var ErrBufferInUse = errors.New("buffer used by Python")
+
+// emptyData is the memory of empty memoryviews, Python needs a non nil
+// pointer
+var emptyData [1]byte
+
+// NewMemoryView returns a MemoryView of data, call Release when Python is
+// done with it.
+func NewMemoryView(data []byte) (*MemoryView, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	m := &MemoryView{data: data}
+	ptr := &emptyData[0]
+	if len(data) > 0 {
+		ptr = &data[0]
+		m.pinner.Pin(ptr)
+	}
+
+	err := withGIL(func() error {
+		m.obj = C.py_memoryview(unsafe.Pointer(ptr), C.Py_ssize_t(len(data)), &m.owner)
+		if m.obj == nil {
+			return cError(C.py_error())
+		}
+		return nil
+	})
+	if err != nil {
+		m.pinner.Unpin()
+		return nil, err
+	}
+
+	refAcquired(m.obj, nil)
+	refAcquired(m.owner, nil)
+	track(unsafe.Pointer(m), "MemoryView", nil, nil) // Release unpins data after Finalize
+	runtime.SetFinalizer(m, finalizeMemoryView)
+	return m, nil
+}
+
+// finalizeMemoryView releases a MemoryView that wasn't released. If Python
+// still uses the memory we try again in the next garbage collection, data
+// stays in place until then.
+func finalizeMemoryView(m *MemoryView) {
+	logLeak(unsafe.Pointer(m))
+	if m.Release() != nil {
+		runtime.SetFinalizer(m, finalizeMemoryView)
+	}
+}
+
+// Release releases the memoryview (memoryview.release() in Python), using it
+// in Python afterwards raises ValueError, and unpins the data. It fails with
+// ErrBufferInUse if Python code still uses the memory, e.g. through
+// numpy.frombuffer(view) or memoryview(view): try again once these objects
+// are gone.
+func (m *MemoryView) Release() error {
+	name := C.CString("release")
+	defer C.free(unsafe.Pointer(name))
+
+	if pyFinalized() { // Python objects are gone, only unpin
+		m.mu.Lock()
+		m.obj, m.owner = nil, nil
+		m.mu.Unlock()
+		runtime.SetFinalizer(m, nil)
+		untrack(unsafe.Pointer(m))
+		m.pinner.Unpin()
+		m.data = nil
+		return nil
+	}
+
+	released := false
+	// Lock the MemoryView with the GIL held, toPython locks it in this order
+	err := withGIL(func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.owner == nil {
+			return nil
+		}
+
+		if m.obj != nil {
+			out := C.py_call_method(m.obj, name)
+			if out == nil {
+				return fmt.Errorf("%w: %w", ErrBufferInUse, cError(C.py_error()))
+			}
+			C.Py_DecRef(out)
+			C.Py_DecRef(m.obj)
+			refReleased(m.obj)
+			m.obj = nil
+		}
+
+		// Views derived from ours hold a reference to owner
+		if C.py_refcnt(m.owner) > 1 {
+			return ErrBufferInUse
+		}
+		C.Py_DecRef(m.owner)
+		refReleased(m.owner)
+		m.owner = nil
+		released = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("release memoryview: %w", err)
+	}
+	if !released {
+		return nil
+	}
+
+	runtime.SetFinalizer(m, nil)
+	untrack(unsafe.Pointer(m))
+	m.pinner.Unpin()
+	runtime.KeepAlive(m.data)
+	m.data = nil
+	return nil
+}
+
+// newRef returns a new reference to the memoryview, must be called with the
+// GIL held.
+func (m *MemoryView) newRef() (*C.PyObject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.obj == nil {
+		return nil, fmt.Errorf("released memoryview")
+	}
+	C.Py_IncRef(m.obj)
+	return m.obj, nil
+}
@@ -0,0 +1,128 @@
+package outliers
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"runtime/pprof"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics are published with expvar under "outliers" (see /debug/vars when
+// importing net/http/pprof or expvar's handler):
+//
+//	new_outliers.calls    Number of NewOutliers calls
+//	new_outliers.errors   Number of failed NewOutliers calls
+//	new_outliers.latency  NewOutliers latency histogram
+//	detect.calls          Number of calls to Python by Detect methods
+//	detect.errors         Number of failed calls to Python
+//	detect.latency        Latency histogram of calls to Python
+//	func_cache.hits       Number of NewOutliers using a cached function
+//	func_cache.misses     Number of NewOutliers loading the function
+//	func_cache.evictions  Number of functions released from the cache
+//	isolated.starts       Number of Isolated worker processes started
+//	isolated.crashes      Number of Isolated worker processes that crashed
+//
+// Histograms are JSON objects with "count", "sum" (in seconds) and
+// "buckets", a map from upper bound in seconds ("+Inf" for the last one) to
+// the number of calls in the bucket.
+//
+// Calls to Python run with the pprof label "outliers" set to "detect" or
+// "new_outliers", so CPU profiles can be filtered by time spent in Python
+// (e.g. go tool pprof -tagfocus outliers=detect).
+var metrics = expvar.NewMap("outliers")
+
+var (
+	newOutliersMetrics = newOpMetrics("new_outliers")
+	detectMetrics      = newOpMetrics("detect")
+)
+
+// latencyBuckets are histogram upper bounds, the last bucket is +Inf
+var latencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+type opMetrics struct {
+	name    string
+	calls   *expvar.Int
+	errors  *expvar.Int
+	latency *histogram
+}
+
+func newOpMetrics(name string) *opMetrics {
+	m := &opMetrics{
+		name:    name,
+		calls:   new(expvar.Int),
+		errors:  new(expvar.Int),
+		latency: newHistogram(latencyBuckets),
+	}
+	metrics.Set(name+".calls", m.calls)
+	metrics.Set(name+".errors", m.errors)
+	metrics.Set(name+".latency", m.latency)
+	return m
+}
+
+// do runs fn with m's pprof label and records its latency and error
+func (m *opMetrics) do(fn func() error) error {
+	var err error
+	start := time.Now()
+	pprof.Do(context.Background(), pprof.Labels("outliers", m.name), func(context.Context) {
+		err = fn()
+	})
+	m.latency.observe(time.Since(start))
+	m.calls.Add(1)
+	if err != nil {
+		m.errors.Add(1)
+	}
+	return err
+}
+
+// histogram is a latency histogram, it implements expvar.Var
+type histogram struct {
+	bounds []time.Duration
+	counts []atomic.Int64 // len(bounds)+1, last is +Inf
+	count  atomic.Int64
+	sum    atomic.Int64 // nanoseconds
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]atomic.Int64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// String implements expvar.Var
+func (h *histogram) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"count": %d, "sum": %g, "buckets": {`, h.count.Load(), time.Duration(h.sum.Load()).Seconds())
+	for i := range h.counts {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		bound := "+Inf"
+		if i < len(h.bounds) {
+			bound = fmt.Sprintf("%g", h.bounds[i].Seconds())
+		}
+		fmt.Fprintf(&b, "%q: %d", bound, h.counts[i].Load())
+	}
+	b.WriteString("}}")
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package outliers
+
+/*
+#include <stdlib.h>
+
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Module is a Python module imported once in the main interpreter, functions
+// are looked up on it when bound with Func or Outliers. Use it instead of
+// LoadFunc or NewOutliers when calling several functions from the same
+// module. It's safe for concurrent use.
+type Module struct {
+	mu   sync.RWMutex
+	name string
+	mod  *C.PyObject
+}
+
+// LoadModule imports the Python module name, e.g. "import name" in Python.
+func LoadModule(name string) (*Module, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var mod *C.PyObject
+	err := withGIL(func() error {
+		mod = C.PyImport_ImportModule(cName)
+		if mod == nil {
+			return cError(C.py_error())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	refAcquired(mod, nil)
+
+	m := &Module{name: name, mod: mod}
+	track(unsafe.Pointer(m), "Module", nil, func() {
+		C.py_decref(mod)
+		refReleased(mod)
+	})
+	runtime.SetFinalizer(m, func(m *Module) {
+		logLeak(unsafe.Pointer(m))
+		m.Close()
+	})
+
+	return m, nil
+}
+
+// Name returns the module name.
+func (m *Module) Name() string {
+	return m.name
+}
+
+// Func returns the module function name, e.g. module.name in Python. The
+// function stays valid after the module is closed.
+func (m *Module) Func(name string) (*PyFunc, error) {
+	fn, err := m.attr(nil, name)
+	if err != nil {
+		return nil, err
+	}
+	return newPyFunc(fn), nil
+}
+
+// Outliers returns a new Outliers using the module function name, see
+// NewOutliers. WithSubInterpreter isn't supported since the module lives in
+// the main interpreter.
+func (m *Module) Outliers(name string, opts ...Option) (*Outliers, error) {
+	var o *Outliers
+	err := newOutliersMetrics.do(func() error {
+		var err error
+		o, err = newOutliers(func(interp *C.PyInterpreterState) (*C.PyObject, error) {
+			return m.attr(interp, name)
+		}, nil, opts...)
+		return err
+	})
+	return o, err
+}
+
+// attr returns a new reference to m.name, interp must be nil (main)
+func (m *Module) attr(interp *C.PyInterpreterState, name string) (*C.PyObject, error) {
+	if interp != nil {
+		return nil, fmt.Errorf("%s.%s: module functions can't run in a sub-interpreter", m.name, name)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.mod == nil {
+		return nil, fmt.Errorf("%s: closed", m.name)
+	}
+
+	var fn *C.PyObject
+	err := withGIL(func() error {
+		var err error
+		fn, err = getAttr(m.mod, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	refAcquired(fn, nil)
+	return fn, nil
+}
+
+// Close frees the underlying Python module. Functions and Outliers bound from
+// the module remain usable.
+func (m *Module) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mod == nil {
+		return
+	}
+	runtime.SetFinalizer(m, nil)
+	if release := untrack(unsafe.Pointer(m)); release != nil {
+		release()
+	}
+	m.mod = nil
+}
@@ -0,0 +1,100 @@
+package outliers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NaNPolicy is how Detect methods handle NaN values in data, see
+// WithNaNPolicy
+type NaNPolicy int
+
+const (
+	// NaNForward passes NaN values to the Python function (the default)
+	NaNForward NaNPolicy = iota
+	// NaNDrop removes NaN values before calling the Python function, returned
+	// indices are mapped back to positions in the original data
+	NaNDrop
+	// NaNError fails the call with ErrNaN if data contains NaN values
+	NaNError
+)
+
+// ErrNaN is returned by Detect methods when data contains NaN values and the
+// policy is NaNError
+var ErrNaN = errors.New("data contains NaN")
+
+// dropNaN applies policy to data. With NaNDrop it returns data without NaN
+// values and the original index of each value kept, or a nil index if there
+// was nothing to drop.
+func dropNaN[T float32 | float64 | complex128](data []T, policy NaNPolicy) ([]T, []int, error) {
+	if policy == NaNForward {
+		return data, nil, nil
+	}
+
+	first := -1
+	for i, v := range data {
+		if isNaN(v) {
+			first = i
+			break
+		}
+	}
+
+	switch {
+	case first == -1:
+		return data, nil, nil
+	case policy == NaNError:
+		return nil, nil, fmt.Errorf("%w (index %d)", ErrNaN, first)
+	}
+
+	values := make([]T, 0, len(data))
+	keep := make([]int, 0, len(data))
+	for i, v := range data {
+		if !isNaN(v) {
+			values = append(values, v)
+			keep = append(keep, i)
+		}
+	}
+	return values, keep, nil
+}
+
+// dropNaNRows is like dropNaN for a matrix (in row major order), with NaNDrop
+// rows with a NaN value are removed and keep has the original row indices.
+func dropNaNRows(data []float64, rows, cols int, policy NaNPolicy) ([]float64, int, []int, error) {
+	values, keep, err := dropNaN(data, policy)
+	if err != nil || keep == nil {
+		return values, rows, nil, err
+	}
+
+	values = make([]float64, 0, len(data))
+	keep = make([]int, 0, rows)
+	for r := 0; r < rows; r++ {
+		row := data[r*cols : (r+1)*cols]
+		if _, k, _ := dropNaN(row, NaNDrop); k == nil {
+			values = append(values, row...)
+			keep = append(keep, r)
+		}
+	}
+	return values, len(keep), keep, nil
+}
+
+// remap maps indices into data with dropped values back to indices into the
+// original data, keep is from dropNaN.
+func remap(indices, keep []int) ([]int, error) {
+	if keep == nil {
+		return indices, nil
+	}
+
+	out := make([]int, len(indices))
+	for i, idx := range indices {
+		if idx < 0 || idx >= len(keep) {
+			return nil, fmt.Errorf("index %d out of range [0:%d]", idx, len(keep))
+		}
+		out[i] = keep[idx]
+	}
+	return out, nil
+}
+
+// isNaN returns true if v is NaN, for complex values if either part is NaN.
+func isNaN[T float32 | float64 | complex128](v T) bool {
+	return v != v // NaN is the only value not equal to itself
+}
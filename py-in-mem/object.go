@@ -0,0 +1,271 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Object is a reference to a Python object in the main interpreter, it lets
+// Go code navigate results that don't convert to Go values (see PyFunc.Call).
+// Call DecRef when done with the object. It's safe for concurrent use.
+//
+// Objects can be passed as arguments to PyFunc.Call and Object.Call.
+type Object struct {
+	mu  sync.RWMutex
+	obj *C.PyObject
+}
+
+// newObject returns an Object owning the reference to obj.
+func newObject(obj *C.PyObject) *Object {
+	o := &Object{obj: obj}
+	refAcquired(obj, nil)
+	track(unsafe.Pointer(o), "Object", nil, func() {
+		C.py_decref(obj)
+		refReleased(obj)
+	})
+	runtime.SetFinalizer(o, func(o *Object) {
+		logLeak(unsafe.Pointer(o))
+		o.DecRef()
+	})
+	return o
+}
+
+// NewInstance creates an instance of moduleName.className with args,
+// converted as in PyFunc.Call, e.g. an sklearn estimator
+//
+//	model, err := outliers.NewInstance("sklearn.ensemble", "IsolationForest")
+//	...
+//	labels, err := model.CallMethod("fit_predict", rows)
+func NewInstance(moduleName, className string, args ...interface{}) (*Object, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	args, _, err := prepareArgs(args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *C.PyObject
+	err = withGIL(func() error {
+		cls, err := importAttr(moduleName, className)
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(cls)
+
+		out, err = callArgs(cls, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newObject(out), nil
+}
+
+// CallObject is like Call but returns the result as an Object without
+// converting it.
+func (f *PyFunc) CallObject(args ...interface{}) (*Object, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	args, _, err := prepareArgs(args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *C.PyObject
+	err = withGIL(func() error {
+		var err error
+		out, err = callArgs(f.fn, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newObject(out), nil
+}
+
+// GetAttr returns the attribute name of the object, e.g. o.name in Python.
+func (o *Object) GetAttr(name string) (*Object, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.obj == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	var out *C.PyObject
+	err := withGIL(func() error {
+		var err error
+		out, err = getAttr(o.obj, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newObject(out), nil
+}
+
+// Call calls the object with args, converted as in PyFunc.Call, and returns
+// the result as an Object.
+func (o *Object) Call(args ...interface{}) (*Object, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.obj == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	args, _, err := prepareArgs(args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *C.PyObject
+	err = withGIL(func() error {
+		var err error
+		out, err = callArgs(o.obj, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newObject(out), nil
+}
+
+// CallMethod calls the method name of the object with args, converted as in
+// PyFunc.Call, and returns the result as an Object.
+func (o *Object) CallMethod(name string, args ...interface{}) (*Object, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.obj == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	args, _, err := prepareArgs(args, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *C.PyObject
+	err = withGIL(func() error {
+		method, err := getAttr(o.obj, name)
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(method)
+
+		out, err = callArgs(method, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newObject(out), nil
+}
+
+// Value converts the object to a Go value, see PyFunc.Call for the mapping.
+func (o *Object) Value() (interface{}, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.obj == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	var out interface{}
+	err := withGIL(func() error {
+		var err error
+		out, err = fromPython(o.obj)
+		return err
+	})
+	return out, err
+}
+
+// String returns str(o) in Python.
+func (o *Object) String() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.obj == nil {
+		return "<closed>"
+	}
+
+	var s string
+	err := withGIL(func() error {
+		str := C.PyObject_Str(o.obj)
+		if str == nil {
+			return cError(C.py_error())
+		}
+		defer C.Py_DecRef(str)
+
+		v, err := fromPython(str)
+		if err != nil {
+			return err
+		}
+		s, _ = v.(string)
+		return nil
+	})
+	if err != nil {
+		return fmt.Sprintf("<error: %s>", err)
+	}
+	return s
+}
+
+// DecRef releases the reference to the Python object, you can't use the
+// object after calling DecRef.
+func (o *Object) DecRef() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.obj == nil {
+		return
+	}
+	runtime.SetFinalizer(o, nil)
+	if release := untrack(unsafe.Pointer(o)); release != nil {
+		release()
+	}
+	o.obj = nil
+}
+
+// newRef returns a new reference to the Python object, must be called with
+// the GIL held.
+func (o *Object) newRef() (*C.PyObject, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.obj == nil {
+		return nil, fmt.Errorf("closed object")
+	}
+	C.Py_IncRef(o.obj)
+	return o.obj, nil
+}
+
+// callArgs calls fn with args converted to Python and returns the result,
+// awaiting it if needed. Must be called with the GIL held.
+func callArgs(fn *C.PyObject, args []interface{}) (*C.PyObject, error) {
+	pyArgs, err := toPyTuple(args)
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(pyArgs)
+
+	out := C.py_await(C.PyObject_Call(fn, pyArgs, nil))
+	if out == nil {
+		return nil, cError(C.py_error())
+	}
+	return out, nil
+}
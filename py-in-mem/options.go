@@ -0,0 +1,60 @@
+package outliers
+
+import "time"
+
+// Option is an option for NewOutliers
+type Option func(*Outliers)
+
+// WithTimeout aborts Python calls running longer than d by raising
+// TimeoutError in the calling thread, the call then fails with ErrTimeout.
+//
+// Python checks for the exception between bytecode instructions, a call stuck
+// in C code (such as a long numpy operation) is aborted only when it returns
+// to Python code.
+func WithTimeout(d time.Duration) Option {
+	return func(o *Outliers) {
+		o.timeout = d
+	}
+}
+
+// WithSubInterpreter runs the Outliers in its own Python sub-interpreter, so
+// modules it imports and their global state are not shared with other
+// Outliers. Results from DetectView must be released before calling Close.
+//
+// Sub-interpreters still share the GIL with the main interpreter. Some
+// extension modules, numpy included, don't fully support sub-interpreters.
+func WithSubInterpreter() Option {
+	return func(o *Outliers) {
+		o.subInterp = true
+	}
+}
+
+// WithMaxResultSize sets the maximal number of indices Detect (and other
+// methods copying the result) accept from the Python function, larger results
+// fail with a *ResultSizeError. The default is DefaultMaxResultSize, n <= 0
+// means no limit.
+func WithMaxResultSize(n int) Option {
+	return func(o *Outliers) {
+		o.maxResult = n
+	}
+}
+
+// WithNaNPolicy sets how Detect methods handle NaN values in data, the
+// default is NaNForward. Streams only support NaNForward and NaNError.
+func WithNaNPolicy(p NaNPolicy) Option {
+	return func(o *Outliers) {
+		o.nanPolicy = p
+	}
+}
+
+// WithWarnings calls fn with Python warnings (see the warnings module)
+// emitted by Detect calls, after the call returns. Warnings are then no
+// longer printed to stderr. Python's warning filters still apply, by default
+// a warning is shown once per code location. Warnings emitted in other
+// threads (e.g. by async functions, see PyFunc.Call) are not collected.
+// WithWarnings can't be used with WithSubInterpreter.
+func WithWarnings(fn func(Warning)) Option {
+	return func(o *Outliers) {
+		o.onWarning = fn
+	}
+}
@@ -3,15 +3,16 @@ package outliers
 
 import (
 	"fmt"
+	"log"
 	"runtime"
 	"sync"
+	"time"
 	"unsafe"
 )
 
-/*
-#cgo pkg-config: python3
-#cgo LDFLAGS: -lpython3.11
+//go:generate go run ./internal/pyconfig -o cgo_pyconfig.go
 
+/*
 #include "glue.h"
 */
 import "C"
@@ -21,36 +22,144 @@ var (
 	initErr  error
 )
 
-// initialize Python & numpy, idempotent
-func initialize() {
+// initialize Python & numpy with the default configuration unless Init was
+// called, idempotent. Returns the initialization error, or ErrFinalized after
+// Finalize.
+func initialize() error {
 	initOnce.Do(func() {
-		C.init_python()
-		initErr = pyLastError()
+		initErr = initPython(initConfig{})
 	})
+	if pyFinalized() {
+		return ErrFinalized
+	}
+	return initErr
 }
 
 // Outliers does outlier detection
+//
+// Outliers is safe for concurrent use. Each call acquires the Python GIL in
+// the C glue and releases it before returning, so calls to Python are
+// serialized while Go code keeps running in parallel.
 type Outliers struct {
-	fn *C.PyObject // Outlier detection Python function object
+	mu        sync.RWMutex          // Guards fn, Close waits for running calls
+	fn        *C.PyObject           // Outlier detection Python function object
+	timeout   time.Duration         // Per call timeout, 0 means no timeout
+	subInterp bool                  // Run in a sub-interpreter
+	interp    *C.PyInterpreterState // Sub-interpreter, nil for main
+	interpTS  *C.PyThreadState      // Initial thread state of interp
+	maxResult int                   // Maximal number of indices in a result, 0 for no limit
+	nanPolicy NaNPolicy             // How to handle NaN values in data
+	onWarning func(Warning)         // Called with Python warnings, see WithWarnings
+	cacheKey  *funcKey              // fn key in the function cache, nil if not cached
+}
+
+// NewOutliers returns an new Outliers using moduleName.funcName Python
+// function. In the main interpreter the function comes from the function
+// cache (see SetFuncCacheSize), so the module isn't imported again.
+func NewOutliers(moduleName, funcName string, opts ...Option) (*Outliers, error) {
+	var o *Outliers
+	err := newOutliersMetrics.do(func() error {
+		var err error
+		o, err = newOutliers(func(interp *C.PyInterpreterState) (*C.PyObject, error) {
+			if interp != nil {
+				return loadPyFunc(interp, moduleName, funcName)
+			}
+			return acquireFunc(moduleName, funcName)
+		}, &funcKey{moduleName, funcName}, opts...)
+		return err
+	})
+	return o, err
 }
 
-// NewOutliers returns an new Outliers using moduleName.funcName Python function
-func NewOutliers(moduleName, funcName string) (*Outliers, error) {
-	initialize()
-	if initErr != nil {
-		return nil, initErr
+// newOutliers returns a new Outliers with the function load returns in the
+// Outliers interpreter (nil for main). key is the function cache key of the
+// function load returns in the main interpreter, nil if it isn't cached.
+func newOutliers(load func(*C.PyInterpreterState) (*C.PyObject, error), key *funcKey, opts ...Option) (*Outliers, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	o := &Outliers{maxResult: DefaultMaxResultSize}
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	fn, err := loadPyFunc(moduleName, funcName)
+	if o.onWarning != nil {
+		if o.subInterp {
+			return nil, fmt.Errorf("WithWarnings not supported with WithSubInterpreter")
+		}
+		if err := installWarnings(); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.subInterp {
+		var cErr *C.py_error_t
+		o.interp = C.new_interpreter(&o.interpTS, &cErr)
+		if o.interp == nil {
+			return nil, cError(cErr)
+		}
+	}
+
+	fn, err := load(o.interp)
 	if err != nil {
+		if o.interp != nil {
+			C.end_interpreter(o.interpTS)
+		}
 		return nil, err
 	}
+	o.fn = fn
+	if o.interp == nil {
+		o.cacheKey = key
+	}
+
+	track(unsafe.Pointer(o), "Outliers", o.interp, o.releaser(nil))
+	runtime.SetFinalizer(o, func(o *Outliers) {
+		logLeak(unsafe.Pointer(o))
+		o.Close()
+	})
 
-	return &Outliers{fn}, nil
+	return o, nil
 }
 
-// Detect returns slice of outliers indices
+// Detect returns slice of outliers indices. If the Python function is async,
+// Detect waits for the result (see PyFunc.Call).
 func (o *Outliers) Detect(data []float64) ([]int, error) {
+	data, keep, err := dropNaN(data, o.nanPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := o.detect(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return remap(indices, keep)
+}
+
+// DetectWithParams is like Detect but passes params as keyword arguments to
+// the Python function, e.g. detect(data, threshold=3.5) for
+//
+//	o.DetectWithParams(data, map[string]float64{"threshold": 3.5})
+func (o *Outliers) DetectWithParams(data []float64, params map[string]float64) ([]int, error) {
+	data, keep, err := dropNaN(data, o.nanPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := o.detect(data, params)
+	if err != nil {
+		return nil, err
+	}
+	return remap(indices, keep)
+}
+
+// detect is Detect without NaN handling, params are passed as keyword
+// arguments
+func (o *Outliers) detect(data []float64, params map[string]float64) ([]int, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
 	if o.fn == nil {
 		return nil, fmt.Errorf("closed")
 	}
@@ -59,39 +168,364 @@ func (o *Outliers) Detect(data []float64) ([]int, error) {
 		return nil, nil
 	}
 
+	var (
+		names  []*C.char
+		values []float64
+	)
+	for name, value := range params {
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		names = append(names, cName)
+		values = append(values, value)
+	}
+
 	// Convert []float64 to C double*
 	carr := (*C.double)(&(data[0]))
-	res := C.detect(o.fn, carr, (C.long)(len(data)))
+	res, err := o.call(func() C.result_t {
+		if len(names) == 0 {
+			return C.detect(o.interp, o.fn, carr, (C.long)(len(data)))
+		}
+		return C.detect_params(
+			o.interp, o.fn, carr, (C.long)(len(data)),
+			&names[0], (*C.double)(&values[0]), (C.long)(len(names)),
+		)
+	})
 
 	// Tell Go's GC to keep data alive until here
 	runtime.KeepAlive(data)
-	if res.err != 0 {
-		return nil, pyLastError()
+	if err != nil {
+		return nil, err
+	}
+	return o.resultToSlice(res)
+}
+
+// Result is the result of DetectView. Indices alias the memory of the numpy
+// array returned by Python, which is kept alive until Release is called.
+type Result struct {
+	Indices []int // Outliers indices, invalid after Release
+
+	obj    *C.PyObject           // numpy array object owning Indices memory
+	interp *C.PyInterpreterState // Interpreter owning obj
+	owner  *Outliers             // Outliers that created the result
+}
+
+// Release frees the underlying numpy array, you can't use Indices after
+// calling Release
+func (r *Result) Release() {
+	if r.obj == nil {
+		return
+	}
+	runtime.SetFinalizer(r, nil)
+
+	r.owner.mu.RLock()
+	// A closed sub-interpreter, or Finalize, already freed obj
+	if release := untrack(unsafe.Pointer(r)); release != nil {
+		release()
+	}
+	r.owner.mu.RUnlock()
+	r.obj = nil
+	r.Indices = nil
+}
+
+// DetectView is like Detect but doesn't copy the result, Indices in the
+// returned Result point to the numpy array memory. You must call Release on
+// the result when done and keep the result reachable while using Indices,
+// otherwise its finalizer might free the memory.
+func (o *Outliers) DetectView(data []float64) (*Result, error) {
+	data, keep, err := dropNaN(data, o.nanPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	if len(data) == 0 { // Short path
+		return &Result{}, nil
+	}
+
+	carr := (*C.double)(&(data[0]))
+	res, err := o.call(func() C.result_t {
+		return C.detect_view(o.interp, o.fn, carr, (C.long)(len(data)))
+	})
+	runtime.KeepAlive(data)
+	if err != nil {
+		return nil, err
+	}
+
+	refAcquired(res.obj, o.interp)
+	if o.maxResult > 0 && int(res.size) > o.maxResult {
+		C.py_decref_in(o.interp, res.obj)
+		refReleased(res.obj)
+		return nil, &ResultSizeError{int(res.size), o.maxResult}
+	}
+
+	r := &Result{obj: res.obj, interp: o.interp, owner: o}
+	if res.size > 0 {
+		r.Indices = unsafe.Slice((*int)(unsafe.Pointer(res.indices)), res.size)
+	}
+	if keep != nil { // Indices are a Go slice after mapping
+		if r.Indices, err = remap(r.Indices, keep); err != nil {
+			C.py_decref_in(o.interp, res.obj)
+			refReleased(res.obj)
+			return nil, err
+		}
+	}
+
+	obj, interp := res.obj, o.interp
+	track(unsafe.Pointer(r), "Result", interp, func() {
+		C.py_decref_in(interp, obj)
+		refReleased(obj)
+	})
+	runtime.SetFinalizer(r, func(r *Result) {
+		logLeak(unsafe.Pointer(r))
+		r.Release()
+	})
+
+	return r, nil
+}
+
+// DetectMatrix calls the Python function with a rows x cols 2D array built
+// from data (in row major order) and returns slice of outliers indices. With
+// NaNDrop rows containing NaN are removed and the indices returned by Python
+// are taken as row indices.
+func (o *Outliers) DetectMatrix(data []float64, rows, cols int) ([]int, error) {
+	if rows < 0 || cols < 0 || rows*cols != len(data) {
+		return nil, fmt.Errorf("bad shape: %d values for %dx%d matrix", len(data), rows, cols)
+	}
+
+	data, rows, keep, err := dropNaNRows(data, rows, cols, o.nanPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := o.detectMatrix(data, rows, cols)
+	if err != nil {
+		return nil, err
+	}
+	return remap(indices, keep)
+}
+
+func (o *Outliers) detectMatrix(data []float64, rows, cols int) ([]int, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	if len(data) == 0 { // Short path
+		return nil, nil
+	}
+
+	carr := (*C.double)(&(data[0]))
+	res, err := o.call(func() C.result_t {
+		return C.detect_matrix(o.interp, o.fn, carr, (C.long)(rows), (C.long)(cols))
+	})
+	runtime.KeepAlive(data)
+	if err != nil {
+		return nil, err
+	}
+	return o.resultToSlice(res)
+}
+
+// DetectBatch is like calling Detect for each series but crosses into Python
+// once, which is much faster for many small series. The Python function is
+// still called once per series. The timeout (see WithTimeout) applies to the
+// whole batch.
+func (o *Outliers) DetectBatch(series [][]float64) ([][]int, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	out := make([][]int, len(series))
+	sizes := make([]C.long, len(series))
+	batch := make([][]float64, len(series))
+	keeps := make([][]int, len(series))
+	total := 0
+	for i, s := range series {
+		s, keep, err := dropNaN(s, o.nanPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("series %d: %w", i, err)
+		}
+		batch[i], keeps[i] = s, keep
+		sizes[i] = C.long(len(s))
+		total += len(s)
 	}
 
-	// Create a Go slice from C long*
-	indices, err := cArrToSlice(res.indices, res.size)
+	if total == 0 { // Short path
+		return out, nil
+	}
+
+	// Copy to one slice, cgo doesn't allow passing Go pointers to Go pointers
+	values := make([]float64, 0, total)
+	for _, s := range batch {
+		values = append(values, s...)
+	}
+
+	results := make([]C.result_t, len(series))
+	_, err := o.call(func() C.result_t {
+		err := C.detect_batch(o.interp, o.fn, (*C.double)(&values[0]), &sizes[0], C.long(len(series)), &results[0])
+		return C.result_t{err: err}
+	})
+	// Free indices copied by the C glue
+	defer func() {
+		for _, res := range results {
+			C.free(unsafe.Pointer(res.indices))
+		}
+	}()
 	if err != nil {
 		return nil, err
 	}
 
-	// Free Python array object
-	C.py_decref(res.obj)
-	return indices, nil
+	for i, res := range results {
+		if sizes[i] == 0 {
+			continue
+		}
+		indices, err := cArrToSlice(res.indices, res.size, o.maxResult)
+		if err != nil {
+			return nil, err
+		}
+		if out[i], err = remap(indices, keeps[i]); err != nil {
+			return nil, fmt.Errorf("series %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// DetectFloat32 is like Detect for float32 values, the Python function gets a
+// numpy array of dtype float32 sharing data's memory
+func (o *Outliers) DetectFloat32(data []float32) ([]int, error) {
+	data, keep, err := dropNaN(data, o.nanPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return o.detectDtype(nil, nil, 0, C.DTYPE_FLOAT32)
+	}
+
+	indices, err := o.detectDtype(data, unsafe.Pointer(&data[0]), len(data), C.DTYPE_FLOAT32)
+	if err != nil {
+		return nil, err
+	}
+	return remap(indices, keep)
+}
+
+// DetectInt32 is like Detect for int32 values, the Python function gets a
+// numpy array of dtype int32 sharing data's memory
+func (o *Outliers) DetectInt32(data []int32) ([]int, error) {
+	if len(data) == 0 {
+		return o.detectDtype(nil, nil, 0, C.DTYPE_INT32)
+	}
+	return o.detectDtype(data, unsafe.Pointer(&data[0]), len(data), C.DTYPE_INT32)
+}
+
+// DetectInt64 is like Detect for int64 values, the Python function gets a
+// numpy array of dtype int64 sharing data's memory
+func (o *Outliers) DetectInt64(data []int64) ([]int, error) {
+	if len(data) == 0 {
+		return o.detectDtype(nil, nil, 0, C.DTYPE_INT64)
+	}
+	return o.detectDtype(data, unsafe.Pointer(&data[0]), len(data), C.DTYPE_INT64)
+}
+
+// DetectComplex128 is like Detect for complex128 values, the Python function
+// gets a numpy array of dtype complex128 sharing data's memory (e.g. for
+// detectors working on a spectrum). A value is NaN if either part is NaN.
+func (o *Outliers) DetectComplex128(data []complex128) ([]int, error) {
+	data, keep, err := dropNaN(data, o.nanPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return o.detectDtype(nil, nil, 0, C.DTYPE_COMPLEX128)
+	}
+
+	indices, err := o.detectDtype(data, unsafe.Pointer(&data[0]), len(data), C.DTYPE_COMPLEX128)
+	if err != nil {
+		return nil, err
+	}
+	return remap(indices, keep)
+}
+
+// detectDtype calls the Python function with size values of type dtype at
+// ptr. data is the Go slice holding the values, kept alive during the call.
+func (o *Outliers) detectDtype(data interface{}, ptr unsafe.Pointer, size int, dtype C.dtype_t) ([]int, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	if size == 0 { // Short path
+		return nil, nil
+	}
+
+	res, err := o.call(func() C.result_t {
+		return C.detect_dtype(o.interp, o.fn, ptr, dtype, (C.long)(size))
+	})
+	runtime.KeepAlive(data)
+	if err != nil {
+		return nil, err
+	}
+	return o.resultToSlice(res)
 }
 
 // Close frees the underlying Python function
 // You can't use the object after closing it
 func (o *Outliers) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	if o.fn == nil {
 		return
 	}
-	C.py_decref(o.fn)
+	runtime.SetFinalizer(o, nil)
+	if release := untrack(unsafe.Pointer(o)); release != nil {
+		release()
+	}
 	o.fn = nil
+	o.interp, o.interpTS = nil, nil
 }
 
-// loadPyFunc loads a Python function by module and function name
-func loadPyFunc(moduleName, funcName string) (*C.PyObject, error) {
+// releaser returns a function freeing o's function, and extra unless nil,
+// and ending o's sub-interpreter if it has one. It doesn't reference o, see
+// track.
+func (o *Outliers) releaser(extra *C.PyObject) func() {
+	fn, key, interp, interpTS := o.fn, o.cacheKey, o.interp, o.interpTS
+	return func() {
+		if extra != nil {
+			C.py_decref_in(interp, extra)
+			refReleased(extra)
+		}
+		if key != nil {
+			releaseFunc(key.module, key.name)
+		} else {
+			C.py_decref_in(interp, fn)
+			refReleased(fn)
+		}
+
+		if interp != nil {
+			logOpen(interp)
+			if err := checkRefs(interp, true); err != nil {
+				log.Printf("outliers: closing sub-interpreter: %s", err)
+			}
+			C.end_interpreter(interpTS)
+		}
+	}
+}
+
+// loadPyFunc loads a Python function by module and function name in interp
+// (nil for main interpreter)
+func loadPyFunc(interp *C.PyInterpreterState, moduleName, funcName string) (*C.PyObject, error) {
 	// Convert names to C char*
 	cMod := C.CString(moduleName)
 	cFunc := C.CString(funcName)
@@ -102,43 +536,89 @@ func loadPyFunc(moduleName, funcName string) (*C.PyObject, error) {
 		C.free(unsafe.Pointer(cFunc))
 	}()
 
-	fn := C.load_func(cMod, cFunc)
+	var cErr *C.py_error_t
+	fn := C.load_func(interp, cMod, cFunc, &cErr)
 	if fn == nil {
-		return nil, pyLastError()
+		return nil, cError(cErr)
 	}
+	refAcquired(fn, interp)
 
 	return fn, nil
 }
 
-// Python last error
-func pyLastError() error {
-	cp := C.py_last_error()
-	if cp == nil {
+// call runs detect with o's timeout, returns the error from Python,
+// ErrTimeout if the call timed out or ErrFinalized after Finalize. Calls are
+// recorded in detect metrics.
+func (o *Outliers) call(detect func() C.result_t) (C.result_t, error) {
+	var res C.result_t
+	err := detectMetrics.do(func() error {
+		var (
+			timedOut bool
+			err      error
+		)
+		collectWarnings(o.onWarning, func() {
+			timedOut = watch(o.interp, o.timeout, func() {
+				err = trackCall(o.interp, func() {
+					res = detect()
+				})
+			})
+		})
+
+		if err != nil {
+			return err
+		}
+		if res.err != nil {
+			err := cError(res.err)
+			if timedOut {
+				return fmt.Errorf("%w (%s): %w", ErrTimeout, o.timeout, err)
+			}
+			return err
+		}
 		return nil
-	}
+	})
+	return res, err
+}
+
+// DefaultMaxResultSize is the default maximal number of indices in a result,
+// see WithMaxResultSize
+const DefaultMaxResultSize = 1 << 20
 
-	err := C.GoString(cp)
-	// We don't need to free cp, see
-	// https://docs.python.org/3/c-api/unicode.html#c.PyUnicode_AsUTF8AndSize
-	// which says: "The caller is not responsible for deallocating the buffer."
-	return fmt.Errorf("%s", err)
+// ResultSizeError is returned when the Python function returns more indices
+// than the maximal result size
+type ResultSizeError struct {
+	Size int // Number of indices returned
+	Max  int // Maximal result size
 }
 
-// Create a new []int from a *C.long
-func cArrToSlice(cArr *C.long, size C.long) ([]int, error) {
-	const maxSize = 1 << 20
-	if size > maxSize {
-		return nil, fmt.Errorf("C array to large (%d > %d)", size, maxSize)
-	}
+func (e *ResultSizeError) Error() string {
+	return fmt.Sprintf("result too large (%d > %d)", e.Size, e.Max)
+}
+
+// resultToSlice converts the result of a detect call to a Go slice and frees
+// the indices copied by the C glue
+func (o *Outliers) resultToSlice(res C.result_t) ([]int, error) {
+	defer C.free(unsafe.Pointer(res.indices))
 
-	// Ugly hack to convert C.long* to []int - make the compiler think there's
-	// a Go array at the C array memory location
-	ptr := unsafe.Pointer(cArr)
-	arr := (*[maxSize]int)(ptr)
+	// Create a Go slice from C int64_t*
+	return cArrToSlice(res.indices, res.size, o.maxResult)
+}
+
+// Indices are C int64_t, cArrToSlice and DetectView read them as Go int which
+// must be 64 bit. Fails to compile (constant overflow) on 32 bit platforms.
+var _ [unsafe.Sizeof(int(0)) - 8]byte
+
+// Create a new []int from a *C.int64_t, maxSize <= 0 means no limit
+func cArrToSlice(cArr *C.int64_t, size C.long, maxSize int) ([]int, error) {
+	if maxSize > 0 && int(size) > maxSize {
+		return nil, &ResultSizeError{int(size), maxSize}
+	}
+	if size == 0 {
+		return []int{}, nil
+	}
 
 	// Create a slice with copy of data managed by Go
 	s := make([]int, size)
-	copy(s, arr[:size])
+	copy(s, unsafe.Slice((*int)(unsafe.Pointer(cArr)), size))
 
 	return s, nil
 }
@@ -1,9 +1,57 @@
 """Detect outliers"""
+import asyncio
+
 import numpy as np
 
 
-def detect(data):
-    """Return indices where values more than 2 standard deviations from mean"""
-    out = np.where(np.abs(data - data.mean()) > 2 * data.std())
+def detect(data, threshold=2):
+    """Return indices where values more than threshold standard deviations
+    from mean"""
+    out = np.where(np.abs(data - data.mean()) > threshold * data.std())
     # np.where returns a tuple for each dimension, we want the 1st element
     return out[0]
+
+
+def detect_rows(data):
+    """Return indices of rows more than 2 standard deviations from the mean row
+
+    data is a 2D array, each row is a sample and each column a feature.
+    """
+    dist = np.linalg.norm(data - data.mean(axis=0), axis=1)
+    out = np.where(dist > dist.mean() + 2 * dist.std())
+    return out[0]
+
+
+async def detect_async(data):
+    """Async version of detect"""
+    await asyncio.sleep(0)
+    return detect(data)
+
+
+class RollingZScore:
+    """Streaming detector, flags values more than threshold standard
+    deviations from the mean of the previous window values.
+
+    push returns indices counted from the start of the stream.
+    """
+
+    def __init__(self, window=100, threshold=3):
+        self.window = window
+        self.threshold = threshold
+        self.history = np.empty(0)
+        self.count = 0  # Number of values seen
+
+    def push(self, data):
+        values = np.concatenate([self.history, data])
+        start = len(self.history)
+        out = []
+        for i in range(start, len(values)):
+            past = values[i - self.window:i]
+            if i < self.window:  # Not enough history
+                continue
+            if abs(values[i] - past.mean()) > self.threshold * past.std():
+                out.append(self.count + i - start)
+
+        self.count += len(data)
+        self.history = values[-self.window:]
+        return np.array(out, dtype=np.int64)
@@ -1,12 +1,34 @@
 package outliers
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
 	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
 	"github.com/stretchr/testify/require"
 )
 
+//go:embed plain.py
+var plainPy string
+
 func genData() ([]float64, []int) {
 	const size = 1000
 	data := make([]float64, size)
@@ -36,6 +58,109 @@ func TestDetect(t *testing.T) {
 	require.Equal(indices, out, "outliers")
 }
 
+func TestDetectWithParams(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+
+	out, err := o.DetectWithParams(data, map[string]float64{"threshold": 2})
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "outliers")
+
+	out, err = o.DetectWithParams(data, map[string]float64{"threshold": 100})
+	require.NoError(err, "high threshold")
+	require.Empty(out)
+
+	out, err = o.DetectWithParams(data, nil)
+	require.NoError(err, "no params")
+	require.Equal(indices, out)
+
+	_, err = o.DetectWithParams(data, map[string]float64{"nope": 1})
+	require.Error(err, "unknown param")
+	require.Contains(err.Error(), "TypeError")
+}
+
+func TestDetectPlain(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("plain", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+
+	out, err := o.Detect(data)
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "outliers")
+
+	out, err = o.DetectWithParams(data, map[string]float64{"threshold": 100})
+	require.NoError(err, "params")
+	require.Empty(out)
+}
+
+func TestDetectConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				out, err := o.Detect(data)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(out) != len(indices) {
+					errs <- fmt.Errorf("bad result: %v", out)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(err)
+	}
+}
+
+func TestCloseConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+
+	data, _ := genData()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.Detect(data) // Either succeeds or fails with "closed"
+		}()
+	}
+	o.Close()
+	wg.Wait()
+
+	_, err = o.Detect(data)
+	require.Error(err)
+}
+
 func TestNotFound(t *testing.T) {
 	require := require.New(t)
 
@@ -46,6 +171,115 @@ func TestNotFound(t *testing.T) {
 	require.Error(err, "module")
 }
 
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	type histogram struct {
+		Count   int64
+		Buckets map[string]int64
+	}
+	var before, after struct {
+		NewCalls  int64     `json:"new_outliers.calls"`
+		NewErrors int64     `json:"new_outliers.errors"`
+		Calls     int64     `json:"detect.calls"`
+		Errors    int64     `json:"detect.errors"`
+		Latency   histogram `json:"detect.latency"`
+	}
+	err := json.Unmarshal([]byte(metrics.String()), &before)
+	require.NoError(err, "before")
+
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+	_, err = NewOutliers("no_such_module", "detect")
+	require.Error(err, "new")
+
+	data, _ := genData()
+	_, err = o.Detect(data)
+	require.NoError(err, "detect")
+
+	err = json.Unmarshal([]byte(metrics.String()), &after)
+	require.NoError(err, "after")
+	require.Equal(before.NewCalls+2, after.NewCalls, "new calls")
+	require.Equal(before.NewErrors+1, after.NewErrors, "new errors")
+	require.Equal(before.Calls+1, after.Calls, "calls")
+	require.Equal(before.Errors, after.Errors, "errors")
+	require.Equal(before.Latency.Count+1, after.Latency.Count, "latency count")
+	require.Len(after.Latency.Buckets, len(latencyBuckets)+1, "buckets")
+}
+
+func TestResultDtype(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	code := `import numpy as np
+
+def detect_int32(values):
+    return np.array([1, 2], dtype=np.int32)
+
+def detect_list(values):
+    return [1, 2]
+`
+	require.NoError(os.WriteFile(filepath.Join(dir, "dtypemod.py"), []byte(code), 0o644))
+	require.NoError(AddPath(dir))
+
+	for _, name := range []string{"detect_int32", "detect_list"} {
+		o, err := NewOutliers("dtypemod", name)
+		require.NoError(err, name)
+		indices, err := o.Detect([]float64{1, 2, 3})
+		require.NoError(err, name)
+		require.Equal([]int{1, 2}, indices, name)
+		o.Close()
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	code := `import warnings
+
+def detect(values):
+    warnings.warn("first", RuntimeWarning)
+    warnings.warn("second")
+    return []
+`
+	require.NoError(os.WriteFile(filepath.Join(dir, "warnmod.py"), []byte(code), 0o644))
+	require.NoError(AddPath(dir))
+
+	var ws []Warning
+	o, err := NewOutliers("warnmod", "detect", WithWarnings(func(w Warning) {
+		ws = append(ws, w)
+	}))
+	require.NoError(err, "new")
+	defer o.Close()
+
+	_, err = o.Detect([]float64{1, 2, 3})
+	require.NoError(err, "detect")
+	require.Len(ws, 2)
+	require.Equal("RuntimeWarning", ws[0].Category)
+	require.Equal("first", ws[0].Message)
+	require.Equal("warnmod.py", filepath.Base(ws[0].File))
+	require.Equal(4, ws[0].Line)
+	require.Equal("UserWarning", ws[1].Category)
+	require.Equal("second", ws[1].Message)
+
+	_, err = NewOutliers("warnmod", "detect", WithWarnings(func(Warning) {}), WithSubInterpreter())
+	require.Error(err, "sub-interpreter")
+}
+
+func TestLibPythonNames(t *testing.T) {
+	require := require.New(t)
+
+	names := libPythonNames("windows")
+	require.Equal("python313.dll", names[0])
+	require.Equal("python3.dll", names[len(names)-1])
+	require.Contains(names, "python38.dll")
+
+	require.Equal("libpython3.13.dylib", libPythonNames("darwin")[0])
+	require.Contains(libPythonNames("linux"), "libpython3.8.so.1.0")
+}
+
 func TestNil(t *testing.T) {
 	require := require.New(t)
 
@@ -56,6 +290,172 @@ func TestNil(t *testing.T) {
 	require.Equal(0, len(indices), "len")
 }
 
+func TestDetectMatrix(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect_rows")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	const rows, cols = 500, 3
+	data := make([]float64, rows*cols)
+	for i := range data {
+		data[i] = rand.Float64()
+	}
+	indices := []int{17, 342}
+	for _, i := range indices {
+		for j := 0; j < cols; j++ {
+			data[i*cols+j] += 50
+		}
+	}
+
+	out, err := o.DetectMatrix(data, rows, cols)
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "outliers")
+
+	_, err = o.DetectMatrix(data, rows, cols+1)
+	require.Error(err, "shape")
+}
+
+func TestDetectDtypes(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+	f32 := make([]float32, len(data))
+	i32 := make([]int32, len(data))
+	i64 := make([]int64, len(data))
+	c128 := make([]complex128, len(data))
+	for i, v := range data {
+		f32[i] = float32(v)
+		i32[i] = int32(v * 10)
+		i64[i] = int64(v * 10)
+		c128[i] = complex(v, 0)
+	}
+
+	out, err := o.DetectFloat32(f32)
+	require.NoError(err, "float32")
+	require.Equal(indices, out, "float32")
+
+	out, err = o.DetectInt32(i32)
+	require.NoError(err, "int32")
+	require.Equal(indices, out, "int32")
+
+	out, err = o.DetectInt64(i64)
+	require.NoError(err, "int64")
+	require.Equal(indices, out, "int64")
+
+	out, err = o.DetectComplex128(c128)
+	require.NoError(err, "complex128")
+	require.Equal(indices, out, "complex128")
+
+	out, err = o.DetectInt64(nil)
+	require.NoError(err, "nil")
+	require.Equal(0, len(out), "nil")
+}
+
+func TestComplexArray(t *testing.T) {
+	require := require.New(t)
+
+	fft, err := LoadFunc("numpy.fft", "fft")
+	require.NoError(err)
+	defer fft.Close()
+
+	out, err := fft.Call([]complex128{1, 1, 1, 1})
+	require.NoError(err)
+	require.Equal([]complex128{4, 0, 0, 0}, out)
+
+	// Scalars are converted with tolist
+	sum, err := LoadFunc("numpy", "sum")
+	require.NoError(err)
+	defer sum.Close()
+
+	out, err = sum.Call(out)
+	require.NoError(err)
+	require.Equal(complex(4, 0), out)
+}
+
+func TestDetectView(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+	r, err := o.DetectView(data)
+	require.NoError(err, "detect")
+	require.Equal(indices, r.Indices, "outliers")
+
+	r.Release()
+	require.Nil(r.Indices, "release")
+	r.Release() // Idempotent
+}
+
+func TestSubInterpreter(t *testing.T) {
+	require := require.New(t)
+
+	o1, err := NewOutliers("outliers", "detect", WithSubInterpreter())
+	require.NoError(err, "new 1")
+	o2, err := NewOutliers("outliers", "detect", WithSubInterpreter())
+	require.NoError(err, "new 2")
+	defer o2.Close()
+
+	data, indices := genData()
+	for _, o := range []*Outliers{o1, o2} {
+		out, err := o.Detect(data)
+		require.NoError(err, "detect")
+		require.Equal(indices, out, "outliers")
+	}
+
+	// Closing one interpreter doesn't affect the other
+	o1.Close()
+	out, err := o2.Detect(data)
+	require.NoError(err, "detect after close")
+	require.Equal(indices, out, "outliers after close")
+}
+
+func TestPool(t *testing.T) {
+	require := require.New(t)
+
+	p, err := NewPool(4, "outliers", "detect")
+	require.NoError(err, "new")
+
+	data, indices := genData()
+	ctx := context.Background()
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := p.Detect(ctx, data)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(out) != len(indices) {
+				errs <- fmt.Errorf("bad result: %v", out)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(err)
+	}
+
+	p.Close()
+	_, err = p.Detect(ctx, data)
+	require.ErrorIs(err, ErrPoolClosed)
+	p.Close() // Idempotent
+}
+
 func BenchmarkOutliers(b *testing.B) {
 	require := require.New(b)
 	o, err := NewOutliers("outliers", "detect")
@@ -70,3 +470,1422 @@ func BenchmarkOutliers(b *testing.B) {
 		require.NoError(err)
 	}
 }
+
+func BenchmarkOutliersView(b *testing.B) {
+	require := require.New(b)
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, _ := genData()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := o.DetectView(data)
+		require.NoError(err)
+		r.Release()
+	}
+}
+
+func TestMaxResultSize(t *testing.T) {
+	require := require.New(t)
+	o, err := NewOutliers("outliers", "detect", WithMaxResultSize(2))
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+	_, err = o.Detect(data)
+	var sizeErr *ResultSizeError
+	require.ErrorAs(err, &sizeErr)
+	require.Equal(len(indices), sizeErr.Size)
+	require.Equal(2, sizeErr.Max)
+
+	_, err = o.DetectView(data)
+	require.ErrorAs(err, &sizeErr, "view")
+	require.Equal(len(indices), sizeErr.Size, "view")
+
+	o, err = NewOutliers("outliers", "detect", WithMaxResultSize(0))
+	require.NoError(err, "new")
+	defer o.Close()
+	out, err := o.Detect(data)
+	require.NoError(err, "no limit")
+	require.Equal(indices, out)
+}
+
+func TestNaNPolicy(t *testing.T) {
+	require := require.New(t)
+
+	data, indices := genData()
+	// NaNs at 0 and 200 in the original data
+	withNaN := append([]float64{math.NaN()}, data[:199]...)
+	withNaN = append(withNaN, math.NaN())
+	withNaN = append(withNaN, data[199:]...)
+	expected := []int{indices[0] + 1, indices[1] + 1, indices[2] + 2}
+
+	o, err := NewOutliers("outliers", "detect", WithNaNPolicy(NaNDrop))
+	require.NoError(err, "new")
+	defer o.Close()
+
+	out, err := o.Detect(withNaN)
+	require.NoError(err, "drop")
+	require.Equal(expected, out)
+
+	batch, err := o.DetectBatch([][]float64{withNaN, data})
+	require.NoError(err, "batch")
+	require.Equal([][]int{expected, indices}, batch)
+
+	o, err = NewOutliers("outliers", "detect", WithNaNPolicy(NaNError))
+	require.NoError(err, "new")
+	defer o.Close()
+
+	_, err = o.Detect(withNaN)
+	require.ErrorIs(err, ErrNaN)
+	out, err = o.Detect(data)
+	require.NoError(err, "no NaN")
+	require.Equal(indices, out)
+}
+
+func TestDropNaN(t *testing.T) {
+	require := require.New(t)
+	nan := math.NaN()
+
+	values, keep, err := dropNaN([]float32{1, float32(nan), 2, float32(nan)}, NaNDrop)
+	require.NoError(err)
+	require.Equal([]float32{1, 2}, values)
+	require.Equal([]int{0, 2}, keep)
+
+	out, err := remap([]int{1}, keep)
+	require.NoError(err)
+	require.Equal([]int{2}, out)
+	_, err = remap([]int{2}, keep)
+	require.Error(err, "out of range")
+
+	_, keep, err = dropNaN([]float64{1, 2}, NaNDrop)
+	require.NoError(err)
+	require.Nil(keep, "nothing dropped")
+
+	matrix := []float64{
+		1, 2,
+		3, nan,
+		5, 6,
+	}
+	values64, rows, keep, err := dropNaNRows(matrix, 3, 2, NaNDrop)
+	require.NoError(err)
+	require.Equal([]float64{1, 2, 5, 6}, values64)
+	require.Equal(2, rows)
+	require.Equal([]int{0, 2}, keep)
+
+	_, _, err = dropNaN([]float64{1, nan}, NaNError)
+	require.ErrorIs(err, ErrNaN)
+}
+
+func TestDetectBatch(t *testing.T) {
+	require := require.New(t)
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data1, indices1 := genData()
+	data2, indices2 := genData()
+	out, err := o.DetectBatch([][]float64{data1, nil, data2})
+	require.NoError(err, "detect")
+	require.Equal([][]int{indices1, nil, indices2}, out)
+
+	out, err = o.DetectBatch(nil)
+	require.NoError(err, "empty")
+	require.Empty(out)
+}
+
+func BenchmarkDetectBatch(b *testing.B) {
+	require := require.New(b)
+	o, err := NewOutliers("outliers", "detect")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	series := make([][]float64, 100)
+	for i := range series {
+		series[i], _ = genData()
+		series[i] = series[i][:50]
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := o.DetectBatch(series)
+		require.NoError(err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	require := require.New(t)
+	s, err := NewStream("outliers", "RollingZScore")
+	require.NoError(err, "new")
+	defer s.Close()
+
+	data := make([]float64, 1000)
+	for i := range data {
+		data[i] = float64(i % 10)
+	}
+	indices := []int{150, 500, 920}
+	for _, i := range indices {
+		data[i] = 100
+	}
+
+	const chunkSize = 64
+	for i := 0; i < len(data); i += chunkSize {
+		end := i + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		require.NoError(s.Push(data[i:end]), "push")
+	}
+
+	out, err := s.Flush()
+	require.NoError(err, "flush")
+	require.Equal(indices, out)
+
+	out, err = s.Flush()
+	require.NoError(err, "second flush")
+	require.Empty(out)
+}
+
+func TestStreamKeepsChunk(t *testing.T) {
+	require := require.New(t)
+
+	// Flags values in the previous chunk, which it keeps until the next push
+	dir := t.TempDir()
+	code := `class Delayed:
+    def __init__(self):
+        self.prev = []
+        self.start = 0  # Stream index of prev[0]
+
+    def _detect(self):
+        out = [self.start + i for i, v in enumerate(self.prev) if v > 10]
+        self.start += len(self.prev)
+        return out
+
+    def push(self, chunk):
+        out = self._detect()
+        self.prev = chunk
+        return out
+
+    def flush(self):
+        out = self._detect()
+        self.prev = []
+        return out
+`
+	require.NoError(os.WriteFile(filepath.Join(dir, "streammod.py"), []byte(code), 0o644))
+	require.NoError(AddPath(dir))
+
+	s, err := NewStream("streammod", "Delayed")
+	require.NoError(err, "new")
+	defer s.Close()
+
+	// Reuse the chunk memory, the detector must see its own copy
+	chunk := make([]float64, 4)
+	for _, values := range [][]float64{{1, 20, 3, 4}, {5, 6, 7, 8}, {9, 10, 11, 12}} {
+		copy(chunk, values)
+		require.NoError(s.Push(chunk), "push")
+	}
+
+	out, err := s.Flush()
+	require.NoError(err, "flush")
+	require.Equal([]int{1, 10, 11}, out)
+}
+
+func TestPyFuncCall(t *testing.T) {
+	require := require.New(t)
+
+	add, err := LoadFunc("operator", "add")
+	require.NoError(err)
+	defer add.Close()
+
+	out, err := add.Call(1, 2)
+	require.NoError(err)
+	require.Equal(3, out)
+
+	out, err = add.Call("go", "py")
+	require.NoError(err)
+	require.Equal("gopy", out)
+
+	out, err = add.Call([]int{1}, []float64{2.5})
+	require.NoError(err)
+	require.Equal([]interface{}{1, 2.5}, out)
+
+	_, err = add.Call(1, "2")
+	require.Error(err)
+	require.Contains(err.Error(), "TypeError")
+}
+
+func TestModule(t *testing.T) {
+	require := require.New(t)
+
+	mod, err := LoadModule("operator")
+	require.NoError(err)
+	require.Equal("operator", mod.Name())
+
+	add, err := mod.Func("add")
+	require.NoError(err)
+	defer add.Close()
+
+	mul, err := mod.Func("mul")
+	require.NoError(err)
+	defer mul.Close()
+
+	_, err = mod.Func("nope")
+	require.Error(err)
+	require.Contains(err.Error(), "AttributeError")
+
+	_, err = mod.Outliers("add", WithSubInterpreter())
+	require.Error(err, "sub-interpreter")
+
+	mod.Close()
+	_, err = mod.Func("add")
+	require.Error(err, "closed")
+
+	out, err := add.Call(2, 3)
+	require.NoError(err, "after module close")
+	require.Equal(5, out)
+
+	out, err = mul.Call(2, 3)
+	require.NoError(err)
+	require.Equal(6, out)
+
+	_, err = LoadModule("no_such_module")
+	require.Error(err)
+	require.Contains(err.Error(), "ModuleNotFoundError")
+}
+
+func TestPyFuncConvert(t *testing.T) {
+	require := require.New(t)
+
+	deepcopy, err := LoadFunc("copy", "deepcopy")
+	require.NoError(err)
+	defer deepcopy.Close()
+
+	cases := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{int8(-3), -3},
+		{uint64(7), 7},
+		{float32(1.5), 1.5},
+		{complex64(1 + 2i), 1 + 2i},
+		{complex(0, -1), complex(0, -1)},
+		{"π", "π"},
+		{[3]int{1, 2, 3}, []interface{}{1, 2, 3}},
+		{
+			map[string]interface{}{"a": []interface{}{1, 2.5, "x", false, nil}},
+			map[string]interface{}{"a": []interface{}{1, 2.5, "x", false, nil}},
+		},
+		{map[int]string{1: "one"}, map[interface{}]interface{}{1: "one"}},
+		{struct{ A int }{1}, map[string]interface{}{"A": 1}},
+	}
+
+	for _, tc := range cases {
+		out, err := deepcopy.Call(tc.in)
+		require.NoError(err, "%#v", tc.in)
+		require.Equal(tc.out, out, "%#v", tc.in)
+	}
+
+	_, err = deepcopy.Call(make(chan int))
+	require.Error(err)
+}
+
+func TestPyFuncResult(t *testing.T) {
+	require := require.New(t)
+
+	// array.array has a tolist method
+	array, err := LoadFunc("array", "array")
+	require.NoError(err)
+	defer array.Close()
+
+	out, err := array.Call("d", []float64{1, 2})
+	require.NoError(err)
+	require.Equal([]interface{}{1.0, 2.0}, out)
+
+	object, err := LoadFunc("builtins", "object")
+	require.NoError(err)
+	defer object.Close()
+
+	out, err = object.Call()
+	require.NoError(err)
+	require.IsType(Pickled{}, out, "pickled")
+
+	// Locks can't be pickled
+	lock, err := LoadFunc("threading", "Lock")
+	require.NoError(err)
+	defer lock.Close()
+
+	_, err = lock.Call()
+	require.Error(err)
+
+	sqrt, err := LoadFunc("math", "sqrt")
+	require.NoError(err)
+	sqrt.Close()
+	_, err = sqrt.Call(4.0)
+	require.Error(err)
+}
+
+func TestPyFuncStrBytes(t *testing.T) {
+	require := require.New(t)
+
+	escape, err := LoadFunc("html", "escape")
+	require.NoError(err)
+	defer escape.Close()
+
+	out, err := escape.Call("<héllo>")
+	require.NoError(err)
+	require.Equal("&lt;héllo&gt;", out)
+
+	compress, err := LoadFunc("zlib", "compress")
+	require.NoError(err)
+	defer compress.Close()
+	decompress, err := LoadFunc("zlib", "decompress")
+	require.NoError(err)
+	defer decompress.Close()
+
+	data := []byte("hello hello hello")
+	z, err := compress.Call(data)
+	require.NoError(err)
+	require.IsType([]byte{}, z)
+	out, err = decompress.Call(z)
+	require.NoError(err)
+	require.Equal(data, out)
+
+	bytearray, err := LoadFunc("builtins", "bytearray")
+	require.NoError(err)
+	defer bytearray.Close()
+	out, err = bytearray.Call([]byte{1, 2, 3})
+	require.NoError(err)
+	require.Equal([]byte{1, 2, 3}, out)
+
+	memoryview, err := LoadFunc("builtins", "memoryview")
+	require.NoError(err)
+	defer memoryview.Close()
+	out, err = memoryview.Call([]byte{})
+	require.NoError(err)
+	require.Equal([]byte{}, out)
+}
+
+func TestPyFuncCallKw(t *testing.T) {
+	require := require.New(t)
+
+	dumps, err := LoadFunc("json", "dumps")
+	require.NoError(err)
+	defer dumps.Close()
+
+	args := []interface{}{map[string]int{"b": 2, "a": 1}}
+	kw := map[string]interface{}{"sort_keys": true, "separators": []string{",", ":"}}
+	out, err := dumps.CallKw(args, kw)
+	require.NoError(err)
+	require.Equal(`{"a":1,"b":2}`, out)
+
+	_, err = dumps.CallKw(args, map[string]interface{}{"no_such_arg": 1})
+	require.Error(err)
+	require.Contains(err.Error(), "TypeError")
+}
+
+func TestTimeout(t *testing.T) {
+	require := require.New(t)
+
+	exec, err := LoadFunc("builtins", "exec")
+	require.NoError(err)
+	defer exec.Close()
+
+	// exec needs globals when there's no calling Python frame
+	globals := map[string]interface{}{}
+	var callErr error
+	timedOut := watch(nil, 100*time.Millisecond, func() {
+		_, callErr = exec.Call("while True: pass", globals)
+	})
+	require.True(timedOut, "timeout")
+	require.Error(callErr)
+	require.Contains(callErr.Error(), "TimeoutError")
+
+	// Next call in the same thread should not be affected
+	timedOut = watch(nil, time.Second, func() {
+		_, callErr = exec.Call("x = 1", globals)
+	})
+	require.False(timedOut, "no timeout")
+	require.NoError(callErr)
+}
+
+func TestInterrupt(t *testing.T) {
+	require := require.New(t)
+
+	exec, err := LoadFunc("builtins", "exec")
+	require.NoError(err)
+	defer exec.Close()
+
+	time.AfterFunc(100*time.Millisecond, Interrupt)
+	globals := map[string]interface{}{}
+	_, err = exec.Call("while True: pass", globals)
+	var pyErr *PyError
+	require.ErrorAs(err, &pyErr)
+	require.Equal("KeyboardInterrupt", pyErr.Type)
+
+	_, err = exec.Call("x = 1", globals)
+	require.NoError(err, "after interrupt")
+}
+
+func TestPyError(t *testing.T) {
+	require := require.New(t)
+
+	loads, err := LoadFunc("json", "loads")
+	require.NoError(err)
+	defer loads.Close()
+
+	_, err = loads.Call("{")
+	require.Error(err)
+
+	var pyErr *PyError
+	require.True(errors.As(err, &pyErr), "errors.As")
+	require.Equal("JSONDecodeError", pyErr.Type)
+	require.Contains(pyErr.Message, "line 1 column 2")
+	require.NotEmpty(pyErr.Frames, "frames")
+
+	last := pyErr.Frames[len(pyErr.Frames)-1]
+	require.Contains(last.File, "decoder.py")
+	require.Equal("raw_decode", last.Func)
+	require.Greater(last.Line, 0)
+	require.Contains(pyErr.Traceback(), "Traceback (most recent call last):")
+
+	_, err = NewOutliers("no_such_module", "detect")
+	require.True(errors.As(err, &pyErr), "errors.As")
+	require.Equal("ModuleNotFoundError", pyErr.Type)
+}
+
+type trade struct {
+	Symbol string
+	Price  float64
+	Volume int
+	note   string
+}
+
+func TestDataFrame(t *testing.T) {
+	require := require.New(t)
+
+	trades := []trade{
+		{"AAPL", 172.5, 100, "a"},
+		{"MSFT", 331.2, 30, "b"},
+	}
+	df, err := NewDataFrame(trades)
+	require.NoError(err)
+	require.Equal([]string{"Symbol", "Price", "Volume"}, df.Columns)
+	require.Equal(2, df.Len())
+
+	// deepcopy returns a new pandas.DataFrame
+	deepcopy, err := LoadFunc("copy", "deepcopy")
+	require.NoError(err)
+	defer deepcopy.Close()
+
+	out, err := deepcopy.Call(df)
+	require.NoError(err)
+	df2, ok := out.(*DataFrame)
+	require.True(ok, "type %T", out)
+	require.Equal(df.Columns, df2.Columns)
+
+	var trades2 []trade
+	require.NoError(df2.Decode(&trades2))
+	for i := range trades {
+		trades[i].note = ""
+	}
+	require.Equal(trades, trades2)
+
+	_, err = NewDataFrame(1)
+	require.Error(err)
+}
+
+func TestCallArrow(t *testing.T) {
+	require := require.New(t)
+
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "value", Type: arrow.PrimitiveTypes.Float64},
+	}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.Float64Builder).AppendValues([]float64{1, 2, 3}, nil)
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	// copy.copy returns a pyarrow.RecordBatch sharing the same buffers
+	cp, err := LoadFunc("copy", "copy")
+	require.NoError(err)
+	defer cp.Close()
+
+	out, err := cp.CallArrow(rec)
+	require.NoError(err)
+	defer out.Release()
+	require.True(array.RecordEqual(rec, out), "record")
+
+	_, err = cp.CallArrow(nil)
+	require.Error(err)
+}
+
+type fraction struct {
+	pickled Pickled
+}
+
+func TestPickle(t *testing.T) {
+	require := require.New(t)
+
+	newFraction, err := LoadFunc("fractions", "Fraction")
+	require.NoError(err)
+	defer newFraction.Close()
+
+	str, err := LoadFunc("builtins", "str")
+	require.NoError(err)
+	defer str.Close()
+
+	out, err := newFraction.Call(1, 3)
+	require.NoError(err)
+	p, ok := out.(Pickled)
+	require.True(ok, "type %T", out)
+
+	// Round trip
+	out, err = str.Call(p)
+	require.NoError(err)
+	require.Equal("1/3", out)
+
+	RegisterCodec("Fraction", fraction{}, Codec{
+		Decode: func(p Pickled) (interface{}, error) { return fraction{p}, nil },
+		Encode: func(v interface{}) (Pickled, error) { return v.(fraction).pickled, nil },
+	})
+	out, err = newFraction.Call(2, 5)
+	require.NoError(err)
+	f, ok := out.(fraction)
+	require.True(ok, "codec type %T", out)
+
+	out, err = str.Call(f)
+	require.NoError(err)
+	require.Equal("2/5", out)
+}
+
+func TestRegisterFunc(t *testing.T) {
+	require := require.New(t)
+
+	var progress []float64
+	err := RegisterFunc("progress", func(pct float64) {
+		progress = append(progress, pct)
+	})
+	require.NoError(err)
+
+	err = RegisterFunc("div", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	})
+	require.NoError(err)
+
+	require.Error(RegisterFunc("bad", 7))
+
+	exec, err := LoadFunc("builtins", "exec")
+	require.NoError(err)
+	defer exec.Close()
+
+	globals := map[string]interface{}{}
+	code := `
+import go
+for i in range(1, 5):
+    go.progress(i / 4)
+`
+	_, err = exec.Call(code, globals)
+	require.NoError(err)
+	require.Equal([]float64{0.25, 0.5, 0.75, 1}, progress)
+
+	_, err = exec.Call("import go; assert go.div(7, 2) == 3", globals)
+	require.NoError(err)
+
+	_, err = exec.Call("import go; go.div(1, 0)", globals)
+	require.Error(err)
+	require.Contains(err.Error(), "division by zero")
+
+	_, err = exec.Call("import go; go.div('a', 1)", globals)
+	require.Error(err)
+}
+
+func TestDebugLeaks(t *testing.T) {
+	require := require.New(t)
+
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	DebugLeaks(true)
+	defer DebugLeaks(false)
+
+	func() {
+		_, err := LoadFunc("builtins", "len") // No Close
+		require.NoError(err)
+	}()
+
+	fn, err := LoadFunc("builtins", "len")
+	require.NoError(err)
+	fn.Close()
+
+	for i := 0; i < 10 && !strings.Contains(buf.String(), "PyFunc"); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	out := buf.String()
+	require.Contains(out, "PyFunc garbage collected without Close")
+	require.Contains(out, "TestDebugLeaks")
+	require.Equal(1, strings.Count(out, "without Close"))
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use (finalizers run in
+// their own goroutine)
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCheckRefs(t *testing.T) {
+	if !refDebug {
+		t.Skip("build with -tags py_refdebug")
+	}
+	require := require.New(t)
+
+	f, err := LoadFunc("html", "escape")
+	require.NoError(err, "load")
+	fn := f.fn
+	require.Equal(1, refCount(fn), "loaded")
+
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	err = checkRefs(nil, false)
+	require.Error(err, "open")
+	require.Contains(buf.String(), "TestCheckRefs", "stack")
+
+	f.Close()
+	require.Equal(0, refCount(fn), "closed")
+}
+
+func TestSetStdout(t *testing.T) {
+	require := require.New(t)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(SetStdout(&stdout))
+	defer SetStdout(nil)
+	require.NoError(SetStderr(&stderr))
+	defer SetStderr(nil)
+
+	exec, err := LoadFunc("builtins", "exec")
+	require.NoError(err)
+	defer exec.Close()
+
+	globals := map[string]interface{}{}
+	_, err = exec.Call("import sys; print('hello'); print('oops', file=sys.stderr)", globals)
+	require.NoError(err)
+	require.Equal("hello\n", stdout.String())
+	require.Equal("oops\n", stderr.String())
+
+	require.NoError(SetStdout(nil))
+	_, err = exec.Call("print('bye')", globals)
+	require.NoError(err)
+	require.Equal("hello\n", stdout.String())
+}
+
+func TestSetLogger(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	require.NoError(SetLogger(slog.New(h)))
+	defer SetLogger(nil)
+
+	exec, err := LoadFunc("builtins", "exec")
+	require.NoError(err)
+	defer exec.Close()
+
+	code := `
+import logging
+log = logging.getLogger('detector')
+log.debug('not logged')
+log.info('found %d outliers', 3)
+try:
+    1/0
+except ZeroDivisionError:
+    log.exception('oops')
+`
+	_, err = exec.Call(code, map[string]interface{}{})
+	require.NoError(err)
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r map[string]interface{}
+		require.NoError(dec.Decode(&r))
+		records = append(records, r)
+	}
+	require.Len(records, 2)
+
+	require.Equal("INFO", records[0]["level"])
+	require.Equal("found 3 outliers", records[0]["msg"])
+	require.Equal("detector", records[0]["logger"])
+
+	require.Equal("ERROR", records[1]["level"])
+	require.Contains(records[1]["exc_info"], "ZeroDivisionError")
+}
+
+func TestInit(t *testing.T) {
+	require := require.New(t)
+
+	initialize()
+	require.Error(Init(WithVenv(t.TempDir())), "second init")
+	require.Error(Init(WithConfig(Config{Isolated: true})), "config after init")
+}
+
+func TestVenv(t *testing.T) {
+	require := require.New(t)
+
+	eval, err := LoadFunc("builtins", "eval")
+	require.NoError(err)
+	defer eval.Close()
+
+	// Restore sys.prefix after the test
+	prefix, err := eval.Call("__import__('sys').prefix", map[string]interface{}{})
+	require.NoError(err)
+	defer func() {
+		code := "import sys; sys.prefix = sys.exec_prefix = prefix"
+		require.NoError(execPython(code, map[string]interface{}{"prefix": prefix}))
+	}()
+
+	version, err := LoadFunc("platform", "python_version_tuple")
+	require.NoError(err)
+	defer version.Close()
+	out, err := version.Call()
+	require.NoError(err)
+	v := out.([]interface{})
+
+	dir := t.TempDir()
+	cfg := "home = /usr/bin\ninclude-system-site-packages = true\n"
+	require.NoError(os.WriteFile(filepath.Join(dir, "pyvenv.cfg"), []byte(cfg), 0o644))
+	site := filepath.Join(dir, "lib", fmt.Sprintf("python%s.%s", v[0], v[1]), "site-packages")
+	if runtime.GOOS == "windows" {
+		site = filepath.Join(dir, "Lib", "site-packages")
+	}
+	require.NoError(os.MkdirAll(site, 0o755))
+	code := "def answer():\n    return 42\n"
+	require.NoError(os.WriteFile(filepath.Join(site, "venvmod.py"), []byte(code), 0o644))
+
+	require.NoError(setupVenv(dir))
+
+	fn, err := LoadFunc("venvmod", "answer")
+	require.NoError(err)
+	defer fn.Close()
+	out, err = fn.Call()
+	require.NoError(err)
+	require.Equal(42, out)
+
+	require.Error(setupVenv(t.TempDir()), "no pyvenv.cfg")
+}
+
+func TestPaths(t *testing.T) {
+	require := require.New(t)
+
+	orig, err := Paths()
+	require.NoError(err)
+	require.NotEmpty(orig)
+	defer func() {
+		require.NoError(SetPaths(orig))
+	}()
+
+	dir := t.TempDir()
+	code := "def answer():\n    return 42\n"
+	require.NoError(os.WriteFile(filepath.Join(dir, "pathmod.py"), []byte(code), 0o644))
+
+	require.NoError(AddPath(dir))
+	require.NoError(AddPath(dir))
+	paths, err := Paths()
+	require.NoError(err)
+	require.Equal(dir, paths[0])
+	require.Equal(len(orig)+1, len(paths))
+
+	fn, err := LoadFunc("pathmod", "answer")
+	require.NoError(err)
+	defer fn.Close()
+	out, err := fn.Call()
+	require.NoError(err)
+	require.Equal(42, out)
+
+	require.NoError(SetPaths(orig[1:]))
+	paths, err = Paths()
+	require.NoError(err)
+	require.Equal(orig[1:], paths)
+}
+
+func TestDetectAsync(t *testing.T) {
+	require := require.New(t)
+
+	o, err := NewOutliers("outliers", "detect_async")
+	require.NoError(err, "new")
+	defer o.Close()
+
+	data, indices := genData()
+	out, err := o.Detect(data)
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "outliers")
+}
+
+func TestCallAsync(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	code := `
+import asyncio
+
+async def double(x):
+    await asyncio.sleep(0.1)
+    return x * 2
+
+async def fail():
+    raise ValueError('oops')
+`
+	require.NoError(os.WriteFile(filepath.Join(dir, "asyncmod.py"), []byte(code), 0o644))
+	require.NoError(AddPath(dir))
+
+	double, err := LoadFunc("asyncmod", "double")
+	require.NoError(err)
+	defer double.Close()
+
+	out, err := double.Call(21)
+	require.NoError(err)
+	require.Equal(42, out)
+
+	// Calls run concurrently on the event loop
+	start := time.Now()
+	var chans []<-chan AsyncResult
+	for i := 0; i < 5; i++ {
+		chans = append(chans, double.CallAsync(i))
+	}
+	for i, ch := range chans {
+		r := <-ch
+		require.NoError(r.Err)
+		require.Equal(i*2, r.Value)
+	}
+	require.Less(time.Since(start), 400*time.Millisecond)
+
+	fail, err := LoadFunc("asyncmod", "fail")
+	require.NoError(err)
+	defer fail.Close()
+
+	r := <-fail.CallAsync()
+	require.Error(r.Err)
+	require.Contains(r.Err.Error(), "oops")
+}
+
+func TestObject(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	code := `
+from dataclasses import dataclass
+
+@dataclass
+class Result:
+    indices: list
+    score: float
+
+    def top(self, n):
+        return self.indices[:n]
+
+def analyze(score):
+    return Result([7, 113, 835], score)
+`
+	require.NoError(os.WriteFile(filepath.Join(dir, "objmod.py"), []byte(code), 0o644))
+	require.NoError(AddPath(dir))
+
+	analyze, err := LoadFunc("objmod", "analyze")
+	require.NoError(err)
+	defer analyze.Close()
+
+	res, err := analyze.CallObject(0.5)
+	require.NoError(err)
+	defer res.DecRef()
+	require.Equal("Result(indices=[7, 113, 835], score=0.5)", res.String())
+
+	score, err := res.GetAttr("score")
+	require.NoError(err)
+	defer score.DecRef()
+	v, err := score.Value()
+	require.NoError(err)
+	require.Equal(0.5, v)
+
+	top, err := res.GetAttr("top")
+	require.NoError(err)
+	defer top.DecRef()
+	out, err := top.Call(2)
+	require.NoError(err)
+	defer out.DecRef()
+	v, err = out.Value()
+	require.NoError(err)
+	require.Equal([]interface{}{7, 113}, v)
+
+	_, err = res.GetAttr("nope")
+	require.Error(err)
+
+	// Objects as arguments
+	typeName, err := LoadFunc("builtins", "type")
+	require.NoError(err)
+	defer typeName.Close()
+	typ, err := typeName.CallObject(res)
+	require.NoError(err)
+	defer typ.DecRef()
+	require.Equal("<class 'objmod.Result'>", typ.String())
+
+	score.DecRef()
+	_, err = score.Value()
+	require.Error(err)
+}
+
+func TestNewInstance(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	code := `
+class MeanModel:
+    def __init__(self, factor=1):
+        self.factor = factor
+
+    def fit(self, xs):
+        self.mean = sum(xs) / len(xs)
+        return self
+
+    def predict(self, xs):
+        return [x > self.mean * self.factor for x in xs]
+`
+	require.NoError(os.WriteFile(filepath.Join(dir, "instmod.py"), []byte(code), 0o644))
+	require.NoError(AddPath(dir))
+
+	model, err := NewInstance("instmod", "MeanModel", 2)
+	require.NoError(err)
+	defer model.DecRef()
+
+	fitted, err := model.CallMethod("fit", []float64{1, 2, 3})
+	require.NoError(err)
+	fitted.DecRef()
+
+	pred, err := model.CallMethod("predict", []float64{1, 5})
+	require.NoError(err)
+	defer pred.DecRef()
+	v, err := pred.Value()
+	require.NoError(err)
+	require.Equal([]interface{}{false, true}, v)
+
+	_, err = model.CallMethod("nope")
+	require.Error(err)
+
+	frac, err := NewInstance("fractions", "Fraction", 1, 3)
+	require.NoError(err)
+	defer frac.DecRef()
+	require.Equal("1/3", frac.String())
+
+	_, err = NewInstance("instmod", "NoSuchClass")
+	require.Error(err)
+}
+
+type detectConfig struct {
+	Window    int               `py:"window"`
+	Threshold float64           `py:"threshold,omitempty"`
+	Columns   []string          `py:"columns"`
+	Weights   map[string]int    `py:"weights"`
+	Range     *[2]float64       `py:"range"`
+	Inner     *detectConfig     `py:"inner,omitempty"`
+	Debug     bool              `py:"-"`
+	Name      string            // No tag
+	Meta      map[string]string `py:"meta,omitempty"`
+}
+
+func TestStructDict(t *testing.T) {
+	require := require.New(t)
+
+	dumps, err := LoadFunc("json", "dumps")
+	require.NoError(err)
+	defer dumps.Close()
+
+	cfg := detectConfig{
+		Window:  10,
+		Columns: []string{"a", "b"},
+		Weights: map[string]int{"a": 2},
+		Range:   &[2]float64{-1, 1},
+		Inner:   &detectConfig{Window: 3, Threshold: 0.5},
+		Debug:   true,
+		Name:    "cfg",
+	}
+	out, err := dumps.CallKw([]interface{}{cfg}, map[string]interface{}{"sort_keys": true})
+	require.NoError(err)
+	expected := `{"Name": "cfg", "columns": ["a", "b"], "inner": {"Name": "", "columns": null, "range": null, "threshold": 0.5, "weights": null, "window": 3}, "range": [-1.0, 1.0], "weights": {"a": 2}, "window": 10}`
+	require.Equal(expected, out)
+
+	// Round trip
+	dict, err := LoadFunc("builtins", "dict")
+	require.NoError(err)
+	defer dict.Close()
+
+	out, err = dict.Call(cfg)
+	require.NoError(err)
+	var cfg2 detectConfig
+	require.NoError(Decode(out, &cfg2))
+	cfg.Debug = false
+	require.Equal(cfg, cfg2)
+
+	var bad struct {
+		Window string `py:"window"`
+	}
+	require.Error(Decode(out, &bad))
+	require.Error(Decode(out, cfg2))
+}
+
+func TestCallOf(t *testing.T) {
+	require := require.New(t)
+
+	sorted, err := LoadFunc("builtins", "sorted")
+	require.NoError(err)
+	defer sorted.Close()
+
+	ints, err := CallOf[[]int](sorted, []int{3, 1, 2})
+	require.NoError(err)
+	require.Equal([]int{1, 2, 3}, ints)
+
+	floats, err := CallKwOf[[]float64](sorted, []interface{}{[]int{3, 1, 2}}, map[string]interface{}{"reverse": true})
+	require.NoError(err)
+	require.Equal([]float64{3, 2, 1}, floats)
+
+	complexes, err := CallOf[[]complex64](sorted, []float64{2, 1})
+	require.NoError(err)
+	require.Equal([]complex64{1, 2}, complexes)
+
+	dict, err := LoadFunc("builtins", "dict")
+	require.NoError(err)
+	defer dict.Close()
+
+	cfg, err := CallOf[detectConfig](dict, map[string]interface{}{"window": 7})
+	require.NoError(err)
+	require.Equal(7, cfg.Window)
+
+	_, err = CallOf[[]string](sorted, []int{1})
+	require.Error(err, "bad type")
+
+	_, err = CallOf[int](sorted, 1)
+	require.Error(err, "Python error")
+	require.Contains(err.Error(), "TypeError")
+}
+
+func TestLoadFuncFromSource(t *testing.T) {
+	require := require.New(t)
+
+	detect, err := LoadFuncFromSource(plainPy, "detect")
+	require.NoError(err)
+	defer detect.Close()
+
+	data, indices := genData()
+	out, err := CallOf[[]int](detect, data)
+	require.NoError(err)
+	require.Equal(indices, out)
+
+	// The module is created once per source
+	const counter = "import sys\nsys.loads = getattr(sys, 'loads', 0) + 1\ndef loads():\n    return sys.loads\n"
+	for i := 0; i < 2; i++ {
+		loads, err := LoadFuncFromSource(counter, "loads")
+		require.NoError(err)
+		n, err := CallOf[int](loads)
+		loads.Close()
+		require.NoError(err)
+		require.Equal(1, n, "loads")
+	}
+
+	_, err = LoadFuncFromSource(plainPy, "nope")
+	require.Error(err)
+	require.Contains(err.Error(), "AttributeError")
+
+	_, err = LoadFuncFromSource("def detect(:\n", "detect")
+	require.Error(err)
+	require.Contains(err.Error(), "SyntaxError")
+}
+
+func TestFuncCache(t *testing.T) {
+	require := require.New(t)
+	defer SetFuncCacheSize(DefaultFuncCacheSize)
+
+	ClearFuncCache()
+	hits, misses, evictions := funcCacheHits.Value(), funcCacheMisses.Value(), funcCacheEvictions.Value()
+
+	o1, err := NewOutliers("plain", "detect")
+	require.NoError(err)
+	o2, err := NewOutliers("plain", "detect")
+	require.NoError(err)
+	require.Equal(o1.fn, o2.fn, "shared function")
+	require.Equal(hits+1, funcCacheHits.Value(), "hits")
+	require.Equal(misses+1, funcCacheMisses.Value(), "misses")
+
+	data, indices := genData()
+	o1.Close()
+	out, err := o2.Detect(data)
+	require.NoError(err)
+	require.Equal(indices, out)
+
+	// Unused functions are kept up to the cache size
+	o2.Close()
+	require.Equal(evictions, funcCacheEvictions.Value(), "kept")
+	o3, err := NewOutliers("plain", "detect")
+	require.NoError(err)
+	require.Equal(hits+2, funcCacheHits.Value(), "hits after close")
+	o3.Close()
+
+	SetFuncCacheSize(0)
+	require.Equal(evictions+1, funcCacheEvictions.Value(), "evicted")
+
+	_, err = NewOutliers("plain", "nope")
+	require.Error(err)
+	require.Empty(funcCache.funcs, "failed load cached")
+}
+
+const memoryViewPy = `
+import ctypes
+
+kept = []
+exported = []
+
+
+def fill(buf):
+    buf[0] = 7
+    return bytes(buf[1:3])
+
+
+def keep(buf):
+    kept.append(buf)
+
+
+def kept_len():
+    try:
+        return len(kept[-1])
+    except ValueError:  # Released
+        return -1
+
+
+def export(buf):
+    exported.append((ctypes.c_char * len(buf)).from_buffer(buf))
+
+
+def unexport():
+    exported.clear()
+`
+
+func TestMemoryView(t *testing.T) {
+	require := require.New(t)
+
+	load := func(name string) *PyFunc {
+		fn, err := LoadFuncFromSource(memoryViewPy, name)
+		require.NoError(err, name)
+		t.Cleanup(fn.Close)
+		return fn
+	}
+
+	data := []byte{0, 1, 2, 3}
+	mv, err := NewMemoryView(data)
+	require.NoError(err)
+
+	out, err := load("fill").Call(mv)
+	require.NoError(err)
+	require.Equal([]byte{1, 2}, out)
+	require.Equal(byte(7), data[0], "written")
+
+	_, err = load("keep").Call(mv)
+	require.NoError(err)
+	n, err := CallOf[int](load("kept_len"))
+	require.NoError(err)
+	require.Equal(len(data), n)
+
+	// Derived buffers block release
+	_, err = load("export").Call(mv)
+	require.NoError(err)
+	err = mv.Release()
+	require.ErrorIs(err, ErrBufferInUse)
+	n, err = CallOf[int](load("kept_len"))
+	require.NoError(err)
+	require.Equal(-1, n, "view released")
+
+	_, err = load("unexport").Call()
+	require.NoError(err)
+	require.NoError(mv.Release())
+	require.NoError(mv.Release(), "idempotent")
+
+	_, err = load("fill").Call(mv)
+	require.Error(err, "call after release")
+
+	empty, err := NewMemoryView(nil)
+	require.NoError(err)
+	_, err = load("keep").Call(empty)
+	require.NoError(err)
+	n, err = CallOf[int](load("kept_len"))
+	require.NoError(err)
+	require.Equal(0, n, "empty")
+	require.NoError(empty.Release())
+}
+
+const crashPy = `
+import os
+import time
+
+
+def detect(data):
+    if len(data) == 1:
+        os.abort()
+    if len(data) == 2:
+        raise ValueError('bad data')
+    if len(data) == 3:
+        time.sleep(60)
+    return [i for i, v in enumerate(data) if v > 50]
+`
+
+func TestIsolated(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "crash.py"), []byte(crashPy), 0o644)
+	require.NoError(err)
+
+	_, err = NewIsolated("crash", "nope", dir)
+	require.Error(err, "bad function")
+
+	iso, err := NewIsolated("crash", "detect", dir)
+	require.NoError(err)
+	defer iso.Close()
+
+	ctx := context.Background()
+	data := []float64{1, 99, 2, 98}
+	indices, err := iso.Detect(ctx, data)
+	require.NoError(err)
+	require.Equal([]int{1, 3}, indices)
+
+	var pyErr *PyError
+	_, err = iso.Detect(ctx, []float64{1, 2})
+	require.ErrorAs(err, &pyErr)
+	require.Equal("ValueError", pyErr.Type)
+
+	starts := isolatedStarts.Value()
+	_, err = iso.Detect(ctx, []float64{1})
+	require.ErrorIs(err, ErrWorkerCrashed)
+
+	indices, err = iso.Detect(ctx, data)
+	require.NoError(err, "restarted")
+	require.Equal([]int{1, 3}, indices)
+	require.Equal(starts+1, isolatedStarts.Value(), "starts")
+
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = iso.Detect(tctx, []float64{1, 2, 3})
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	indices, err = iso.Detect(ctx, data)
+	require.NoError(err, "after timeout")
+	require.Equal([]int{1, 3}, indices)
+
+	require.NoError(iso.Close())
+	_, err = iso.Detect(ctx, data)
+	require.Error(err, "closed")
+}
+
+func TestImportFilter(t *testing.T) {
+	require := require.New(t)
+
+	initialize()
+	require.NoError(execPython("import builtins\nbuiltins._saved_import = builtins.__import__", map[string]interface{}{}))
+	defer func() {
+		code := "import builtins\nbuiltins.__import__ = builtins._saved_import\ndel builtins._saved_import"
+		require.NoError(execPython(code, map[string]interface{}{}))
+	}()
+
+	var cfg initConfig
+	WithImportAllow("json", "socket")(&cfg)
+	WithImportDeny("socket")(&cfg)
+	require.NoError(installImportFilter(cfg))
+
+	code := "def imp(name):\n    exec('import ' + name)\n\ndef direct(name):\n    __import__(name)\n"
+	imp, err := LoadFuncFromSource(code, "imp")
+	require.NoError(err)
+	defer imp.Close()
+	direct, err := LoadFuncFromSource(code, "direct")
+	require.NoError(err)
+	defer direct.Close()
+
+	for _, name := range []string{"json", "json.decoder"} {
+		_, err = imp.Call(name)
+		require.NoError(err, name)
+	}
+
+	for _, name := range []string{"csv", "socket"} {
+		_, err = imp.Call(name)
+		require.Error(err, name)
+		require.Contains(err.Error(), "ImportError", name)
+	}
+
+	_, err = direct.Call("csv")
+	require.Error(err, "__import__")
+
+	// Modules loaded from Go and the standard library aren't restricted
+	reader, err := LoadFunc("csv", "reader")
+	require.NoError(err)
+	reader.Close()
+}
+
+// finalizeEnv runs TestFinalize in a child process, Finalize can't be undone
+const finalizeEnv = "OUTLIERS_FINALIZE_TEST"
+
+func TestFinalize(t *testing.T) {
+	if os.Getenv(finalizeEnv) == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFinalize$", "-test.v")
+		cmd.Env = append(os.Environ(), finalizeEnv+"=1")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return
+	}
+
+	require := require.New(t)
+
+	src := "def add(a, b):\n    return a + b\n"
+	kept, err := LoadFuncFromSource(src, "add")
+	require.NoError(err)
+	fn, err := LoadFuncFromSource(src, "add")
+	require.NoError(err)
+	out, err := fn.Call(1, 2)
+	require.NoError(err)
+	require.Equal(3, out)
+	obj, err := fn.CallObject(1, 2)
+	require.NoError(err)
+	fn.Close()
+
+	// Open handles are released, CheckRefs finds no leaks in refDebug mode
+	require.NoError(Finalize())
+	require.NoError(Finalize(), "idempotent")
+
+	_, err = kept.Call(1, 2)
+	require.ErrorIs(err, ErrFinalized)
+	_, err = obj.Value()
+	require.ErrorIs(err, ErrFinalized)
+	kept.Close() // no-op
+	obj.DecRef()
+
+	fn, err = LoadFuncFromSource(src, "add")
+	require.Nil(fn)
+	require.ErrorIs(err, ErrFinalized)
+	require.ErrorIs(Init(), ErrFinalized)
+}
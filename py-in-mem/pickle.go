@@ -0,0 +1,145 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// Pickled is a Python object serialized with pickle. PyFunc.Call returns
+// Python objects it can't convert to Go as Pickled, and converts Pickled
+// arguments back to the original Python object.
+type Pickled []byte
+
+// Codec converts Pickled Python objects of a specific type to Go values and
+// back, see RegisterCodec.
+type Codec struct {
+	Decode func(Pickled) (interface{}, error) // Python -> Go
+	Encode func(interface{}) (Pickled, error) // Go -> Python
+}
+
+var codecs struct {
+	sync.RWMutex
+	byPyType map[string]Codec       // Python type name -> codec
+	byGoType map[reflect.Type]Codec // Go type -> codec
+}
+
+// RegisterCodec registers c for objects of Python type pyType (e.g.
+// "Fraction") and Go values with the same type as goValue. Results of pyType
+// are returned as c.Decode(pickled) instead of Pickled, and arguments of
+// goValue's type are passed to Python as the object pickled by c.Encode.
+func RegisterCodec(pyType string, goValue interface{}, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if codecs.byPyType == nil {
+		codecs.byPyType = make(map[string]Codec)
+		codecs.byGoType = make(map[reflect.Type]Codec)
+	}
+
+	if c.Decode != nil {
+		codecs.byPyType[pyType] = c
+	}
+	if c.Encode != nil {
+		codecs.byGoType[reflect.TypeOf(goValue)] = c
+	}
+}
+
+func pyTypeCodec(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	c, ok := codecs.byPyType[name]
+	return c, ok
+}
+
+func goTypeCodec(typ reflect.Type) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	c, ok := codecs.byGoType[typ]
+	return c, ok
+}
+
+// pickle returns pickle.dumps(obj), must be called with the GIL held.
+func pickle(obj *C.PyObject) (Pickled, error) {
+	dumps, err := importAttr("pickle", "dumps")
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(dumps)
+
+	data, err := callObject(dumps, obj)
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(data)
+
+	var (
+		cs   *C.char
+		size C.Py_ssize_t
+	)
+	if C.PyBytes_AsStringAndSize(data, &cs, &size) != 0 {
+		return nil, cError(C.py_error())
+	}
+	return Pickled(C.GoBytes(unsafe.Pointer(cs), C.int(size))), nil
+}
+
+// unpickle returns a new reference to pickle.loads(p), must be called with the
+// GIL held.
+func unpickle(p Pickled) (*C.PyObject, error) {
+	loads, err := importAttr("pickle", "loads")
+	if err != nil {
+		return nil, err
+	}
+	defer C.Py_DecRef(loads)
+
+	var cs *C.char
+	if len(p) > 0 {
+		cs = (*C.char)(unsafe.Pointer(&p[0]))
+	}
+	data := C.PyBytes_FromStringAndSize(cs, C.Py_ssize_t(len(p)))
+	if data == nil {
+		return nil, cError(C.py_error())
+	}
+	defer C.Py_DecRef(data)
+
+	return callObject(loads, data)
+}
+
+// pickledFromPython converts objects the bridge doesn't support to Pickled or
+// with a registered codec, must be called with the GIL held.
+func pickledFromPython(obj *C.PyObject) (interface{}, error) {
+	name := typeName(obj)
+	p, err := pickle(obj)
+	if err != nil {
+		return nil, fmt.Errorf("can't convert %s to Go: %w", name, err)
+	}
+
+	if c, ok := pyTypeCodec(name); ok {
+		return c.Decode(p)
+	}
+	return p, nil
+}
+
+// codecToPython converts v with a registered codec, ok is false if there's no
+// codec for v's type. Must be called with the GIL held.
+func codecToPython(v interface{}) (obj *C.PyObject, ok bool, err error) {
+	c, ok := goTypeCodec(reflect.TypeOf(v))
+	if !ok {
+		return nil, false, nil
+	}
+
+	p, err := c.Encode(v)
+	if err != nil {
+		return nil, true, err
+	}
+	obj, err = unpickle(p)
+	return obj, true, err
+}
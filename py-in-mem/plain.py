@@ -0,0 +1,14 @@
+"""Detect outliers without numpy"""
+
+
+def detect(data, threshold=2):
+    """Return indices where values more than threshold standard deviations
+    from mean.
+
+    data is any sequence of numbers, a numpy array or a memoryview when numpy
+    isn't installed.
+    """
+    values = list(data)
+    mean = sum(values) / len(values)
+    std = (sum((v - mean) ** 2 for v in values) / len(values)) ** 0.5
+    return [i for i, v in enumerate(values) if abs(v - mean) > threshold * std]
@@ -0,0 +1,136 @@
+package outliers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Pool.Detect after the pool is closed
+var ErrPoolClosed = errors.New("pool closed")
+
+// Pool runs Detect calls on a fixed number of workers, each with its own
+// Outliers on a dedicated OS thread. Calls wait in a queue until a worker is
+// free.
+//
+// The GIL still serializes Python code, Pool helps when the Python function
+// releases the GIL (as many numpy operations do) or with WithSubInterpreter.
+type Pool struct {
+	mu     sync.RWMutex // Guards closed, Close waits for pending sends
+	closed bool
+	reqs   chan poolReq
+	wg     sync.WaitGroup
+}
+
+type poolReq struct {
+	ctx  context.Context
+	data []float64
+	out  chan poolResp
+}
+
+type poolResp struct {
+	indices []int
+	err     error
+}
+
+// NewPool returns a new Pool with size workers using moduleName.funcName
+// Python function, opts are passed to NewOutliers
+func NewPool(size int, moduleName, funcName string, opts ...Option) (*Pool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("bad pool size: %d", size)
+	}
+
+	workers := make([]*Outliers, size)
+	for i := range workers {
+		o, err := NewOutliers(moduleName, funcName, opts...)
+		if err != nil {
+			for _, w := range workers[:i] {
+				w.Close()
+			}
+			return nil, err
+		}
+		workers[i] = o
+	}
+
+	p := Pool{
+		reqs: make(chan poolReq, size),
+	}
+	p.wg.Add(size)
+	for _, o := range workers {
+		go p.worker(o)
+	}
+
+	return &p, nil
+}
+
+func (p *Pool) worker(o *Outliers) {
+	defer p.wg.Done()
+	defer o.Close()
+
+	// Keep the worker Python thread state on one OS thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for req := range p.reqs {
+		if err := req.ctx.Err(); err != nil { // Caller gave up
+			req.out <- poolResp{err: err}
+			continue
+		}
+		indices, err := o.Detect(req.data)
+		req.out <- poolResp{indices, err}
+	}
+}
+
+// Detect returns slice of outliers indices, it waits for a free worker or
+// until ctx is done
+func (p *Pool) Detect(ctx context.Context, data []float64) ([]int, error) {
+	req := poolReq{
+		ctx:  ctx,
+		data: data,
+		out:  make(chan poolResp, 1),
+	}
+
+	if err := p.send(ctx, req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-req.out:
+		return resp.indices, resp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) send(ctx context.Context, req poolReq) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.reqs <- req:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new calls, waits for queued calls to finish and frees
+// the workers
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.reqs)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
@@ -0,0 +1,68 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Frame is a Python traceback frame
+type Frame struct {
+	File string
+	Func string
+	Line int
+}
+
+// PyError is a Python exception. Use errors.As to get it from errors returned
+// by this package.
+type PyError struct {
+	Type    string  // Exception type name (e.g. "ZeroDivisionError")
+	Message string  // str(exception)
+	Frames  []Frame // Traceback, most recent call last
+}
+
+func (e *PyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// Traceback returns the traceback in Python's format
+func (e *PyError) Traceback() string {
+	var b strings.Builder
+	b.WriteString("Traceback (most recent call last):\n")
+	for _, f := range e.Frames {
+		fmt.Fprintf(&b, "  File %q, line %d, in %s\n", f.File, f.Line, f.Func)
+	}
+	b.WriteString(e.Error())
+	return b.String()
+}
+
+// cError converts an error from the C glue to a *PyError and frees it.
+// Returns nil if ce is nil.
+func cError(ce *C.py_error_t) error {
+	if ce == nil {
+		return nil
+	}
+	defer C.py_error_free(ce)
+
+	e := PyError{
+		Type:    C.GoString(ce._type),
+		Message: C.GoString(ce.msg),
+	}
+	if ce.nframes > 0 {
+		frames := unsafe.Slice(ce.frames, ce.nframes)
+		e.Frames = make([]Frame, len(frames))
+		for i, f := range frames {
+			e.Frames[i] = Frame{
+				File: C.GoString(f.file),
+				Func: C.GoString(f._func),
+				Line: int(f.line),
+			}
+		}
+	}
+	return &e
+}
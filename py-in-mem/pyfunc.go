@@ -0,0 +1,203 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// PyFunc is a Python callable. It's safe for concurrent use.
+type PyFunc struct {
+	mu sync.RWMutex
+	fn *C.PyObject
+}
+
+// LoadFunc returns moduleName.funcName Python function.
+func LoadFunc(moduleName, funcName string) (*PyFunc, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	fn, err := loadPyFunc(nil, moduleName, funcName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPyFunc(fn), nil
+}
+
+// newPyFunc returns a PyFunc owning the reference to fn.
+func newPyFunc(fn *C.PyObject) *PyFunc {
+	f := &PyFunc{fn: fn}
+	track(unsafe.Pointer(f), "PyFunc", nil, func() {
+		C.py_decref(fn)
+		refReleased(fn)
+	})
+	runtime.SetFinalizer(f, func(f *PyFunc) {
+		logLeak(unsafe.Pointer(f))
+		f.Close()
+	})
+	return f
+}
+
+// Call calls the function with args and returns the converted result.
+//
+// Arguments are converted to Python objects:
+//
+//	nil -> None
+//	bool -> bool
+//	int, int8 ... uint64, uintptr -> int
+//	float32, float64 -> float
+//	complex64, complex128 -> complex
+//	string -> str
+//	[]byte -> bytes
+//	slices & arrays -> list
+//	maps -> dict
+//	structs -> dict (see Decode)
+//	pointers -> the value they point to
+//	*DataFrame -> pandas.DataFrame
+//	Pickled -> pickle.loads(value)
+//	*Object -> the object
+//	*MemoryView -> memoryview sharing the []byte memory
+//
+// The result is converted back to Go:
+//
+//	None -> nil
+//	bool -> bool
+//	int -> int
+//	float -> float64
+//	complex -> complex128
+//	str -> string
+//	bytes, bytearray, memoryview -> []byte
+//	list, tuple -> []interface{}
+//	dict -> map[string]interface{} if all keys are str, otherwise map[interface{}]interface{}
+//	pandas.DataFrame -> *DataFrame
+//	1 dimensional numpy complex arrays -> []complex128
+//
+// Other objects that have a "tolist" method (such as numpy arrays and
+// scalars) are converted by calling it, the rest are returned as Pickled. See
+// RegisterCodec to convert specific types, or use CallObject to get the result
+// as an Object.
+//
+// If the function returns an awaitable (e.g. it's an async function) the
+// awaitable runs on an asyncio event loop in a background Python thread and
+// Call returns its result.
+func (f *PyFunc) Call(args ...interface{}) (interface{}, error) {
+	return f.CallKw(args, nil)
+}
+
+// CallKw calls the function with positional args and keyword arguments
+// kwargs, e.g. fn(*args, **kwargs) in Python. Conversions are the same as in
+// Call.
+func (f *PyFunc) CallKw(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	args, kwargs, err := prepareArgs(args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	err = withGIL(func() error {
+		pyArgs, err := toPyTuple(args)
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(pyArgs)
+
+		var pyKw *C.PyObject
+		if len(kwargs) > 0 {
+			pyKw, err = toPython(kwargs)
+			if err != nil {
+				return err
+			}
+			defer C.Py_DecRef(pyKw)
+		}
+
+		res := C.py_await(C.PyObject_Call(f.fn, pyArgs, pyKw))
+		if res == nil {
+			return cError(C.py_error())
+		}
+		defer C.Py_DecRef(res)
+
+		out, err = fromPython(res)
+		return err
+	})
+	return out, err
+}
+
+// Close frees the underlying Python function.
+// You can't use the function after closing it.
+func (f *PyFunc) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fn == nil {
+		return
+	}
+	runtime.SetFinalizer(f, nil)
+	if release := untrack(unsafe.Pointer(f)); release != nil {
+		release()
+	}
+	f.fn = nil
+}
+
+// withGIL runs fn with the GIL held and returns its error, or ErrFinalized
+// after Finalize. The goroutine is locked to its OS thread since the GIL
+// state is per thread.
+func withGIL(fn func() error) error {
+	var err error
+	if terr := trackCall(nil, func() {
+		state := C.PyGILState_Ensure()
+		defer C.PyGILState_Release(state)
+
+		err = fn()
+	}); terr != nil {
+		return terr
+	}
+	return err
+}
+
+// execPython runs Python code with globals in the main interpreter
+func execPython(code string, globals map[string]interface{}) error {
+	return withGIL(func() error {
+		exec, err := importAttr("builtins", "exec")
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(exec)
+
+		args, err := toPyTuple([]interface{}{code, globals})
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(args)
+
+		out := C.PyObject_Call(exec, args, nil)
+		if out == nil {
+			return cError(C.py_error())
+		}
+		C.Py_DecRef(out)
+		return nil
+	})
+}
+
+// cString returns a C char* pointing to s data and its length. The pointer is
+// valid only during the cgo call it's passed to.
+func cString(s string) (*C.char, C.Py_ssize_t) {
+	if len(s) == 0 {
+		return nil, 0
+	}
+	return (*C.char)(unsafe.Pointer(unsafe.StringData(s))), C.Py_ssize_t(len(s))
+}
@@ -0,0 +1,21 @@
+//go:build !py_refdebug
+
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+// Reference tracking is off, build with the "py_refdebug" tag to turn it on
+// (see CheckRefs).
+
+const refDebug = false
+
+func refAcquired(obj *C.PyObject, interp *C.PyInterpreterState) {}
+
+func refReleased(obj *C.PyObject) {}
+
+func refCount(obj *C.PyObject) int { return 0 }
+
+func checkRefs(interp *C.PyInterpreterState, forget bool) error { return nil }
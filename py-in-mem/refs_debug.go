@@ -0,0 +1,96 @@
+//go:build py_refdebug
+
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+const refDebug = true
+
+var refs struct {
+	sync.Mutex
+	objs map[*C.PyObject]*refEntry
+}
+
+// refEntry are the references Go holds to a Python object
+type refEntry struct {
+	interp *C.PyInterpreterState
+	stacks [][]byte // Where each reference was acquired
+}
+
+// refAcquired records a new reference to obj held by Go
+func refAcquired(obj *C.PyObject, interp *C.PyInterpreterState) {
+	refs.Lock()
+	defer refs.Unlock()
+
+	if refs.objs == nil {
+		refs.objs = make(map[*C.PyObject]*refEntry)
+	}
+	e, ok := refs.objs[obj]
+	if !ok {
+		e = &refEntry{interp: interp}
+		refs.objs[obj] = e
+	}
+	e.stacks = append(e.stacks, debug.Stack())
+}
+
+// refReleased records Go released a reference to obj
+func refReleased(obj *C.PyObject) {
+	refs.Lock()
+	defer refs.Unlock()
+
+	e, ok := refs.objs[obj]
+	if !ok {
+		log.Printf("outliers: releasing untracked reference to %p at:\n%s", obj, debug.Stack())
+		return
+	}
+	e.stacks = e.stacks[:len(e.stacks)-1]
+	if len(e.stacks) == 0 {
+		delete(refs.objs, obj)
+	}
+}
+
+// refCount returns the number of references to obj held by Go
+func refCount(obj *C.PyObject) int {
+	refs.Lock()
+	defer refs.Unlock()
+
+	if e, ok := refs.objs[obj]; ok {
+		return len(e.stacks)
+	}
+	return 0
+}
+
+// checkRefs logs references held in interp, forgets them if forget is true
+func checkRefs(interp *C.PyInterpreterState, forget bool) error {
+	refs.Lock()
+	defer refs.Unlock()
+
+	n := 0
+	for obj, e := range refs.objs {
+		if e.interp != interp {
+			continue
+		}
+		if forget {
+			delete(refs.objs, obj)
+		}
+		for _, stack := range e.stacks {
+			log.Printf("outliers: unreleased reference to %p, acquired at:\n%s", obj, stack)
+		}
+		n += len(e.stacks)
+	}
+
+	if n > 0 {
+		return fmt.Errorf("%d unreleased Python references", n)
+	}
+	return nil
+}
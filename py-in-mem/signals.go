@@ -0,0 +1,113 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+)
+
+// WithGoSignals initializes Python without its signal handlers. By default
+// Python installs a SIGINT handler which replaces Go's, so Ctrl-C is ignored
+// while no Python code runs and os/signal doesn't see it. With WithGoSignals
+// signals are handled by Go, use Interrupt to stop running Python calls on
+// Ctrl-C:
+//
+//	c := make(chan os.Signal, 1)
+//	signal.Notify(c, os.Interrupt)
+//	go func() {
+//		for range c {
+//			outliers.Interrupt()
+//		}
+//	}()
+func WithGoSignals() InitOption {
+	return func(c *initConfig) {
+		c.goSignals = true
+	}
+}
+
+// threadKey is a thread running Python code in an interpreter
+type threadKey struct {
+	interp *C.PyInterpreterState
+	tid    C.ulong
+}
+
+var running struct {
+	sync.Mutex
+	calls       map[threadKey]int  // Number of (nested) calls per thread
+	interrupted map[threadKey]bool // Threads interrupted by Interrupt
+	finalized   bool               // No new calls after Finalize
+}
+
+// interruptMu serializes raising KeyboardInterrupt with clearing it when
+// calls end. It's not held with running, Interrupt needs the GIL which
+// threads starting nested calls already hold.
+var interruptMu sync.Mutex
+
+// trackCall runs call, a call to Python in interp (nil for main), so
+// Interrupt can find it. It returns ErrFinalized without running call after
+// Finalize, unless the call is nested in one already running.
+func trackCall(interp *C.PyInterpreterState, call func()) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	key := threadKey{interp, C.PyThread_get_thread_ident()}
+
+	running.Lock()
+	if running.finalized && running.calls[key] == 0 { // Not nested
+		running.Unlock()
+		return ErrFinalized
+	}
+	if running.calls == nil {
+		running.calls = make(map[threadKey]int)
+		running.interrupted = make(map[threadKey]bool)
+	}
+	running.calls[key]++
+	running.Unlock()
+
+	call()
+
+	running.Lock()
+	running.calls[key]--
+	interrupted := false
+	if running.calls[key] == 0 {
+		delete(running.calls, key)
+		interrupted = running.interrupted[key]
+		delete(running.interrupted, key)
+	}
+	running.Unlock()
+
+	if interrupted {
+		// The call might have finished before Python raised the exception,
+		// clear it so it won't be raised in the next call in this thread.
+		interruptMu.Lock()
+		C.py_async_exc(key.interp, key.tid, C.ASYNC_CLEAR)
+		interruptMu.Unlock()
+	}
+	return nil
+}
+
+// Interrupt raises KeyboardInterrupt in all running Python calls, which then
+// fail with a *PyError unless the Python code handles the exception. Like
+// timeouts (see WithTimeout) the exception is raised between bytecode
+// instructions.
+func Interrupt() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+
+	running.Lock()
+	var keys []threadKey
+	for key := range running.calls {
+		running.interrupted[key] = true
+		keys = append(keys, key)
+	}
+	running.Unlock()
+
+	// Calls in keys wait for us to clear the exception when they end, their
+	// interpreters are still alive
+	for _, key := range keys {
+		C.py_async_exc(key.interp, key.tid, C.ASYNC_INTERRUPT)
+	}
+}
@@ -0,0 +1,108 @@
+package outliers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+var pyLog struct {
+	sync.RWMutex
+	once   sync.Once
+	err    error
+	logger *slog.Logger
+}
+
+// slogCode installs (or removes) a handler on Python's root logger that
+// forwards records to Go, level and install are set in globals
+const slogCode = `
+import logging, go
+
+class GoHandler(logging.Handler):
+    def emit(self, record):
+        try:
+            exc = ''
+            if record.exc_info:
+                exc = logging.Formatter().formatException(record.exc_info)
+            go._slog_record(record.levelno, record.name, record.getMessage(), exc)
+        except Exception:
+            self.handleError(record)
+
+root = logging.getLogger()
+for h in list(root.handlers):
+    if type(h).__name__ == 'GoHandler':
+        root.removeHandler(h)
+
+if install:
+    root.addHandler(GoHandler())
+    root.setLevel(level)
+`
+
+// SetLogger forwards Python logging records (from the root logger in the main
+// interpreter) to logger. Python levels map to the closest slog level, the
+// logger name is in the "logger" attribute and formatted exception
+// information in the "exc_info" attribute. The root logger level is set to the
+// lowest level logger is enabled for. A nil logger removes the handler.
+func SetLogger(logger *slog.Logger) error {
+	if err := initialize(); err != nil {
+		return err
+	}
+
+	pyLog.once.Do(func() {
+		pyLog.err = RegisterFunc("_slog_record", slogRecord)
+	})
+	if pyLog.err != nil {
+		return pyLog.err
+	}
+
+	pyLog.Lock()
+	pyLog.logger = logger
+	pyLog.Unlock()
+
+	globals := map[string]interface{}{
+		"install": logger != nil,
+		"level":   40, // logging.ERROR
+	}
+	if logger != nil {
+		for _, lvl := range []int{10, 20, 30} {
+			if logger.Enabled(context.Background(), slogLevel(lvl)) {
+				globals["level"] = lvl
+				break
+			}
+		}
+	}
+
+	return execPython(slogCode, globals)
+}
+
+// slogLevel converts a Python logging level to slog level
+func slogLevel(pyLevel int) slog.Level {
+	switch {
+	case pyLevel < 20: // DEBUG
+		return slog.LevelDebug
+	case pyLevel < 30: // INFO
+		return slog.LevelInfo
+	case pyLevel < 40: // WARNING
+		return slog.LevelWarn
+	case pyLevel < 50: // ERROR
+		return slog.LevelError
+	}
+	return slog.LevelError + 4 // CRITICAL
+}
+
+// slogRecord is called from Python GoHandler.emit
+func slogRecord(pyLevel int, name, msg, exc string) {
+	pyLog.RLock()
+	logger := pyLog.logger
+	pyLog.RUnlock()
+
+	if logger == nil {
+		return
+	}
+
+	args := []interface{}{"logger", name}
+	if exc != "" {
+		args = append(args, "exc_info", exc)
+	}
+	logger.Log(context.Background(), slogLevel(pyLevel), msg, args...)
+}
@@ -0,0 +1,56 @@
+package outliers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// sourceCode creates module name from source and adds it to sys.modules,
+// name and source are set in globals. As with import, the module is in
+// sys.modules while its code runs and is removed if it fails.
+const sourceCode = `
+import sys, types
+
+if name not in sys.modules:
+    module = types.ModuleType(name)
+    sys.modules[name] = module
+    try:
+        exec(compile(source, '<%s>' % name, 'exec'), module.__dict__)
+    except BaseException:
+        del sys.modules[name]
+        raise
+`
+
+// LoadFuncFromSource returns funcName Python function from the module source,
+// Python code in memory instead of in a file on the PYTHONPATH. Use it with
+// go:embed to ship the Python code inside the executable:
+//
+//	//go:embed detect.py
+//	var detectPy string
+//	...
+//	detect, err := outliers.LoadFuncFromSource(detectPy, "detect")
+//
+// The module is created once per source, loading other functions from the
+// same source reuses it.
+func LoadFuncFromSource(source, funcName string) (*PyFunc, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	name := sourceModule(source)
+	globals := map[string]interface{}{
+		"name":   name,
+		"source": source,
+	}
+	if err := execPython(sourceCode, globals); err != nil {
+		return nil, err
+	}
+
+	return LoadFunc(name, funcName)
+}
+
+// sourceModule returns the name of the module created from source
+func sourceModule(source string) string {
+	h := sha256.Sum256([]byte(source))
+	return "_outliers_source_" + hex.EncodeToString(h[:8])
+}
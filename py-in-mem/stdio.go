@@ -0,0 +1,94 @@
+package outliers
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+var stdio struct {
+	sync.RWMutex
+	once    sync.Once
+	err     error
+	writers map[string]io.Writer // "stdout" or "stderr" -> writer
+}
+
+// stdioCode installs a sys.<name> that writes to Go, name and redirect are
+// set in globals
+const stdioCode = `
+import io, sys, go
+
+class GoWriter(io.TextIOBase):
+    encoding = 'utf-8'
+
+    def __init__(self, name):
+        self.name = name
+
+    def writable(self):
+        return True
+
+    def write(self, s):
+        return go._stdio_write(self.name, s)
+
+if redirect:
+    setattr(sys, name, GoWriter(name))
+else:
+    setattr(sys, name, getattr(sys, '__%s__' % name))
+`
+
+// SetStdout sets the writer Python's sys.stdout (e.g. print) writes to in the
+// main interpreter. A nil w restores the original sys.stdout.
+func SetStdout(w io.Writer) error {
+	return setStdio("stdout", w)
+}
+
+// SetStderr sets the writer Python's sys.stderr writes to in the main
+// interpreter. A nil w restores the original sys.stderr.
+func SetStderr(w io.Writer) error {
+	return setStdio("stderr", w)
+}
+
+func setStdio(name string, w io.Writer) error {
+	if err := initialize(); err != nil {
+		return err
+	}
+
+	stdio.once.Do(func() {
+		stdio.err = RegisterFunc("_stdio_write", stdioWrite)
+	})
+	if stdio.err != nil {
+		return stdio.err
+	}
+
+	stdio.Lock()
+	if stdio.writers == nil {
+		stdio.writers = make(map[string]io.Writer)
+	}
+	stdio.writers[name] = w
+	stdio.Unlock()
+
+	globals := map[string]interface{}{
+		"name":     name,
+		"redirect": w != nil,
+	}
+
+	return execPython(stdioCode, globals)
+}
+
+// stdioWrite is called from Python GoWriter.write, returns the number of
+// characters written
+func stdioWrite(name, s string) (int, error) {
+	stdio.RLock()
+	w := stdio.writers[name]
+	stdio.RUnlock()
+
+	if w == nil {
+		return 0, fmt.Errorf("%s: no writer", name)
+	}
+
+	if _, err := io.WriteString(w, s); err != nil {
+		return 0, err
+	}
+	return utf8.RuneCountInString(s), nil
+}
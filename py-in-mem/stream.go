@@ -0,0 +1,162 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Stream feeds a time series to a stateful Python detector in chunks.
+//
+// The detector is created by calling a Python factory (such as a class)
+// without arguments. Its push method is called with each chunk (as a numpy
+// array) and returns a numpy array of outlier indices, counted from the
+// start of the stream. An optional flush method (without arguments) returns
+// indices the detector was holding back, e.g. until a window is full.
+//
+// Unlike Detect, push gets a copy of the chunk owned by Python, the detector
+// may keep chunks between calls and the caller may reuse the chunk memory
+// once Push returns.
+//
+// Stream is safe for concurrent use, chunks are pushed in call order.
+type Stream struct {
+	mu      sync.Mutex  // Guards pending and orders pushes
+	o       *Outliers   // fn is the detector push method
+	flush   *C.PyObject // Detector flush method, nil if it has none
+	pending []int       // Indices found since last Flush
+}
+
+// NewStream returns a new Stream using a detector created by calling
+// moduleName.factoryName, opts are the same as for NewOutliers.
+func NewStream(moduleName, factoryName string, opts ...Option) (*Stream, error) {
+	o, err := NewOutliers(moduleName, factoryName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if o.nanPolicy == NaNDrop {
+		o.Close()
+		return nil, fmt.Errorf("NaNDrop not supported in streams")
+	}
+
+	var (
+		flush *C.PyObject
+		cErr  *C.py_error_t
+	)
+	push := C.new_stream(o.interp, o.fn, &flush, &cErr)
+	if push == nil {
+		o.Close()
+		return nil, cError(cErr)
+	}
+
+	refAcquired(push, o.interp)
+	refAcquired(flush, o.interp)
+
+	// Replace the factory with the push method, flush is released with it
+	if o.cacheKey != nil {
+		releaseFunc(o.cacheKey.module, o.cacheKey.name)
+		o.cacheKey = nil
+	} else {
+		C.py_decref_in(o.interp, o.fn)
+		refReleased(o.fn)
+	}
+	o.fn = push
+	track(unsafe.Pointer(o), "Stream", o.interp, o.releaser(flush))
+
+	return &Stream{o: o, flush: flush}, nil
+}
+
+// Push sends chunk to the detector.
+func (s *Stream) Push(chunk []float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.o.nanPolicy == NaNError {
+		if _, _, err := dropNaN(chunk, NaNError); err != nil {
+			return err
+		}
+	}
+
+	indices, err := s.push(chunk)
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending, indices...)
+	return nil
+}
+
+// push calls the detector push method with a copy of chunk (see Stream).
+func (s *Stream) push(chunk []float64) ([]int, error) {
+	o := s.o
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	if len(chunk) == 0 {
+		return nil, nil
+	}
+
+	carr := (*C.double)(&chunk[0])
+	res, err := o.call(func() C.result_t {
+		return C.detect_copy(o.interp, o.fn, carr, C.long(len(chunk)))
+	})
+	runtime.KeepAlive(chunk)
+	if err != nil {
+		return nil, err
+	}
+	return o.resultToSlice(res)
+}
+
+// Flush returns the outlier indices found since the previous Flush, calling
+// the detector flush method if it has one.
+func (s *Stream) Flush() ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flush != nil {
+		indices, err := s.callFlush()
+		if err != nil {
+			return nil, err
+		}
+		s.pending = append(s.pending, indices...)
+	}
+
+	out := s.pending
+	s.pending = nil
+	return out, nil
+}
+
+func (s *Stream) callFlush() ([]int, error) {
+	o := s.o
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.fn == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	res, err := o.call(func() C.result_t {
+		return C.detect_call(o.interp, s.flush)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.resultToSlice(res)
+}
+
+// Close frees the detector, you can't use the stream after closing it.
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flush = nil
+	s.o.Close() // Releases flush as well
+}
@@ -0,0 +1,74 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import "path/filepath"
+
+// AddPath adds dir to the front of sys.path in the main interpreter (if it's
+// not there already), making Python modules in dir importable.
+func AddPath(dir string) error {
+	if err := initialize(); err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	code := `
+import importlib, sys
+if dir not in sys.path:
+    sys.path.insert(0, dir)
+    importlib.invalidate_caches()
+`
+	return execPython(code, map[string]interface{}{"dir": dir})
+}
+
+// SetPaths replaces sys.path in the main interpreter with paths. Keep the
+// standard library paths (see Paths) if you want to import from it.
+func SetPaths(paths []string) error {
+	if err := initialize(); err != nil {
+		return err
+	}
+
+	code := `
+import importlib, sys
+sys.path[:] = paths
+importlib.invalidate_caches()
+`
+	return execPython(code, map[string]interface{}{"paths": paths})
+}
+
+// Paths returns sys.path of the main interpreter.
+func Paths() ([]string, error) {
+	if err := initialize(); err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	err := withGIL(func() error {
+		obj, err := importAttr("sys", "path")
+		if err != nil {
+			return err
+		}
+		defer C.Py_DecRef(obj)
+
+		out, err = fromPython(obj)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, p := range out.([]interface{}) {
+		if s, ok := p.(string); ok {
+			paths = append(paths, s)
+		}
+	}
+	return paths, nil
+}
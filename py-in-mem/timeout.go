@@ -0,0 +1,63 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned when a Python call runs longer than the timeout set
+// with WithTimeout
+var ErrTimeout = errors.New("python call timed out")
+
+// watch runs call, if call runs longer than d it raises TimeoutError in the
+// Python thread running it in interp (nil for main). call must not hold the
+// GIL when it returns. Returns true if the timeout fired.
+func watch(interp *C.PyInterpreterState, d time.Duration, call func()) bool {
+	if d <= 0 {
+		call()
+		return false
+	}
+
+	// Python raises the exception in a specific thread, make sure call runs in
+	// the current one
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	tid := C.PyThread_get_thread_ident()
+
+	var (
+		mu    sync.Mutex
+		done  bool
+		fired bool
+	)
+	t := time.AfterFunc(d, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if done {
+			return
+		}
+		fired = true
+		C.py_async_exc(interp, tid, C.ASYNC_TIMEOUT)
+	})
+
+	call()
+
+	t.Stop()
+	mu.Lock()
+	done = true
+	mu.Unlock()
+
+	if fired {
+		// The call might have finished before Python raised the exception,
+		// clear it so it won't be raised in the next call in this thread.
+		C.py_async_exc(interp, tid, C.ASYNC_CLEAR)
+	}
+	return fired
+}
@@ -0,0 +1,20 @@
+package outliers
+
+// CallOf calls f with args and decodes the result to T (see Decode), so the
+// result has a compile time type, e.g.
+//
+//	indices, err := outliers.CallOf[[]int](detect, data)
+func CallOf[T any](f *PyFunc, args ...interface{}) (T, error) {
+	return CallKwOf[T](f, args, nil)
+}
+
+// CallKwOf is like CallOf with keyword arguments, see PyFunc.CallKw.
+func CallKwOf[T any](f *PyFunc, args []interface{}, kwargs map[string]interface{}) (T, error) {
+	var out T
+	v, err := f.CallKw(args, kwargs)
+	if err != nil {
+		return out, err
+	}
+	err = Decode(v, &out)
+	return out, err
+}
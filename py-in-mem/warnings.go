@@ -0,0 +1,103 @@
+package outliers
+
+/*
+#include "glue.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Warning is a Python warning (see the warnings module) emitted during a call
+type Warning struct {
+	Category string // Warning class name, e.g. "RuntimeWarning"
+	Message  string
+	File     string
+	Line     int
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", w.File, w.Line, w.Category, w.Message)
+}
+
+var pyWarnings struct {
+	sync.Mutex
+	once     sync.Once
+	err      error
+	warnings map[uint64][]Warning // Thread ID -> warnings collected in calls
+}
+
+// warningsCode replaces warnings.showwarning with a function sending
+// warnings to Go when a call in the current thread collects them
+const warningsCode = `
+import threading, warnings, go
+
+_showwarning = warnings.showwarning
+
+def showwarning(message, category, filename, lineno, file=None, line=None):
+    if not go._warning(threading.get_ident(), category.__name__, str(message), filename, lineno):
+        _showwarning(message, category, filename, lineno, file, line)
+
+warnings.showwarning = showwarning
+`
+
+// installWarnings installs the showwarning hook once in the main interpreter
+func installWarnings() error {
+	pyWarnings.once.Do(func() {
+		if pyWarnings.err = RegisterFunc("_warning", pyWarning); pyWarnings.err != nil {
+			return
+		}
+		pyWarnings.err = execPython(warningsCode, map[string]interface{}{})
+	})
+	return pyWarnings.err
+}
+
+// pyWarning is called from Python showwarning, it returns false if no call
+// collects warnings in thread tid so Python shows the warning as usual.
+func pyWarning(tid uint64, category, message, file string, line int) bool {
+	pyWarnings.Lock()
+	defer pyWarnings.Unlock()
+
+	ws, ok := pyWarnings.warnings[tid]
+	if !ok {
+		return false
+	}
+	pyWarnings.warnings[tid] = append(ws, Warning{category, message, file, line})
+	return true
+}
+
+// collectWarnings runs call and passes warnings emitted by Python in the
+// current thread to fn after call returns. If fn is nil it only runs call.
+func collectWarnings(fn func(Warning), call func()) {
+	if fn == nil {
+		call()
+		return
+	}
+
+	// Python reports the thread ID of the caller, make sure call runs in the
+	// current thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	tid := uint64(C.PyThread_get_thread_ident())
+
+	pyWarnings.Lock()
+	if pyWarnings.warnings == nil {
+		pyWarnings.warnings = make(map[uint64][]Warning)
+	}
+	pyWarnings.warnings[tid] = []Warning{}
+	pyWarnings.Unlock()
+
+	call()
+
+	pyWarnings.Lock()
+	ws := pyWarnings.warnings[tid]
+	delete(pyWarnings.warnings, tid)
+	pyWarnings.Unlock()
+
+	for _, w := range ws {
+		fn(w)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build embedpy
+
+package pybridge
+
+import (
+	"context"
+	"sync"
+
+	outliers "py-in-mem"
+)
+
+// Embedded is a Backend calling Python embedded in the current process.
+type Embedded struct {
+	mu    sync.Mutex
+	funcs map[string]*outliers.Outliers
+}
+
+func newEmbedded() (Backend, error) {
+	return NewEmbedded(), nil
+}
+
+// NewEmbedded returns a new Embedded backend.
+func NewEmbedded() *Embedded {
+	return &Embedded{
+		funcs: make(map[string]*outliers.Outliers),
+	}
+}
+
+// Call calls fn, args must be a single []float64. The result is []int.
+// Functions are loaded on first call.
+func (e *Embedded) Call(ctx context.Context, fn string, args ...any) (any, error) {
+	data, err := floatsArg(fn, args)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := e.load(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.Detect(data)
+}
+
+func (e *Embedded) load(fn string) (*outliers.Outliers, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if o, ok := e.funcs[fn]; ok {
+		return o, nil
+	}
+
+	module, name, err := splitFunc(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := outliers.NewOutliers(module, name)
+	if err != nil {
+		return nil, err
+	}
+	e.funcs[fn] = o
+	return o, nil
+}
+
+// Close frees all loaded functions.
+func (e *Embedded) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for name, o := range e.funcs {
+		o.Close()
+		delete(e.funcs, name)
+	}
+	return nil
+}
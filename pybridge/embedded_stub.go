@@ -0,0 +1,8 @@
+//go:build !embedpy
+
+package pybridge
+
+// newEmbedded fails when built without embedded Python support.
+func newEmbedded() (Backend, error) {
+	return nil, ErrNotBuilt
+}
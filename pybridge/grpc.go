@@ -0,0 +1,74 @@
+package pybridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ardanlabs/python-go/grpc/pb"
+)
+
+// GRPC is a Backend calling the Python outliers gRPC server. It can call
+// only a single function - the one the server exposes.
+type GRPC struct {
+	fn     string
+	conn   *grpc.ClientConn
+	client pb.OutliersClient
+}
+
+// NewGRPC returns a new GRPC backend connected to addr. fn is the function
+// name callers use, default to "outliers.detect".
+func NewGRPC(addr, fn string) (*GRPC, error) {
+	if fn == "" {
+		fn = "outliers.detect"
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	g := GRPC{
+		fn:     fn,
+		conn:   conn,
+		client: pb.NewOutliersClient(conn),
+	}
+	return &g, nil
+}
+
+// Call calls the server Detect method, args must be a single []float64. The
+// result is []int32.
+func (g *GRPC) Call(ctx context.Context, fn string, args ...any) (any, error) {
+	if fn != g.fn {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFunc, fn)
+	}
+
+	data, err := floatsArg(fn, args)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timestamppb.New(time.Now())
+	req := pb.OutliersRequest{
+		Metrics: make([]*pb.Metric, len(data)),
+	}
+	for i, v := range data {
+		req.Metrics[i] = &pb.Metric{Time: now, Value: v}
+	}
+
+	resp, err := g.client.Detect(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Indices, nil
+}
+
+// Close closes the connection to the server.
+func (g *GRPC) Close() error {
+	return g.conn.Close()
+}
@@ -0,0 +1,120 @@
+// Package pybridge provides a single interface over the different ways this
+// repository calls Python from Go:
+//
+//   - embedded: Python embedded in the Go process via cgo (py-in-mem)
+//   - subprocess: Python worker processes (pyproc)
+//   - grpc: Python gRPC server (grpc)
+//
+// Applications pick a backend with Config and can switch strategies without
+// code changes:
+//
+//	b, err := pybridge.New(pybridge.Config{Backend: "subprocess"})
+//	...
+//	indices, err := pybridge.Detect(ctx, b, "outliers.detect", data)
+//
+// The embedded backend requires cgo, Python & numpy headers and is built only
+// with the "embedpy" build tag.
+package pybridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ardanlabs/python-go/pyproc"
+)
+
+var (
+	// ErrUnknownFunc is returned when a backend can't call a function.
+	ErrUnknownFunc = errors.New("pybridge: unknown function")
+	// ErrBadArgs is returned when arguments are not supported by the backend.
+	ErrBadArgs = errors.New("pybridge: bad arguments")
+	// ErrNotBuilt is returned by New for backends not compiled in.
+	ErrNotBuilt = errors.New("pybridge: backend not built")
+)
+
+// Backend calls Python functions. fn is "module.func" (e.g.
+// "outliers.detect").
+type Backend interface {
+	Call(ctx context.Context, fn string, args ...any) (any, error)
+	Close() error
+}
+
+// Config selects and configures a backend.
+type Config struct {
+	Backend string        `json:"backend"` // embedded, subprocess or grpc
+	Addr    string        `json:"addr"`    // grpc: server address
+	Func    string        `json:"func"`    // grpc: function served, default "outliers.detect"
+	Pool    pyproc.Config `json:"-"`       // subprocess: worker pool configuration
+}
+
+// New returns a new Backend according to cfg.
+func New(cfg Config) (Backend, error) {
+	switch cfg.Backend {
+	case "embedded":
+		return newEmbedded()
+	case "subprocess":
+		return NewSubprocess(cfg.Pool)
+	case "grpc":
+		return NewGRPC(cfg.Addr, cfg.Func)
+	}
+
+	return nil, fmt.Errorf("pybridge: unknown backend - %q", cfg.Backend)
+}
+
+// Detect calls fn with data and returns the result as outlier indices. It
+// hides the different result types each backend returns.
+func Detect(ctx context.Context, b Backend, fn string, data []float64) ([]int, error) {
+	out, err := b.Call(ctx, fn, data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := out.(type) {
+	case []int:
+		return v, nil
+	case []int32:
+		indices := make([]int, len(v))
+		for i, n := range v {
+			indices[i] = int(n)
+		}
+		return indices, nil
+	case []any:
+		indices := make([]int, len(v))
+		for i, n := range v {
+			val, ok := n.(int64)
+			if !ok {
+				return nil, fmt.Errorf("%s: %d: expected int, got %T", fn, i, n)
+			}
+			indices[i] = int(val)
+		}
+		return indices, nil
+	case nil:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%s: unexpected result type %T", fn, out)
+}
+
+// splitFunc splits "module.func" to module and function names.
+func splitFunc(fn string) (string, string, error) {
+	i := strings.LastIndex(fn, ".")
+	if i <= 0 || i == len(fn)-1 {
+		return "", "", fmt.Errorf("%w: %q (expected module.func)", ErrUnknownFunc, fn)
+	}
+	return fn[:i], fn[i+1:], nil
+}
+
+// floatsArg returns args as a single []float64 argument.
+func floatsArg(fn string, args []any) ([]float64, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%w: %s: expected 1 argument, got %d", ErrBadArgs, fn, len(args))
+	}
+
+	data, ok := args[0].([]float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: expected []float64, got %T", ErrBadArgs, fn, args[0])
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+package pybridge
+
+import (
+	"context"
+	"math"
+	"net"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/ardanlabs/python-go/grpc/pb"
+	"github.com/ardanlabs/python-go/pyproc"
+)
+
+// outliersServer is a Go implementation of the Python gRPC server.
+type outliersServer struct {
+	pb.UnimplementedOutliersServer
+}
+
+func (s *outliersServer) Detect(_ context.Context, req *pb.OutliersRequest) (*pb.OutliersResponse, error) {
+	n := float64(len(req.Metrics))
+	mean, std := 0.0, 0.0
+	for _, m := range req.Metrics {
+		mean += m.Value
+	}
+	mean /= n
+	for _, m := range req.Metrics {
+		std += (m.Value - mean) * (m.Value - mean)
+	}
+	std = math.Sqrt(std / n)
+
+	var resp pb.OutliersResponse
+	for i, m := range req.Metrics {
+		if math.Abs(m.Value-mean) > 2*std {
+			resp.Indices = append(resp.Indices, int32(i))
+		}
+	}
+	return &resp, nil
+}
+
+func startServer(t *testing.T) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err, "listen")
+
+	srv := grpc.NewServer()
+	pb.RegisterOutliersServer(srv, &outliersServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func genData() ([]float64, []int) {
+	data := make([]float64, 1000)
+	for i := range data {
+		data[i] = float64(i % 10)
+	}
+
+	indices := []int{7, 113, 835}
+	for _, i := range indices {
+		data[i] += 97
+	}
+	return data, indices
+}
+
+func TestGRPC(t *testing.T) {
+	require := require.New(t)
+
+	b, err := New(Config{Backend: "grpc", Addr: startServer(t)})
+	require.NoError(err, "new")
+	defer b.Close()
+
+	data, indices := genData()
+	out, err := Detect(context.Background(), b, "outliers.detect", data)
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "indices")
+
+	_, err = b.Call(context.Background(), "outliers.other", data)
+	require.ErrorIs(err, ErrUnknownFunc, "unknown func")
+
+	_, err = b.Call(context.Background(), "outliers.detect", "data")
+	require.ErrorIs(err, ErrBadArgs, "bad args")
+}
+
+func TestUnknownBackend(t *testing.T) {
+	_, err := New(Config{Backend: "carrier-pigeon"})
+	require.Error(t, err)
+}
+
+func TestSplitFunc(t *testing.T) {
+	require := require.New(t)
+
+	mod, fn, err := splitFunc("a.b.detect")
+	require.NoError(err)
+	require.Equal("a.b", mod)
+	require.Equal("detect", fn)
+
+	for _, name := range []string{"detect", ".detect", "outliers."} {
+		_, _, err := splitFunc(name)
+		require.ErrorIs(err, ErrUnknownFunc, name)
+	}
+}
+
+func TestSubprocess(t *testing.T) {
+	require := require.New(t)
+	if err := exec.Command("python3", "-c", "import msgpack").Run(); err != nil {
+		t.Skip("python3 with msgpack not found")
+	}
+
+	cfg := Config{
+		Backend: "subprocess",
+		Pool:    pyproc.Config{Size: 1, Dir: "../pyproc/testdata"},
+	}
+	b, err := New(cfg)
+	require.NoError(err, "new")
+	defer b.Close()
+
+	data, indices := genData()
+	out, err := Detect(context.Background(), b, "funcs.detect", data)
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "indices")
+}
@@ -0,0 +1,46 @@
+package pybridge
+
+import (
+	"context"
+
+	"github.com/ardanlabs/python-go/pyproc"
+)
+
+// Subprocess is a Backend calling Python in worker processes.
+type Subprocess struct {
+	pool *pyproc.Pool
+}
+
+// NewSubprocess returns a new Subprocess backend.
+func NewSubprocess(cfg pyproc.Config) (*Subprocess, error) {
+	pool, err := pyproc.NewPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subprocess{pool}, nil
+}
+
+// Call calls fn in a worker process. []float64 arguments are sent as numpy
+// arrays.
+func (s *Subprocess) Call(ctx context.Context, fn string, args ...any) (any, error) {
+	module, name, err := splitFunc(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	pargs := make([]any, len(args))
+	for i, arg := range args {
+		if v, ok := arg.([]float64); ok {
+			arg = pyproc.Float64s(v)
+		}
+		pargs[i] = arg
+	}
+
+	return s.pool.Call(ctx, module, name, pargs...)
+}
+
+// Close stops the worker processes.
+func (s *Subprocess) Close() error {
+	return s.pool.Close()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Hash algorithms
+const (
+	MD5     = "md5"
+	SHA1    = "sha1"
+	SHA256  = "sha256"
+	SHA512  = "sha512"
+	BLAKE2b = "blake2b" // BLAKE2b-512, as b2sum
+)
+
+// algorithm is a hash algorithm and its signature files
+type algorithm struct {
+	newHash   func() hash.Hash
+	manifests []string // Signature file names, in lookup order
+	tag       string   // Name in BSD format lines
+}
+
+var algorithms = map[string]algorithm{
+	MD5:     {md5.New, []string{"md5sum.txt", "MD5SUMS"}, "MD5"},
+	SHA1:    {sha1.New, []string{"sha1sum.txt", "SHA1SUMS"}, "SHA1"},
+	SHA256:  {sha256.New, []string{"sha256sum.txt", "SHA256SUMS"}, "SHA256"},
+	SHA512:  {sha512.New, []string{"sha512sum.txt", "SHA512SUMS"}, "SHA512"},
+	BLAKE2b: {newBlake2b, []string{"b2sum.txt", "B2SUMS"}, "BLAKE2b"},
+}
+
+// autoOrder is the signature files lookup order when detecting algorithms
+var autoOrder = []string{SHA1, SHA256, SHA512, MD5, BLAKE2b}
+
+// digestAlgorithms maps hex digest length to algorithm. BLAKE2b digests have
+// the same length as SHA512, they're detected only in BLAKE2b signature
+// files.
+var digestAlgorithms = map[int]string{
+	32:  MD5,
+	40:  SHA1,
+	64:  SHA256,
+	128: SHA512,
+}
+
+func newBlake2b() hash.Hash {
+	h, _ := blake2b.New512(nil) // Fails only on a bad key
+	return h
+}
+
+// signature is an expected file digest and the algorithm to compute it
+type signature struct {
+	digest    string
+	algo      string
+	newHash   func() hash.Hash
+	chunkSize int64 // Chunked digest (see chunkPrefix) if > 0
+}
+
+// loadSigs loads signatures of files selected by opts (see Options.selected)
+// from the opts.Algorithm signature file in st, or from opts.Manifest if set
+// (see readManifest). If the algorithm is "" it uses the first signature file
+// found (see autoOrder) and detects the algorithm of every digest from its
+// length, or from its BSD format line.
+func loadSigs(ctx context.Context, st storage, opts Options) (map[string]signature, error) {
+	if err := opts.checkPatterns(); err != nil {
+		return nil, err
+	}
+
+	algo := opts.Algorithm
+	names := autoOrder
+	if algo != "" {
+		if _, ok := algorithms[algo]; !ok {
+			return nil, withCode(codeArgument, fmt.Errorf("unknown hash algorithm: %q", algo))
+		}
+		names = []string{algo}
+	}
+
+	var (
+		data     []byte
+		fileAlgo string
+		err      error
+	)
+	if opts.Manifest != "" {
+		data, fileAlgo, err = readManifest(ctx, st, opts)
+	} else {
+		data, fileAlgo, err = findManifest(ctx, st, names, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	digests, err := parseSigFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := make(map[string]signature, len(digests))
+	for name, e := range digests {
+		if !opts.selected(name) {
+			logf(LogDebug, "%s: skipped", st.Path(name))
+			continue
+		}
+		digest, chunkSize, err := parseChunked(e.digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		a := algo
+		switch {
+		case e.algo != "" && algo != "" && e.algo != algo:
+			err := fmt.Errorf("%s: %s signature, expected %s", name, e.algo, algo)
+			return nil, withCode(codeManifest, err)
+		case e.algo != "":
+			a = e.algo
+		case a == "":
+			if a, err = detectAlgorithm(digest, fileAlgo); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		sigs[name] = signature{digest, a, algorithms[a].newHash, chunkSize}
+	}
+	return sigs, nil
+}
+
+// detectAlgorithm returns the algorithm of digest from its length, fileAlgo
+// is the algorithm of the signature file
+func detectAlgorithm(digest, fileAlgo string) (string, error) {
+	if fileAlgo == BLAKE2b && len(digest) == 128 {
+		return BLAKE2b, nil
+	}
+	algo, ok := digestAlgorithms[len(digest)]
+	if !ok {
+		return "", withCode(codeManifest, fmt.Errorf("can't detect hash algorithm of %q", digest))
+	}
+	return algo, nil
+}
+
+// findManifest reads the first signature file found in st for the algos
+// algorithms, see readManifest
+func findManifest(ctx context.Context, st storage, algos []string, opts Options) ([]byte, string, error) {
+	var err error
+	for _, algo := range algos {
+		for _, name := range algorithms[algo].manifests {
+			opts.Manifest = name
+			var (
+				data     []byte
+				fileAlgo string
+			)
+			data, fileAlgo, err = readManifest(ctx, st, opts)
+			if !errors.Is(err, fs.ErrNotExist) {
+				return data, fileAlgo, err
+			}
+		}
+	}
+	return nil, "", err
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CheckArchiveSignatures checks the signatures of files in the zip (".zip") or
+// tar (".tar", ".tar.gz" or ".tgz") archive archivePath against the signature
+// file at the archive root (see CheckSignatures), without extracting it.
+func CheckArchiveSignatures(archivePath string) error {
+	return checkArchiveSignatures(archivePath, Options{})
+}
+
+// checkArchiveSignatures is CheckArchiveSignatures with options, Progress is
+// ignored
+func checkArchiveSignatures(archivePath string, opts Options) error {
+	name := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return checkZip(archivePath, opts)
+	case strings.HasSuffix(name, ".tar"), isGzip(name):
+		return checkTar(archivePath, opts)
+	}
+	err := fmt.Errorf("%s: unknown archive type, expected .zip, .tar, .tar.gz or .tgz", archivePath)
+	return withCode(codeArgument, err)
+}
+
+func isGzip(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// checkZip checks the signatures of files in the zip archive archivePath
+func checkZip(archivePath string, opts Options) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return checkFS(r, archivePath, opts)
+}
+
+// checkTar checks the signatures of files in the tar archive archivePath.
+// Files in tar archives can only be read in order, the archive is read twice:
+// once for the signature file, once to hash files while streaming them.
+func checkTar(archivePath string, opts Options) error {
+	ctx := context.Background()
+	start := time.Now()
+
+	// The signature file and its detached signature (see checkManifestSig)
+	// can be anywhere in the stream, keep candidates in memory
+	manifests := make(memStorage)
+	err := walkTar(archivePath, func(name string, r io.Reader) error {
+		if !isManifestCandidate(name, opts) {
+			return nil
+		}
+		data, err := limitedRead(r, path.Join(archivePath, name))
+		if err != nil {
+			return err
+		}
+		manifests[name] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sigs, err := loadSigs(ctx, manifests, opts)
+	if err != nil {
+		return err
+	}
+
+	count := len(sigs)
+	err = walkTar(archivePath, func(name string, r io.Reader) error {
+		sig, ok := sigs[name]
+		if !ok {
+			return nil
+		}
+		delete(sigs, name)
+		digest, err := readerSig(ctx, r, sig, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path.Join(archivePath, name), err)
+		}
+		if digest != sig.digest {
+			return mismatchError(path.Join(archivePath, name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(sigs) > 0 {
+		missing := make([]string, 0, len(sigs))
+		for name := range sigs {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("%s: %w", path.Join(archivePath, missing[0]), fs.ErrNotExist)
+	}
+	logf(LogInfo, "%s: %d files checked in %v", archivePath, count, time.Since(start))
+	return nil
+}
+
+// isManifestCandidate returns true if name may be the signature file or its
+// detached signature
+func isManifestCandidate(name string, opts Options) bool {
+	for _, ext := range []string{minisignExt, ed25519Ext} {
+		if base, ok := strings.CutSuffix(name, ext); ok && isManifestCandidate(base, opts) {
+			return true
+		}
+	}
+	return manifestAlgorithm(name) != "" || name == path.Clean(opts.Manifest)
+}
+
+// walkTar calls fn with the name and content of every regular file in the tar
+// archive archivePath, gzip compressed if its name ends with ".tar.gz" or
+// ".tgz". It stops at the first error returned by fn.
+func walkTar(archivePath string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if isGzip(strings.ToLower(archivePath)) {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", archivePath, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", archivePath, err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		// path.Clean removes the "./" prefix of "tar -C dir ." archives
+		if err := fn(path.Clean(hdr.Name), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// memStorage is files in memory, by name
+type memStorage map[string][]byte
+
+func (m memStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m memStorage) Path(name string) string {
+	return name
+}
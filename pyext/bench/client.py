@@ -3,6 +3,9 @@ import grpc
 import bench_pb2 as pb
 import bench_pb2_grpc as gpb
 
+# Maximal message size, see maxMessage in server.go
+max_message = (64 << 20) + (1 << 10)
+
 
 def call(chan):
     msg = pb.Empty()
@@ -10,5 +13,42 @@ def call(chan):
     return stub.Bench(msg)
 
 
+def payload(size, count):
+    """Payload with size bytes and count doubles"""
+    return pb.Payload(data=bytes(size), values=[i / 3 for i in range(count)])
+
+
+def get(chan, size, count):
+    """Get a payload of size bytes and count doubles"""
+    stub = gpb.BenchStub(chan)
+    return stub.Get(pb.PayloadRequest(size=size, count=count))
+
+
+def put(chan, msg):
+    """Send msg, a payload"""
+    stub = gpb.BenchStub(chan)
+    return stub.Put(msg)
+
+
+def stream(chan, size, count, messages):
+    """Receive messages payloads of size bytes and count doubles"""
+    stub = gpb.BenchStub(chan)
+    req = pb.StreamRequest(
+        payload=pb.PayloadRequest(size=size, count=count),
+        messages=messages,
+    )
+    for _ in stub.Stream(req):
+        pass
+
+
+def echo(chan, msg, delay_us=0):
+    """Send msg, a payload, and get it back after delay_us microseconds"""
+    stub = gpb.BenchStub(chan)
+    return stub.Echo(pb.EchoRequest(payload=msg, delay_us=delay_us))
+
+
 def connect(host, port):
-    return grpc.insecure_channel(f'{host}:{port}')
+    return grpc.insecure_channel(f'{host}:{port}', options=[
+        ('grpc.max_receive_message_length', max_message),
+        ('grpc.max_send_message_length', max_message),
+    ])
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histogram buckets are log-linear as in HdrHistogram: values below subCount
+// nanoseconds are exact, above every power of 2 range is split in
+// subCount/2 buckets. The relative error is below 2/subCount (about 1.6%).
+const (
+	subBits  = 7
+	subCount = 1 << subBits
+	subHalf  = subCount / 2
+)
+
+// histogram is a latency histogram, the zero value is empty
+type histogram struct {
+	counts []int64 // By bucket index, see bucketIndex
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// bucketIndex returns the bucket of v, v >= 0
+func bucketIndex(v int64) int {
+	if v < subCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBits
+	return shift*subHalf + int(v>>shift)
+}
+
+// bucketMax returns the highest value in bucket i
+func bucketMax(i int) int64 {
+	if i < subCount {
+		return int64(i)
+	}
+	shift := i/subHalf - 1
+	sub := int64(i - shift*subHalf)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds a latency, negative ones are recorded as 0
+func (h *histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := bucketIndex(int64(d))
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the latencies recorded in o
+func (h *histogram) Merge(o *histogram) {
+	if o.count == 0 {
+		return
+	}
+	if len(o.counts) > len(h.counts) {
+		counts := make([]int64, len(o.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.sum += o.sum
+}
+
+// Count returns the number of latencies recorded
+func (h *histogram) Count() int64 {
+	return h.count
+}
+
+// Min returns the lowest latency, 0 if h is empty
+func (h *histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the highest latency, 0 if h is empty
+func (h *histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean returns the mean latency, 0 if h is empty
+func (h *histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the latency below which are p percents of latencies, 0
+// if h is empty. It's the highest value of its bucket, capped to Max.
+func (h *histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var n int64
+	for i, c := range h.counts {
+		n += c
+		if n >= rank {
+			if v := time.Duration(bucketMax(i)); v < h.max {
+				return v
+			}
+			break
+		}
+	}
+	return h.max
+}
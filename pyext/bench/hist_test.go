@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuckets(t *testing.T) {
+	require := require.New(t)
+
+	prev := -1
+	for _, v := range []int64{0, 1, 127, 128, 129, 255, 256, 1e3, 1e6, 1e9, 1 << 62} {
+		i := bucketIndex(v)
+		require.GreaterOrEqual(i, prev, "%d: index decreasing", v)
+		prev = i
+		top := bucketMax(i)
+		require.GreaterOrEqual(top, v, "%d: bucket max", v)
+		require.LessOrEqual(float64(top-v), float64(v)/subHalf, "%d: bucket error", v)
+		require.Equal(i, bucketIndex(top), "%d: bucket max index", v)
+		require.Equal(i+1, bucketIndex(top+1), "%d: next bucket", v)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	require := require.New(t)
+
+	var h histogram
+	require.Equal(time.Duration(0), h.Percentile(99))
+
+	var h1, h2 histogram
+	for i := 1; i <= 1000; i++ {
+		d := time.Duration(i) * time.Microsecond
+		if i%2 == 0 {
+			h1.Record(d)
+		} else {
+			h2.Record(d)
+		}
+	}
+	h.Merge(&h1)
+	h.Merge(&h2)
+
+	require.Equal(int64(1000), h.Count())
+	require.Equal(time.Microsecond, h.Min())
+	require.Equal(time.Millisecond, h.Max())
+	require.InDelta(500500*time.Nanosecond, h.Mean(), 1)
+	for _, p := range []float64{50, 95, 99} {
+		expected := time.Duration(p*10) * time.Microsecond
+		require.InEpsilon(expected, h.Percentile(p), 2.0/subCount, "p%v", p)
+	}
+	require.Equal(time.Millisecond, h.Percentile(100))
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/ardanlabs/python-go/pyext/bench/pb"
+)
+
+// loadConfig is the load driver configuration
+type loadConfig struct {
+	Method      string        // RPC called: bench, get, put, stream or echo
+	Size        int           // Payload bytes
+	Count       int           // Payload doubles
+	Messages    int           // Payloads per stream call
+	Delay       time.Duration // Echo delay
+	Requests    int           // Number of calls, 0 to use Duration
+	Duration    time.Duration // Load duration if Requests is 0
+	Concurrency int           // Number of concurrent callers
+}
+
+// sample is a call latency
+type sample struct {
+	Start   time.Duration `json:"start_ns"` // Since the load start
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// loadReport is the result of runLoad, durations are in nanoseconds in JSON
+type loadReport struct {
+	Method   string        `json:"method"`
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	Duration time.Duration `json:"duration_ns"`
+	RPS      float64       `json:"rps"` // Requests per second
+	Min      time.Duration `json:"min_ns"`
+	Mean     time.Duration `json:"mean_ns"`
+	P50      time.Duration `json:"p50_ns"`
+	P95      time.Duration `json:"p95_ns"`
+	P99      time.Duration `json:"p99_ns"`
+	Max      time.Duration `json:"max_ns"`
+
+	Samples []sample `json:"samples,omitempty"`
+}
+
+// drive runs the load against the server at addr, writes the report in
+// format to stdout and the samples to samplesFile if set (see writeSamples)
+func drive(addr string, cfg loadConfig, format, samplesFile string) error {
+	// Fail before running the load
+	if err := writeReport(io.Discard, &loadReport{}, format); err != nil {
+		return err
+	}
+	if ext := filepath.Ext(samplesFile); samplesFile != "" && ext != ".csv" && ext != ".json" {
+		return fmt.Errorf("%s: unknown samples format %q, use .csv or .json", samplesFile, ext)
+	}
+
+	conn, err := grpc.Dial(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessage)),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r, err := runLoad(context.Background(), pb.NewBenchClient(conn), cfg)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(os.Stdout, r, format); err != nil {
+		return err
+	}
+	if samplesFile == "" {
+		return nil
+	}
+	return writeSamples(samplesFile, r)
+}
+
+// caller returns the function calling the cfg.Method RPC
+func caller(client pb.BenchClient, cfg loadConfig) (func(context.Context) error, error) {
+	switch cfg.Method {
+	case "bench":
+		return func(ctx context.Context) error {
+			_, err := client.Bench(ctx, &pb.Empty{})
+			return err
+		}, nil
+	case "get":
+		req := &pb.PayloadRequest{Size: int32(cfg.Size), Count: int32(cfg.Count)}
+		return func(ctx context.Context) error {
+			_, err := client.Get(ctx, req)
+			return err
+		}, nil
+	case "put":
+		p, err := newPayload(&pb.PayloadRequest{Size: int32(cfg.Size), Count: int32(cfg.Count)})
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			_, err := client.Put(ctx, p)
+			return err
+		}, nil
+	case "stream":
+		req := &pb.StreamRequest{
+			Payload:  &pb.PayloadRequest{Size: int32(cfg.Size), Count: int32(cfg.Count)},
+			Messages: int32(cfg.Messages),
+		}
+		return func(ctx context.Context) error {
+			stream, err := client.Stream(ctx, req)
+			if err != nil {
+				return err
+			}
+			for {
+				if _, err := stream.Recv(); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+			}
+		}, nil
+	case "echo":
+		p, err := newPayload(&pb.PayloadRequest{Size: int32(cfg.Size), Count: int32(cfg.Count)})
+		if err != nil {
+			return nil, err
+		}
+		req := &pb.EchoRequest{Payload: p, DelayUs: cfg.Delay.Microseconds()}
+		return func(ctx context.Context) error {
+			_, err := client.Echo(ctx, req)
+			return err
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown method: %q", cfg.Method)
+}
+
+// runLoad calls cfg.Method from cfg.Concurrency goroutines, cfg.Requests
+// times or for cfg.Duration. Failed calls are counted but not recorded. The
+// first error is logged.
+func runLoad(ctx context.Context, client pb.BenchClient, cfg loadConfig) (*loadReport, error) {
+	call, err := caller(client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Requests <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var (
+		mu      sync.Mutex
+		hist    histogram
+		samples []sample
+		errs    int64
+		calls   int64 // Calls started when cfg.Requests is set
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var (
+				h      histogram
+				s      []sample
+				failed int64
+			)
+			for ctx.Err() == nil {
+				if cfg.Requests > 0 {
+					mu.Lock()
+					calls++
+					done := calls > int64(cfg.Requests)
+					mu.Unlock()
+					if done {
+						break
+					}
+				}
+
+				t := time.Now()
+				err := call(ctx)
+				latency := time.Since(t)
+				if err != nil {
+					if ctx.Err() != nil {
+						break // Load duration is over
+					}
+					if failed == 0 {
+						log.Printf("%s: %v", cfg.Method, err)
+					}
+					failed++
+					continue
+				}
+				h.Record(latency)
+				s = append(s, sample{t.Sub(start), latency})
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			hist.Merge(&h)
+			samples = append(samples, s...)
+			errs += failed
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Start < samples[j].Start })
+
+	r := loadReport{
+		Method:   cfg.Method,
+		Requests: hist.Count(),
+		Errors:   errs,
+		Duration: elapsed,
+		RPS:      float64(hist.Count()) / elapsed.Seconds(),
+		Min:      hist.Min(),
+		Mean:     hist.Mean(),
+		P50:      hist.Percentile(50),
+		P95:      hist.Percentile(95),
+		P99:      hist.Percentile(99),
+		Max:      hist.Max(),
+		Samples:  samples,
+	}
+	return &r, nil
+}
+
+// writeReport writes the report summary in format: "text", "json" or
+// "csv" (a header line and a values line). Samples are omitted.
+func writeReport(w io.Writer, r *loadReport, format string) error {
+	switch format {
+	case "text":
+		_, err := fmt.Fprintf(w,
+			"%s: %d requests (%d errors) in %v, %.1f req/sec\n"+
+				"min %v, mean %v, p50 %v, p95 %v, p99 %v, max %v\n",
+			r.Method, r.Requests, r.Errors, r.Duration.Round(time.Millisecond), r.RPS,
+			r.Min, r.Mean, r.P50, r.P95, r.P99, r.Max)
+		return err
+	case "json":
+		summary := *r
+		summary.Samples = nil
+		return json.NewEncoder(w).Encode(summary)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{
+			"method", "requests", "errors", "duration_ns", "rps",
+			"min_ns", "mean_ns", "p50_ns", "p95_ns", "p99_ns", "max_ns",
+		})
+		cw.Write([]string{
+			r.Method,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.Errors, 10),
+			ns(r.Duration),
+			strconv.FormatFloat(r.RPS, 'f', 1, 64),
+			ns(r.Min), ns(r.Mean), ns(r.P50), ns(r.P95), ns(r.P99), ns(r.Max),
+		})
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format: %q", format)
+}
+
+// writeSamples writes the report samples, sorted by start time, to fileName
+// in CSV ("start_ns,latency_ns" lines with a header) or JSON (an array of
+// sample) depending on its extension
+func writeSamples(fileName string, r *loadReport) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch ext := filepath.Ext(fileName); ext {
+	case ".csv":
+		cw := csv.NewWriter(file)
+		cw.Write([]string{"start_ns", "latency_ns"})
+		for _, s := range r.Samples {
+			cw.Write([]string{ns(s.Start), ns(s.Latency)})
+		}
+		cw.Flush()
+		err = cw.Error()
+	case ".json":
+		samples := r.Samples
+		if samples == nil {
+			samples = []sample{}
+		}
+		err = json.NewEncoder(file).Encode(samples)
+	default:
+		err = fmt.Errorf("%s: unknown samples format %q, use .csv or .json", fileName, ext)
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ns returns d in nanoseconds
+func ns(d time.Duration) string {
+	return strconv.FormatInt(int64(d), 10)
+}
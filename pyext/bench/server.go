@@ -2,14 +2,27 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/ardanlabs/python-go/pyext/bench/pb"
 )
 
+const (
+	// maxPayload is the maximal payload size in bytes (data and values)
+	maxPayload = 64 << 20
+	// maxMessage is the maximal message size, a payload and protobuf framing
+	maxMessage = maxPayload + 1<<10
+	// maxDelay is the maximal Echo delay
+	maxDelay = 10 * time.Second
+)
+
 type BenchServer struct {
 	pb.UnimplementedBenchServer
 }
@@ -18,16 +31,109 @@ func (b *BenchServer) Bench(context.Context, *pb.Empty) (*pb.Empty, error) {
 	return &pb.Empty{}, nil
 }
 
+func (b *BenchServer) Get(ctx context.Context, req *pb.PayloadRequest) (*pb.Payload, error) {
+	return newPayload(req)
+}
+
+func (b *BenchServer) Put(context.Context, *pb.Payload) (*pb.Empty, error) {
+	return &pb.Empty{}, nil
+}
+
+func (b *BenchServer) Stream(req *pb.StreamRequest, stream pb.Bench_StreamServer) error {
+	if req.Messages < 0 {
+		return status.Errorf(codes.InvalidArgument, "negative messages: %d", req.Messages)
+	}
+	p, err := newPayload(req.Payload)
+	if err != nil {
+		return err
+	}
+
+	for i := int32(0); i < req.Messages; i++ {
+		if err := stream.Send(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BenchServer) Echo(ctx context.Context, req *pb.EchoRequest) (*pb.Payload, error) {
+	delay := time.Duration(req.DelayUs) * time.Microsecond
+	if delay < 0 || delay > maxDelay {
+		return nil, status.Errorf(codes.InvalidArgument, "bad delay: %v (max %v)", delay, maxDelay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	if req.Payload == nil {
+		return &pb.Payload{}, nil
+	}
+	return req.Payload, nil
+}
+
+// newPayload returns a payload with req.Size bytes and req.Count doubles, req
+// can be nil for an empty payload
+func newPayload(req *pb.PayloadRequest) (*pb.Payload, error) {
+	size, count := req.GetSize(), req.GetCount()
+	if size < 0 || count < 0 || int64(size)+8*int64(count) > maxPayload {
+		return nil, status.Errorf(codes.InvalidArgument, "bad payload: %d bytes, %d values (max %d bytes)", size, count, maxPayload)
+	}
+
+	p := pb.Payload{
+		Data:   make([]byte, size),
+		Values: make([]float64, count),
+	}
+	for i := range p.Data {
+		p.Data[i] = byte(i)
+	}
+	for i := range p.Values {
+		p.Values[i] = float64(i) / 3
+	}
+	return &p, nil
+}
+
+// newServer returns a gRPC server serving BenchServer
+func newServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(maxMessage))
+	pb.RegisterBenchServer(srv, &BenchServer{})
+	return srv
+}
+
 func main() {
-	addr := "localhost:8888"
-	lis, err := net.Listen("tcp", addr)
+	addr := flag.String("addr", "localhost:8888", "address to listen on, or of the server with -load")
+	load := flag.Bool("load", false, "call the server at -addr and report latencies instead of serving")
+	var cfg loadConfig
+	flag.StringVar(&cfg.Method, "method", "bench", "load: RPC called (bench, get, put, stream or echo)")
+	flag.IntVar(&cfg.Size, "size", 0, "load: payload bytes")
+	flag.IntVar(&cfg.Count, "count", 0, "load: payload doubles")
+	flag.IntVar(&cfg.Messages, "messages", 10, "load: payloads per stream call")
+	flag.DurationVar(&cfg.Delay, "delay", 0, "load: echo delay")
+	flag.IntVar(&cfg.Requests, "n", 0, "load: number of calls, 0 to call for -duration")
+	flag.DurationVar(&cfg.Duration, "duration", 10*time.Second, "load: duration")
+	flag.IntVar(&cfg.Concurrency, "c", 1, "load: number of concurrent callers")
+	format := flag.String("format", "text", "load: report format (text, json or csv)")
+	samples := flag.String("samples", "", "load: raw latencies file (.csv or .json)")
+	flag.Parse()
+
+	if *load {
+		if err := drive(*addr, cfg, *format, *samples); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	lis, err := net.Listen("tcp", *addr)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	log.Printf("server listening on %s", addr)
+	log.Printf("server listening on %s", *addr)
 
-	srv := grpc.NewServer()
-	pb.RegisterBenchServer(srv, &BenchServer{})
+	srv := newServer()
 	srv.Serve(lis)
 }
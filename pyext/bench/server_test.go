@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/ardanlabs/python-go/pyext/bench/pb"
+)
+
+// startServer starts a server on a random port and returns a client to it
+func startServer(t *testing.T) pb.BenchClient {
+	require := require.New(t)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err, "listen")
+	srv := newServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(
+		lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessage)),
+	)
+	require.NoError(err, "connect")
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewBenchClient(conn)
+}
+
+func TestPayload(t *testing.T) {
+	require := require.New(t)
+	client := startServer(t)
+	ctx := context.Background()
+
+	p, err := client.Get(ctx, &pb.PayloadRequest{Size: 1 << 20, Count: 1000})
+	require.NoError(err, "get")
+	require.Len(p.Data, 1<<20)
+	require.Len(p.Values, 1000)
+
+	_, err = client.Put(ctx, p)
+	require.NoError(err, "put")
+
+	_, err = client.Get(ctx, &pb.PayloadRequest{Size: maxPayload + 1})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	stream, err := client.Stream(ctx, &pb.StreamRequest{
+		Payload:  &pb.PayloadRequest{Size: 10, Count: 3},
+		Messages: 5,
+	})
+	require.NoError(err, "stream")
+	n := 0
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(err, "recv")
+		require.Len(p.Values, 3)
+		n++
+	}
+	require.Equal(5, n)
+}
+
+func TestEcho(t *testing.T) {
+	require := require.New(t)
+	client := startServer(t)
+	ctx := context.Background()
+
+	delay := 20 * time.Millisecond
+	start := time.Now()
+	req := &pb.EchoRequest{
+		Payload: &pb.Payload{Data: []byte("hello"), Values: []float64{1.5}},
+		DelayUs: delay.Microseconds(),
+	}
+	p, err := client.Echo(ctx, req)
+	require.NoError(err, "echo")
+	require.GreaterOrEqual(time.Since(start), delay)
+	require.Equal([]byte("hello"), p.Data)
+	require.Equal([]float64{1.5}, p.Values)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = client.Echo(ctx, &pb.EchoRequest{DelayUs: time.Second.Microseconds()})
+	require.Equal(codes.DeadlineExceeded, status.Code(err))
+
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{DelayUs: -1})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+	client := startServer(t)
+	ctx := context.Background()
+
+	for _, method := range []string{"bench", "get", "put", "stream", "echo"} {
+		cfg := loadConfig{Method: method, Size: 100, Count: 10, Messages: 3, Requests: 50, Concurrency: 4}
+		r, err := runLoad(ctx, client, cfg)
+		require.NoError(err, method)
+		require.Equal(int64(50), r.Requests, method)
+		require.Zero(r.Errors, method)
+		require.Len(r.Samples, 50, method)
+		require.True(r.Min <= r.P50 && r.P50 <= r.P95 && r.P95 <= r.P99 && r.P99 <= r.Max, method)
+	}
+
+	cfg := loadConfig{Method: "bench", Duration: 50 * time.Millisecond, Concurrency: 2}
+	r, err := runLoad(ctx, client, cfg)
+	require.NoError(err, "duration")
+	require.NotZero(r.Requests)
+	require.Zero(r.Errors)
+
+	_, err = runLoad(ctx, client, loadConfig{Method: "nop"})
+	require.Error(err, "unknown method")
+
+	// Failed calls are counted
+	cfg = loadConfig{Method: "get", Size: -1, Requests: 5}
+	r, err = runLoad(ctx, client, cfg)
+	require.NoError(err, "bad size")
+	require.Equal(int64(5), r.Errors)
+	require.Zero(r.Requests)
+}
+
+func TestReport(t *testing.T) {
+	require := require.New(t)
+	r := &loadReport{
+		Method:   "get",
+		Requests: 2,
+		Duration: time.Second,
+		P50:      time.Millisecond,
+		Samples:  []sample{{0, time.Millisecond}, {time.Millisecond, 2 * time.Millisecond}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(writeReport(&buf, r, "csv"))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(lines, 2)
+	require.True(strings.HasPrefix(lines[1], "get,2,0,1000000000,"), lines[1])
+
+	buf.Reset()
+	require.NoError(writeReport(&buf, r, "json"))
+	var summary map[string]interface{}
+	require.NoError(json.Unmarshal(buf.Bytes(), &summary))
+	require.Equal(float64(time.Millisecond), summary["p50_ns"])
+	require.NotContains(summary, "samples")
+
+	require.Error(writeReport(&buf, r, "xml"))
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "samples.csv")
+	require.NoError(writeSamples(csvFile, r))
+	data, err := os.ReadFile(csvFile)
+	require.NoError(err)
+	require.Equal("start_ns,latency_ns\n0,1000000\n1000000,2000000\n", string(data))
+
+	jsonFile := filepath.Join(dir, "samples.json")
+	require.NoError(writeSamples(jsonFile, r))
+	data, err = os.ReadFile(jsonFile)
+	require.NoError(err)
+	var samples []sample
+	require.NoError(json.Unmarshal(data, &samples))
+	require.Equal(r.Samples, samples)
+
+	require.Error(writeSamples(filepath.Join(dir, "samples.txt"), r))
+}
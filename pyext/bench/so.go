@@ -1,3 +1,7 @@
+//go:build ignore
+
+// Shared library counterpart of the gRPC server, built with "make so"
+
 package main
 
 import "C"
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// racyWindow is the minimal age of a file modification time to cache its
+// signature. A file modified twice within the modification time resolution
+// (2 seconds on FAT) would keep the same size and time.
+const racyWindow = 2 * time.Second
+
+// cacheEntry is the signature of a file with its size and modification time
+// when it was hashed
+type cacheEntry struct {
+	Size   int64  `json:"size"`
+	MTime  int64  `json:"mtime_ns"`
+	Digest string `json:"digest"`
+}
+
+// sigCache is the on-disk signature cache (see Options.Cache), a JSON object
+// of cacheEntry by "algorithm:absolute path"
+type sigCache struct {
+	fileName string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// loadCache loads the opts.Cache signature cache, it returns nil if
+// opts.Cache is "". A missing or invalid cache file is an empty cache.
+func loadCache(opts Options) (*sigCache, error) {
+	if opts.Cache == "" {
+		return nil, nil
+	}
+	fileName, err := filepath.Abs(opts.Cache)
+	if err != nil {
+		return nil, withCode(codeArgument, err)
+	}
+
+	c := sigCache{
+		fileName: fileName,
+		entries:  make(map[string]cacheEntry),
+	}
+	data, err := os.ReadFile(fileName)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			logf(LogWarning, "%s: bad signature cache, ignoring it: %v", fileName, err)
+			c.entries = make(map[string]cacheEntry)
+		}
+	}
+	return &c, nil
+}
+
+// save writes the cache if it changed, c can be nil. Failures are logged,
+// the cache is only an optimization.
+func (c *sigCache) save() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return
+	}
+
+	if err := c.write(); err != nil {
+		logf(LogWarning, "%s: can't write signature cache: %v", c.fileName, err)
+		return
+	}
+	c.dirty = false
+}
+
+// write writes the cache to a temporary file renamed to c.fileName, c.mu
+// must be held
+func (c *sigCache) write() error {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.fileName), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.fileName), ".sigcache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails after rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.fileName)
+}
+
+// cachedSig returns the signature of the file name in st like fileSig, or
+// chunkedSig if sig.chunkSize is set. Local files signatures are taken from
+// opts.cache if their size and modification time didn't change, unless
+// opts.Force is set.
+func cachedSig(ctx context.Context, st storage, name string, sig signature, opts Options) (string, error) {
+	c := opts.cache
+	d, ok := st.(dirStorage)
+	if c == nil || !ok {
+		return hashSig(ctx, st, name, sig, opts)
+	}
+
+	fileName, err := filepath.Abs(d.Path(name))
+	if err != nil {
+		return hashSig(ctx, st, name, sig, opts)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return hashSig(ctx, st, name, sig, opts) // Reports the error
+	}
+
+	key := sig.algo + ":" + fileName
+	if sig.chunkSize > 0 {
+		key = formatChunked(key, sig.chunkSize)
+	}
+	mtime := info.ModTime().UnixNano()
+	if !opts.Force {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		c.mu.Unlock()
+		if ok && e.Size == info.Size() && e.MTime == mtime {
+			logf(LogDebug, "%s: cached signature", fileName)
+			return e.Digest, nil
+		}
+	}
+
+	digest, err := hashSig(ctx, st, name, sig, opts)
+	if err != nil {
+		return "", err
+	}
+	if time.Since(info.ModTime()) >= racyWindow {
+		c.mu.Lock()
+		c.entries[key] = cacheEntry{info.Size(), mtime, digest}
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return digest, nil
+}
+
+// hashSig returns the digest of the file name in st, chunked if sig.chunkSize
+// is set
+func hashSig(ctx context.Context, st storage, name string, sig signature, opts Options) (string, error) {
+	if sig.chunkSize > 0 {
+		return chunkedSig(ctx, st, name, sig, opts)
+	}
+	return fileSig(ctx, st, name, sig.newHash, opts)
+}
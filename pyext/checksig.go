@@ -2,81 +2,440 @@ package main
 
 import (
 	"bufio"
-	"crypto/sha1"
+	"context"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
-	"path"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
 
-// CheckSignatures calculates sha1 signatures for files in rootDir and compare
-// them with signatures found at "sha1sum.txt" in the same directory. It'll
-// return an error if one of the signatures don't match
-func CheckSignatures(rootDir string) error {
-	file, err := os.Open(path.Join(rootDir, "sha1sum.txt"))
+// CheckSignatures calculates signatures for files in rootDir with the algo hash
+// algorithm and compare them with signatures found in the algorithm signature
+// file ("sha1sum.txt" or "SHA1SUMS" for sha1, see algorithms) in the same
+// directory. If algo is "" the algorithm of every signature is detected from
+// its length, see loadSigs. It'll return an error if one of the signatures
+// don't match
+func CheckSignatures(rootDir, algo string) error {
+	return checkSignatures(rootDir, Options{Algorithm: algo})
+}
+
+// checkSignatures is CheckSignatures with options, Progress is ignored
+func checkSignatures(rootDir string, opts Options) error {
+	st, err := newStorage(rootDir)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	return checkStorage(st, rootDir, opts)
+}
 
-	sigs, err := parseSigFile(file)
+// checkStorage checks the signatures of files in st, root is st root for
+// messages
+func checkStorage(st storage, root string, opts Options) error {
+	start := time.Now()
+	sigs, err := loadSigs(context.Background(), st, opts)
 	if err != nil {
 		return err
 	}
+	if opts.cache, err = loadCache(opts); err != nil {
+		return err
+	}
+	defer opts.cache.save()
+	opts.limits = newLimits(opts)
 
 	var g errgroup.Group
-	for name, signature := range sigs {
-		fileName := path.Join(rootDir, name)
-		expected := signature
+	g.SetLimit(opts.concurrency())
+	for name, sig := range sigs {
+		name, expected := name, sig
 		g.Go(func() error {
-			sig, err := fileSig(fileName)
+			sig, err := cachedSig(context.Background(), st, name, expected, opts)
 			if err != nil {
 				return err
 			}
-			if sig != expected {
-				return fmt.Errorf("%q - mismatch", fileName)
+			if sig != expected.digest {
+				return mismatchError(st.Path(name))
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	logf(LogInfo, "%s: %d files checked in %v", root, len(sigs), time.Since(start))
+	return nil
+}
+
+// File signature check status
+const (
+	StatusOK       = "ok"
+	StatusMismatch = "mismatch"
+	StatusError    = "error"
+)
+
+// FileResult is the result of checking the signature of a single file
+type FileResult struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Expected string        `json:"expected"`
+	Actual   string        `json:"actual,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	Bytes    int64         `json:"bytes"`      // Bytes hashed, 0 if the signature was cached
+	MBPerSec float64       `json:"mb_per_sec"` // Bytes hashed per second, in MB (10^6 bytes)
+
+	err error // Mismatch or IO error, nil if OK
+}
+
+// Options are verification options, the zero value is the default
+type Options struct {
+	// Algorithm is the hash algorithm, "" to detect it (see CheckSignatures)
+	Algorithm string `json:"-"`
+
+	// Concurrency is the maximal number of files hashed in parallel, 0 is
+	// runtime.NumCPU()
+	Concurrency int `json:"concurrency"`
+
+	// Include, if set, restricts the files checked to the ones matching one
+	// of the patterns (see path.Match). Patterns without a "/" match the file
+	// base name: "*.csv" matches "a.csv" and "data/a.csv".
+	Include []string `json:"include"`
+
+	// Exclude are patterns of files to skip, like Include
+	Exclude []string `json:"exclude"`
+
+	// BufferSize is the size of the buffer used to read files, 0 is 32KB
+	BufferSize int `json:"buffer_size"`
+
+	// MMap hashes local files from memory mapped with mmap instead of reading
+	// them, when supported
+	MMap bool `json:"mmap"`
+
+	// Sequential tells the OS files are read sequentially and drops them from
+	// the page cache once hashed (Linux only), avoiding to evict other data
+	// when hashing big files
+	Sequential bool `json:"sequential"`
+
+	// Format is the format of generated signature files, FormatGNU (default)
+	// or FormatBSD. Both are read.
+	Format string `json:"format"`
+
+	// ChunkSize, if set, makes WriteSignatures write chunked digests (see
+	// chunkPrefix) of files bigger than ChunkSize, their chunks are hashed in
+	// parallel. Chunked digests in signature files are checked whatever
+	// ChunkSize.
+	ChunkSize int64 `json:"chunk_size"`
+
+	// MaxOpenFiles is the maximal number of files opened at once, 0 is half
+	// of RLIMIT_NOFILE on Unix (no limit on other systems). Concurrency is
+	// lowered to it.
+	MaxOpenFiles int `json:"max_open_files"`
+
+	// MaxMemory is the maximal memory used by read buffers in bytes, 0 for
+	// no limit. Concurrency is lowered to MaxMemory / BufferSize.
+	MaxMemory int64 `json:"max_memory"`
+
+	// Cache is the signature cache file, "" for no cache. Signatures of local
+	// files whose size and modification time didn't change since they were
+	// cached are taken from it instead of hashing the files.
+	Cache string `json:"cache"`
+
+	// Force hashes all files, ignoring signatures in Cache. The cache is
+	// still updated.
+	Force bool `json:"force"`
+
+	// Manifest is the signature file path, relative to rootDir, or https://
+	// URL. "" to look for it in rootDir (see CheckSignatures).
+	Manifest string `json:"manifest"`
+
+	// ManifestDigest, if set, is the expected hex digest of the signature
+	// file, its algorithm is detected from its length
+	ManifestDigest string `json:"manifest_digest"`
+
+	// PublicKey, if set, is the key of the signature file detached signature,
+	// checked before trusting it: a minisign public key ("RW..." with or
+	// without its comment line) for "<manifest>.minisig" or a raw ed25519 key
+	// (32 bytes in hex or base64) for "<manifest>.sig", see
+	// checkManifestSig.
+	PublicKey string `json:"public_key"`
+
+	// ManifestTimeout is the timeout to fetch a remote signature file, 0 is
+	// 30 seconds. It's "manifest_timeout" in seconds in JSON.
+	ManifestTimeout time.Duration `json:"-"`
+
+	// Progress, if set, is called after each file is checked with the number
+	// of files checked so far, the total number of files and the file name.
+	// Calls are serialized.
+	Progress func(done, total int, name string) `json:"-"`
+
+	cache  *sigCache // Loaded Cache
+	limits *limits
+	hashed *byteCounter // Bytes hashed
+}
+
+func (o Options) bufferSize() int {
+	if o.BufferSize > 0 {
+		return o.BufferSize
+	}
+	return 32 << 10
+}
+
+// concurrency returns the number of files hashed in parallel, Concurrency
+// lowered to keep within the open files and memory limits
+func (o Options) concurrency() int {
+	n := o.Concurrency
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	if limit := o.maxOpenFiles(); limit > 0 && n > limit {
+		n = limit
+	}
+	if o.MaxMemory > 0 {
+		limit := int(o.MaxMemory / int64(o.bufferSize()))
+		if limit < 1 {
+			limit = 1
+		}
+		if n > limit {
+			n = limit
+		}
+	}
+	return n
+}
+
+// maxOpenFiles returns the maximal number of files opened at once, 0 for no
+// limit
+func (o Options) maxOpenFiles() int {
+	if o.MaxOpenFiles > 0 {
+		return o.MaxOpenFiles
+	}
+	return rlimitFiles()
+}
+
+// VerifySignatures is like CheckSignatures but checks every file and returns
+// a result per file, sorted by name. The error is set if the signature file
+// can't be read, or to ctx.Err() if ctx is done before all files are checked:
+// files not checked have StatusError.
+func VerifySignatures(ctx context.Context, rootDir string, opts Options) ([]FileResult, error) {
+	st, err := newStorage(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := loadSigs(ctx, st, opts)
+	if err != nil {
+		return nil, err
+	}
+	return verifySigs(ctx, st, rootDir, sigs, opts)
+}
+
+// verifySigs checks the files in st against sigs, see VerifySignatures. root
+// is st root for messages.
+func verifySigs(ctx context.Context, st storage, root string, sigs map[string]signature, opts Options) ([]FileResult, error) {
+	start := time.Now()
+	var err error
+	if opts.cache, err = loadCache(opts); err != nil {
+		return nil, err
+	}
+	defer opts.cache.save()
+	opts.limits = newLimits(opts)
+
+	results := make([]FileResult, 0, len(sigs))
+	var mu sync.Mutex
+	var g errgroup.Group
+	g.SetLimit(opts.concurrency())
+	for name, sig := range sigs {
+		name, expected := name, sig
+		g.Go(func() error {
+			r := checkFile(ctx, st, name, expected, opts)
+			r.Name = name
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, r)
+			if opts.Progress != nil {
+				opts.Progress(len(results), len(sigs), name)
 			}
 			return nil
 		})
 	}
+	g.Wait()
+	stats := newStats(results, time.Since(start))
+	logf(LogInfo, "%s: %d files checked in %v, %d bytes hashed at %.1f MB/s",
+		root, stats.Files, stats.Duration, stats.Bytes, stats.MBPerSec)
 
-	return g.Wait()
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, ctx.Err()
 }
 
-// fileSig returns the fileName sha1 digital signature of the specified file.
-func fileSig(fileName string) (string, error) {
-	file, err := os.Open(fileName)
+// CheckAllSignatures is like CheckSignatures but doesn't stop at the first
+// error, it checks every file and returns all mismatches and IO errors (sorted
+// by file name) joined with errors.Join.
+func CheckAllSignatures(rootDir, algo string) error {
+	opts := Options{Algorithm: algo}
+	results, err := VerifySignatures(context.Background(), rootDir, opts)
+	if err != nil {
+		return err
+	}
+	return errors.Join(fileErrors(results)...)
+}
+
+// fileErrors returns the errors in results
+func fileErrors(results []FileResult) []error {
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	return errs
+}
+
+// checkFile checks the signature of name in st against expected
+func checkFile(ctx context.Context, st storage, name string, expected signature, opts Options) FileResult {
+	r := FileResult{Expected: expected.digest}
+	opts.hashed = new(byteCounter)
+	start := time.Now()
+	sig, err := cachedSig(ctx, st, name, expected, opts)
+	r.Duration = time.Since(start)
+	r.Bytes = opts.hashed.count()
+	r.MBPerSec = mbPerSec(r.Bytes, r.Duration)
+
+	switch {
+	case err != nil:
+		r.Status = StatusError
+		r.Error = err.Error()
+		r.err = err
+	case sig != expected.digest:
+		r.Status = StatusMismatch
+		r.Actual = sig
+		r.err = mismatchError(st.Path(name))
+	default:
+		r.Status = StatusOK
+		r.Actual = sig
+	}
+
+	if r.err != nil {
+		logf(LogWarning, "%v", r.err)
+	} else {
+		logf(LogDebug, "%s: ok in %v", st.Path(name), r.Duration)
+	}
+	return r
+}
+
+// fileSig returns the digital signature of the file name in st using a hash
+// from newHash, reading it as set in opts. It stops reading the file once ctx
+// is done. It's called in worker goroutines and recovers from panics.
+func fileSig(ctx context.Context, st storage, name string, newHash func() hash.Hash, opts Options) (sig string, err error) {
+	defer recoverError(&err)
+
+	if err := opts.limits.acquireFile(ctx); err != nil {
+		return "", err
+	}
+	defer opts.limits.releaseFile()
+	file, err := st.Open(ctx, name)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	hash := sha1.New()
-	if _, err = io.Copy(hash, file); err != nil {
+	h := newHash()
+	if f, ok := file.(*os.File); ok {
+		if opts.Sequential {
+			adviseSequential(f)
+			defer adviseDone(f)
+		}
+		if opts.MMap {
+			if ok, err := hashMmap(ctx, h, f); ok {
+				if err != nil {
+					return "", err
+				}
+				if info, err := f.Stat(); err == nil {
+					opts.hashed.add(info.Size())
+				}
+				return fmt.Sprintf("%x", h.Sum(nil)), nil
+			}
+			logf(LogDebug, "%s: can't mmap, reading it", st.Path(name))
+		}
+	}
+
+	return hashReader(ctx, h, file, opts)
+}
+
+// hashReader returns the hex digest of r content using h, reading it with a
+// buffer of opts.BufferSize
+func hashReader(ctx context.Context, h hash.Hash, r io.Reader, opts Options) (string, error) {
+	buf, err := opts.limits.buffer(ctx, opts.bufferSize())
+	if err != nil {
+		return "", err
+	}
+	defer opts.limits.releaseBuffer(buf)
+
+	n, err := io.CopyBuffer(h, ctxReader{ctx, r}, buf)
+	opts.hashed.add(n)
+	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ctxReader is an io.Reader failing with ctx.Err() once ctx is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// sigEntry is a signature file entry
+type sigEntry struct {
+	digest string
+	algo   string // Set by BSD format lines, "" otherwise
 }
 
 // parseSigFile parses the signature file and returns a map of path->signature.
-func parseSigFile(r io.Reader) (map[string]string, error) {
-	sigs := make(map[string]string)
+// Lines are in GNU or BSD format (see parseGNULine and parseBSDLine), they can
+// be mixed. Lines starting with a backslash have escaped names (see
+// escapeName), as written by GNU coreutils for names with a backslash or a
+// newline.
+func parseSigFile(r io.Reader) (map[string]sigEntry, error) {
+	sigs := make(map[string]sigEntry)
 	scanner := bufio.NewScanner(r)
 	lnum := 0
 
 	for scanner.Scan() {
 		lnum++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		line, escaped := strings.CutPrefix(text, `\`)
 
-		// Line example: 6c6427da7893932731901035edbb9214 nasa-00.log
-		fields := strings.Fields(scanner.Text())
-		if len(fields) != 2 {
-			return nil, fmt.Errorf("%d: bad line: %q", lnum, scanner.Text())
+		algo, name, digest, ok := parseBSDLine(line)
+		if ok && algo == "" {
+			err := fmt.Errorf("%d: unknown hash algorithm: %q", lnum, text)
+			return nil, withCode(codeManifest, err)
+		}
+		if !ok {
+			name, digest, ok = parseGNULine(line)
+		}
+		if ok && escaped {
+			name, ok = unescapeName(name)
+		}
+		if !ok {
+			err := fmt.Errorf("%d: bad line: %q", lnum, text)
+			return nil, withCode(codeManifest, err)
 		}
-		sigs[fields[1]] = fields[0]
+		sigs[name] = sigEntry{digest, algo}
 	}
 
 	if err := scanner.Err(); err != nil {
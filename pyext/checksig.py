@@ -1,29 +1,481 @@
 """Parallel check of files digital signature"""
 
+import asyncio
 import ctypes
+import json
+import os
+import sys
 from distutils.sysconfig import get_config_var
 from pathlib import Path
 
-# Location of shared library
+# Location of shared library, setup.py builds _checksig$(EXT_SUFFIX) and
+# "make so" _checksig.dll, _checksig.dylib or _checksig.so
 here = Path(__file__).absolute().parent
 ext_suffix = get_config_var('EXT_SUFFIX')
 so_file = here / ('_checksig' + ext_suffix)
+if not so_file.exists():
+    if sys.platform == 'win32':
+        so_file = here / '_checksig.dll'
+    elif sys.platform == 'darwin':
+        so_file = here / '_checksig.dylib'
+    else:
+        so_file = here / '_checksig.so'
+
+# Version of the shared library exported functions we use
+ABI_VERSION = 1
+
+# Error codes, see CheckError
+OK = 0
+MISMATCH = 1  # File signature doesn't match
+IO_ERROR = 2  # Can't read a file or the signature file
+BAD_MANIFEST = 3  # Invalid signature file
+BAD_ARGUMENT = 4  # Unknown algorithm, bad options ...
+CANCELED = 5  # Verification canceled
+INTERNAL_ERROR = 6  # Bug in the shared library
+
+# Load functions from shared library set their signatures. Go exports use the
+# C calling convention (cdecl) on all platforms, hence CDLL and not WinDLL.
+so = ctypes.CDLL(str(so_file))
+so.checksig_abi_version.argtypes = []
+so.checksig_abi_version.restype = ctypes.c_int
+if so.checksig_abi_version() != ABI_VERSION:
+    raise ImportError(
+        f'{so_file}: ABI version {so.checksig_abi_version()}, '
+        f'expected {ABI_VERSION}')
 
-# Load functions from shared library set their signatures
-so = ctypes.cdll.LoadLibrary(so_file)
 verify = so.verify
-verify.argtypes = [ctypes.c_char_p]
+verify.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
 verify.restype = ctypes.c_void_p
-free = so.free
+verify_code = so.verify_code
+verify_code.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p,
+    ctypes.POINTER(ctypes.c_void_p)]
+verify_code.restype = ctypes.c_int
+verify_json = so.verify_json
+verify_json.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
+verify_json.restype = ctypes.c_void_p
+verify_entries = so.verify_entries
+verify_entries.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
+verify_entries.restype = ctypes.c_void_p
+verify_zipfs = so.verify_zipfs
+verify_zipfs.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
+verify_zipfs.restype = ctypes.c_void_p
+verify_file = so.verify_file
+verify_file.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p,
+    ctypes.POINTER(ctypes.c_void_p), ctypes.POINTER(ctypes.c_void_p)]
+verify_file.restype = ctypes.c_int
+ProgressFunc = ctypes.CFUNCTYPE(
+    None, ctypes.c_int, ctypes.c_int, ctypes.c_char_p)
+verify_with_progress = so.verify_with_progress
+verify_with_progress.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p, ProgressFunc,
+    ctypes.POINTER(ctypes.c_void_p)]
+verify_with_progress.restype = ctypes.c_int
+verify_start = so.verify_start
+verify_start.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
+verify_start.restype = ctypes.c_longlong
+verify_cancel = so.verify_cancel
+verify_cancel.argtypes = [ctypes.c_longlong]
+verify_cancel.restype = ctypes.c_int
+verify_fd = so.verify_fd
+verify_fd.argtypes = [ctypes.c_longlong]
+verify_fd.restype = ctypes.c_int
+verify_wait = so.verify_wait
+verify_wait.argtypes = [ctypes.c_longlong]
+verify_wait.restype = ctypes.c_void_p
+verify_wait_code = so.verify_wait_code
+verify_wait_code.argtypes = [
+    ctypes.c_longlong, ctypes.POINTER(ctypes.c_void_p)]
+verify_wait_code.restype = ctypes.c_int
+verify_all = so.verify_all
+verify_all.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
+verify_all.restype = ctypes.POINTER(ctypes.c_void_p)
+verify_remote = so.verify_remote
+verify_remote.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p,
+    ctypes.POINTER(ctypes.c_void_p)]
+verify_remote.restype = ctypes.c_int
+verify_archive = so.verify_archive
+verify_archive.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p,
+    ctypes.POINTER(ctypes.c_void_p)]
+verify_archive.restype = ctypes.c_int
+generate = so.generate
+generate.argtypes = [
+    ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p,
+    ctypes.POINTER(ctypes.c_void_p)]
+generate.restype = ctypes.c_int
+if sys.platform == 'win32':
+    # Windows paths are UTF-16, pass them as is: functions taking a path are
+    # replaced by their *_w variant taking it as a wide string (see _path).
+    # verify_with_progress has none, it takes a UTF-8 root.
+    def _wide(fn):
+        fn_w = getattr(so, fn.__name__ + '_w')
+        fn_w.argtypes = [ctypes.c_wchar_p, *fn.argtypes[1:]]
+        fn_w.restype = fn.restype
+        return fn_w
+
+    verify = _wide(verify)
+    verify_code = _wide(verify_code)
+    verify_json = _wide(verify_json)
+    verify_zipfs = _wide(verify_zipfs)
+    verify_file = _wide(verify_file)
+    verify_start = _wide(verify_start)
+    verify_all = _wide(verify_all)
+    verify_archive = _wide(verify_archive)
+    generate = _wide(generate)
+LogFunc = ctypes.CFUNCTYPE(None, ctypes.c_int, ctypes.c_char_p)
+set_log_callback_c = so.set_log_callback
+set_log_callback_c.argtypes = [LogFunc]
+set_log_callback_c.restype = None
+free = so.verify_free
 free.argtypes = [ctypes.c_void_p]
+free.restype = None
+free_strings = so.verify_free_strings
+free_strings.argtypes = [ctypes.POINTER(ctypes.c_void_p)]
+free_strings.restype = None
+
+
+# Log callbacks passed to the shared library, they're kept alive since Go might
+# still be calling a replaced callback
+_log_funcs = []
+
+
+def set_log_callback(fn):
+    """Call fn(level, msg) with the shared library log messages (skipped files,
+    signature file used, timing ...), level is a logging level (DEBUG, INFO
+    ...). None discards them. fn is called from several threads.
+
+    >>> set_log_callback(logging.getLogger('checksig').log)
+    """
+    if fn is None:
+        set_log_callback_c(LogFunc())
+        return
+
+    def callback(level, msg):
+        fn(level, msg.decode('utf-8', 'replace'))
+
+    func = LogFunc(callback)
+    _log_funcs.append(func)
+    set_log_callback_c(func)
+
+
+class CheckError(ValueError):
+    """Signatures check failed, code is the error code (MISMATCH ...)"""
+    def __init__(self, msg, code):
+        super().__init__(msg)
+        self.code = code
+
+
+def _check(code, msg):
+    """Raise CheckError if code isn't OK, frees msg"""
+    if code == OK:
+        return
+    text = ctypes.string_at(msg).decode('utf-8')
+    free(msg)
+    raise CheckError(text, code)
+
+
+def _path(path):
+    """Path (str, bytes or path-like) for the shared library. Paths are bytes
+    on POSIX, file names that aren't valid UTF-8 round trip. On Windows they
+    are str, passed as UTF-16 to the *_w functions.
+    """
+    if sys.platform == 'win32':
+        return os.fsdecode(path)
+    return os.fsencode(path)
+
+
+def _algo(algo):
+    """Algorithm name for the shared library, NULL to detect it"""
+    return None if algo is None else algo.encode('utf-8')
+
+
+def _options(options):
+    """JSON options for the shared library, NULL for defaults"""
+    if not options:
+        return None
+    return json.dumps(options).encode('utf-8')
+
+
+def check_signatures(root_dir, progress=None, algo=None, **options):
+    """Check (in parallel) digital signature of all files in root_dir, a str,
+    bytes or path-like object.
+    We assume there's a signature file for algo under root_dir: sha1sum.txt
+    or SHA1SUMS for sha1, sha256sum.txt or SHA256SUMS for sha256 ... Its
+    lines are in GNU ("<digest>  <name>") or BSD ("SHA1 (<name>) = <digest>")
+    format.
+
+    algo is one of md5, sha1, sha256, sha512 or blake2b. If it's None, the
+    first signature file found is used and the algorithm of every digest is
+    detected from its length.
+
+    options are:
+    - concurrency: maximal number of files hashed in parallel (default number
+      of CPUs)
+    - include: list of glob patterns, check only files matching one of them.
+      Patterns without a "/" match the file name: "*.csv" matches "a.csv" and
+      "data/a.csv".
+    - exclude: list of glob patterns of files to skip
+    - buffer_size: size of the buffer used to read files (default 32KB)
+    - max_open_files: maximal number of files opened at once (default half of
+      RLIMIT_NOFILE on Unix), concurrency is lowered to it
+    - max_memory: maximal memory used by read buffers in bytes, concurrency
+      is lowered to max_memory / buffer_size
+    - mmap: hash local files from memory mapped with mmap, when supported
+    - sequential: hint the OS files are read sequentially and drop them from
+      the page cache once hashed (Linux only)
+    - cache: signature cache file path, signatures of files whose size and
+      modification time didn't change are taken from it instead of hashing
+      the files
+    - force: hash all files, ignoring the cache (it's still updated)
+    - manifest: signature file path (relative to root_dir) or https:// URL
+    - manifest_digest: expected hex digest of the signature file
+    - manifest_timeout: timeout in seconds to fetch a remote signature file
+      (default 30)
+    - public_key: check the detached signature of the signature file before
+      trusting it. A minisign public key ("RW..." or the .pub file content)
+      checks the "<signature file>.minisig" minisign signature, a raw ed25519
+      key (hex or base64) checks the "<signature file>.sig" signature (64
+      bytes or base64). Raises CheckError with BAD_MANIFEST if it's missing
+      or invalid.
+
+    If progress is set, it's called with (files done, files total, path) after
+    each file is checked. All files are checked in this case.
+
+    Raises CheckError on error.
+    """
+    msg = ctypes.c_void_p()
+    if progress is None:
+        code = verify_code(
+            _path(root_dir), _algo(algo), _options(options),
+            ctypes.byref(msg))
+    else:
+        def callback(done, total, path):
+            progress(done, total, os.fsdecode(path))
+
+        # No UTF-16 variant, os.fsencode returns UTF-8 on Windows
+        code = verify_with_progress(
+            os.fsencode(root_dir), _algo(algo), _options(options),
+            ProgressFunc(callback), ctypes.byref(msg))
+    _check(code, msg.value)
+
+
+class Verification:
+    """Signatures check running in the background.
+
+    >>> v = Verification('/tmp/logs')
+    >>> v.cancel()  # optional
+    >>> v.wait()  # raises CheckError on error
+
+    In asyncio code, use "await v.wait_async()" instead of wait.
+    """
+    def __init__(self, root_dir, algo=None, **options):
+        """See check_signatures for algo & options"""
+        self._handle = verify_start(
+            _path(root_dir), _algo(algo), _options(options))
+
+    def cancel(self):
+        """Stop the check, wait will raise CheckError"""
+        if self._handle is not None:
+            verify_cancel(self._handle)
+
+    def fileno(self):
+        """File descriptor readable when the check is done, don't read from or
+        close it. Not supported on Windows.
+        """
+        if self._handle is None:
+            raise ValueError('already waited')
+        fd = verify_fd(self._handle)
+        if fd < 0:
+            raise OSError('completion file descriptor not supported')
+        return fd
+
+    async def wait_async(self):
+        """Like wait, without blocking the event loop"""
+        loop = asyncio.get_running_loop()
+        fd = self.fileno()
+        done = loop.create_future()
+
+        def on_done():
+            if not done.done():
+                done.set_result(None)
+
+        loop.add_reader(fd, on_done)
+        try:
+            await done
+        finally:
+            loop.remove_reader(fd)
+        self.wait()
+
+    def wait(self):
+        """Wait for the check to finish, raise CheckError on error"""
+        if self._handle is None:
+            raise ValueError('already waited')
+        msg = ctypes.c_void_p()
+        code = verify_wait_code(self._handle, ctypes.byref(msg))
+        self._handle = None
+        _check(code, msg.value)
+
+
+class SignaturesError(ValueError):
+    """Signatures check failed, errors is the list of error messages"""
+    def __init__(self, errors):
+        super().__init__('\n'.join(errors))
+        self.errors = errors
+
+
+def check_all_signatures(root_dir, algo=None, **options):
+    """Check digital signature of all files in root_dir, unlike
+    check_signatures it doesn't stop at the first error. Raises
+    SignaturesError with all mismatches and IO errors.
+    """
+    arr = verify_all(
+        _path(root_dir), _algo(algo), _options(options))
+    if not arr:
+        return
+
+    errors = []
+    for ptr in arr:
+        if ptr is None:
+            break
+        errors.append(ctypes.string_at(ptr).decode('utf-8'))
+    free_strings(arr)
+    raise SignaturesError(errors)
+
+
+def check_remote_signatures(uri, algo=None, **options):
+    """Check digital signature of objects under a bucket prefix, uri is
+    s3://bucket/prefix or gs://bucket/prefix. The signature file is an object
+    under the prefix too. See check_signatures for algo and options.
+
+    S3 credentials and region come from the AWS_ACCESS_KEY_ID,
+    AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION environment
+    variables, GCS access token from GOOGLE_OAUTH_ACCESS_TOKEN. Requests are
+    anonymous without credentials.
+
+    Raises CheckError on error.
+    """
+    msg = ctypes.c_void_p()
+    code = verify_remote(
+        uri.encode('utf-8'), _algo(algo), _options(options),
+        ctypes.byref(msg))
+    _check(code, msg.value)
+
+
+def check_archive_signatures(archive_path, algo=None, **options):
+    """Check digital signature of files in a zip (.zip) or tar (.tar, .tar.gz
+    or .tgz) archive without extracting it. The signature file is at the
+    archive root. See check_signatures for algo and options, mmap and
+    sequential don't apply, nor concurrency to tar archives which are read in
+    order.
+
+    Raises CheckError on error.
+    """
+    msg = ctypes.c_void_p()
+    code = verify_archive(
+        _path(archive_path), _algo(algo), _options(options),
+        ctypes.byref(msg))
+    _check(code, msg.value)
+
+
+def write_signatures(root_dir, algo='sha1', **options):
+    """Write the signature file of algo (sha1sum.txt for sha1 ...) in root_dir
+    with the digital signatures of all files in root_dir and its sub
+    directories. See check_signatures for algo and options, the format option
+    is "gnu" (default) for "<digest>  <name>" lines or "bsd" for
+    "SHA1 (<name>) = <digest>" lines, as sha1sum --tag. If the chunk_size
+    option is set, files bigger than chunk_size bytes have a chunked digest:
+    their chunks are hashed in parallel, which is faster for huge files. Their
+    digest is then "chunked-<chunk size>:<digest>", other tools can't check
+    it. Raises CheckError on error.
+    """
+    msg = ctypes.c_void_p()
+    code = generate(
+        _path(root_dir), _algo(algo), _options(options),
+        ctypes.byref(msg))
+    _check(code, msg.value)
+
+
+def signatures_report(root_dir, algo=None, **options):
+    """Check digital signature of all files in root_dir, return a dict with
+    a "files" list holding name, status ("ok", "mismatch" or "error"),
+    expected & actual digest, duration (in nanoseconds), bytes hashed (0 if
+    the signature was cached) and throughput (mb_per_sec) for every file. The
+    "stats" dict holds the number of files, total bytes hashed, duration_ns
+    and mb_per_sec of the whole check.
+    Raises ValueError if the signature file can't be read.
+    """
+    res = verify_json(
+        _path(root_dir), _algo(algo), _options(options))
+    data = ctypes.string_at(res).decode('utf-8')
+    free(res)
+    report = json.loads(data)
+    if report.get('error'):
+        raise ValueError(report['error'])
+    return report
+
+
+def check_file(path, digest, algo=None, **options):
+    """Check digital signature of the file path against digest, as found in a
+    signature file. Returns (ok, actual) where ok is False on mismatch and
+    actual is the file digest, to record it. See check_signatures for algo
+    and options.
+
+    Raises CheckError if the file can't be read or on bad arguments.
+    """
+    actual = ctypes.c_void_p()
+    msg = ctypes.c_void_p()
+    code = verify_file(
+        _path(path), digest.encode('utf-8'), _algo(algo), _options(options),
+        ctypes.byref(actual), ctypes.byref(msg))
+    digest = None
+    if actual.value:
+        digest = ctypes.string_at(actual.value).decode('utf-8')
+        free(actual.value)
+    if code == MISMATCH:
+        free(msg.value)
+        return False, digest
+    _check(code, msg.value)
+    return True, digest
+
+
+def zip_report(archive_path, algo=None, **options):
+    """Check digital signature of all files in a zip archive without
+    extracting it, return a report like signatures_report. The signature file
+    is at the archive root. See check_signatures for algo and options.
+    Raises ValueError if the archive or its signature file can't be read.
+    """
+    res = verify_zipfs(
+        _path(archive_path), _algo(algo), _options(options))
+    data = ctypes.string_at(res).decode('utf-8')
+    free(res)
+    report = json.loads(data)
+    if report.get('error'):
+        raise ValueError(report['error'])
+    return report
 
 
-def check_signatures(root_dir):
-    """Check (in parallel) digital signature of all files in root_dir.
-    We assume there's a sha1sum.txt file under root_dir
+def entries_report(entries, algo=None, **options):
+    """Check digital signature of files in entries, without signature file.
+    entries is a dict of path -> digest or an iterable of (path, digest)
+    pairs, paths are absolute or relative to the current directory. Returns a
+    report like signatures_report. See check_signatures for algo and options.
+    Raises ValueError on bad entries.
     """
-    res = verify(root_dir.encode('utf-8'))
-    if res is not None:
-        msg = ctypes.string_at(res).decode('utf-8')
-        free(res)
-        raise ValueError(msg)
+    if isinstance(entries, dict):
+        entries = entries.items()
+    data = json.dumps([
+        {'path': os.fsdecode(path), 'digest': digest}
+        for path, digest in entries
+    ])
+    res = verify_entries(
+        data.encode('utf-8'), _algo(algo), _options(options))
+    data = ctypes.string_at(res).decode('utf-8')
+    free(res)
+    report = json.loads(data)
+    if report.get('error'):
+        raise ValueError(report['error'])
+    return report
@@ -0,0 +1,107 @@
+//go:build py_native
+
+// checksig CPython extension module, calls the exported Go functions
+
+#define PY_SSIZE_T_CLEAN
+#include <Python.h>
+
+#include "_cgo_export.h"
+
+// options_json returns kw (without "algo") as a JSON string (caller should
+// decref), None if there are no options.
+static PyObject *options_json(PyObject *kw) {
+  if (kw == NULL || PyDict_Size(kw) == 0) {
+    Py_RETURN_NONE;
+  }
+
+  PyObject *json = PyImport_ImportModule("json");
+  if (json == NULL) {
+    return NULL;
+  }
+  PyObject *out = PyObject_CallMethod(json, "dumps", "O", kw);
+  Py_DECREF(json);
+  return out;
+}
+
+// verify(root, algo=None, **options), raises ValueError on mismatch
+static PyObject *checksig_verify(PyObject *self, PyObject *args,
+                                 PyObject *kw) {
+  // root is str, bytes or path-like, converted to bytes in the file system
+  // encoding (UTF-8 on Windows)
+  PyObject *root = NULL;
+  const char *algo = NULL;
+  if (!PyArg_ParseTuple(args, "O&|z", PyUnicode_FSConverter, &root, &algo)) {
+    return NULL;
+  }
+
+  PyObject *opts = NULL;
+  if (kw != NULL) {
+    opts = PyDict_Copy(kw);
+    if (opts == NULL) {
+      goto error;
+    }
+    PyObject *kwalgo = PyDict_GetItemString(opts, "algo"); // borrowed
+    if (kwalgo != NULL) {
+      if (algo != NULL) {
+        PyErr_SetString(PyExc_TypeError, "algo given twice");
+        goto error;
+      }
+      if (kwalgo != Py_None) {
+        algo = PyUnicode_AsUTF8(kwalgo); // kw keeps the string alive
+        if (algo == NULL) {
+          goto error;
+        }
+      }
+      if (PyDict_DelItemString(opts, "algo") < 0) {
+        goto error;
+      }
+    }
+  }
+
+  PyObject *json = options_json(opts);
+  if (json == NULL) {
+    goto error;
+  }
+  const char *options = json == Py_None ? NULL : PyUnicode_AsUTF8(json);
+
+  char *err;
+  Py_BEGIN_ALLOW_THREADS;
+  err = verify(PyBytes_AS_STRING(root), (char *)algo, (char *)options);
+  Py_END_ALLOW_THREADS;
+  Py_DECREF(json);
+  Py_XDECREF(opts);
+  Py_DECREF(root);
+
+  if (err != NULL) {
+    PyErr_SetString(PyExc_ValueError, err);
+    verify_free(err);
+    return NULL;
+  }
+  Py_RETURN_NONE;
+
+error:
+  Py_XDECREF(opts);
+  Py_DECREF(root);
+  return NULL;
+}
+
+static PyMethodDef checksig_methods[] = {
+    {"verify", (PyCFunction)(void (*)(void))checksig_verify,
+     METH_VARARGS | METH_KEYWORDS,
+     "verify(root, algo=None, **options)\n\n"
+     "Check digital signature of all files in root, raise ValueError on "
+     "mismatch. See checksig.check_signatures for algo and options."},
+    {NULL, NULL, 0, NULL},
+};
+
+static struct PyModuleDef checksig_module = {
+    PyModuleDef_HEAD_INIT,
+    "checksig",
+    "Parallel check of files digital signature",
+    -1,
+    checksig_methods,
+};
+
+PyMODINIT_FUNC PyInit_checksig(void) {
+  return PyModule_Create(&checksig_module);
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Chunked digests are written as "chunked-<chunk size>:<hex digest>" in
+// signature files. The digest is the hash of the concatenated (binary)
+// digests of the file chunks, so chunks can be hashed in parallel.
+const chunkPrefix = "chunked-"
+
+// parseChunked splits a signature file digest into its hex digest and chunk
+// size, 0 if it's not a chunked digest
+func parseChunked(digest string) (string, int64, error) {
+	if !strings.HasPrefix(digest, chunkPrefix) {
+		return digest, 0, nil
+	}
+
+	size, hex, ok := strings.Cut(digest[len(chunkPrefix):], ":")
+	chunkSize, err := strconv.ParseInt(size, 10, 64)
+	if !ok || err != nil || chunkSize <= 0 {
+		return "", 0, withCode(codeManifest, fmt.Errorf("bad chunked digest: %q", digest))
+	}
+	return hex, chunkSize, nil
+}
+
+// formatChunked returns the signature file digest of a chunked digest
+func formatChunked(digest string, chunkSize int64) string {
+	return fmt.Sprintf("%s%d:%s", chunkPrefix, chunkSize, digest)
+}
+
+// chunkedSig returns the chunked digest (see chunkPrefix) of the file name in
+// st, with sig.chunkSize chunks. Chunks of local files are hashed in parallel
+// (up to opts.Concurrency), other files are hashed sequentially.
+func chunkedSig(ctx context.Context, st storage, name string, sig signature, opts Options) (digest string, err error) {
+	defer recoverError(&err)
+
+	if err := opts.limits.acquireFile(ctx); err != nil {
+		return "", err
+	}
+	defer opts.limits.releaseFile()
+	file, err := st.Open(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var sums [][]byte
+	if f, ok := file.(*os.File); ok {
+		sums, err = hashChunksAt(ctx, f, sig, opts)
+	} else {
+		sums, err = hashChunks(ctx, file, sig, opts)
+	}
+	if err != nil {
+		return "", err
+	}
+	return joinChunks(sig, sums), nil
+}
+
+// readerSig returns the digest of r content, chunked if sig.chunkSize is set
+func readerSig(ctx context.Context, r io.Reader, sig signature, opts Options) (string, error) {
+	if sig.chunkSize == 0 {
+		return hashReader(ctx, sig.newHash(), r, opts)
+	}
+	sums, err := hashChunks(ctx, r, sig, opts)
+	if err != nil {
+		return "", err
+	}
+	return joinChunks(sig, sums), nil
+}
+
+// joinChunks returns the hex digest of the chunk digests sums
+func joinChunks(sig signature, sums [][]byte) string {
+	h := sig.newHash()
+	for _, sum := range sums {
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hashChunks returns the digests of r chunks, an empty file has one empty
+// chunk
+func hashChunks(ctx context.Context, r io.Reader, sig signature, opts Options) ([][]byte, error) {
+	buf, err := opts.limits.buffer(ctx, opts.bufferSize())
+	if err != nil {
+		return nil, err
+	}
+	defer opts.limits.releaseBuffer(buf)
+
+	r = ctxReader{ctx, r}
+	var sums [][]byte
+	for {
+		h := sig.newHash()
+		n, err := io.CopyBuffer(h, io.LimitReader(r, sig.chunkSize), buf)
+		opts.hashed.add(n)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 && len(sums) > 0 {
+			return sums, nil
+		}
+		sums = append(sums, h.Sum(nil))
+		if n < sig.chunkSize {
+			return sums, nil
+		}
+	}
+}
+
+// hashChunksAt is hashChunks hashing file chunks in parallel
+func hashChunksAt(ctx context.Context, file *os.File, sig signature, opts Options) ([][]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	count := (info.Size() + sig.chunkSize - 1) / sig.chunkSize
+	if count == 0 {
+		count = 1
+	}
+
+	sums := make([][]byte, count)
+	var g errgroup.Group
+	g.SetLimit(opts.concurrency())
+	for i := range sums {
+		i := i
+		g.Go(func() (err error) {
+			defer recoverError(&err)
+			h := sig.newHash()
+			r := io.NewSectionReader(file, int64(i)*sig.chunkSize, sig.chunkSize)
+			buf, err := opts.limits.buffer(ctx, opts.bufferSize())
+			if err != nil {
+				return err
+			}
+			defer opts.limits.releaseBuffer(buf)
+
+			n, err := io.CopyBuffer(h, ctxReader{ctx, r}, buf)
+			opts.hashed.add(n)
+			if err != nil {
+				return err
+			}
+			sums[i] = h.Sum(nil)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Entry is a file and its expected digest
+type Entry struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest"`
+}
+
+// VerifyEntries is like VerifySignatures but checks files against entries
+// instead of a signature file. Paths are absolute or relative to the current
+// directory, digests are in signature file format (hex or chunked digests).
+// The algorithm is detected from the digest length if opts.Algorithm is "".
+func VerifyEntries(ctx context.Context, entries []Entry, opts Options) ([]FileResult, error) {
+	sigs, err := entrySigs(entries, opts)
+	if err != nil {
+		return nil, err
+	}
+	return verifySigs(ctx, dirStorage(""), "entries", sigs, opts)
+}
+
+// VerifyFile checks the signature of the file fileName against digest, see
+// VerifyEntries. It returns the file digest, set on mismatch, and a mismatch
+// error or an error if the file can't be read.
+func VerifyFile(ctx context.Context, fileName, digest string, opts Options) (string, error) {
+	if fileName == "" {
+		return "", withCode(codeArgument, errors.New("empty path"))
+	}
+	algo := opts.Algorithm
+	if _, ok := algorithms[algo]; algo != "" && !ok {
+		return "", withCode(codeArgument, fmt.Errorf("unknown hash algorithm: %q", algo))
+	}
+	sig, err := entrySig(Entry{fileName, digest}, algo)
+	if err != nil {
+		return "", withCode(codeArgument, fmt.Errorf("%s: %w", fileName, err))
+	}
+
+	results, err := verifySigs(ctx, dirStorage(""), fileName, map[string]signature{fileName: sig}, opts)
+	if err != nil {
+		return "", err
+	}
+	r := results[0]
+	if sig.chunkSize > 0 && r.Actual != "" {
+		r.Actual = formatChunked(r.Actual, sig.chunkSize)
+	}
+	return r.Actual, r.err
+}
+
+// entrySigs returns signatures of entries selected by opts
+func entrySigs(entries []Entry, opts Options) (map[string]signature, error) {
+	if err := opts.checkPatterns(); err != nil {
+		return nil, err
+	}
+	algo := opts.Algorithm
+	if _, ok := algorithms[algo]; algo != "" && !ok {
+		return nil, withCode(codeArgument, fmt.Errorf("unknown hash algorithm: %q", algo))
+	}
+
+	sigs := make(map[string]signature, len(entries))
+	for i, e := range entries {
+		if e.Path == "" {
+			return nil, withCode(codeArgument, fmt.Errorf("entry %d: empty path", i))
+		}
+		if !opts.selected(e.Path) {
+			logf(LogDebug, "%s: skipped", e.Path)
+			continue
+		}
+		sig, err := entrySig(e, algo)
+		if err != nil {
+			return nil, withCode(codeArgument, fmt.Errorf("entry %d: %s: %w", i, e.Path, err))
+		}
+		if prev, ok := sigs[e.Path]; ok && prev.digest != sig.digest {
+			err := errors.New("conflicting digests")
+			return nil, withCode(codeArgument, fmt.Errorf("entry %d: %s: %w", i, e.Path, err))
+		}
+		sigs[e.Path] = sig
+	}
+	return sigs, nil
+}
+
+// entrySig returns the signature of e, algo is "" to detect it. Digests are
+// case insensitive.
+func entrySig(e Entry, algo string) (signature, error) {
+	digest, chunkSize, err := parseChunked(strings.ToLower(e.Digest))
+	if err != nil {
+		return signature{}, err
+	}
+	if algo == "" {
+		if algo, err = detectAlgorithm(digest, ""); err != nil {
+			return signature{}, err
+		}
+	}
+	return signature{digest, algo, algorithms[algo].newHash, chunkSize}, nil
+}
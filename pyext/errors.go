@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// abiVersion is the version of the exported functions, change it on
+// incompatible changes to their signatures or semantics
+const abiVersion = 1
+
+// Error codes returned by exported functions, they won't change between
+// versions
+const (
+	codeOK       = 0
+	codeMismatch = 1 // File signature doesn't match
+	codeIO       = 2 // Can't read a file or the signature file
+	codeManifest = 3 // Invalid signature file
+	codeArgument = 4 // Bad argument: unknown algorithm, bad options ...
+	codeCanceled = 5 // Verification canceled
+	codeInternal = 6 // Bug in the library (recovered panic)
+)
+
+// codeError is an error with an error code
+type codeError struct {
+	code int
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+func (e *codeError) Unwrap() error { return e.err }
+
+// withCode returns err with code
+func withCode(code int, err error) error {
+	return &codeError{code, err}
+}
+
+// errorCode returns the error code of err, the one of the first error if err
+// was joined with errors.Join. Errors without code are IO errors.
+func errorCode(err error) int {
+	if err == nil {
+		return codeOK
+	}
+	if errs, ok := err.(interface{ Unwrap() []error }); ok && len(errs.Unwrap()) > 0 {
+		return errorCode(errs.Unwrap()[0])
+	}
+
+	var ce *codeError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return codeCanceled
+	}
+	return codeIO
+}
+
+// recoverError sets *err to an internal error if the function deferring it
+// panics, the panic would abort the process hosting the library. Goroutines
+// started by the library must recover too, see safeCall.
+func recoverError(err *error) {
+	if v := recover(); v != nil {
+		logf(LogError, "panic: %v\n%s", v, debug.Stack())
+		*err = withCode(codeInternal, fmt.Errorf("internal error: %v", v))
+	}
+}
+
+// safeCall calls fn and returns its error, or an internal error if it panics
+func safeCall(fn func() error) (err error) {
+	defer recoverError(&err)
+	return fn()
+}
+
+// mismatchError returns the error for fileName signature mismatch
+func mismatchError(fileName string) error {
+	return withCode(codeMismatch, fmt.Errorf("%q - mismatch", fileName))
+}
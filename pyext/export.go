@@ -1,15 +1,459 @@
 package main
 
+/*
+#include <stdlib.h>
+
+typedef void (*progress_cb)(int done, int total, const char *path);
+
+static inline void call_progress(progress_cb cb, int done, int total, const char *path) {
+	cb(done, total, path);
+}
+
+typedef void (*log_cb)(int level, const char *msg);
+
+static inline void call_log(log_cb cb, int level, const char *msg) {
+	cb(level, msg);
+}
+*/
 import "C"
 
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Exported functions recover from panics (see safeCall) and return them as
+// internal errors, a Go panic would abort the calling process.
+//
+// Exported functions accept the hash algorithm (NULL or "" to detect it) and
+// options as a JSON object (NULL for defaults) with the Options fields:
+//
+//	{"concurrency": 4, "include": ["*.log"], "exclude": ["tmp/*"]}
+
+// goOptions returns Options from the algo and opts exported functions
+// parameters
+func goOptions(algo, opts *C.char) (Options, error) {
+	var o struct {
+		Options
+		ManifestTimeout float64 `json:"manifest_timeout"` // Seconds
+	}
+	if opts != nil {
+		dec := json.NewDecoder(strings.NewReader(C.GoString(opts)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			return Options{}, withCode(codeArgument, fmt.Errorf("bad options: %w", err))
+		}
+	}
+	o.Algorithm = C.GoString(algo)
+	o.Options.ManifestTimeout = time.Duration(o.ManifestTimeout * float64(time.Second))
+	return o.Options, nil
+}
+
+// checksig_abi_version returns the version of the exported functions, it
+// changes on incompatible changes
+//
+//export checksig_abi_version
+func checksig_abi_version() C.int {
+	return abiVersion
+}
+
+// set_log_callback sets the function called with log messages and their level
+// (10 debug, 20 info, 30 warning, 40 error like Python logging), NULL to
+// discard them. cb is called from several threads, msg is valid only during
+// the call.
+//
+//export set_log_callback
+func set_log_callback(cb C.log_cb) {
+	if cb == nil {
+		SetLogger(nil)
+		return
+	}
+	SetLogger(func(level int, msg string) {
+		cMsg := C.CString(msg)
+		defer C.free(unsafe.Pointer(cMsg))
+		C.call_log(cb, C.int(level), cMsg)
+	})
+}
+
+// setError sets *msg (if msg isn't NULL) to the message of err, NULL if err is
+// nil, and returns the error code. The caller should free *msg with
+// verify_free.
+func setError(err error, msg **C.char) C.int {
+	if msg != nil {
+		*msg = nil
+		if err != nil {
+			*msg = C.CString(err.Error())
+		}
+	}
+	return C.int(errorCode(err))
+}
+
+// verify_code is like verify but returns the error code, the error message is
+// in *msg
+//
+//export verify_code
+func verify_code(root, algo, opts *C.char, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return checkSignatures(C.GoString(root), o)
+	}), msg)
+}
+
+// verify_remote checks the signatures of objects under a bucket prefix, uri is
+// "s3://bucket/prefix" or "gs://bucket/prefix" (see newStorage). Returns the
+// error code and the error message in *msg.
+//
+//export verify_remote
+func verify_remote(uri, algo, opts *C.char, msg **C.char) C.int {
+	root := C.GoString(uri)
+	if !strings.HasPrefix(root, "s3://") && !strings.HasPrefix(root, "gs://") {
+		err := withCode(codeArgument, fmt.Errorf("%s: not an s3:// or gs:// URI", root))
+		return setError(err, msg)
+	}
+	return verify_code(uri, algo, opts, msg)
+}
+
+// verify_archive checks the signatures of files in a zip or tar archive (see
+// CheckArchiveSignatures), returns the error code and the error message in
+// *msg
+//
+//export verify_archive
+func verify_archive(archive, algo, opts *C.char, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return checkArchiveSignatures(C.GoString(archive), o)
+	}), msg)
+}
+
+// verify checks the signatures of files in root, returns the error or NULL
+//
 //export verify
-func verify(root *C.char) *C.char {
-	rootDir := C.GoString(root)
-	if err := CheckSignatures(rootDir); err != nil {
+func verify(root, algo, opts *C.char) *C.char {
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return checkSignatures(C.GoString(root), o)
+	})
+	if err != nil {
 		return C.CString(err.Error())
 	}
 
 	return nil
 }
 
+// verify_with_progress is like verify_code, but checks all files and calls cb
+// with (files done, files total, current path) after each file
+//
+//export verify_with_progress
+func verify_with_progress(root, algo, opts *C.char, cb C.progress_cb, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		rootDir := C.GoString(root)
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		o.Progress = func(done, total int, name string) {
+			cName := C.CString(path.Join(rootDir, name))
+			defer C.free(unsafe.Pointer(cName))
+			C.call_progress(cb, C.int(done), C.int(total), cName)
+		}
+
+		results, err := VerifySignatures(context.Background(), rootDir, o)
+		if err != nil {
+			return err
+		}
+		return errors.Join(fileErrors(results)...)
+	}), msg)
+}
+
+// verify_all checks all files in root and returns a NULL terminated array of
+// error messages, NULL if there are none. The caller should free it with
+// verify_free_strings.
+//
+//export verify_all
+func verify_all(root, algo, opts *C.char) **C.char {
+	return verifyAll(C.GoString(root), algo, opts)
+}
+
+// verifyAll is verify_all with a Go root
+func verifyAll(root string, algo, opts *C.char) **C.char {
+	var results []FileResult
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		results, err = VerifySignatures(context.Background(), root, o)
+		return err
+	})
+	if err != nil {
+		return cStrings([]string{err.Error()})
+	}
+
+	errs := fileErrors(results)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return cStrings(msgs)
+}
+
+// generate writes the signature file of files in root (see WriteSignatures),
+// returns the error code and the error message in *msg
+//
+//export generate
+func generate(root, algo, opts *C.char, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return writeSignatures(context.Background(), C.GoString(root), o)
+	}), msg)
+}
+
+// verify_free frees a string returned by the exported functions. Callers
+// should use it instead of their C library free, which might not be the one
+// the shared library uses (e.g. on Windows).
+//
+//export verify_free
+func verify_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// verify_free_strings frees an array returned by verify_all and its strings
+//
+//export verify_free_strings
+func verify_free_strings(arr **C.char) {
+	if arr == nil {
+		return
+	}
+	for p := arr; *p != nil; p = (**C.char)(unsafe.Add(unsafe.Pointer(p), unsafe.Sizeof(*p))) {
+		C.free(unsafe.Pointer(*p))
+	}
+	C.free(unsafe.Pointer(arr))
+}
+
+// cStrings returns strs as a NULL terminated C array, free it with
+// verify_free_strings
+func cStrings(strs []string) **C.char {
+	size := C.size_t(len(strs)+1) * C.size_t(unsafe.Sizeof((*C.char)(nil)))
+	arr := (**C.char)(C.malloc(size))
+	elems := unsafe.Slice(arr, len(strs)+1)
+	for i, s := range strs {
+		elems[i] = C.CString(s)
+	}
+	elems[len(strs)] = nil
+	return arr
+}
+
+// verify_start starts checking files in root in the background and returns a
+// handle for verify_cancel and verify_wait
+//
+//export verify_start
+func verify_start(root, algo, opts *C.char) C.longlong {
+	o, err := goOptions(algo, opts)
+	if err != nil {
+		return C.longlong(startTask(func(context.Context) error { return err }))
+	}
+	return C.longlong(startVerify(C.GoString(root), o))
+}
+
+// verify_cancel stops the verification started with verify_start, it returns
+// -1 if handle is unknown
+//
+//export verify_cancel
+func verify_cancel(handle C.longlong) C.int {
+	if !cancelVerify(int64(handle)) {
+		return -1
+	}
+	return 0
+}
+
+// verify_fd returns a file descriptor which becomes readable when the
+// verification started with verify_start is done, to use with poll or asyncio
+// loop.add_reader. Don't read from or close it, verify_wait closes it. It
+// returns -1 if handle is unknown or on Windows.
+//
+//export verify_fd
+func verify_fd(handle C.longlong) C.int {
+	fd, err := notifyFD(int64(handle))
+	if err != nil {
+		return -1
+	}
+	return C.int(fd)
+}
+
+// verify_wait waits for the verification started with verify_start and
+// returns the error like verify, handle can't be used after
+//
+//export verify_wait
+func verify_wait(handle C.longlong) *C.char {
+	if err := waitVerify(int64(handle)); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// verify_wait_code is like verify_wait but returns the error code, the error
+// message is in *msg
+//
+//export verify_wait_code
+func verify_wait_code(handle C.longlong, msg **C.char) C.int {
+	return setError(waitVerify(int64(handle)), msg)
+}
+
+// verifyReport is the JSON document returned by verify_json
+type verifyReport struct {
+	Root  string       `json:"root"`
+	Code  int          `json:"code"` // Error code of Error
+	Error string       `json:"error,omitempty"`
+	Files []FileResult `json:"files"`
+	Stats Stats        `json:"stats"`
+}
+
+// verify_json checks all files in root and returns a JSON report (see
+// verifyReport)
+//
+//export verify_json
+func verify_json(root, algo, opts *C.char) *C.char {
+	return verifyJSON(C.GoString(root), algo, opts)
+}
+
+// verifyJSON is verify_json with a Go root
+func verifyJSON(rootDir string, algo, opts *C.char) *C.char {
+	var files []FileResult
+	start := time.Now()
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		files, err = VerifySignatures(context.Background(), rootDir, o)
+		return err
+	})
+	return jsonReport(rootDir, files, time.Since(start), err)
+}
+
+// verify_zipfs checks the files in a zip archive, read as a fs.FS (see
+// VerifySignaturesFS), and returns a JSON report like verify_json
+//
+//export verify_zipfs
+func verify_zipfs(archive, algo, opts *C.char) *C.char {
+	return verifyZipFS(C.GoString(archive), algo, opts)
+}
+
+// verifyZipFS is verify_zipfs with a Go archive path
+func verifyZipFS(archivePath string, algo, opts *C.char) *C.char {
+	var files []FileResult
+	start := time.Now()
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		r, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		files, err = verifyFS(context.Background(), r, archivePath, o)
+		return err
+	})
+	return jsonReport(archivePath, files, time.Since(start), err)
+}
+
+// verify_file checks the signature of the file path against digest (see
+// VerifyFile), returns the error code and the error message in *msg. The file
+// digest is in *actual, NULL if the file can't be read.
+//
+//export verify_file
+func verify_file(path, digest, algo, opts *C.char, actual, msg **C.char) C.int {
+	return verifyFile(C.GoString(path), digest, algo, opts, actual, msg)
+}
+
+// verifyFile is verify_file with a Go path
+func verifyFile(fileName string, digest, algo, opts *C.char, actual, msg **C.char) C.int {
+	var sig string
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		sig, err = VerifyFile(context.Background(), fileName, C.GoString(digest), o)
+		return err
+	})
+	if actual != nil {
+		*actual = nil
+		if sig != "" {
+			*actual = C.CString(sig)
+		}
+	}
+	return setError(err, msg)
+}
+
+// verify_entries checks files against entries, a JSON array of Entry
+// ({"path": "data/a.csv", "digest": "..."}), without signature file. It
+// returns a JSON report like verify_json, with an empty root.
+//
+//export verify_entries
+func verify_entries(entries, algo, opts *C.char) *C.char {
+	var files []FileResult
+	start := time.Now()
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		var es []Entry
+		if err := json.Unmarshal([]byte(C.GoString(entries)), &es); err != nil {
+			return withCode(codeArgument, fmt.Errorf("bad entries: %w", err))
+		}
+		files, err = VerifyEntries(context.Background(), es, o)
+		return err
+	})
+	return jsonReport("", files, time.Since(start), err)
+}
+
+// jsonReport returns the verifyReport of files checked in elapsed time and
+// err as a C string
+func jsonReport(root string, files []FileResult, elapsed time.Duration, err error) *C.char {
+	report := verifyReport{
+		Root:  root,
+		Files: files,
+		Code:  errorCode(err),
+		Stats: newStats(files, elapsed),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if report.Files == nil {
+		report.Files = []FileResult{}
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil { // Can't happen, report is plain data
+		data = []byte(`{"error": "can't marshal report"}`)
+	}
+	return C.CString(string(data))
+}
+
 func main() {}
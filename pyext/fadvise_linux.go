@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// adviseSequential tells the kernel file is read sequentially, once
+func adviseSequential(file *os.File) {
+	unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+// adviseDone drops file pages from the page cache, like O_DIRECT would, so
+// hashing big files doesn't evict other data
+func adviseDone(file *os.File) {
+	unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// File access hints are supported only on Linux
+
+func adviseSequential(file *os.File) {}
+
+func adviseDone(file *os.File) {}
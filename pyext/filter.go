@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// checkPatterns checks the syntax of the include and exclude patterns
+func (o Options) checkPatterns() error {
+	for _, patterns := range [][]string{o.Include, o.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return withCode(codeArgument, fmt.Errorf("bad pattern %q: %w", pattern, err))
+			}
+		}
+	}
+	return nil
+}
+
+// selected returns true if name is matched by one of the include patterns
+// (or there are none) and by none of the exclude patterns. Patterns must be
+// valid, see checkPatterns.
+func (o Options) selected(name string) bool {
+	if len(o.Include) > 0 && !matchAny(o.Include, name) {
+		return false
+	}
+	return !matchAny(o.Exclude, name)
+}
+
+// matchAny returns true if one of patterns matches name. Patterns with a "/"
+// match the whole name, others match its last element: "*.csv" matches
+// "a.csv" and "data/a.csv".
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
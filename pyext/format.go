@@ -0,0 +1,86 @@
+package main
+
+import "strings"
+
+// nameEscaper escapes file names in signature files as GNU coreutils, lines
+// with escaped names start with a backslash
+var nameEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// Signature file formats
+const (
+	FormatGNU = "gnu" // "<digest>  <name>", as sha1sum
+	FormatBSD = "bsd" // "SHA1 (<name>) = <digest>", as sha1sum --tag or BSD sha1
+)
+
+// parseGNULine parses a GNU format line, it returns false if line isn't one.
+// The digest is followed by a space (or a tab) and by "*" for files read in
+// binary mode by sha1sum (always the case here) or by another space.
+func parseGNULine(line string) (name, digest string, ok bool) {
+	// Line example: 6c6427da7893932731901035edbb9214  nasa-00.log
+	i := strings.IndexAny(line, " \t")
+	if i <= 0 {
+		return "", "", false
+	}
+	digest, name = line[:i], line[i+1:]
+	if strings.HasPrefix(name, " ") || strings.HasPrefix(name, "*") {
+		name = name[1:]
+	}
+	return name, digest, name != ""
+}
+
+// escapeName returns the escaped name and true if name must be escaped
+func escapeName(name string) (string, bool) {
+	if !strings.ContainsAny(name, "\\\n\r") {
+		return name, false
+	}
+	return nameEscaper.Replace(name), true
+}
+
+// unescapeName returns name escaped by escapeName, it returns false on an
+// invalid escape sequence
+func unescapeName(name string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i == len(name) {
+			return "", false
+		}
+		switch name[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// parseBSDLine parses a BSD format line, it returns false if line isn't one.
+// algo is "" if the algorithm tag is unknown.
+func parseBSDLine(line string) (algo, name, digest string, ok bool) {
+	// Line example: SHA256 (data/a.csv) = 2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+	tag, rest, ok := strings.Cut(line, " (")
+	if !ok || strings.ContainsAny(tag, " \t") {
+		return "", "", "", false
+	}
+	i := strings.LastIndex(rest, ") = ")
+	if i < 0 {
+		return "", "", "", false
+	}
+	name, digest = rest[:i], rest[i+len(") = "):]
+
+	for a, alg := range algorithms {
+		if alg.tag == tag {
+			algo = a
+		}
+	}
+	return algo, name, digest, true
+}
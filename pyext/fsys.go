@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+)
+
+// CheckSignaturesFS is CheckSignatures for files in fsys, an embed.FS, a
+// zip.Reader or any fs.FS. The signature file is at the root of fsys.
+func CheckSignaturesFS(fsys fs.FS, algo string) error {
+	return checkFS(fsys, "", Options{Algorithm: algo})
+}
+
+// VerifySignaturesFS is VerifySignatures for files in fsys, see
+// CheckSignaturesFS
+func VerifySignaturesFS(ctx context.Context, fsys fs.FS, opts Options) ([]FileResult, error) {
+	return verifyFS(ctx, fsys, "", opts)
+}
+
+// checkFS is CheckSignaturesFS with options, root is fsys location for
+// messages
+func checkFS(fsys fs.FS, root string, opts Options) error {
+	return checkStorage(fsStorage{fsys, root}, root, opts)
+}
+
+// verifyFS is VerifySignaturesFS, root is fsys location for messages
+func verifyFS(ctx context.Context, fsys fs.FS, root string, opts Options) ([]FileResult, error) {
+	st := fsStorage{fsys, root}
+	sigs, err := loadSigs(ctx, st, opts)
+	if err != nil {
+		return nil, err
+	}
+	return verifySigs(ctx, st, root, sigs, opts)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// WriteSignatures is the counterpart of CheckSignatures, it calculates
+// signatures of all files in rootDir (and its sub directories) with the algo
+// hash algorithm ("" is sha1) and writes them to the algorithm signature file
+// in rootDir ("sha1sum.txt" for sha1, see algorithms). Signature files are
+// skipped.
+func WriteSignatures(rootDir, algo string) error {
+	return writeSignatures(context.Background(), rootDir, Options{Algorithm: algo})
+}
+
+// writeSignatures is WriteSignatures with options, Progress is ignored
+func writeSignatures(ctx context.Context, rootDir string, opts Options) error {
+	start := time.Now()
+	algo := opts.Algorithm
+	if algo == "" {
+		algo = SHA1
+	}
+	a, ok := algorithms[algo]
+	if !ok {
+		return withCode(codeArgument, fmt.Errorf("unknown hash algorithm: %q", algo))
+	}
+
+	if err := opts.checkPatterns(); err != nil {
+		return err
+	}
+	format := opts.Format
+	if format == "" {
+		format = FormatGNU
+	}
+	if format != FormatGNU && format != FormatBSD {
+		return withCode(codeArgument, fmt.Errorf("unknown signature file format: %q", format))
+	}
+	names, err := listFiles(rootDir, opts)
+	if err != nil {
+		return err
+	}
+	if opts.cache, err = loadCache(opts); err != nil {
+		return err
+	}
+	defer opts.cache.save()
+	opts.limits = newLimits(opts)
+
+	sigs := make(map[string]string, len(names))
+	fileHash := signature{algo: algo, newHash: a.newHash}
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.concurrency())
+	for _, name := range names {
+		name := name
+		g.Go(func() error {
+			sig := fileHash
+			if opts.ChunkSize > 0 {
+				info, err := os.Stat(filepath.Join(rootDir, name))
+				if err != nil {
+					return err
+				}
+				if info.Size() > opts.ChunkSize {
+					sig.chunkSize = opts.ChunkSize
+				}
+			}
+
+			digest, err := cachedSig(ctx, dirStorage(rootDir), name, sig, opts)
+			if err != nil {
+				return err
+			}
+			if sig.chunkSize > 0 {
+				digest = formatChunked(digest, sig.chunkSize)
+			}
+			mu.Lock()
+			sigs[name] = digest
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(rootDir, a.manifests[0])
+	if err := writeSigFile(fileName, names, sigs, format, a.tag); err != nil {
+		return err
+	}
+	logf(LogInfo, "%s: %d signatures written in %v", fileName, len(names), time.Since(start))
+	return nil
+}
+
+// listFiles returns the sorted names, relative to rootDir, of regular files in
+// rootDir and its sub directories that aren't signature files and are
+// selected by opts
+func listFiles(rootDir string, opts Options) ([]string, error) {
+	manifests := make(map[string]bool)
+	for _, a := range algorithms {
+		for _, name := range a.manifests {
+			manifests[name] = true
+		}
+	}
+
+	var names []string
+	err := fs.WalkDir(os.DirFS(rootDir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || manifests[name] {
+			return nil
+		}
+		if !opts.selected(name) {
+			logf(LogDebug, "%s: skipped", filepath.Join(rootDir, name))
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeSigFile writes sigs of names in the signature file format (see
+// parseSigFile) to fileName, replacing it once all signatures are written. tag
+// is the algorithm name in FormatBSD lines.
+func writeSigFile(fileName string, names []string, sigs map[string]string, format, tag string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), ".sigs-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails after rename
+
+	w := bufio.NewWriter(tmp)
+	for _, name := range names {
+		sig := sigs[name]
+		if escaped, ok := escapeName(name); ok {
+			w.WriteByte('\\')
+			name = escaped
+		}
+		if format == FormatBSD {
+			fmt.Fprintf(w, "%s (%s) = %s\n", tag, name, sig)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s\n", sig, name)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
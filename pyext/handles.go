@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+var errBadHandle = withCode(codeArgument, errors.New("unknown verification handle"))
+
+// verification is a verification running in the background
+type verification struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	mu     sync.Mutex
+	notify *os.File // Read end of the completion pipe, see notifyFD
+	waited bool
+}
+
+// verifications are running verifications by handle
+var verifications struct {
+	sync.Mutex
+	next int64
+	m    map[int64]*verification
+}
+
+// startVerify starts checking all files in rootDir in the background and
+// returns a handle for cancelVerify and waitVerify
+func startVerify(rootDir string, opts Options) int64 {
+	return startTask(func(ctx context.Context) error {
+		results, err := VerifySignatures(ctx, rootDir, opts)
+		if err != nil {
+			return err
+		}
+		return errors.Join(fileErrors(results)...)
+	})
+}
+
+// startTask runs task in the background and returns a handle for cancelVerify
+// and waitVerify
+func startTask(task func(ctx context.Context) error) int64 {
+	ctx, cancel := context.WithCancel(context.Background())
+	v := &verification{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	verifications.Lock()
+	if verifications.m == nil {
+		verifications.m = make(map[int64]*verification)
+	}
+	verifications.next++
+	h := verifications.next
+	verifications.m[h] = v
+	verifications.Unlock()
+
+	go func() {
+		defer close(v.done)
+		v.err = safeCall(func() error { return task(ctx) })
+	}()
+	return h
+}
+
+// cancelVerify cancels the verification h, returns false if h is unknown
+func cancelVerify(h int64) bool {
+	verifications.Lock()
+	v, ok := verifications.m[h]
+	verifications.Unlock()
+	if !ok {
+		return false
+	}
+	v.cancel()
+	return true
+}
+
+// waitVerify waits for verification h and returns its error, h is released
+func waitVerify(h int64) error {
+	verifications.Lock()
+	v, ok := verifications.m[h]
+	delete(verifications.m, h)
+	verifications.Unlock()
+	if !ok {
+		return errBadHandle
+	}
+
+	<-v.done
+	v.cancel()
+	v.mu.Lock()
+	v.waited = true
+	if v.notify != nil {
+		v.notify.Close()
+	}
+	v.mu.Unlock()
+	return v.err
+}
+
+// notifyFD returns a file descriptor which becomes readable (end of file) when
+// verification h is done. It's closed by waitVerify.
+func notifyFD(h int64) (int, error) {
+	verifications.Lock()
+	v, ok := verifications.m[h]
+	verifications.Unlock()
+	if !ok {
+		return -1, errBadHandle
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.waited {
+		return -1, errBadHandle
+	}
+	if v.notify == nil {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return -1, err
+		}
+		if _, err := pipeFD(r); err != nil {
+			r.Close()
+			w.Close()
+			return -1, err
+		}
+		v.notify = r
+		go func() {
+			<-v.done
+			w.Close()
+		}()
+	}
+	return pipeFD(v.notify)
+}
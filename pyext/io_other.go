@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"hash"
+	"os"
+)
+
+// hashMmap isn't supported, files are read
+func hashMmap(ctx context.Context, h hash.Hash, file *os.File) (bool, error) {
+	return false, nil
+}
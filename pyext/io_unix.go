@@ -0,0 +1,45 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"hash"
+	"os"
+	"runtime/debug"
+	"syscall"
+)
+
+// mmapChunk is the size of mapped data hashed between context checks
+const mmapChunk = 4 << 20
+
+// hashMmap writes file content to h using mmap, it returns false if the file
+// can't be mapped and should be read instead
+func hashMmap(ctx context.Context, h hash.Hash, file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 || int64(int(info.Size())) != info.Size() {
+		return false, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return false, nil
+	}
+	defer syscall.Munmap(data)
+	// Reading past the end of a file truncated while hashing raises SIGBUS,
+	// make it a panic that fileSig recovers from instead of a crash
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	for len(data) > 0 {
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+		n := len(data)
+		if n > mmapChunk {
+			n = mmapChunk
+		}
+		h.Write(data[:n])
+		data = data[n:]
+	}
+	return true, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// limits caps the files opened and the memory used by read buffers during a
+// verification, see Options.MaxOpenFiles and Options.MaxMemory. A nil
+// *limits doesn't limit anything.
+type limits struct {
+	files     *semaphore.Weighted // nil for no limit
+	memory    *semaphore.Weighted // nil for no limit
+	maxMemory int64
+}
+
+// newLimits returns the limits set by opts
+func newLimits(opts Options) *limits {
+	var l limits
+	if n := opts.maxOpenFiles(); n > 0 {
+		l.files = semaphore.NewWeighted(int64(n))
+	}
+	if opts.MaxMemory > 0 {
+		l.memory = semaphore.NewWeighted(opts.MaxMemory)
+		l.maxMemory = opts.MaxMemory
+	}
+
+	if n := opts.concurrency(); opts.Concurrency > n {
+		logf(LogInfo, "concurrency lowered from %d to %d by open files or memory limits", opts.Concurrency, n)
+	}
+	return &l
+}
+
+// acquireFile waits for a file slot, release it with releaseFile
+func (l *limits) acquireFile(ctx context.Context) error {
+	if l == nil || l.files == nil {
+		return nil
+	}
+	return l.files.Acquire(ctx, 1)
+}
+
+func (l *limits) releaseFile() {
+	if l == nil || l.files == nil {
+		return
+	}
+	l.files.Release(1)
+}
+
+// buffer waits for memory and returns a buffer of size bytes, smaller if size
+// is over the memory limit. Release it with releaseBuffer.
+func (l *limits) buffer(ctx context.Context, size int) ([]byte, error) {
+	if l == nil || l.memory == nil {
+		return make([]byte, size), nil
+	}
+	if int64(size) > l.maxMemory {
+		size = int(l.maxMemory)
+	}
+	if err := l.memory.Acquire(ctx, int64(size)); err != nil {
+		return nil, err
+	}
+	return make([]byte, size), nil
+}
+
+func (l *limits) releaseBuffer(buf []byte) {
+	if l == nil || l.memory == nil {
+		return
+	}
+	l.memory.Release(int64(len(buf)))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Log levels, same values as Python logging levels
+const (
+	LogDebug   = 10
+	LogInfo    = 20
+	LogWarning = 30
+	LogError   = 40
+)
+
+// logger is the function receiving log messages, nil discards them
+var logger struct {
+	sync.RWMutex
+	fn func(level int, msg string)
+}
+
+// SetLogger sets the function called with log messages (skipped files,
+// signature file used, timing ...), nil discards them. fn is called from
+// several goroutines.
+func SetLogger(fn func(level int, msg string)) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.fn = fn
+}
+
+// logf logs a message formatted with fmt.Sprintf at level
+func logf(level int, format string, args ...any) {
+	logger.RLock()
+	fn := logger.fn
+	logger.RUnlock()
+	if fn == nil {
+		return
+	}
+	fn(level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// maxManifestSize is the maximal size of a signature file
+	maxManifestSize = 64 << 20
+
+	// defaultManifestTimeout is the default timeout to fetch a remote
+	// signature file
+	defaultManifestTimeout = 30 * time.Second
+)
+
+// httpClient fetches remote signature files
+var httpClient = http.DefaultClient
+
+// readManifest reads the signature file opts.Manifest, an https:// URL, an
+// absolute local path or a path relative to st, and checks its digest if
+// opts.ManifestDigest is set and its detached signature if opts.PublicKey is
+// set (see checkManifestSig). It returns the file content and its algorithm
+// ("" if the name isn't a known signature file name).
+func readManifest(ctx context.Context, st storage, opts Options) ([]byte, string, error) {
+	src := opts.Manifest
+	data, name, err := readSource(ctx, st, src, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	logf(LogInfo, "%s: signature file, %d bytes", name, len(data))
+
+	if opts.ManifestDigest != "" {
+		if err := checkDigest(data, opts.ManifestDigest); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", opts.Manifest, err)
+		}
+	}
+	if opts.PublicKey != "" {
+		if err := checkManifestSig(ctx, st, src, data, opts); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return data, manifestAlgorithm(path.Base(src)), nil
+}
+
+// readSource reads src, an https:// URL, an absolute local path or a path
+// relative to st. It returns its content and its name for messages.
+func readSource(ctx context.Context, st storage, src string, opts Options) ([]byte, string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	name := src
+	switch {
+	case strings.HasPrefix(src, "https://"):
+		data, err = fetchManifest(ctx, src, opts.ManifestTimeout)
+	case strings.HasPrefix(src, "http://"):
+		err = withCode(codeArgument, fmt.Errorf("%s: insecure manifest URL, use https", src))
+	case filepath.IsAbs(src):
+		data, err = readAll(ctx, dirStorage(""), src)
+	default:
+		name = st.Path(src)
+		data, err = readAll(ctx, st, src)
+	}
+	return data, name, err
+}
+
+// fetchManifest returns the signature file at url
+func fetchManifest(ctx context.Context, url string, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = defaultManifestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, withCode(codeArgument, err)
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	logf(LogDebug, "%s: %s in %v", url, resp.Status, time.Since(start))
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return limitedRead(resp.Body, url)
+}
+
+// readAll returns the content of the signature file name in st
+func readAll(ctx context.Context, st storage, name string) ([]byte, error) {
+	file, err := st.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return limitedRead(file, st.Path(name))
+}
+
+// limitedRead reads r up to maxManifestSize
+func limitedRead(r io.Reader, name string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, withCode(codeManifest, fmt.Errorf("%s: too big", name))
+	}
+	return data, nil
+}
+
+// checkDigest checks that data digest is digest, the algorithm is detected
+// from the digest length
+func checkDigest(data []byte, digest string) error {
+	algo, err := detectAlgorithm(digest, "")
+	if err != nil {
+		return withCode(codeArgument, err)
+	}
+	h := algorithms[algo].newHash()
+	h.Write(data)
+	sig := fmt.Sprintf("%x", h.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(strings.ToLower(digest))) != 1 {
+		return withCode(codeManifest, fmt.Errorf("signature file %s mismatch", algo))
+	}
+	return nil
+}
+
+// manifestAlgorithm returns the algorithm of the signature file name, "" if
+// it's not a known name
+func manifestAlgorithm(name string) string {
+	for algo, a := range algorithms {
+		for _, n := range a.manifests {
+			if n == name {
+				return algo
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,18 @@
+//go:build py_native
+
+package main
+
+// Build with the "py_native" tag to add PyInit_checksig (see
+// checksig_module.c) to the shared library, making it a CPython extension
+// module you can import as checksig:
+//
+//	go build -tags py_native -buildmode=c-shared -o checksig$(EXT_SUFFIX)
+//
+// Python symbols are resolved by the interpreter loading the module, the
+// library isn't linked with libpython.
+
+/*
+#cgo pkg-config: python3
+#cgo darwin LDFLAGS: -undefined dynamic_lookup
+*/
+import "C"
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoFD = withCode(codeArgument, errors.New("completion file descriptor not supported"))
+
+// pipeFD fails, Windows pipes are handles and not file descriptors
+func pipeFD(r *os.File) (int, error) {
+	return -1, errNoFD
+}
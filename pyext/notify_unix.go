@@ -0,0 +1,10 @@
+//go:build unix
+
+package main
+
+import "os"
+
+// pipeFD returns the file descriptor of the pipe end r
+func pipeFD(r *os.File) (int, error) {
+	return int(r.Fd()), nil
+}
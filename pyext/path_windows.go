@@ -0,0 +1,120 @@
+package main
+
+// Windows callers have paths as UTF-16 wchar_t strings, the *_w functions
+// accept them. Other strings (algorithm, options, messages) are UTF-8.
+
+/*
+#include <wchar.h>
+*/
+import "C"
+
+import (
+	"context"
+	"syscall"
+	"unsafe"
+)
+
+// goPath converts a NUL terminated UTF-16 path to a Go (UTF-8) string
+func goPath(p *C.wchar_t) string {
+	if p == nil {
+		return ""
+	}
+	n := int(C.wcslen(p))
+	return syscall.UTF16ToString(unsafe.Slice((*uint16)(unsafe.Pointer(p)), n))
+}
+
+// verify_w is verify with a UTF-16 root
+//
+//export verify_w
+func verify_w(root *C.wchar_t, algo, opts *C.char) *C.char {
+	err := safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return checkSignatures(goPath(root), o)
+	})
+	if err != nil {
+		return C.CString(err.Error())
+	}
+
+	return nil
+}
+
+// verify_code_w is verify_code with a UTF-16 root
+//
+//export verify_code_w
+func verify_code_w(root *C.wchar_t, algo, opts *C.char, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return checkSignatures(goPath(root), o)
+	}), msg)
+}
+
+// verify_archive_w is verify_archive with a UTF-16 archive path
+//
+//export verify_archive_w
+func verify_archive_w(archive *C.wchar_t, algo, opts *C.char, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return checkArchiveSignatures(goPath(archive), o)
+	}), msg)
+}
+
+// verify_all_w is verify_all with a UTF-16 root
+//
+//export verify_all_w
+func verify_all_w(root *C.wchar_t, algo, opts *C.char) **C.char {
+	return verifyAll(goPath(root), algo, opts)
+}
+
+// generate_w is generate with a UTF-16 root
+//
+//export generate_w
+func generate_w(root *C.wchar_t, algo, opts *C.char, msg **C.char) C.int {
+	return setError(safeCall(func() error {
+		o, err := goOptions(algo, opts)
+		if err != nil {
+			return err
+		}
+		return writeSignatures(context.Background(), goPath(root), o)
+	}), msg)
+}
+
+// verify_start_w is verify_start with a UTF-16 root
+//
+//export verify_start_w
+func verify_start_w(root *C.wchar_t, algo, opts *C.char) C.longlong {
+	o, err := goOptions(algo, opts)
+	if err != nil {
+		return C.longlong(startTask(func(context.Context) error { return err }))
+	}
+	return C.longlong(startVerify(goPath(root), o))
+}
+
+// verify_json_w is verify_json with a UTF-16 root
+//
+//export verify_json_w
+func verify_json_w(root *C.wchar_t, algo, opts *C.char) *C.char {
+	return verifyJSON(goPath(root), algo, opts)
+}
+
+// verify_zipfs_w is verify_zipfs with a UTF-16 archive path
+//
+//export verify_zipfs_w
+func verify_zipfs_w(archive *C.wchar_t, algo, opts *C.char) *C.char {
+	return verifyZipFS(goPath(archive), algo, opts)
+}
+
+// verify_file_w is verify_file with a UTF-16 path
+//
+//export verify_file_w
+func verify_file_w(path *C.wchar_t, digest, algo, opts *C.char, actual, msg **C.char) C.int {
+	return verifyFile(goPath(path), digest, algo, opts, actual, msg)
+}
@@ -1,11 +1,11 @@
 import ctypes
 so = ctypes.cdll.LoadLibrary('./_checksig.so')
 verify = so.verify
-verify.argtypes = [ctypes.c_char_p]
+verify.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_char_p]
 verify.restype = ctypes.c_void_p
-free = so.free
+free = so.verify_free
 free.argtypes = [ctypes.c_void_p]
-ptr = verify('/tmp/logs'.encode('utf-8'))
+ptr = verify('/tmp/logs'.encode('utf-8'), None, None)
 out = ctypes.string_at(ptr)
 free(ptr)
 print(out.decode('utf-8'))
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// rlimitFiles returns 0, there's no open files limit
+func rlimitFiles() int {
+	return 0
+}
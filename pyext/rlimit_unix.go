@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// rlimitFiles returns the number of files the library can open, half of
+// RLIMIT_NOFILE to leave room for the hosting process. 0 if there's no
+// limit.
+func rlimitFiles() int {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0
+	}
+	cur := uint64(rl.Cur) // int64 on some systems, RLIM_INFINITY is -1
+	if cur == 0 || cur > 1<<30 {
+		return 0
+	}
+	if cur < 2 {
+		return 1
+	}
+	return int(cur / 2)
+}
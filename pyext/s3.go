@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials are AWS credentials, zero value for anonymous requests
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// newS3Storage returns the storage of objects under prefix in an S3 bucket.
+// Credentials and region come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN and AWS_REGION (default us-east-1), requests are
+// anonymous without credentials. AWS_ENDPOINT_URL overrides the endpoint
+// (e.g. for MinIO) and uses path style URLs.
+func newS3Storage(bucket, prefix string) storage {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	creds := awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	objectURL := func(key string) string {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, escapeKey(key))
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		objectURL = func(key string) string {
+			return endpoint + "/" + url.PathEscape(bucket) + "/" + escapeKey(key)
+		}
+	}
+
+	return &bucketStorage{
+		scheme:    "s3",
+		bucket:    bucket,
+		prefix:    prefix,
+		objectURL: objectURL,
+		authorize: func(req *http.Request) error {
+			if creds.accessKey == "" {
+				return nil
+			}
+			req.Header.Set("x-amz-content-sha256", "UNSIGNED-PAYLOAD")
+			if creds.sessionToken != "" {
+				req.Header.Set("x-amz-security-token", creds.sessionToken)
+			}
+			signV4(req, creds, region, "s3", time.Now())
+			return nil
+		},
+	}
+}
+
+// signV4 signs req with AWS signature version 4, signing the Host header and
+// all headers set in req. req must have the x-amz-content-sha256 header.
+func signV4(req *http.Request, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102T150405Z")
+	req.Header.Set("x-amz-date", date)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date[:8], region, service)
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		date,
+		scope,
+		hexSHA256(canonRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date[:8])
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	auth := fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, sig,
+	)
+	req.Header.Set("Authorization", auth)
+}
+
+// canonicalQuery returns the sorted & escaped query for signV4
+func canonicalQuery(query url.Values) string {
+	var parts []string
+	for name, values := range query {
+		for _, v := range values {
+			parts = append(parts, awsEscape(name)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "&")
+}
+
+// awsEscape escapes s as AWS expects, only unreserved characters are kept
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
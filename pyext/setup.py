@@ -1,5 +1,6 @@
 """Setup for checksig package"""
 from distutils.errors import CompileError
+from glob import glob
 from subprocess import call
 
 from setuptools import Extension, setup
@@ -10,19 +11,25 @@ class build_go_ext(build_ext):
     """Custom command to build extension from Go source files"""
     def build_extension(self, ext):
         ext_path = self.get_ext_fullpath(ext.name)
-        cmd = ['go', 'build', '-buildmode=c-shared', '-o', ext_path]
-        cmd += ext.sources
+        # Build the package, not ext.sources: go build ignores build
+        # constraints of files passed on the command line
+        cmd = ['go', 'build', '-buildmode=c-shared', '-o', ext_path, '.']
         out = call(cmd)
         if out != 0:
             raise CompileError('Go build failed')
 
 
+# Go files, for setuptools to know the sources
+go_sources = sorted(
+    name for name in glob('*.go') if not name.endswith('_test.go'))
+
+
 setup(
     name='checksig',
     version='0.1.0',
     py_modules=['checksig'],
     ext_modules=[
-        Extension('_checksig', ['checksig.go', 'export.go'])
+        Extension('_checksig', go_sources)
     ],
     cmdclass={'build_ext': build_go_ext},
     zip_safe=False,
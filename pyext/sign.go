@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Detached signature file extensions, appended to the signature file name
+const (
+	minisignExt = ".minisig"
+	ed25519Ext  = ".sig"
+)
+
+// minisign key and signature algorithms
+const (
+	minisignAlgo       = "Ed" // Signature of the file
+	minisignHashedAlgo = "ED" // Signature of the file BLAKE2b-512 digest
+)
+
+const (
+	minisignIDSize       = 8
+	minisignKeySize      = len(minisignAlgo) + minisignIDSize + ed25519.PublicKeySize
+	minisignSigSize      = len(minisignAlgo) + minisignIDSize + ed25519.SignatureSize
+	trustedCommentPrefix = "trusted comment: "
+)
+
+// publicKey is a signature file public key
+type publicKey struct {
+	key ed25519.PublicKey
+	id  []byte // minisign key ID, nil for raw ed25519 keys
+}
+
+// parsePublicKey parses s, a minisign public key, optionally with its
+// "untrusted comment:" line, or a raw ed25519 key in hex or base64
+func parsePublicKey(s string) (publicKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+
+	if len(line) == 2*ed25519.PublicKeySize {
+		if key, err := hex.DecodeString(line); err == nil {
+			return publicKey{key: key}, nil
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(line)
+	switch {
+	case err != nil:
+	case len(data) == ed25519.PublicKeySize:
+		return publicKey{key: data}, nil
+	case len(data) == minisignKeySize && string(data[:2]) == minisignAlgo:
+		id, key := data[2:2+minisignIDSize], data[2+minisignIDSize:]
+		return publicKey{key: key, id: id}, nil
+	}
+	return publicKey{}, withCode(codeArgument, errors.New("bad public key"))
+}
+
+// checkManifestSig checks the detached signature of the signature file src
+// (see readManifest) with content data against opts.PublicKey. The signature
+// is in the minisign "<src>.minisig" file for minisign keys, or is a raw
+// ed25519 signature (64 bytes, or in base64) in "<src>.sig" for ed25519 keys.
+func checkManifestSig(ctx context.Context, st storage, src string, data []byte, opts Options) error {
+	key, err := parsePublicKey(opts.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	ext := ed25519Ext
+	if key.id != nil {
+		ext = minisignExt
+	}
+	sig, name, err := readSource(ctx, st, src+ext, opts)
+	if err != nil {
+		// Not wrapped: findManifest would look for another signature file if
+		// err is fs.ErrNotExist
+		return withCode(codeManifest, fmt.Errorf("can't read signature: %v", err))
+	}
+
+	if key.id != nil {
+		err = checkMinisign(key, data, sig)
+	} else {
+		err = checkEd25519(key, data, sig)
+	}
+	if err != nil {
+		return withCode(codeManifest, fmt.Errorf("%s: %w", name, err))
+	}
+	logf(LogDebug, "%s: good signature", name)
+	return nil
+}
+
+// checkEd25519 checks that sig is the ed25519 signature of data, raw or in
+// base64
+func checkEd25519(key publicKey, data, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return errors.New("bad signature format")
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(key.key, data, sig) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// checkMinisign checks that sig, a minisign signature file, is the signature
+// of data by key, including its trusted comment
+func checkMinisign(key publicKey, data, sig []byte) error {
+	lines := strings.Split(strings.ReplaceAll(string(sig), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return errors.New("bad signature format")
+	}
+	s, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(s) != minisignSigSize {
+		return errors.New("bad signature format")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("bad signature format")
+	}
+
+	algo, id, s := string(s[:2]), s[2:2+minisignIDSize], s[2+minisignIDSize:]
+	if !bytes.Equal(id, key.id) {
+		return fmt.Errorf("signed by key %X, expected %X", reverse(id), reverse(key.id))
+	}
+	switch algo {
+	case minisignAlgo:
+	case minisignHashedAlgo:
+		sum := blake2b.Sum512(data)
+		data = sum[:]
+	default:
+		return fmt.Errorf("unknown signature algorithm: %q", algo)
+	}
+	if !ed25519.Verify(key.key, data, s) {
+		return errors.New("bad signature")
+	}
+
+	comment := lines[2][len(trustedCommentPrefix):]
+	if !ed25519.Verify(key.key, append(append([]byte(nil), s...), comment...), global) {
+		return errors.New("bad trusted comment signature")
+	}
+	return nil
+}
+
+// reverse returns a reversed copy of b, minisign shows key IDs as little
+// endian numbers
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	return r
+}
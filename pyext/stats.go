@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats are aggregate statistics of a verification
+type Stats struct {
+	Files    int           `json:"files"`
+	Bytes    int64         `json:"bytes"`       // Bytes hashed
+	Duration time.Duration `json:"duration_ns"` // Wall clock time
+	MBPerSec float64       `json:"mb_per_sec"`  // Bytes hashed per second, in MB (10^6 bytes)
+}
+
+// newStats returns the statistics of results checked in elapsed time
+func newStats(results []FileResult, elapsed time.Duration) Stats {
+	s := Stats{Files: len(results), Duration: elapsed}
+	for _, r := range results {
+		s.Bytes += r.Bytes
+	}
+	s.MBPerSec = mbPerSec(s.Bytes, elapsed)
+	return s
+}
+
+// mbPerSec returns the throughput of n bytes in d
+func mbPerSec(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / 1e6 / d.Seconds()
+}
+
+// byteCounter counts bytes hashed, a nil *byteCounter doesn't count
+type byteCounter struct {
+	n atomic.Int64
+}
+
+func (c *byteCounter) add(n int64) {
+	if c != nil {
+		c.n.Add(n)
+	}
+}
+
+func (c *byteCounter) count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.n.Load()
+}
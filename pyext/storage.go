@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// storage holds the files to check, a local directory or a bucket prefix
+type storage interface {
+	// Open opens the file name, relative to the storage root. The error wraps
+	// fs.ErrNotExist if the file doesn't exist.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Path returns the full path of name, for messages
+	Path(name string) string
+}
+
+// newStorage returns the storage for root: objects under a bucket prefix for
+// "s3://bucket/prefix" and "gs://bucket/prefix" (see s3Storage and
+// gcsStorage), a local directory otherwise.
+func newStorage(root string) (storage, error) {
+	scheme, rest, ok := strings.Cut(root, "://")
+	if !ok {
+		return dirStorage(root), nil
+	}
+
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, withCode(codeArgument, fmt.Errorf("%s: missing bucket", root))
+	}
+	switch scheme {
+	case "s3":
+		return newS3Storage(bucket, prefix), nil
+	case "gs":
+		return newGCSStorage(bucket, prefix), nil
+	}
+	return nil, withCode(codeArgument, fmt.Errorf("%s: unknown storage", root))
+}
+
+// dirStorage is a local directory
+type dirStorage string
+
+func (d dirStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(d.Path(name))
+}
+
+func (d dirStorage) Path(name string) string {
+	return path.Join(string(d), name)
+}
+
+// fsStorage is files in a fs.FS, root is its location for messages
+type fsStorage struct {
+	fsys fs.FS
+	root string
+}
+
+func (s fsStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	// fs.FS names can't start with "./", fs.ValidPath rejects ".." elements
+	return s.fsys.Open(path.Clean(name))
+}
+
+func (s fsStorage) Path(name string) string {
+	return path.Join(s.root, name)
+}
+
+// bucketStorage is objects under prefix in a bucket, fetched over HTTP
+type bucketStorage struct {
+	scheme string // For Path
+	bucket string
+	prefix string
+
+	// objectURL returns the URL of the key object
+	objectURL func(key string) string
+	// authorize adds credentials to req, if any
+	authorize func(req *http.Request) error
+}
+
+func (b *bucketStorage) Path(name string) string {
+	return b.scheme + "://" + b.bucket + "/" + b.key(name)
+}
+
+func (b *bucketStorage) key(name string) string {
+	if b.prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(b.prefix, "/") + "/" + name
+}
+
+func (b *bucketStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.objectURL(b.key(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.authorize(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, &fs.PathError{Op: "open", Path: b.Path(name), Err: fs.ErrNotExist}
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("%s: %s", b.Path(name), resp.Status)
+}
+
+// escapeKey escapes the key object for an URL path, keeping "/". Escaping
+// every reserved character keeps the path as signV4 signs it.
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = awsEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// newGCSStorage returns the storage of objects under prefix in a Google Cloud
+// Storage bucket. Requests use the access token in GOOGLE_OAUTH_ACCESS_TOKEN
+// if set, anonymous requests otherwise. STORAGE_EMULATOR_HOST overrides the
+// endpoint.
+func newGCSStorage(bucket, prefix string) storage {
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = strings.TrimSuffix(host, "/")
+	}
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+
+	return &bucketStorage{
+		scheme: "gs",
+		bucket: bucket,
+		prefix: prefix,
+		objectURL: func(key string) string {
+			return endpoint + "/" + url.PathEscape(bucket) + "/" + escapeKey(key)
+		},
+		authorize: func(req *http.Request) error {
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return nil
+		},
+	}
+}
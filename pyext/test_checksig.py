@@ -1,10 +1,244 @@
-from checksig import check_signatures
+import asyncio
+import logging
+import os
+import sys
+import time
+import zipfile
+from checksig import (
+    BAD_ARGUMENT, BAD_MANIFEST, CANCELED, MISMATCH, CheckError,
+    SignaturesError, Verification, check_all_signatures,
+    check_archive_signatures, check_file, check_remote_signatures,
+    check_signatures, entries_report, set_log_callback, signatures_report,
+    write_signatures, zip_report,
+)
+from pathlib import Path
+from tempfile import TemporaryDirectory
 
-from unittest import TestCase
+from unittest import TestCase, skipIf
 
 
 class TestCheckSignatures(TestCase):
     def test_logs(self):
         logs_dir = 'testdata/logs'
-        with self.assertRaises(ValueError):
+        with self.assertRaises(CheckError) as ctx:
             check_signatures(logs_dir)
+        self.assertEqual(MISMATCH, ctx.exception.code)
+
+    def test_report(self):
+        report = signatures_report('testdata/logs')
+        statuses = {f['name']: f['status'] for f in report['files']}
+        self.assertEqual('mismatch', statuses['httpd-08.log'])
+        self.assertEqual('ok', statuses['httpd-00.log'])
+        size = Path('testdata/logs/httpd-00.log').stat().st_size
+        files = {f['name']: f for f in report['files']}
+        self.assertEqual(size, files['httpd-00.log']['bytes'])
+        stats = report['stats']
+        self.assertEqual(10, stats['files'])
+        total = sum(f['bytes'] for f in files.values())
+        self.assertEqual(total, stats['bytes'])
+        self.assertGreater(stats['mb_per_sec'], 0)
+
+    def test_all(self):
+        with self.assertRaises(SignaturesError) as ctx:
+            check_all_signatures('testdata/logs')
+        self.assertEqual(1, len(ctx.exception.errors))
+        self.assertIn('httpd-08.log', ctx.exception.errors[0])
+
+    def test_progress(self):
+        calls = []
+        with self.assertRaises(ValueError):
+            check_signatures('testdata/logs', lambda *args: calls.append(args))
+        self.assertEqual(10, len(calls))
+        self.assertEqual(list(range(1, 11)), [c[0] for c in calls])
+        self.assertTrue(all(c[1] == 10 for c in calls))
+
+    def test_cancel(self):
+        v = Verification('testdata/logs')
+        v.cancel()
+        with self.assertRaises(CheckError) as ctx:
+            v.wait()
+        self.assertEqual(CANCELED, ctx.exception.code)
+        with self.assertRaises(ValueError):
+            v.wait()
+
+    @skipIf(sys.platform == 'win32', 'no file descriptors on Windows')
+    def test_wait_async(self):
+        async def check():
+            v = Verification('testdata/logs')
+            with self.assertRaises(CheckError) as ctx:
+                await v.wait_async()
+            self.assertEqual(MISMATCH, ctx.exception.code)
+
+        asyncio.run(check())
+
+    def test_algo(self):
+        with self.assertRaises(ValueError) as ctx:
+            check_signatures('testdata/logs', algo='crc32')
+        self.assertIn('unknown hash algorithm', str(ctx.exception))
+        self.assertEqual(BAD_ARGUMENT, ctx.exception.code)
+
+    def test_options(self):
+        report = signatures_report('testdata/logs', concurrency=1)
+        self.assertEqual(10, len(report['files']))
+        report = signatures_report(
+            'testdata/logs', concurrency=100, max_open_files=2,
+            max_memory=1 << 20)
+        self.assertEqual(10, len(report['files']))
+        with self.assertRaises(ValueError) as ctx:
+            check_signatures('testdata/logs', concurrenc=1)
+        self.assertIn('bad options', str(ctx.exception))
+
+    def test_write(self):
+        with TemporaryDirectory() as root_dir:
+            root = Path(root_dir)
+            (root / 'a.txt').write_text('a')
+            (root / 'sub').mkdir()
+            (root / 'sub' / 'b.txt').write_text('b')
+
+            write_signatures(root_dir, 'sha256')
+            lines = (root / 'sha256sum.txt').read_text().splitlines()
+            self.assertEqual(['a.txt', 'sub/b.txt'], [ln[66:] for ln in lines])
+            check_signatures(root_dir)
+
+            (root / 'a.txt').write_text('A')
+            with self.assertRaises(CheckError):
+                check_signatures(root_dir)
+
+    def test_manifest(self):
+        with TemporaryDirectory() as root_dir:
+            manifest = Path(root_dir) / 'sigs.txt'
+            manifest.write_text(
+                Path('testdata/logs/sha1sum.txt').read_text())
+            with self.assertRaises(CheckError) as ctx:
+                check_signatures('testdata/logs', manifest=str(manifest))
+            self.assertEqual(MISMATCH, ctx.exception.code)
+
+    def test_public_key(self):
+        key = Path('testdata/minisign.pub').read_text()
+        include = ['httpd-0[0-7].log']
+        check_signatures('testdata/logs', public_key=key, include=include)
+
+        with TemporaryDirectory() as root_dir:
+            root = Path(root_dir)
+            for path in Path('testdata/logs').iterdir():
+                (root / path.name).write_bytes(path.read_bytes())
+            with (root / 'sha1sum.txt').open('a') as out:
+                out.write('0' * 40 + '  extra.log\n')
+            with self.assertRaises(CheckError) as ctx:
+                check_signatures(root_dir, public_key=key, include=include)
+            self.assertEqual(BAD_MANIFEST, ctx.exception.code)
+
+    def test_remote(self):
+        with self.assertRaises(CheckError) as ctx:
+            check_remote_signatures('/tmp/logs')
+        self.assertEqual(BAD_ARGUMENT, ctx.exception.code)
+
+    def test_include(self):
+        check_signatures('testdata/logs', include=['httpd-0[0-7].log'])
+        with self.assertRaises(CheckError):
+            check_signatures('testdata/logs', exclude=['httpd-0[0-7].log'])
+
+    def test_path(self):
+        with TemporaryDirectory() as root_dir:
+            root = Path(root_dir) / 'données'
+            root.mkdir()
+            (root / 'été.txt').write_text('a')
+            write_signatures(root)
+            check_signatures(root)
+            check_signatures(bytes(root))
+
+            paths = []
+            check_signatures(root, lambda *args: paths.append(args[2]))
+            self.assertEqual([str(root / 'été.txt')], paths)
+
+    def test_log(self):
+        logger = logging.getLogger('checksig')
+        set_log_callback(logger.log)
+        self.addCleanup(set_log_callback, None)
+        with self.assertLogs(logger, logging.DEBUG) as logs:
+            with self.assertRaises(CheckError):
+                check_signatures(
+                    'testdata/logs', exclude=['httpd-00.log'], concurrency=1)
+        self.assertIn(
+            'DEBUG:checksig:testdata/logs/httpd-00.log: skipped', logs.output)
+
+    def test_bsd(self):
+        with TemporaryDirectory() as root_dir:
+            root = Path(root_dir)
+            (root / 'a.csv').write_text('a')
+            write_signatures(root_dir, 'sha256', format='bsd')
+            line = (root / 'sha256sum.txt').read_text()
+            self.assertTrue(line.startswith('SHA256 (a.csv) = '), line)
+            check_signatures(root_dir)
+
+    def test_archive(self):
+        with TemporaryDirectory() as tmp_dir:
+            archive = Path(tmp_dir) / 'logs.zip'
+            with zipfile.ZipFile(archive, 'w') as zf:
+                for path in Path('testdata/logs').iterdir():
+                    zf.write(path, path.name)
+            with self.assertRaises(CheckError) as ctx:
+                check_archive_signatures(archive)
+            self.assertEqual(MISMATCH, ctx.exception.code)
+            check_archive_signatures(archive, include=['httpd-0[0-7].log'])
+
+            report = zip_report(archive)
+            statuses = {f['name']: f['status'] for f in report['files']}
+            self.assertEqual('mismatch', statuses['httpd-08.log'])
+            self.assertEqual('ok', statuses['httpd-00.log'])
+            with self.assertRaises(ValueError):
+                zip_report(Path(tmp_dir) / 'missing.zip')
+
+    def test_cache(self):
+        with TemporaryDirectory() as root_dir:
+            root = Path(root_dir)
+            (root / 'a.txt').write_text('a')
+            write_signatures(root_dir)
+            # Recently modified files aren't cached
+            mtime = time.time() - 3600
+            os.utime(root / 'a.txt', (mtime, mtime))
+
+            cache = root / 'cache' / 'sigs.json'
+            check_signatures(root_dir, cache=str(cache))
+            self.assertTrue(cache.exists())
+            (root / 'a.txt').write_text('b')
+            os.utime(root / 'a.txt', (mtime, mtime))
+            check_signatures(root_dir, cache=str(cache))
+            with self.assertRaises(CheckError):
+                check_signatures(root_dir, cache=str(cache), force=True)
+
+    def test_chunked(self):
+        with TemporaryDirectory() as root_dir:
+            root = Path(root_dir)
+            (root / 'big.bin').write_bytes(b'0123456789' * 1000)
+            write_signatures(root_dir, 'sha256', chunk_size=4096)
+            line = (root / 'sha256sum.txt').read_text()
+            self.assertTrue(line.startswith('chunked-4096:'), line)
+            check_signatures(root_dir)
+
+    def test_file(self):
+        sha1 = '86f7e437faa5a7fce15d1ddcb9eaeaea377667b8'  # sha1 of 'a'
+        with TemporaryDirectory() as root_dir:
+            path = Path(root_dir) / 'a.txt'
+            path.write_text('a')
+            self.assertEqual((True, sha1), check_file(path, sha1.upper()))
+            path.write_text('b')
+            ok, digest = check_file(path, sha1)
+            self.assertFalse(ok)
+            self.assertNotEqual(sha1, digest)
+            with self.assertRaises(CheckError) as ctx:
+                check_file(Path(root_dir) / 'b.txt', sha1)
+            self.assertNotEqual(MISMATCH, ctx.exception.code)
+
+    def test_entries(self):
+        entries = {}
+        for line in Path('testdata/logs/sha1sum.txt').read_text().splitlines():
+            digest, name = line.split()
+            entries[Path('testdata/logs') / name] = digest
+        report = entries_report(entries)
+        statuses = {f['name']: f['status'] for f in report['files']}
+        self.assertEqual('mismatch', statuses['testdata/logs/httpd-08.log'])
+        self.assertEqual('ok', statuses['testdata/logs/httpd-00.log'])
+
+        with self.assertRaises(ValueError):
+            entries_report([('a.txt', 'abcd')])
@@ -0,0 +1,39 @@
+import sys
+from pathlib import Path
+from unittest import TestCase, skipUnless
+
+# Built with "make native"
+native_dir = Path(__file__).absolute().parent / 'build' / 'native'
+
+
+@skipUnless(native_dir.exists(), 'native module not built')
+class TestNative(TestCase):
+    def setUp(self):
+        sys.path.insert(0, str(native_dir))
+        self.addCleanup(sys.path.remove, str(native_dir))
+        sys.modules.pop('checksig', None)
+        self.addCleanup(sys.modules.pop, 'checksig', None)
+
+    def test_verify(self):
+        import checksig
+        self.assertEqual(str(native_dir), str(Path(checksig.__file__).parent))
+
+        with self.assertRaises(ValueError) as ctx:
+            checksig.verify('testdata/logs')
+        self.assertIn('httpd-08.log', str(ctx.exception))
+
+        with self.assertRaises(ValueError) as ctx:
+            checksig.verify(Path('testdata/logs').absolute())
+        self.assertIn('httpd-08.log', str(ctx.exception))
+
+        with self.assertRaises(ValueError) as ctx:
+            checksig.verify('testdata/logs', algo='crc32')
+        self.assertIn('unknown hash algorithm', str(ctx.exception))
+
+        with self.assertRaises(ValueError) as ctx:
+            checksig.verify('testdata/logs', 'sha1', concurrency=1)
+        self.assertIn('mismatch', str(ctx.exception))
+
+        with self.assertRaises(ValueError) as ctx:
+            checksig.verify('testdata/logs', concurrenc=1)
+        self.assertIn('bad options', str(ctx.exception))
@@ -0,0 +1,94 @@
+package pyproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// Maximal frame size
+	maxFrameSize = 1 << 30
+
+	// msgpack extension ID for Float64s, see worker.py
+	float64sExtID = 1
+)
+
+func init() {
+	msgpack.RegisterExtEncoder(float64sExtID, Float64s(nil), encodeFloat64s)
+}
+
+// Float64s is sent to Python as a numpy float64 array (or a list if numpy is
+// not installed). It's encoded as raw bytes which is much faster than encoding
+// every value.
+type Float64s []float64
+
+func encodeFloat64s(_ *msgpack.Encoder, v reflect.Value) ([]byte, error) {
+	f := v.Interface().(Float64s)
+	buf := make([]byte, len(f)*8)
+	for i, v := range f {
+		binary.LittleEndian.PutUint64(buf[i*8:], math.Float64bits(v))
+	}
+	return buf, nil
+}
+
+// request is a request to a worker.
+type request struct {
+	ID     uint64        `msgpack:"id"`
+	Op     string        `msgpack:"op"` // call, load or ping
+	Module string        `msgpack:"module"`
+	Func   string        `msgpack:"func"`
+	Args   []interface{} `msgpack:"args"`
+}
+
+// response is a response from a worker.
+type response struct {
+	ID     uint64      `msgpack:"id"`
+	Result interface{} `msgpack:"result"`
+	Error  string      `msgpack:"error"`
+}
+
+// writeFrame writes v encoded as msgpack prefixed by its size.
+func writeFrame(w io.Writer, v interface{}) error {
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// readFrame reads a size prefixed msgpack frame and decodes it to v.
+// Integers are decoded as int64 and floats as float64.
+func readFrame(r io.Reader, v interface{}) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return fmt.Errorf("frame too large (%d > %d)", size, maxFrameSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
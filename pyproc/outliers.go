@@ -0,0 +1,80 @@
+package pyproc
+
+import (
+	"context"
+	"fmt"
+)
+
+// Outliers does outlier detection using Python worker processes. It has the
+// same API as the py-in-mem Outliers.
+type Outliers struct {
+	pool   *Pool
+	module string
+	fn     string
+}
+
+// NewOutliers returns an new Outliers using moduleName.funcName Python function
+func NewOutliers(moduleName, funcName string) (*Outliers, error) {
+	pool, err := NewPool(Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Load(context.Background(), moduleName, funcName); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	o := Outliers{
+		pool:   pool,
+		module: moduleName,
+		fn:     funcName,
+	}
+	return &o, nil
+}
+
+// Detect returns slice of outliers indices
+func (o *Outliers) Detect(data []float64) ([]int, error) {
+	if o.pool == nil {
+		return nil, fmt.Errorf("closed")
+	}
+
+	if len(data) == 0 { // Short path
+		return nil, nil
+	}
+
+	out, err := o.pool.Call(context.Background(), o.module, o.fn, Float64s(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return toInts(out)
+}
+
+// Close stops the underlying worker processes.
+// You can't use the object after closing it
+func (o *Outliers) Close() {
+	if o.pool == nil {
+		return
+	}
+	o.pool.Close()
+	o.pool = nil
+}
+
+// toInts converts a Python list of integers to []int
+func toInts(v interface{}) ([]int, error) {
+	vals, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected list, got %T", v)
+	}
+
+	out := make([]int, len(vals))
+	for i, v := range vals {
+		n, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("%d: expected int, got %T", i, v)
+		}
+		out[i] = int(n)
+	}
+	return out, nil
+}
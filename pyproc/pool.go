@@ -0,0 +1,188 @@
+// Package pyproc calls Python functions in a pool of worker processes.
+//
+// Unlike py-in-mem there's no cgo involved and a crash in Python code only
+// kills a worker process, which is restarted on the next call. Requests and
+// replies are msgpack encoded and sent over the workers stdin/stdout, every
+// message prefixed by its size as a 4 byte big endian integer.
+//
+// The Python side requires the msgpack package.
+package pyproc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when calling a closed Pool.
+var ErrClosed = errors.New("pyproc: pool closed")
+
+// Config is pool configuration.
+type Config struct {
+	Size    int           // Number of workers, default to runtime.NumCPU()
+	Python  string        // Python executable, default to "python3"
+	Dir     string        // Workers working directory, modules there are importable
+	Env     []string      // Extra environment variables (e.g. PYTHONPATH=/opt/lib)
+	Stderr  io.Writer     // Workers stderr (and stdout), default to os.Stderr
+	Timeout time.Duration // Default call timeout, 0 means no timeout
+}
+
+// Pool is a pool of Python worker processes. It's safe to use a Pool from
+// multiple goroutines.
+type Pool struct {
+	cfg  Config
+	idle chan *worker
+	done chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewPool starts cfg.Size Python workers.
+func NewPool(cfg Config) (*Pool, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = runtime.NumCPU()
+	}
+	if cfg.Python == "" {
+		cfg.Python = "python3"
+	}
+	if cfg.Stderr == nil {
+		cfg.Stderr = os.Stderr
+	}
+
+	p := Pool{
+		cfg:  cfg,
+		idle: make(chan *worker, cfg.Size),
+		done: make(chan struct{}),
+	}
+
+	for i := 0; i < cfg.Size; i++ {
+		w := &worker{cfg: cfg}
+		if err := w.start(); err != nil {
+			for len(p.idle) > 0 {
+				(<-p.idle).stop()
+			}
+			return nil, err
+		}
+		p.idle <- w
+	}
+
+	return &p, nil
+}
+
+// Call calls module.fn with args and returns the result. Integers in the
+// result are int64, floats are float64, sequences are []interface{} and dicts
+// are map[string]interface{}.
+// If the context (or the pool Timeout) expires, the worker process is killed
+// and a new one is started on the next call.
+func (p *Pool) Call(ctx context.Context, module, fn string, args ...interface{}) (interface{}, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	req := request{
+		Op:     "call",
+		Module: module,
+		Func:   fn,
+		Args:   args,
+	}
+	return p.do(ctx, req)
+}
+
+// Load imports module and checks it has fn.
+func (p *Pool) Load(ctx context.Context, module, fn string) error {
+	_, err := p.do(ctx, request{Op: "load", Module: module, Func: fn})
+	return err
+}
+
+// Ping checks all workers are healthy, restarting dead ones. It waits for
+// workers that are currently busy.
+func (p *Pool) Ping(ctx context.Context) error {
+	workers := make([]*worker, 0, p.cfg.Size)
+	defer func() {
+		for _, w := range workers {
+			p.release(w)
+		}
+	}()
+
+	for i := 0; i < p.cfg.Size; i++ {
+		w, err := p.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		workers = append(workers, w)
+	}
+
+	for _, w := range workers {
+		if _, err := w.call(ctx, request{Op: "ping"}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close stops all workers, waiting for running calls to finish.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	var err error
+	for i := 0; i < p.cfg.Size; i++ {
+		w := <-p.idle
+		if serr := w.stop(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+func (p *Pool) do(ctx context.Context, req request) (interface{}, error) {
+	if p.cfg.Timeout > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+			defer cancel()
+		}
+	}
+
+	w, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(w)
+
+	return w.call(ctx, req)
+}
+
+func (p *Pool) acquire(ctx context.Context) (*worker, error) {
+	select {
+	case <-p.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	select {
+	case w := <-p.idle:
+		return w, nil
+	case <-p.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) release(w *worker) {
+	p.idle <- w
+}
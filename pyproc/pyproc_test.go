@@ -0,0 +1,123 @@
+package pyproc
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requirePython(t testing.TB) {
+	if err := exec.Command("python3", "-c", "import msgpack").Run(); err != nil {
+		t.Skip("python3 with msgpack not found")
+	}
+}
+
+func newPool(t testing.TB, cfg Config) *Pool {
+	requirePython(t)
+
+	cfg.Dir = "testdata"
+	p, err := NewPool(cfg)
+	require.NoError(t, err, "new pool")
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestFrame(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	req := request{ID: 7, Op: "call", Module: "m", Func: "f", Args: []interface{}{1, "a"}}
+	require.NoError(writeFrame(&buf, req), "write")
+
+	var out request
+	require.NoError(readFrame(&buf, &out), "read")
+	require.Equal(req.ID, out.ID, "id")
+	require.Equal([]interface{}{int64(1), "a"}, out.Args, "args")
+}
+
+func TestCall(t *testing.T) {
+	require := require.New(t)
+	p := newPool(t, Config{Size: 2})
+
+	out, err := p.Call(context.Background(), "funcs", "add", 1, 2)
+	require.NoError(err, "call")
+	require.Equal(int64(3), out, "add")
+
+	_, err = p.Call(context.Background(), "funcs", "fail")
+	require.Error(err, "fail")
+
+	_, err = p.Call(context.Background(), "funcs", "no_such_func")
+	require.Error(err, "not found")
+
+	require.NoError(p.Ping(context.Background()), "ping")
+}
+
+func TestRestart(t *testing.T) {
+	require := require.New(t)
+	p := newPool(t, Config{Size: 1})
+
+	_, err := p.Call(context.Background(), "funcs", "crash")
+	require.Error(err, "crash")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = p.Call(ctx, "funcs", "sleep", 10)
+	require.ErrorIs(err, context.DeadlineExceeded, "timeout")
+
+	out, err := p.Call(context.Background(), "funcs", "add", 1, 2)
+	require.NoError(err, "after restart")
+	require.Equal(int64(3), out, "add")
+}
+
+func TestClosed(t *testing.T) {
+	p := newPool(t, Config{Size: 1})
+	p.Close()
+
+	_, err := p.Call(context.Background(), "funcs", "add", 1, 2)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func genData() ([]float64, []int) {
+	const size = 1000
+	data := make([]float64, size)
+	for i := 0; i < size; i++ {
+		data[i] = rand.Float64()
+	}
+
+	indices := []int{7, 113, 835}
+	for _, i := range indices {
+		data[i] += 97
+	}
+
+	return data, indices
+}
+
+func TestOutliers(t *testing.T) {
+	require := require.New(t)
+	o := &Outliers{newPool(t, Config{Size: 1}), "funcs", "detect"}
+	defer o.Close()
+
+	data, indices := genData()
+	out, err := o.Detect(data)
+	require.NoError(err, "detect")
+	require.Equal(indices, out, "outliers")
+}
+
+func BenchmarkOutliers(b *testing.B) {
+	require := require.New(b)
+	o := &Outliers{newPool(b, Config{Size: 1}), "funcs", "detect"}
+	defer o.Close()
+
+	data, _ := genData()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := o.Detect(data)
+		require.NoError(err)
+	}
+}
@@ -0,0 +1,27 @@
+"""Functions used by pyproc tests"""
+import os
+import time
+
+
+def add(a, b):
+    return a + b
+
+
+def detect(data):
+    """Return indices where values more than 2 standard deviations from mean"""
+    data = list(data)
+    mean = sum(data) / len(data)
+    std = (sum((v - mean) ** 2 for v in data) / len(data)) ** 0.5
+    return [i for i, v in enumerate(data) if abs(v - mean) > 2 * std]
+
+
+def sleep(seconds):
+    time.sleep(seconds)
+
+
+def crash():
+    os._exit(1)
+
+
+def fail():
+    raise ValueError('oops')
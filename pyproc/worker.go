@@ -0,0 +1,140 @@
+package pyproc
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+//go:embed worker.py
+var workerCode string
+
+// worker is a Python worker process. A worker is used by one goroutine at a
+// time.
+type worker struct {
+	cfg    Config
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	nextID uint64
+	dead   bool // Process needs to be restarted
+}
+
+// start starts the worker Python process.
+func (w *worker) start() error {
+	cmd := exec.Command(w.cfg.Python, "-c", workerCode)
+	cmd.Dir = w.cfg.Dir
+	cmd.Env = append(os.Environ(), w.cfg.Env...)
+	cmd.Stderr = w.cfg.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	w.cmd = cmd
+	w.stdin = stdin
+	w.stdout = bufio.NewReader(stdout)
+	w.dead = false
+	return nil
+}
+
+// restart kills the current process (if any) and starts a new one.
+func (w *worker) restart() error {
+	w.kill()
+	return w.start()
+}
+
+// kill kills the worker process.
+func (w *worker) kill() {
+	if w.cmd == nil {
+		return
+	}
+
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+	w.cmd = nil
+	w.dead = true
+}
+
+// stop asks the worker process to exit by closing its stdin.
+func (w *worker) stop() error {
+	if w.cmd == nil {
+		return nil
+	}
+
+	w.stdin.Close()
+	err := w.cmd.Wait()
+	w.cmd = nil
+	w.dead = true
+	return err
+}
+
+// call sends req to the worker process and returns the result. The process is
+// killed if ctx is done before the worker replies.
+func (w *worker) call(ctx context.Context, req request) (interface{}, error) {
+	if w.dead {
+		if err := w.restart(); err != nil {
+			return nil, fmt.Errorf("can't restart worker: %w", err)
+		}
+	}
+
+	w.nextID++
+	req.ID = w.nextID
+
+	done := make(chan struct{})
+	defer close(done)
+	proc := w.cmd.Process
+	go func() {
+		select {
+		case <-ctx.Done():
+			proc.Kill()
+		case <-done:
+		}
+	}()
+
+	var resp response
+	err := writeFrame(w.stdin, req)
+	if err == nil {
+		err = readFrame(w.stdout, &resp)
+	}
+
+	if ctx.Err() != nil {
+		// The process might have been killed
+		w.kill()
+		return nil, ctx.Err()
+	}
+
+	if err != nil {
+		w.kill()
+		if errors.Is(err, io.EOF) {
+			err = errors.New("worker exited")
+		}
+		return nil, err
+	}
+
+	if resp.ID != req.ID {
+		w.kill()
+		return nil, fmt.Errorf("protocol error: reply ID %d != request ID %d", resp.ID, req.ID)
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s.%s: %s", req.Module, req.Func, resp.Error)
+	}
+
+	return resp.Result, nil
+}
@@ -0,0 +1,93 @@
+"""pyproc worker, reads requests from stdin and writes replies to stdout.
+
+Every message is a 4 byte big endian length followed by a msgpack payload.
+"""
+import struct
+import sys
+from importlib import import_module
+
+import msgpack
+
+try:
+    import numpy as np
+except ImportError:
+    np = None
+
+# msgpack extension types, see codec.go
+FLOAT64S = 1
+
+funcs = {}  # (module, func) -> function
+
+
+def ext_hook(code, data):
+    if code == FLOAT64S:
+        if np is not None:
+            return np.frombuffer(data, dtype='<f8')
+        return list(struct.unpack(f'<{len(data)//8}d', data))
+    return msgpack.ExtType(code, data)
+
+
+def default(obj):
+    # numpy arrays & scalars
+    if hasattr(obj, 'tolist'):
+        return obj.tolist()
+    raise TypeError(f'unsupported type: {type(obj).__name__}')
+
+
+def read_frame(fp):
+    header = fp.read(4)
+    if len(header) < 4:
+        return None
+    size, = struct.unpack('>I', header)
+    return fp.read(size)
+
+
+def write_frame(fp, data):
+    fp.write(struct.pack('>I', len(data)))
+    fp.write(data)
+    fp.flush()
+
+
+def load(module, func):
+    key = (module, func)
+    fn = funcs.get(key)
+    if fn is None:
+        fn = getattr(import_module(module), func)
+        funcs[key] = fn
+    return fn
+
+
+def handle(req):
+    op = req['op']
+    if op == 'ping':
+        return None
+
+    fn = load(req['module'], req['func'])
+    if op == 'load':
+        return None
+    return fn(*req['args'])
+
+
+def main():
+    stdin, stdout = sys.stdin.buffer, sys.stdout.buffer
+    # Don't let print in user code corrupt the protocol
+    sys.stdout = sys.stderr
+
+    while True:
+        data = read_frame(stdin)
+        if data is None:  # Pool closed stdin
+            return
+
+        req = msgpack.unpackb(data, ext_hook=ext_hook)
+        reply = {'id': req['id'], 'result': None, 'error': ''}
+        try:
+            reply['result'] = handle(req)
+            out = msgpack.packb(reply, default=default)
+        except Exception as err:
+            reply['result'] = None
+            reply['error'] = f'{type(err).__name__}: {err}'
+            out = msgpack.packb(reply)
+        write_frame(stdout, out)
+
+
+main()
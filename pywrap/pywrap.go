@@ -0,0 +1,491 @@
+// pywrap generates a Python module with typed ctypes wrappers for cgo
+// //export functions in a Go package.
+//
+//	$ pywrap -lib _checksig -o checksig_ffi.py ./pyext
+//
+// Or from a go:generate directive in the package
+//
+//	//go:generate go run github.com/ardanlabs/python-go/pywrap -lib _trades -o _trades_ffi.py .
+//
+// Functions returning *C.char are assumed to follow the repository
+// convention of returning NULL on success or an error message (use -errors=false
+// to return strings instead). Pointer parameters are output parameters,
+// their values are returned from the Python function.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cType is a Go type as seen by ctypes.
+type cType struct {
+	ctype  string // ctypes type (e.g. "ctypes.c_int")
+	pytype string // Python type annotation
+}
+
+var (
+	// Go type expression -> ctypes type
+	types = map[string]cType{
+		"C.char":         {"ctypes.c_char", "int"},
+		"C.schar":        {"ctypes.c_byte", "int"},
+		"C.uchar":        {"ctypes.c_ubyte", "int"},
+		"C.short":        {"ctypes.c_short", "int"},
+		"C.ushort":       {"ctypes.c_ushort", "int"},
+		"C.int":          {"ctypes.c_int", "int"},
+		"C.uint":         {"ctypes.c_uint", "int"},
+		"C.long":         {"ctypes.c_long", "int"},
+		"C.ulong":        {"ctypes.c_ulong", "int"},
+		"C.longlong":     {"ctypes.c_longlong", "int"},
+		"C.ulonglong":    {"ctypes.c_ulonglong", "int"},
+		"C.float":        {"ctypes.c_float", "float"},
+		"C.double":       {"ctypes.c_double", "float"},
+		"C.int8_t":       {"ctypes.c_int8", "int"},
+		"C.int16_t":      {"ctypes.c_int16", "int"},
+		"C.int32_t":      {"ctypes.c_int32", "int"},
+		"C.int64_t":      {"ctypes.c_int64", "int"},
+		"C.uint8_t":      {"ctypes.c_uint8", "int"},
+		"C.uint16_t":     {"ctypes.c_uint16", "int"},
+		"C.uint32_t":     {"ctypes.c_uint32", "int"},
+		"C.uint64_t":     {"ctypes.c_uint64", "int"},
+		"C.size_t":       {"ctypes.c_size_t", "int"},
+		"C.uintptr_t":    {"ctypes.c_size_t", "int"},
+		"bool":           {"ctypes.c_bool", "bool"},
+		"int":            {"ctypes.c_longlong", "int"}, // GoInt is 64 bit
+		"int8":           {"ctypes.c_int8", "int"},
+		"int16":          {"ctypes.c_int16", "int"},
+		"int32":          {"ctypes.c_int32", "int"},
+		"int64":          {"ctypes.c_int64", "int"},
+		"uint":           {"ctypes.c_ulonglong", "int"},
+		"uint8":          {"ctypes.c_uint8", "int"},
+		"uint16":         {"ctypes.c_uint16", "int"},
+		"uint32":         {"ctypes.c_uint32", "int"},
+		"uint64":         {"ctypes.c_uint64", "int"},
+		"uintptr":        {"ctypes.c_size_t", "int"},
+		"float32":        {"ctypes.c_float", "float"},
+		"float64":        {"ctypes.c_double", "float"},
+		"unsafe.Pointer": {"ctypes.c_void_p", "int"},
+	}
+)
+
+// Param is a function parameter.
+type Param struct {
+	Name   string
+	CType  string
+	PyType string
+	Kind   string // in, str, out, outstr
+}
+
+// Func is an exported function.
+type Func struct {
+	Name    string
+	Doc     []string
+	Params  []Param
+	Restype string
+	Return  string // none, value, error, str
+	PyType  string // Python return annotation
+}
+
+// In returns input parameters.
+func (f Func) In() []Param {
+	var out []Param
+	for _, p := range f.Params {
+		if p.Kind == "in" || p.Kind == "str" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Out returns output parameters.
+func (f Func) Out() []Param {
+	var out []Param
+	for _, p := range f.Params {
+		if p.Kind == "out" || p.Kind == "outstr" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// typeString returns the string representation of a type expression.
+func typeString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return typeString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + typeString(e.X)
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// exportName returns the name in a //export comment, "" if there's none.
+func exportName(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	for _, c := range doc.List {
+		if name, ok := strings.CutPrefix(c.Text, "//export "); ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// docLines returns the function documentation without the //export line.
+func docLines(doc *ast.CommentGroup) []string {
+	var lines []string
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		if strings.HasPrefix(line, "export ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	// Trim empty lines
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// param returns the Param for a function parameter.
+func param(name, typ string) (Param, error) {
+	if typ == "*C.char" {
+		return Param{name, "ctypes.c_char_p", "str", "str"}, nil
+	}
+
+	if typ == "**C.char" {
+		return Param{name, "ctypes.POINTER(ctypes.c_void_p)", "str", "outstr"}, nil
+	}
+
+	if elem, ok := strings.CutPrefix(typ, "*"); ok {
+		t, ok := types[elem]
+		if !ok {
+			return Param{}, fmt.Errorf("%s: unsupported type %s", name, typ)
+		}
+		return Param{name, t.ctype, t.pytype, "out"}, nil
+	}
+
+	t, ok := types[typ]
+	if !ok {
+		return Param{}, fmt.Errorf("%s: unsupported type %s", name, typ)
+	}
+	return Param{name, t.ctype, t.pytype, "in"}, nil
+}
+
+// parseFunc returns Func from an exported function declaration.
+func parseFunc(name string, decl *ast.FuncDecl, errors bool) (Func, error) {
+	fn := Func{
+		Name:    name,
+		Doc:     docLines(decl.Doc),
+		Restype: "None",
+		Return:  "none",
+		PyType:  "None",
+	}
+
+	for _, field := range decl.Type.Params.List {
+		typ := typeString(field.Type)
+		for _, ident := range field.Names {
+			p, err := param(ident.Name, typ)
+			if err != nil {
+				return Func{}, err
+			}
+			fn.Params = append(fn.Params, p)
+		}
+	}
+
+	results := decl.Type.Results
+	if results == nil || len(results.List) == 0 {
+		return fn, nil
+	}
+
+	if len(results.List) > 1 || len(results.List[0].Names) > 1 {
+		return Func{}, fmt.Errorf("multiple return values are not supported")
+	}
+
+	typ := typeString(results.List[0].Type)
+	if typ == "*C.char" {
+		fn.Restype = "ctypes.c_void_p"
+		if errors {
+			fn.Return = "error"
+		} else {
+			fn.Return = "str"
+			fn.PyType = "Optional[str]"
+		}
+		return fn, nil
+	}
+
+	t, ok := types[typ]
+	if !ok {
+		return Func{}, fmt.Errorf("unsupported return type %s", typ)
+	}
+	fn.Restype = t.ctype
+	fn.Return = "value"
+	fn.PyType = t.pytype
+	return fn, nil
+}
+
+// parseDir returns exported functions in the Go package at dir.
+func parseDir(dir string, errors bool) ([]Func, error) {
+	fset := token.NewFileSet()
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var funcs []Func
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Recv != nil {
+					continue
+				}
+
+				name := exportName(fd.Doc)
+				if name == "" {
+					continue
+				}
+
+				fn, err := parseFunc(name, fd, errors)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s: %w", fset.Position(fd.Pos()), name, err)
+				}
+				funcs = append(funcs, fn)
+			}
+		}
+	}
+
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].Name < funcs[j].Name })
+	return funcs, nil
+}
+
+// Python keywords that are valid Go identifiers
+var pyKeywords = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true,
+	"class": true, "def": true, "del": true, "elif": true, "except": true,
+	"finally": true, "from": true, "global": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "try": true, "while": true, "with": true, "yield": true,
+	"None": true, "True": true, "False": true,
+}
+
+// pyName returns a valid Python identifier for name.
+func pyName(name string) string {
+	if pyKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+const header = `# Code generated by pywrap; DO NOT EDIT.
+"""ctypes bindings for the %[1]s shared library"""
+
+import ctypes
+from distutils.sysconfig import get_config_var
+from pathlib import Path
+from typing import Optional, Tuple  # noqa: F401
+
+# Location of shared library
+_here = Path(__file__).absolute().parent
+_so_file = _here / ('%[1]s' + get_config_var('EXT_SUFFIX'))
+if not _so_file.exists():
+    _so_file = _here / '%[1]s.so'
+
+_so = ctypes.cdll.LoadLibrary(str(_so_file))
+_free = _so.free
+_free.argtypes = [ctypes.c_void_p]
+
+
+class Error(Exception):
+    """Error returned from Go"""
+
+
+def _go_str(ptr):
+    """Convert a C string allocated by Go to str and free it"""
+    if not ptr:
+        return None
+    try:
+        return ctypes.string_at(ptr).decode('utf-8')
+    finally:
+        _free(ptr)
+`
+
+// generate writes the Python module to w.
+func generate(w *bytes.Buffer, lib string, funcs []Func) {
+	fmt.Fprintf(w, header, lib)
+	for _, fn := range funcs {
+		w.WriteString("\n")
+		writeFunc(w, fn)
+	}
+}
+
+// writeFunc writes the ctypes declaration and Python wrapper of fn to w.
+func writeFunc(w *bytes.Buffer, fn Func) {
+	// ctypes declaration
+	var argtypes []string
+	for _, p := range fn.Params {
+		t := p.CType
+		if p.Kind == "out" {
+			t = fmt.Sprintf("ctypes.POINTER(%s)", t)
+		}
+		argtypes = append(argtypes, t)
+	}
+
+	fmt.Fprintf(w, "\n_%s = _so.%s\n", fn.Name, fn.Name)
+	fmt.Fprintf(w, "_%s.argtypes = [%s]\n", fn.Name, strings.Join(argtypes, ", "))
+	fmt.Fprintf(w, "_%s.restype = %s\n", fn.Name, fn.Restype)
+
+	// Signature
+	var params, retTypes, rets []string
+	for _, p := range fn.In() {
+		params = append(params, fmt.Sprintf("%s: %s", pyName(p.Name), p.PyType))
+	}
+
+	switch fn.Return {
+	case "value":
+		retTypes = append(retTypes, fn.PyType)
+		rets = append(rets, "ret")
+	case "str":
+		retTypes = append(retTypes, fn.PyType)
+		rets = append(rets, "_go_str(ret)")
+	}
+
+	for _, p := range fn.Out() {
+		if p.Kind == "outstr" {
+			retTypes = append(retTypes, "Optional[str]")
+			rets = append(rets, fmt.Sprintf("_go_str(%s.value)", pyName(p.Name)))
+			continue
+		}
+		retTypes = append(retTypes, p.PyType)
+		rets = append(rets, pyName(p.Name)+".value")
+	}
+
+	retType := "None"
+	switch len(retTypes) {
+	case 0:
+	case 1:
+		retType = retTypes[0]
+	default:
+		retType = fmt.Sprintf("Tuple[%s]", strings.Join(retTypes, ", "))
+	}
+
+	fmt.Fprintf(w, "\n\ndef %s(%s) -> %s:\n", fn.Name, strings.Join(params, ", "), retType)
+
+	// Doc string
+	doc := fn.Doc
+	if len(doc) == 0 {
+		doc = []string{fmt.Sprintf("Call %s in the Go shared library", fn.Name)}
+	}
+	if fn.Return == "error" {
+		doc = append(doc, "", "Raises Error on failure.")
+	}
+	if len(doc) == 1 {
+		fmt.Fprintf(w, "    \"\"\"%s\"\"\"\n", doc[0])
+	} else {
+		fmt.Fprintf(w, "    \"\"\"%s\n", doc[0])
+		for _, line := range doc[1:] {
+			if line == "" {
+				w.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+		w.WriteString("    \"\"\"\n")
+	}
+
+	// Body
+	var args []string
+	for _, p := range fn.Params {
+		name := pyName(p.Name)
+		switch p.Kind {
+		case "in":
+			args = append(args, name)
+		case "str":
+			args = append(args, name+".encode('utf-8')")
+		case "out":
+			fmt.Fprintf(w, "    %s = %s()\n", name, p.CType)
+			args = append(args, fmt.Sprintf("ctypes.byref(%s)", name))
+		case "outstr":
+			fmt.Fprintf(w, "    %s = ctypes.c_void_p()\n", name)
+			args = append(args, fmt.Sprintf("ctypes.byref(%s)", name))
+		}
+	}
+
+	call := fmt.Sprintf("_%s(%s)", fn.Name, strings.Join(args, ", "))
+	if fn.Return == "value" && len(fn.Out()) == 0 {
+		fmt.Fprintf(w, "    return %s\n", call)
+		return
+	}
+
+	switch fn.Return {
+	case "none":
+		fmt.Fprintf(w, "    %s\n", call)
+	case "error":
+		fmt.Fprintf(w, "    err = _go_str(%s)\n", call)
+		w.WriteString("    if err is not None:\n        raise Error(err)\n")
+	default:
+		fmt.Fprintf(w, "    ret = %s\n", call)
+	}
+
+	switch len(rets) {
+	case 0:
+	case 1:
+		fmt.Fprintf(w, "    return %s\n", rets[0])
+	default:
+		fmt.Fprintf(w, "    return %s\n", strings.Join(rets, ", "))
+	}
+}
+
+func main() {
+	lib := flag.String("lib", "", "shared library name, without extension (e.g. _checksig)")
+	out := flag.String("o", "", "output file (default to stdout)")
+	errors := flag.Bool("errors", true, "*C.char return values are error messages")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] DIR\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *lib == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	funcs, err := parseDir(flag.Arg(0), *errors)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(funcs) == 0 {
+		log.Fatalf("no //export functions in %s", flag.Arg(0))
+	}
+
+	var buf bytes.Buffer
+	generate(&buf, *lib, funcs)
+
+	if *out == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+
+	if err := os.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	require := require.New(t)
+
+	funcs, err := parseDir("testdata", true)
+	require.NoError(err, "parse")
+	require.Len(funcs, 4, "funcs")
+
+	var buf bytes.Buffer
+	generate(&buf, "_export", funcs)
+
+	golden := "testdata/export_ffi.py"
+	if *update {
+		require.NoError(os.WriteFile(golden, buf.Bytes(), 0644), "update")
+	}
+
+	data, err := os.ReadFile(golden)
+	require.NoError(err, "golden")
+	require.Equal(string(data), buf.String())
+}
+
+func TestUnsupported(t *testing.T) {
+	_, err := param("s", "string")
+	require.Error(t, err, "string")
+
+	_, err = param("p", "**C.int")
+	require.Error(t, err, "**C.int")
+}
@@ -0,0 +1,27 @@
+package main
+
+import "C"
+
+//export verify
+func verify(root *C.char) *C.char {
+	return nil
+}
+
+// add returns the sum of a and b.
+//
+//export add
+func add(a, b C.int) C.int {
+	return a + b
+}
+
+// stats sets count, mean and name of n values.
+//
+//export stats
+func stats(n int, from float64, count *C.long, mean *C.double, name **C.char) {}
+
+//export version
+func version() *C.char {
+	return nil
+}
+
+func main() {}
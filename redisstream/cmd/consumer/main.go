@@ -0,0 +1,52 @@
+// Command consumer writes trades from a Redis Stream to an SQLite database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ardanlabs/python-go/redisstream"
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+func main() {
+	var cfg redisstream.Config
+	addr := flag.String("addr", "localhost:6379", "redis address")
+	dbFile := flag.String("db", "trades.db", "database file")
+	flag.StringVar(&cfg.Stream, "stream", "trades", "stream name")
+	flag.StringVar(&cfg.Group, "group", "trades-db", "consumer group")
+	flag.StringVar(&cfg.Consumer, "consumer", "", "consumer name (default hostname-pid)")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	db, err := trades.NewDB(*dbFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: *addr})
+	defer rdb.Close()
+
+	c, err := redisstream.NewConsumer(ctx, rdb, db, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("consuming %q from %s", cfg.Stream, *addr)
+	err = c.Run(ctx)
+	if cerr := db.Close(); cerr != nil {
+		log.Printf("close: %s", cerr)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package redisstream moves trades from a Redis Stream to a trades database.
+//
+// Producers XADD trades to the stream and return immediately, a Consumer in
+// a consumer group reads them, writes them in batches to a trades.DB and acks
+// them once the batch is committed. Each entry has the fields:
+//
+//	time: RFC 3339 time, UTC is assumed if there's no time zone
+//	symbol: stock symbol
+//	price: trade price
+//	buy: 1 for buy, 0 for sell
+//
+// Malformed entries and entries that were delivered too many times are moved
+// to a dead letter stream.
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+// Config is consumer configuration, zero values are replaced by defaults.
+type Config struct {
+	Stream     string        // Defaults to "trades"
+	Group      string        // Defaults to "trades-db"
+	Consumer   string        // Defaults to hostname-pid
+	DeadLetter string        // Defaults to Stream + ":dead"
+	BatchSize  int64         // Entries per read, defaults to 512
+	Block      time.Duration // Read block time, defaults to 1s
+	MinIdle    time.Duration // Claim other consumers entries idle this long, defaults to 30s
+	MaxRetries int64         // Deliveries before dead lettering, defaults to 5
+}
+
+func (c Config) withDefaults() Config {
+	if c.Stream == "" {
+		c.Stream = "trades"
+	}
+	if c.Group == "" {
+		c.Group = "trades-db"
+	}
+	if c.Consumer == "" {
+		host, _ := os.Hostname()
+		c.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if c.DeadLetter == "" {
+		c.DeadLetter = c.Stream + ":dead"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 512
+	}
+	if c.Block <= 0 {
+		c.Block = time.Second
+	}
+	if c.MinIdle <= 0 {
+		c.MinIdle = 30 * time.Second
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 5
+	}
+	return c
+}
+
+// Consumer reads trades from a stream and writes them to a database.
+type Consumer struct {
+	rdb redis.Cmdable
+	db  *trades.DB
+	cfg Config
+}
+
+// NewConsumer returns a new consumer, creating the stream and the consumer
+// group if they don't exist.
+func NewConsumer(ctx context.Context, rdb redis.Cmdable, db *trades.DB, cfg Config) (*Consumer, error) {
+	cfg = cfg.withDefaults()
+	err := rdb.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("create group %q: %w", cfg.Group, err)
+	}
+
+	c := Consumer{
+		rdb: rdb,
+		db:  db,
+		cfg: cfg,
+	}
+	return &c, nil
+}
+
+// Run consumes trades until ctx is canceled or there's an error writing to
+// the database. Entries that were not acked are retried when the consumer
+// restarts, or claimed by another consumer in the group after MinIdle.
+// Delivery is at least once, a trade might be written more than once.
+func (c *Consumer) Run(ctx context.Context) error {
+	// Entries delivered to us before a restart.
+	if err := c.retry(ctx, c.cfg.Consumer, 0); err != nil {
+		return err
+	}
+
+	var lastClaim time.Time // Claim idle entries on start
+	for {
+		if time.Since(lastClaim) >= c.cfg.MinIdle {
+			if err := c.retry(ctx, "", c.cfg.MinIdle); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+
+		args := redis.XReadGroupArgs{
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			Streams:  []string{c.cfg.Stream, ">"},
+			Count:    c.cfg.BatchSize,
+			Block:    c.cfg.Block,
+		}
+		streams, err := c.rdb.XReadGroup(ctx, &args).Result()
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, redis.Nil): // Block timeout
+			continue
+		case err != nil:
+			return err
+		}
+
+		for _, s := range streams {
+			if err := c.process(ctx, s.Messages); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// retry claims and processes pending entries idle for at least idle. If
+// consumer is not empty, only its pending entries are claimed.
+func (c *Consumer) retry(ctx context.Context, consumer string, idle time.Duration) error {
+	for {
+		args := redis.XPendingExtArgs{
+			Stream:   c.cfg.Stream,
+			Group:    c.cfg.Group,
+			Idle:     idle,
+			Start:    "-",
+			End:      "+",
+			Count:    c.cfg.BatchSize,
+			Consumer: consumer,
+		}
+		pending, err := c.rdb.XPendingExt(ctx, &args).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(pending))
+		retries := make(map[string]int64)
+		for i, p := range pending {
+			ids[i] = p.ID
+			retries[p.ID] = p.RetryCount
+		}
+
+		claim := redis.XClaimArgs{
+			Stream:   c.cfg.Stream,
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			MinIdle:  idle,
+			Messages: ids,
+		}
+		msgs, err := c.rdb.XClaim(ctx, &claim).Result()
+		if err != nil {
+			return err
+		}
+
+		var todo []redis.XMessage
+		for _, msg := range msgs {
+			if n := retries[msg.ID]; n >= c.cfg.MaxRetries {
+				if err := c.deadLetter(ctx, msg, fmt.Errorf("delivered %d times", n)); err != nil {
+					return err
+				}
+				if err := c.ack(ctx, msg.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			todo = append(todo, msg)
+		}
+
+		if err := c.process(ctx, todo); err != nil {
+			return err
+		}
+
+		if int64(len(pending)) < c.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// process writes msgs to the database and acks them once they're committed.
+func (c *Consumer) process(ctx context.Context, msgs []redis.XMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		trade, err := parseTrade(msg.Values)
+		if err != nil {
+			if err := c.deadLetter(ctx, msg, err); err != nil {
+				return err
+			}
+		} else if err := c.db.Add(trade); err != nil {
+			return err
+		}
+		ids = append(ids, msg.ID)
+	}
+
+	if err := c.db.Flush(); err != nil {
+		return fmt.Errorf("unable to flush trades: %w", err)
+	}
+
+	return c.ack(ctx, ids...)
+}
+
+func (c *Consumer) ack(ctx context.Context, ids ...string) error {
+	return c.rdb.XAck(ctx, c.cfg.Stream, c.cfg.Group, ids...).Err()
+}
+
+// deadLetter adds msg with the error and its original ID to the dead letter
+// stream.
+func (c *Consumer) deadLetter(ctx context.Context, msg redis.XMessage, reason error) error {
+	log.Printf("redisstream: dead letter %s: %s", msg.ID, reason)
+	values := make(map[string]interface{}, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["id"] = msg.ID
+	values["error"] = reason.Error()
+
+	args := redis.XAddArgs{
+		Stream: c.cfg.DeadLetter,
+		Values: values,
+	}
+	return c.rdb.XAdd(ctx, &args).Err()
+}
+
+func parseTrade(values map[string]interface{}) (trades.Trade, error) {
+	var (
+		t   trades.Trade
+		err error
+	)
+
+	field := func(name string) string {
+		s, _ := values[name].(string)
+		if s == "" && err == nil {
+			err = fmt.Errorf("missing %q", name)
+		}
+		return s
+	}
+
+	ts, sym, price, buy := field("time"), field("symbol"), field("price"), field("buy")
+	if err != nil {
+		return t, err
+	}
+
+	t.Symbol = sym
+	if t.Time, err = parseTime(ts); err != nil {
+		return t, err
+	}
+	if t.Price, err = strconv.ParseFloat(price, 64); err != nil {
+		return t, fmt.Errorf("bad price: %w", err)
+	}
+	if t.IsBuy, err = strconv.ParseBool(buy); err != nil {
+		return t, fmt.Errorf("bad buy: %w", err)
+	}
+	return t, nil
+}
+
+// parseTime parses RFC 3339 time, and Python's datetime.isoformat output for
+// naive datetime values in UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02T15:04:05.999999999", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad time: %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,185 @@
+package redisstream
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+var (
+	start = time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+)
+
+func setup(t *testing.T) (*miniredis.Miniredis, *redis.Client, *trades.DB) {
+	require := require.New(t)
+
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	db, err := trades.NewDB(filepath.Join(t.TempDir(), "trades.db"))
+	require.NoError(err)
+	t.Cleanup(func() { db.Close() })
+
+	return m, rdb, db
+}
+
+func addTrade(t *testing.T, rdb *redis.Client, values map[string]interface{}) {
+	args := redis.XAddArgs{Stream: "trades", Values: values}
+	require.NoError(t, rdb.XAdd(context.Background(), &args).Err())
+}
+
+func tradeValues(i int) map[string]interface{} {
+	return map[string]interface{}{
+		"time":   start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano),
+		"symbol": "AAPL",
+		"price":  100 + float64(i),
+		"buy":    i % 2,
+	}
+}
+
+// runFor runs c until there are no pending entries or timeout.
+func runFor(t *testing.T, c *Consumer, rdb *redis.Client) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		last, err := rdb.XRevRangeN(ctx, c.cfg.Stream, "+", "-", 1).Result()
+		if err != nil || len(last) == 0 {
+			return false
+		}
+		info, err := rdb.XInfoGroups(ctx, c.cfg.Stream).Result()
+		if err != nil || len(info) != 1 {
+			return false
+		}
+		return info[0].Pending == 0 && info[0].LastDeliveredID == last[0].ID
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+}
+
+func TestConsumer(t *testing.T) {
+	require := require.New(t)
+	_, rdb, db := setup(t)
+
+	ctx := context.Background()
+	c, err := NewConsumer(ctx, rdb, db, Config{BatchSize: 7, Block: 10 * time.Millisecond})
+	require.NoError(err)
+
+	// Group already exists.
+	_, err = NewConsumer(ctx, rdb, db, Config{})
+	require.NoError(err)
+
+	const size = 100
+	for i := 0; i < size; i++ {
+		addTrade(t, rdb, tradeValues(i))
+	}
+	bad := tradeValues(size)
+	bad["price"] = "cheap"
+	addTrade(t, rdb, bad)
+
+	runFor(t, c, rdb)
+
+	out, err := db.Query(start, start.Add(time.Hour))
+	require.NoError(err)
+	require.Len(out, size)
+	require.Equal(start.Add(3*time.Second), out[3].Time.UTC())
+	require.Equal(103.0, out[3].Price)
+	require.True(out[3].IsBuy)
+
+	dead, err := rdb.XRange(ctx, "trades:dead", "-", "+").Result()
+	require.NoError(err)
+	require.Len(dead, 1)
+	require.Equal("cheap", dead[0].Values["price"])
+	require.Contains(dead[0].Values["error"], "bad price")
+}
+
+func TestConsumerRetry(t *testing.T) {
+	require := require.New(t)
+	m, rdb, db := setup(t)
+
+	ctx := context.Background()
+	cfg := Config{
+		Consumer:   "c1",
+		Block:      10 * time.Millisecond,
+		MinIdle:    time.Minute,
+		MaxRetries: 3,
+	}
+	_, err := NewConsumer(ctx, rdb, db, cfg)
+	require.NoError(err)
+
+	addTrade(t, rdb, tradeValues(0))
+	addTrade(t, rdb, tradeValues(1))
+
+	// c1 reads the entries and dies before acking them.
+	args := redis.XReadGroupArgs{
+		Group:    "trades-db",
+		Consumer: "c1",
+		Streams:  []string{"trades", ">"},
+		Block:    -1,
+	}
+	require.NoError(rdb.XReadGroup(ctx, &args).Err())
+
+	// First entry is delivered too many times.
+	pending, err := rdb.XPending(ctx, "trades", "trades-db").Result()
+	require.NoError(err)
+	require.Equal(int64(2), pending.Count)
+	for i := 0; i < 2; i++ {
+		claim := redis.XClaimArgs{
+			Stream:   "trades",
+			Group:    "trades-db",
+			Consumer: "c1",
+			Messages: []string{pending.Lower},
+		}
+		require.NoError(rdb.XClaim(ctx, &claim).Err())
+	}
+
+	// Another consumer claims them once idle.
+	cfg.Consumer = "c2"
+	c2, err := NewConsumer(ctx, rdb, db, cfg)
+	require.NoError(err)
+	m.SetTime(time.Now().Add(2 * time.Minute))
+	runFor(t, c2, rdb)
+
+	out, err := db.Query(start, start.Add(time.Hour))
+	require.NoError(err)
+	require.Len(out, 1)
+	require.Equal(101.0, out[0].Price)
+
+	dead, err := rdb.XRange(ctx, "trades:dead", "-", "+").Result()
+	require.NoError(err)
+	require.Len(dead, 1)
+	require.Equal(pending.Lower, dead[0].Values["id"])
+}
+
+func TestParseTrade(t *testing.T) {
+	require := require.New(t)
+
+	values := map[string]interface{}{
+		"time":   "2020-05-22T14:13:11.123456",
+		"symbol": "MSFT",
+		"price":  "12.5",
+		"buy":    "0",
+	}
+	trade, err := parseTrade(values)
+	require.NoError(err)
+	require.Equal(trades.Trade{
+		Time:   start.Add(123456 * time.Microsecond),
+		Symbol: "MSFT",
+		Price:  12.5,
+	}, trade)
+
+	delete(values, "symbol")
+	_, err = parseTrade(values)
+	require.EqualError(err, `missing "symbol"`)
+}
@@ -0,0 +1,22 @@
+module github.com/ardanlabs/python-go/redisstream
+
+go 1.21
+
+replace github.com/ardanlabs/python-go => ../
+
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/ardanlabs/python-go v0.0.0-00010101000000-000000000000
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/redis/go-redis/v9 v9.5.1
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,32 @@
+"""Add random trades to the "trades" Redis stream"""
+from datetime import datetime, timezone
+from random import choice, random
+
+import redis
+
+symbols = ['AAPL', 'GOOG', 'MSFT', 'NVDA']
+
+
+def add_trade(r, symbol, price, is_buy, stream='trades'):
+    """Add a trade to stream, doesn't wait for it to be stored"""
+    r.xadd(stream, {
+        'time': datetime.now(timezone.utc).isoformat(),
+        'symbol': symbol,
+        'price': price,
+        'buy': int(is_buy),
+    })
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('--host', default='localhost', help='redis host')
+    parser.add_argument('--port', type=int, default=6379, help='redis port')
+    parser.add_argument('--count', type=int, default=1000, help='trades')
+    args = parser.parse_args()
+
+    r = redis.Redis(host=args.host, port=args.port)
+    for _ in range(args.count):
+        add_trade(r, choice(symbols), round(random() * 500, 2), random() > 0.5)
+    print(f'added {args.count} trades')
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package shm
+
+import "os"
+
+// Only Unix systems are supported, Create and Open return ErrNotSupported
+const mmapSupported = false
+
+func mmap(file *os.File, size int) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func munmap(data []byte) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,205 @@
+// Package shm provides a ring buffer of float64 values over a memory mapped
+// file, allowing a Go producer to pass large arrays to a Python consumer
+// (see ring.py) without serialization.
+//
+// File layout (little endian):
+//
+//	offset  size  field
+//	0       4     magic ("PGRB")
+//	4       4     version
+//	8       8     capacity (number of float64 slots)
+//	16      8     write count (total values written)
+//	24      8     read count (total values read)
+//	32      32    reserved
+//	64      8*cap values
+//
+// The ring supports a single writer and a single reader. The writer only
+// updates the write count and the reader only updates the read count.
+package shm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// Magic is the header magic.
+	Magic = "PGRB"
+	// Version is the ring file format version.
+	Version = 1
+	// HeaderSize is the size of the header, values start at this offset.
+	HeaderSize = 64
+)
+
+var (
+	// ErrBadHeader is returned when opening a file that is not a ring.
+	ErrBadHeader = errors.New("shm: bad header")
+	// ErrClosed is returned when using a closed ring.
+	ErrClosed = errors.New("shm: closed")
+	// ErrNotSupported is returned by Create and Open on systems other than
+	// Unix.
+	ErrNotSupported = errors.New("shm: memory mapped rings not supported")
+)
+
+// Ring is a ring buffer of float64 in a memory mapped file.
+type Ring struct {
+	data   []byte
+	values []float64
+	write  *uint64 // points into data
+	read   *uint64 // points into data
+}
+
+// Create creates a new ring file at path with room for capacity values.
+// An existing file will be truncated.
+func Create(path string, capacity int) (*Ring, error) {
+	if !mmapSupported {
+		return nil, ErrNotSupported
+	}
+	if capacity <= 0 {
+		return nil, fmt.Errorf("shm: bad capacity - %d", capacity)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	size := HeaderSize + capacity*8
+	if err := file.Truncate(int64(size)); err != nil {
+		return nil, err
+	}
+
+	data, err := mmap(file, size)
+	if err != nil {
+		return nil, err
+	}
+
+	copy(data, Magic)
+	binary.LittleEndian.PutUint32(data[4:], Version)
+	binary.LittleEndian.PutUint64(data[8:], uint64(capacity))
+	return newRing(data, capacity), nil
+}
+
+// Open opens an existing ring file.
+func Open(path string) (*Ring, error) {
+	if !mmapSupported {
+		return nil, ErrNotSupported
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(info.Size())
+	if size < HeaderSize {
+		return nil, ErrBadHeader
+	}
+
+	data, err := mmap(file, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// capacity comes from the file, check it before multiplying so a corrupt
+	// header can't overflow the size check
+	capacity := int(binary.LittleEndian.Uint64(data[8:]))
+	ok := string(data[:4]) == Magic &&
+		binary.LittleEndian.Uint32(data[4:]) == Version &&
+		capacity > 0 && capacity <= (size-HeaderSize)/8 &&
+		size == HeaderSize+capacity*8
+	if !ok {
+		munmap(data)
+		return nil, ErrBadHeader
+	}
+
+	return newRing(data, capacity), nil
+}
+
+func newRing(data []byte, capacity int) *Ring {
+	// mmap memory is page aligned so the header counters are 8 byte aligned
+	vals := unsafe.Slice((*float64)(unsafe.Pointer(&data[HeaderSize])), capacity)
+	return &Ring{
+		data:   data,
+		values: vals,
+		write:  (*uint64)(unsafe.Pointer(&data[16])),
+		read:   (*uint64)(unsafe.Pointer(&data[24])),
+	}
+}
+
+// Cap returns the ring capacity.
+func (r *Ring) Cap() int {
+	return len(r.values)
+}
+
+// Len returns the number of values ready to be read.
+func (r *Ring) Len() int {
+	if r.data == nil {
+		return 0
+	}
+	return int(atomic.LoadUint64(r.write) - atomic.LoadUint64(r.read))
+}
+
+// Write writes as many values as there is room for and returns the number of
+// values written.
+func (r *Ring) Write(values []float64) (int, error) {
+	if r.data == nil {
+		return 0, ErrClosed
+	}
+
+	w := atomic.LoadUint64(r.write)
+	free := len(r.values) - int(w-atomic.LoadUint64(r.read))
+	n := len(values)
+	if n > free {
+		n = free
+	}
+
+	for i := 0; i < n; i++ {
+		r.values[(w+uint64(i))%uint64(len(r.values))] = values[i]
+	}
+	// Publish values only after they are in memory
+	atomic.StoreUint64(r.write, w+uint64(n))
+	return n, nil
+}
+
+// Read reads up to len(out) values into out and returns the number of values
+// read.
+func (r *Ring) Read(out []float64) (int, error) {
+	if r.data == nil {
+		return 0, ErrClosed
+	}
+
+	rd := atomic.LoadUint64(r.read)
+	n := int(atomic.LoadUint64(r.write) - rd)
+	if n > len(out) {
+		n = len(out)
+	}
+
+	for i := 0; i < n; i++ {
+		out[i] = r.values[(rd+uint64(i))%uint64(len(r.values))]
+	}
+	atomic.StoreUint64(r.read, rd+uint64(n))
+	return n, nil
+}
+
+// Close unmaps the ring file. The file itself is not removed.
+func (r *Ring) Close() error {
+	if r.data == nil {
+		return nil
+	}
+
+	err := munmap(r.data)
+	r.data, r.values, r.write, r.read = nil, nil, nil, nil
+	return err
+}
@@ -0,0 +1,74 @@
+"""Read float64 values written by a Go shm.Ring (see ring.go for layout)"""
+import numpy as np
+
+magic = b'PGRB'
+version = 1
+header_size = 64
+
+
+class Ring:
+    """Ring buffer reader over a memory mapped file.
+
+    Only a single reader is supported. Values returned by read stay reserved
+    until the next read or release call.
+    """
+    def __init__(self, path):
+        hdr = np.memmap(path, dtype='<u8', mode='r+', shape=(header_size // 8,))
+        if hdr[:1].tobytes()[:4] != magic:
+            raise ValueError(f'{path}: bad magic')
+        if int(hdr[:1].view('<u4')[1]) != version:
+            raise ValueError(f'{path}: unsupported version')
+
+        self._hdr = hdr
+        self._pending = 0  # Values returned by read, not released yet
+        self.capacity = int(hdr[1])
+        self._values = np.memmap(
+            path, dtype='<f8', mode='r', offset=header_size,
+            shape=(self.capacity,))
+
+    def __len__(self):
+        """Number of values ready to be read"""
+        return int(self._hdr[2] - self._hdr[3]) - self._pending
+
+    def read(self, size=-1):
+        """Read up to size values (all available if size < 0).
+
+        Returns a read only numpy array: a view of the ring memory, or a copy
+        if the values wrap around the end of the ring. Values returned by the
+        previous read are released for the writer first, don't use a view
+        after the next read or release call (copy it to keep it).
+        """
+        self.release()
+        rd, n = int(self._hdr[3]), len(self)
+        if size >= 0:
+            n = min(n, size)
+
+        start = rd % self.capacity
+        end = start + n
+        if end <= self.capacity:
+            out = self._values[start:end]
+        else:
+            out = np.concatenate(
+                [self._values[start:], self._values[:end - self.capacity]])
+            out.flags.writeable = False
+        self._pending = n
+        return out
+
+    def release(self):
+        """Release the values returned by the last read for the writer"""
+        if self._pending:
+            self._hdr[3] = int(self._hdr[3]) + self._pending
+            self._pending = 0
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('path', help='ring file')
+    args = parser.parse_args()
+
+    ring = Ring(args.path)
+    data = ring.read()
+    print(f'read {len(data)} values, mean={data.mean() if len(data) else 0}')
+    ring.release()
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package shm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotSupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.dat")
+	_, err := Create(path, 8)
+	require.ErrorIs(t, err, ErrNotSupported, "create")
+	_, err = Open(path)
+	require.ErrorIs(t, err, ErrNotSupported, "open")
+}
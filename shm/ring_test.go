@@ -0,0 +1,71 @@
+//go:build unix
+
+package shm
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "ring.dat")
+	w, err := Create(path, 8)
+	require.NoError(err, "create")
+	defer w.Close()
+
+	r, err := Open(path)
+	require.NoError(err, "open")
+	defer r.Close()
+	require.Equal(8, r.Cap(), "cap")
+
+	n, err := w.Write([]float64{1, 2, 3, 4, 5, 6})
+	require.NoError(err, "write")
+	require.Equal(6, n, "write")
+	require.Equal(6, r.Len(), "len")
+
+	out := make([]float64, 4)
+	n, err = r.Read(out)
+	require.NoError(err, "read")
+	require.Equal([]float64{1, 2, 3, 4}, out[:n], "read")
+
+	// Wrap around, only 6 free slots
+	n, err = w.Write([]float64{7, 8, 9, 10, 11, 12, 13})
+	require.NoError(err, "write wrap")
+	require.Equal(6, n, "write wrap")
+
+	out = make([]float64, 16)
+	n, err = r.Read(out)
+	require.NoError(err, "read wrap")
+	require.Equal([]float64{5, 6, 7, 8, 9, 10, 11, 12}, out[:n], "read wrap")
+	require.Equal(0, r.Len(), "empty")
+}
+
+func TestOpenBad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.dat")
+	w, err := Create(path, 8)
+	require.NoError(t, err, "create")
+	copy(w.data, "XXXX")
+	w.Close()
+
+	_, err = Open(path)
+	require.ErrorIs(t, err, ErrBadHeader)
+}
+
+func TestOpenBadCapacity(t *testing.T) {
+	// capacity*8 wraps around to the real data size
+	for _, capacity := range []uint64{0, 9, 8 + 1<<61, 8 + 1<<63} {
+		path := filepath.Join(t.TempDir(), "ring.dat")
+		w, err := Create(path, 8)
+		require.NoError(t, err, "create")
+		binary.LittleEndian.PutUint64(w.data[8:], capacity)
+		w.Close()
+
+		_, err = Open(path)
+		require.ErrorIs(t, err, ErrBadHeader, "capacity %d", capacity)
+	}
+}
@@ -0,0 +1,162 @@
+// Python bindings for the trades database, build with
+//
+//	$ go build -buildmode=c-shared -o _trades.so
+//
+// Databases are passed to Python as integer handles. All functions return
+// NULL on success or an error message which the caller should free.
+package main
+
+// #include <stdint.h>
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/ardanlabs/python-go/sqlite/trades"
+)
+
+// trade is a JSON encoded trades.Trade, time is in nanoseconds since epoch
+type trade struct {
+	Time   int64   `json:"time"`
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+	IsBuy  bool    `json:"buy"`
+}
+
+// Handle registry, we can't pass Go pointers to C
+var (
+	mu         sync.Mutex
+	dbs        = make(map[uint64]*handle)
+	nextHandle uint64
+)
+
+// handle serializes calls to a database, trades.DB is not thread safe and
+// Python threads release the GIL while calling into the library.
+type handle struct {
+	mu sync.Mutex
+	db *trades.DB // nil once closed
+}
+
+// withDB calls fn with the database of handle h while holding its lock.
+func withDB(h C.uint64_t, fn func(db *trades.DB) error) error {
+	mu.Lock()
+	hdl, ok := dbs[uint64(h)]
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("bad handle: %d", h)
+	}
+
+	hdl.mu.Lock()
+	defer hdl.mu.Unlock()
+	if hdl.db == nil {
+		return fmt.Errorf("bad handle: %d", h)
+	}
+	return fn(hdl.db)
+}
+
+// errStr returns err as a C string, NULL if err is nil.
+func errStr(err error) *C.char {
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
+
+// trades_open opens the database at path and sets out to its handle.
+//
+//export trades_open
+func trades_open(path *C.char, out *C.uint64_t) *C.char {
+	db, err := trades.NewDB(C.GoString(path))
+	if err != nil {
+		return errStr(err)
+	}
+
+	mu.Lock()
+	nextHandle++
+	dbs[nextHandle] = &handle{db: db}
+	*out = C.uint64_t(nextHandle)
+	mu.Unlock()
+
+	return nil
+}
+
+// trades_add adds a trade, time is in nanoseconds since epoch.
+//
+//export trades_add
+func trades_add(h C.uint64_t, unixNano C.longlong, symbol *C.char, price C.double, buy C.int) *C.char {
+	t := trades.Trade{
+		Time:   time.Unix(0, int64(unixNano)).UTC(),
+		Symbol: C.GoString(symbol),
+		Price:  float64(price),
+		IsBuy:  buy != 0,
+	}
+	return errStr(withDB(h, func(db *trades.DB) error {
+		return db.Add(t)
+	}))
+}
+
+// trades_flush writes pending trades to the database.
+//
+//export trades_flush
+func trades_flush(h C.uint64_t) *C.char {
+	return errStr(withDB(h, func(db *trades.DB) error {
+		return db.Flush()
+	}))
+}
+
+// trades_query sets out to a JSON array of trades in the time range [start,
+// end] (nanoseconds since epoch). The caller should free out.
+//
+//export trades_query
+func trades_query(h C.uint64_t, start, end C.longlong, out **C.char) *C.char {
+	var ts []trades.Trade
+	err := withDB(h, func(db *trades.DB) error {
+		var err error
+		ts, err = db.Query(time.Unix(0, int64(start)).UTC(), time.Unix(0, int64(end)).UTC())
+		return err
+	})
+	if err != nil {
+		return errStr(err)
+	}
+
+	reply := make([]trade, len(ts))
+	for i, t := range ts {
+		reply[i] = trade{t.Time.UnixNano(), t.Symbol, t.Price, t.IsBuy}
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return errStr(err)
+	}
+
+	*out = C.CString(string(data))
+	return nil
+}
+
+// trades_close closes the database and frees the handle.
+//
+//export trades_close
+func trades_close(h C.uint64_t) *C.char {
+	mu.Lock()
+	hdl, ok := dbs[uint64(h)]
+	delete(dbs, uint64(h))
+	mu.Unlock()
+
+	if !ok {
+		return errStr(fmt.Errorf("bad handle: %d", h))
+	}
+
+	// Wait for calls in flight
+	hdl.mu.Lock()
+	defer hdl.mu.Unlock()
+	db := hdl.db
+	hdl.db = nil
+	return errStr(db.Close())
+}
+
+func main() {}
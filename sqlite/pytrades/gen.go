@@ -0,0 +1,3 @@
+package main
+
+//go:generate go run ../../pywrap -lib _trades -o _trades_ffi.py .
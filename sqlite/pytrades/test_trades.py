@@ -0,0 +1,46 @@
+from datetime import datetime, timedelta, timezone
+from tempfile import TemporaryDirectory
+from threading import Thread
+from unittest import TestCase
+
+import _trades_ffi as ffi
+from trades import DB
+
+
+class TestDB(TestCase):
+    def test_query(self):
+        start = datetime(2020, 5, 22, 14, 13, 11, tzinfo=timezone.utc)
+        with TemporaryDirectory() as tmp, DB(f'{tmp}/trades.db') as db:
+            for i in range(10):
+                db.add(start + timedelta(minutes=i), 'MSFT', 216.39 + i, i % 2)
+            out = db.query(start + timedelta(minutes=2),
+                           start + timedelta(minutes=4))
+        self.assertEqual(3, len(out))
+        self.assertEqual(start + timedelta(minutes=2), out[0]['time'])
+        self.assertEqual('MSFT', out[0]['symbol'])
+
+    def test_bad_handle(self):
+        with TemporaryDirectory() as tmp:
+            db = DB(f'{tmp}/trades.db')
+            db.close()
+            db._handle = 7
+            with self.assertRaises(ffi.Error):
+                db.flush()
+
+    def test_threads(self):
+        start = datetime(2020, 5, 22, 14, 13, 11, tzinfo=timezone.utc)
+        end = start + timedelta(minutes=1)
+
+        def add(db, n):
+            for i in range(n):
+                db.add(start, 'MSFT', 216.39 + i, i % 2)
+                db.query(start, end)
+
+        with TemporaryDirectory() as tmp, DB(f'{tmp}/trades.db') as db:
+            threads = [Thread(target=add, args=(db, 100)) for _ in range(8)]
+            for t in threads:
+                t.start()
+            for t in threads:
+                t.join()
+            out = db.query(start, end)
+        self.assertEqual(800, len(out))
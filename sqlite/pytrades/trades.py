@@ -0,0 +1,47 @@
+"""Trades database, using the Go batched writer (see export.go)"""
+
+import json
+from datetime import datetime, timezone
+
+import _trades_ffi as ffi
+
+
+def _unix_nano(t):
+    """Convert datetime to nanoseconds since epoch"""
+    return int(t.timestamp()) * 1_000_000_000 + t.microsecond * 1000
+
+
+class DB:
+    """Trades database. Trades are buffered and written in batches"""
+    def __init__(self, db_file):
+        self._handle = ffi.trades_open(str(db_file))
+
+    def add(self, time, symbol, price, buy):
+        """Add a trade"""
+        ffi.trades_add(self._handle, _unix_nano(time), symbol, price, buy)
+
+    def flush(self):
+        """Write pending trades to the database"""
+        ffi.trades_flush(self._handle)
+
+    def query(self, start, end):
+        """Return list of trades (dicts) in time range [start, end]"""
+        data = ffi.trades_query(self._handle, _unix_nano(start), _unix_nano(end))
+        trades = json.loads(data)
+        for trade in trades:
+            ts = trade['time'] / 1_000_000_000
+            trade['time'] = datetime.fromtimestamp(ts, timezone.utc)
+        return trades
+
+    def close(self):
+        """Flush pending trades and close the database"""
+        if self._handle is None:
+            return
+        ffi.trades_close(self._handle)
+        self._handle = None
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, exc_type, exc_value, traceback):
+        self.close()
@@ -19,6 +19,13 @@ INSERT INTO trades (
 ) VALUES (
 	?, ?, ?, ?
 )
+`
+
+	selectSQL = `
+SELECT time, symbol, price, buy
+FROM trades
+WHERE time >= ? AND time <= ?
+ORDER BY time
 `
 
 	schemaSQL = `
@@ -99,7 +106,7 @@ func (db *DB) Flush() error {
 	}
 
 	for _, trade := range db.buffer {
-		_, err := tx.Stmt(db.stmt).Exec(trade.Time, trade.Symbol, trade.Price, trade.IsBuy)
+		_, err := tx.Stmt(db.stmt).Exec(trade.Time.UTC(), trade.Symbol, trade.Price, trade.IsBuy)
 		if err != nil {
 			tx.Rollback()
 			return err
@@ -110,6 +117,35 @@ func (db *DB) Flush() error {
 	return tx.Commit()
 }
 
+// Query returns trades in time range [start, end]. Pending trades are flushed
+// to the database first.
+func (db *DB) Query(start, end time.Time) ([]Trade, error) {
+	if err := db.Flush(); err != nil {
+		return nil, err
+	}
+
+	// sqlite compares times as strings, they must be in the same location as
+	// the stored trades.
+	start, end = start.UTC(), end.UTC()
+
+	rows, err := db.sql.Query(selectSQL, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []Trade
+	for rows.Next() {
+		var t Trade
+		if err := rows.Scan(&t.Time, &t.Symbol, &t.Price, &t.IsBuy); err != nil {
+			return nil, err
+		}
+		trades = append(trades, t)
+	}
+
+	return trades, rows.Err()
+}
+
 // Close flushes all trades to the database and prevents any future trading.
 func (db *DB) Close() error {
 	defer func() {
@@ -41,6 +41,60 @@ func TestAdd(t *testing.T) {
 	// TODO: Check database
 }
 
+func TestQuery(t *testing.T) {
+	require := require.New(t)
+
+	db, err := trades.NewDB(tempFile(require))
+	require.NoError(err)
+	defer db.Close()
+
+	start := time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		trade := trades.Trade{
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			Symbol: "MSFT",
+			Price:  216.39 + float64(i),
+			IsBuy:  i%2 == 0,
+		}
+		require.NoError(db.Add(trade))
+	}
+
+	out, err := db.Query(start.Add(2*time.Minute), start.Add(4*time.Minute))
+	require.NoError(err)
+	require.Len(out, 3)
+	require.Equal(218.39, out[0].Price)
+	require.True(out[0].Time.Equal(start.Add(2 * time.Minute)))
+}
+
+func TestQueryLocation(t *testing.T) {
+	require := require.New(t)
+
+	db, err := trades.NewDB(tempFile(require))
+	require.NoError(err)
+	defer db.Close()
+
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	start := time.Date(2020, 5, 22, 14, 13, 11, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		trade := trades.Trade{
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			Symbol: "MSFT",
+			Price:  216.39 + float64(i),
+			IsBuy:  i%2 == 0,
+		}
+		if i%2 == 1 {
+			trade.Time = trade.Time.In(loc)
+		}
+		require.NoError(db.Add(trade))
+	}
+
+	out, err := db.Query(start.Add(2*time.Minute).In(loc), start.Add(4*time.Minute).In(loc))
+	require.NoError(err)
+	require.Len(out, 3)
+	require.True(out[0].Time.Equal(start.Add(2 * time.Minute)))
+	require.True(out[2].Time.Equal(start.Add(4 * time.Minute)))
+}
+
 func BenchmarkAdd(b *testing.B) {
 	require := require.New(b)
 	dbFile := tempFile(require)
@@ -0,0 +1,123 @@
+// checksig checks files digital signatures, it's meant to be compiled to
+// WebAssembly (wasip1) and run from Python via wasmtime (see checksig.py).
+//
+// The host interface is WASI: the directory to check is passed as the first
+// argument (and must be pre-opened by the host) and the result is written to
+// stdout as JSON:
+//
+//	{"ok": false, "error": "\"/data/httpd-08.log\" - mismatch"}
+//
+// Build with (requires Go 1.21+)
+//
+//	$ GOOS=wasip1 GOARCH=wasm go build -o checksig.wasm
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Result is the JSON result written to stdout.
+type Result struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// CheckSignatures calculates sha1 signatures for files in rootDir and compare
+// them with signatures found at "sha1sum.txt" in the same directory. It'll
+// return an error if one of the signatures don't match.
+//
+// This is the same as CheckSignatures in pyext, only sequential since wasm
+// runs on a single thread.
+func CheckSignatures(rootDir string) error {
+	file, err := os.Open(path.Join(rootDir, "sha1sum.txt"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	sigs, err := parseSigFile(file)
+	if err != nil {
+		return err
+	}
+
+	for name, expected := range sigs {
+		fileName := path.Join(rootDir, name)
+		sig, err := fileSig(fileName)
+		if err != nil {
+			return err
+		}
+		if sig != expected {
+			return fmt.Errorf("%q - mismatch", fileName)
+		}
+	}
+
+	return nil
+}
+
+// fileSig returns the fileName sha1 digital signature of the specified file.
+func fileSig(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// parseSigFile parses the signature file and returns a map of path->signature.
+func parseSigFile(r io.Reader) (map[string]string, error) {
+	sigs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lnum := 0
+
+	for scanner.Scan() {
+		lnum++
+
+		// Line example: 6c6427da7893932731901035edbb9214 nasa-00.log
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%d: bad line: %q", lnum, scanner.Text())
+		}
+		sigs[fields[1]] = fields[0]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sigs, nil
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: checksig DIR")
+		os.Exit(2)
+	}
+
+	res := Result{OK: true}
+	if err := CheckSignatures(os.Args[1]); err != nil {
+		res = Result{OK: false, Error: err.Error()}
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(res); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if !res.OK {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,61 @@
+"""Check files digital signatures using Go code compiled to WebAssembly"""
+import json
+from pathlib import Path
+from tempfile import TemporaryDirectory
+
+from wasmtime import Engine, ExitTrap, Linker, Module, Store, WasiConfig
+
+here = Path(__file__).absolute().parent
+wasm_file = here / 'checksig.wasm'
+
+# Compiling the module is expensive, do it once
+engine = Engine()
+module = Module.from_file(engine, str(wasm_file))
+
+# Mount point of root_dir inside the wasm sandbox
+mount_dir = '/data'
+
+
+def check_signatures(root_dir):
+    """Check digital signature of all files in root_dir.
+    We assume there's a sha1sum.txt file under root_dir
+    """
+    with TemporaryDirectory() as tmp:
+        out_file = Path(tmp) / 'out.json'
+
+        cfg = WasiConfig()
+        cfg.argv = ['checksig', mount_dir]
+        cfg.preopen_dir(str(root_dir), mount_dir)
+        cfg.stdout_file = str(out_file)
+        cfg.inherit_stderr()
+
+        store = Store(engine)
+        store.set_wasi(cfg)
+        linker = Linker(engine)
+        linker.define_wasi()
+        instance = linker.instantiate(store, module)
+        start = instance.exports(store)['_start']
+        try:
+            start(store)
+        except ExitTrap as err:
+            if err.code not in (0, 1):
+                raise
+
+        result = json.loads(out_file.read_text())
+
+    if not result['ok']:
+        raise ValueError(result['error'])
+
+
+if __name__ == '__main__':
+    from argparse import ArgumentParser
+
+    parser = ArgumentParser(description=__doc__)
+    parser.add_argument('dir', help='directory to check')
+    args = parser.parse_args()
+
+    try:
+        check_signatures(args.dir)
+        print('OK')
+    except ValueError as err:
+        raise SystemExit(f'error: {err}')
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogs(t *testing.T) {
+	logsDir := "../pyext/testdata/logs"
+	if err := CheckSignatures(logsDir); err == nil {
+		t.Fatalf("no error no %q", logsDir)
+	}
+}
+
+func TestOK(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("hello\n")
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// $ echo hello | sha1sum
+	sigs := "f572d396fae9206628714fb2ce00f72e94f2258f  hello.txt\n"
+	if err := os.WriteFile(filepath.Join(dir, "sha1sum.txt"), []byte(sigs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckSignatures(dir); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+// ZeroMQ REQ client for the Python outliers server (server.py).
+//
+// Messages are msgpack encoded, a request is {"values": [float, ...]} and a
+// reply is {"indices": [int, ...], "error": str}.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/go-zeromq/zmq4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Request is an outliers detection request.
+type Request struct {
+	Values []float64 `msgpack:"values"`
+}
+
+// Reply is an outliers detection reply.
+type Reply struct {
+	Indices []int  `msgpack:"indices"`
+	Error   string `msgpack:"error"`
+}
+
+// Client is an outliers detection client. A Client can't be used from
+// multiple goroutines, REQ sockets must alternate between send and receive.
+type Client struct {
+	sock zmq4.Socket
+}
+
+// Dial returns a client connected to addr (e.g. "tcp://localhost:9998").
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	sock := zmq4.NewReq(ctx)
+	if err := sock.Dial(addr); err != nil {
+		sock.Close()
+		return nil, err
+	}
+
+	return &Client{sock}, nil
+}
+
+// Detect returns indices of outliers in values.
+func (c *Client) Detect(values []float64) ([]int, error) {
+	data, err := msgpack.Marshal(Request{values})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.sock.Send(zmq4.NewMsg(data)); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.sock.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	var reply Reply
+	if err := msgpack.Unmarshal(msg.Bytes(), &reply); err != nil {
+		return nil, fmt.Errorf("bad reply: %w", err)
+	}
+
+	if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Indices, nil
+}
+
+// Close closes the underlying socket.
+func (c *Client) Close() error {
+	return c.sock.Close()
+}
+
+func main() {
+	addr := flag.String("addr", "tcp://localhost:9998", "server address")
+	flag.Parse()
+
+	client, err := Dial(context.Background(), *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	indices, err := client.Detect(dummyData())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("outliers at: %v", indices)
+}
+
+func dummyData() []float64 {
+	const size = 1000
+	out := make([]float64, size)
+	for i := 0; i < size; i++ {
+		// normally we're below 40% CPU utilization
+		out[i] = rand.Float64() * 40
+	}
+	// Create some outliers
+	out[7] = 97.3
+	out[113] = 92.1
+	out[835] = 93.2
+	return out
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-zeromq/zmq4"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// serve answers requests on addr with reply until ctx is done.
+func serve(ctx context.Context, t *testing.T, addr string, reply Reply) {
+	sock := zmq4.NewRep(ctx)
+	require.NoError(t, sock.Listen(addr), "listen")
+	t.Cleanup(func() { sock.Close() })
+
+	go func() {
+		for {
+			msg, err := sock.Recv()
+			if err != nil {
+				return
+			}
+
+			var req Request
+			if err := msgpack.Unmarshal(msg.Bytes(), &req); err != nil {
+				reply = Reply{Error: err.Error()}
+			}
+
+			data, _ := msgpack.Marshal(reply)
+			if err := sock.Send(zmq4.NewMsg(data)); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestDetect(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := "tcp://127.0.0.1:19998"
+	serve(ctx, t, addr, Reply{Indices: []int{7, 113, 835}})
+
+	client, err := Dial(ctx, addr)
+	require.NoError(err, "dial")
+	defer client.Close()
+
+	indices, err := client.Detect(dummyData())
+	require.NoError(err, "detect")
+	require.Equal([]int{7, 113, 835}, indices, "indices")
+}
+
+func TestDetectError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := "tcp://127.0.0.1:19997"
+	serve(ctx, t, addr, Reply{Error: "oops"})
+
+	client, err := Dial(ctx, addr)
+	require.NoError(t, err, "dial")
+	defer client.Close()
+
+	_, err = client.Detect(dummyData())
+	require.EqualError(t, err, "oops")
+}
+
+func BenchmarkClient(b *testing.B) {
+	require := require.New(b)
+
+	client, err := Dial(context.Background(), "tcp://localhost:9998")
+	require.NoError(err, "connect")
+	defer client.Close()
+
+	data := dummyData()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := client.Detect(data)
+		require.NoError(err, "detect")
+	}
+}
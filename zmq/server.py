@@ -0,0 +1,40 @@
+"""ZeroMQ REP outliers server, see client.go for the protocol"""
+import logging
+
+import msgpack
+import numpy as np
+import zmq
+
+
+def find_outliers(data: np.ndarray):
+    """Return indices where values more than 2 standard deviations from mean"""
+    out = np.where(np.abs(data - data.mean()) > 2 * data.std())
+    # np.where returns a tuple for each dimension, we want the 1st element
+    return out[0]
+
+
+def handle(msg):
+    """Handle a single request, return reply"""
+    try:
+        req = msgpack.unpackb(msg)
+        data = np.asarray(req['values'], dtype='float64')
+        indices = find_outliers(data)
+        logging.info('found %d outliers in %d values', len(indices), len(data))
+        return {'indices': indices.tolist(), 'error': ''}
+    except Exception as err:
+        logging.exception('bad request')
+        return {'indices': [], 'error': str(err)}
+
+
+if __name__ == '__main__':
+    logging.basicConfig(
+        level=logging.INFO,
+        format='%(asctime)s - %(levelname)s - %(message)s',
+    )
+    port = 9998
+    sock = zmq.Context().socket(zmq.REP)
+    sock.bind(f'tcp://*:{port}')
+    logging.info('server ready on port %r', port)
+    while True:
+        reply = handle(sock.recv())
+        sock.send(msgpack.packb(reply))